@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeDownload writes a file of size bytes at dir/name, last modified at modTime.
+func writeDownload(t *testing.T, dir, name string, size int, modTime time.Time) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Repeat("x", size)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// remaining returns which of names still exist under dir.
+func remaining(dir string, names ...string) []string {
+	var left []string
+	for _, name := range names {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err == nil {
+			left = append(left, name)
+		}
+	}
+	return left
+}
+
+func TestCleanupDownloadsPrunesByAge(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	old, recent := now.Add(-48*time.Hour), now.Add(-time.Hour)
+	// Extracted archives have their directory next to them.
+	for _, extracted := range []string{"movies/old", "movies/new"} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.FromSlash(extracted)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeDownload(t, dir, "movies/old.zip", 10, old)
+	writeDownload(t, dir, "movies/new.zip", 10, recent)
+	writeDownload(t, dir, "movies/only-copy.zip", 10, old)
+	writeDownload(t, dir, "stale.mp4.part", 10, old)
+	writeDownload(t, dir, "fresh.mp4.part", 10, recent)
+	writeDownload(t, dir, "update_v3.zip", 10, old)
+	writeDownload(t, dir, "notes.txt", 10, old)
+
+	if err := cleanupDownloads(dir, 24*time.Hour, 0, now); err != nil {
+		t.Fatalf("cleanupDownloads: %v", err)
+	}
+	all := []string{"movies/old.zip", "movies/new.zip", "movies/only-copy.zip", "stale.mp4.part",
+		"fresh.mp4.part", "update_v3.zip", "notes.txt", "movies/old", "movies/new"}
+	want := []string{"movies/new.zip", "movies/only-copy.zip", "fresh.mp4.part", "update_v3.zip",
+		"notes.txt", "movies/old", "movies/new"}
+	if got := remaining(dir, all...); !reflect.DeepEqual(got, want) {
+		t.Fatalf("left %v, want %v", got, want)
+	}
+}
+
+func TestCleanupDownloadsCapsTotalSize(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	if err := os.MkdirAll(filepath.Join(dir, "a"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeDownload(t, dir, "a.zip", 40, now.Add(-3*time.Hour))
+	writeDownload(t, dir, "b.part", 30, now.Add(-2*time.Hour))
+	writeDownload(t, dir, "c.part", 20, now.Add(-time.Hour))
+	writeDownload(t, dir, "d.part", 10, now)
+
+	// 100 bytes against a cap of 50: the oldest go until 30 are left.
+	if err := cleanupDownloads(dir, 0, 50, now); err != nil {
+		t.Fatalf("cleanupDownloads: %v", err)
+	}
+	want := []string{"c.part", "d.part"}
+	if got := remaining(dir, "a.zip", "b.part", "c.part", "d.part"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("left %v, want %v", got, want)
+	}
+}
+
+func TestCleanupDownloadsWithoutLimitsKeepsAll(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeDownload(t, dir, "a.part", 10, now.Add(-1000*time.Hour))
+	if err := cleanupDownloads(dir, 0, 0, now); err != nil {
+		t.Fatalf("cleanupDownloads: %v", err)
+	}
+	if got := remaining(dir, "a.part"); len(got) != 1 {
+		t.Fatal("a download was removed with both limits disabled")
+	}
+	if err := cleanupDownloads(filepath.Join(dir, "missing"), time.Hour, 1, now); err != nil {
+		t.Fatalf("cleanupDownloads of a missing directory: %v", err)
+	}
+}
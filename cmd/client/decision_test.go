@@ -0,0 +1,54 @@
+package main
+
+import (
+	"embedup-go/internal/apiclient"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRunUpdateCycleLogsDecision(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		info apiclient.UpdateInfo
+		want string
+	}{
+		{
+			name: "up to date",
+			info: apiclient.UpdateInfo{VersionCode: 3, FileURL: testUpdateURL},
+			want: `update_decision current_version=3 available_version=3 decision=skip reason="already up to date"`,
+		},
+		{
+			name: "newer version",
+			info: apiclient.UpdateInfo{VersionCode: 4, FileURL: testUpdateURL},
+			want: `update_decision current_version=3 available_version=4 decision=update reason="newer version available"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// A failed download ends the cycle once the decision is made.
+			server := &updateServer{info: tc.info, size: 100,
+				stream: func() io.ReadCloser { return io.NopCloser(errReader{}) }}
+			cfg, client := newUpdateTest(t, server)
+			logs := captureLog(t)
+
+			runUpdateCycle(cfg, client, 3)
+			if !strings.Contains(logs.String(), tc.want+"\n") {
+				t.Fatalf("decision record %s is missing:\n%s", tc.want, logs.String())
+			}
+		})
+	}
+}
+
+func TestRunUpdateCycleLogsFailedCheck(t *testing.T) {
+	cfg, client := newUpdateTest(t, &updateServer{})
+	cfg.UpdateCheckAPIURL = "http://updates.test/missing"
+	logs := captureLog(t)
+
+	if _, err := runUpdateCycle(cfg, client, 3); err == nil {
+		t.Fatal("expected the failed update check to fail the cycle")
+	}
+	want := `update_decision current_version=3 available_version=0 decision=skip reason="update check failed"`
+	if !strings.Contains(logs.String(), want+"\n") {
+		t.Fatalf("decision record %s is missing:\n%s", want, logs.String())
+	}
+}
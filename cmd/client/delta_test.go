@@ -0,0 +1,76 @@
+package main
+
+import (
+	"crypto/sha256"
+	"embedup-go/internal/apiclient"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+const testDeltaURL = "http://updates.test/update_v1_v2.patch"
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// newDeltaTest advertises version 2 as a delta from version 1, whose archive is kept,
+// serving patch at testDeltaURL and the full archive at testUpdateURL.
+func newDeltaTest(t *testing.T, patch []byte) (*updateServer, func() error) {
+	t.Helper()
+	full := readTestdata(t, "update_v2.zip")
+	sum := sha256.Sum256(full)
+	server := &updateServer{
+		info: apiclient.UpdateInfo{VersionCode: 2, FileURL: testUpdateURL, FileSHA256: hex.EncodeToString(sum[:]),
+			DeltaFromVersion: 1, DeltaURL: testDeltaURL},
+		files: map[string][]byte{testDeltaURL: patch, testUpdateURL: full},
+	}
+	cfg, client := newUpdateTest(t, server)
+	cfg.UpdateScriptName = "update.sh"
+	cfg.ScriptRunAsUID, cfg.ScriptRunAsGID = -1, -1
+	cfg.CurrentVersionFile = filepath.Join(t.TempDir(), "version")
+	if err := os.WriteFile(cfg.CurrentVersionFile, []byte("2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(updateArchivePath(cfg, 1), readTestdata(t, "update_v1.zip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return server, func() error {
+		if _, err := runUpdateCycle(cfg, client, 1); err != nil {
+			return err
+		}
+		notes, err := os.ReadFile(filepath.Join(cfg.DownloadBaseDir, updateDirName(2), "app", "notes.txt"))
+		if err != nil || string(notes) != "added in v2\n" {
+			t.Fatalf("extracted app/notes.txt = %q, %v", notes, err)
+		}
+		return nil
+	}
+}
+
+func TestRunUpdateCycleAppliesDelta(t *testing.T) {
+	server, run := newDeltaTest(t, readTestdata(t, "update_v1_v2.patch"))
+	if err := run(); err != nil {
+		t.Fatalf("runUpdateCycle: %v", err)
+	}
+	if want := []string{testDeltaURL}; !slices.Equal(server.fetched, want) {
+		t.Fatalf("fetched %v, want only the delta %v", server.fetched, want)
+	}
+}
+
+func TestRunUpdateCycleFallsBackToFullDownload(t *testing.T) {
+	patch := readTestdata(t, "update_v1_v2.patch")
+	server, run := newDeltaTest(t, patch[:len(patch)/2])
+	if err := run(); err != nil {
+		t.Fatalf("runUpdateCycle: %v", err)
+	}
+	if want := []string{testDeltaURL, testUpdateURL}; !slices.Equal(server.fetched, want) {
+		t.Fatalf("fetched %v, want the delta and then the full archive %v", server.fetched, want)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"embedup-go/configs/config"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// DUMP_CONFIG_COMMAND is the subcommand that prints the effective config, with secrets
+// masked, and exits without starting the service.
+const DUMP_CONFIG_COMMAND = "dump-config"
+
+// runDumpConfig implements the dump-config subcommand and returns the exit code.
+func runDumpConfig(args []string, defaultConfigPath string) int {
+	fs := flag.NewFlagSet(DUMP_CONFIG_COMMAND, flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file to dump")
+	if err := fs.Parse(args); err != nil {
+		return ExitConfigError
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s is invalid: %v\n", *configPath, err)
+		return exitCodeFor(err)
+	}
+	fmt.Print(config.Dump(cfg))
+	return ExitOK
+}
+
+// isDumpConfigCommand reports whether the process was started with the dump-config
+// subcommand.
+func isDumpConfigCommand() bool {
+	return len(os.Args) > 1 && os.Args[1] == DUMP_CONFIG_COMMAND
+}
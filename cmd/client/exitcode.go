@@ -0,0 +1,74 @@
+package main
+
+import (
+	"embedup-go/internal/cstmerr"
+	"errors"
+	"log"
+	"os"
+)
+
+// Process exit codes, so schedulers such as cron or systemd can tell failure classes apart.
+const (
+	ExitOK           = 0
+	ExitFailure      = 1 // Any error not covered below
+	ExitConfigError  = 2
+	ExitDBError      = 3
+	ExitAPIError     = 4
+	ExitUpdateFailed = 5
+)
+
+// exitCodeFor maps err to the exit code of its failure class. The first matching class
+// in the chain wins, checked from most to least specific: a download that failed on a
+// database error exits with ExitDBError.
+func exitCodeFor(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var (
+		configErr        *cstmerr.ConfigError
+		versionReadErr   *cstmerr.VersionReadError
+		versionFormatErr *cstmerr.VersionFormatError
+		tokenReadErr     *cstmerr.TokenReadError
+
+		dbErr            *cstmerr.DBError
+		dbConnectionErr  *cstmerr.DBConnectionError
+		dbQueryErr       *cstmerr.DBQueryError
+		dbNotFoundErr    *cstmerr.DBNotFoundError
+		dbTransactionErr *cstmerr.DBTransactionError
+
+		apiClientErr     *cstmerr.APIClientError
+		apiRequestErr    *cstmerr.APIRequestFailedError
+		headErr          *cstmerr.HeadError
+		contentNotFound  *cstmerr.ContentNotFoundError
+		schemaVersionErr *cstmerr.SchemaVersionError
+
+		downloadErr        *cstmerr.DownloadError
+		checksumErr        *cstmerr.ChecksumError
+		archiveErr         *cstmerr.ArchiveError
+		patchErr           *cstmerr.PatchError
+		scriptErr          *cstmerr.ScriptError
+		versionMismatchErr *cstmerr.VersionMismatchError
+	)
+	switch {
+	case errors.As(err, &configErr), errors.As(err, &versionReadErr),
+		errors.As(err, &versionFormatErr), errors.As(err, &tokenReadErr):
+		return ExitConfigError
+	case errors.As(err, &dbErr), errors.As(err, &dbConnectionErr), errors.As(err, &dbQueryErr),
+		errors.As(err, &dbNotFoundErr), errors.As(err, &dbTransactionErr):
+		return ExitDBError
+	case errors.As(err, &apiClientErr), errors.As(err, &apiRequestErr), errors.As(err, &headErr),
+		errors.As(err, &contentNotFound), errors.As(err, &schemaVersionErr):
+		return ExitAPIError
+	case errors.As(err, &downloadErr), errors.As(err, &checksumErr), errors.As(err, &archiveErr),
+		errors.As(err, &patchErr), errors.As(err, &scriptErr), errors.As(err, &versionMismatchErr):
+		return ExitUpdateFailed
+	}
+	return ExitFailure
+}
+
+// exitWithError logs err and exits with its exit code. Deferred calls do not run.
+func exitWithError(err error) {
+	code := exitCodeFor(err)
+	log.Printf("Fatal: %v (exit code %d)", err, code)
+	os.Exit(code)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"embedup-go/internal/cstmerr"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestExitCodeFor(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, ExitOK},
+		{"plain error", errors.New("boom"), ExitFailure},
+		{"config", cstmerr.NewConfigError("bad key", nil), ExitConfigError},
+		{"version file", cstmerr.NewVersionReadError("unreadable", nil), ExitConfigError},
+		{"token file", cstmerr.NewTokenReadError("unreadable", nil), ExitConfigError},
+		{"db", cstmerr.NewDBError("not connected", nil), ExitDBError},
+		{"db connection", cstmerr.NewDBConnectionError("refused", nil), ExitDBError},
+		{"db query", cstmerr.NewDBQueryError("syntax", nil), ExitDBError},
+		{"db transaction", cstmerr.NewDBTransactionError("rolled back", nil), ExitDBError},
+		{"api request", cstmerr.NewAPIRequestFailedError(503, "unavailable"), ExitAPIError},
+		{"api client", cstmerr.NewAPIClientError(errors.New("dial")), ExitAPIError},
+		{"head", cstmerr.NewHeadStatusError(404), ExitAPIError},
+		{"download", cstmerr.NewDownloadError("short read"), ExitUpdateFailed},
+		{"checksum", cstmerr.NewChecksumError("update.zip", "aa", "bb"), ExitUpdateFailed},
+		{"patch", cstmerr.NewPatchError("corrupt", nil), ExitUpdateFailed},
+		{"script", cstmerr.NewScriptError("exit 1", nil), ExitUpdateFailed},
+		{"version mismatch", cstmerr.NewVersionMismatchError(2, 1), ExitUpdateFailed},
+		{"wrapped config", fmt.Errorf("startup: %w", cstmerr.NewConfigError("bad key", nil)), ExitConfigError},
+		{"wrapped api request", fmt.Errorf("update check failed: %w", cstmerr.NewAPIRequestFailedError(500, "")), ExitAPIError},
+		{"wrapped update failure", fmt.Errorf("download failed: %w", cstmerr.NewDownloadError("short read")), ExitUpdateFailed},
+		{"db cause of script failure", cstmerr.NewScriptError("failed", cstmerr.NewDBQueryError("syntax", nil)), ExitDBError},
+		{"db cause wrapped twice", fmt.Errorf("cycle: %w", cstmerr.NewProcessError("save",
+			cstmerr.NewDBConnectionError("refused", nil))), ExitDBError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCodeFor(tt.err); got != tt.want {
+				t.Fatalf("exitCodeFor(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
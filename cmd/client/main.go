@@ -1,21 +1,27 @@
 package main
 
 import (
-	"archive/zip"
 	"context"
 	"embedup-go/configs/config"
 	apiClient "embedup-go/internal/apiclient"
+	"embedup-go/internal/backoff"
 	"embedup-go/internal/controller"
 	"embedup-go/internal/cstmerr"
 	"embedup-go/internal/dbclient"
 	"embedup-go/internal/shared"
+	"errors"
 	"fmt"
-	"io"
 	"log"
+	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
+	"os/user"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
@@ -24,68 +30,37 @@ func initLogging() {
 	log.Println("Logging initialized")
 }
 
-func unzipUpdate(zipFilePath string, outputDir string) error {
-	log.Printf("Unzipping update from %s to %s", zipFilePath, outputDir)
-
-	r, err := zip.OpenReader(zipFilePath)
-	if err != nil {
-		return cstmerr.NewArchiveError(fmt.Sprintf("Failed to open zip file %s", zipFilePath), err)
+// unzipUpdate extracts the update archive, resuming an interrupted extraction with resume.
+func unzipUpdate(zipFilePath string, outputDir string, resume bool) error {
+	if resume {
+		return shared.UnzipFileResume(zipFilePath, outputDir)
 	}
-	defer r.Close()
-
-	log.Printf("Archive contains %d files", len(r.File))
-
-	for _, f := range r.File {
-		outPath := filepath.Join(outputDir, f.Name)
-
-		if !strings.HasPrefix(outPath, filepath.Clean(outputDir)+string(os.PathSeparator)) {
-			return cstmerr.NewArchiveError(fmt.Sprintf("Illegal file path in archive: %s", f.Name), nil)
-		}
-
-		if f.FileInfo().IsDir() {
-			if err := os.MkdirAll(outPath, os.ModePerm); err != nil { //
-				return cstmerr.NewFileSystemError(fmt.Sprintf("Failed to create directory %s: %v", outPath, err))
-			}
-			continue
-		}
-
-		if err := os.MkdirAll(filepath.Dir(outPath), os.ModePerm); err != nil { //
-			return cstmerr.NewFileSystemError(fmt.Sprintf("Failed to create parent directory for %s: %v", outPath, err))
-		}
-
-		outFile, err := os.OpenFile(outPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-		if err != nil {
-			return cstmerr.NewFileIOError(fmt.Sprintf("Failed to create output file %s", outPath), err)
-		}
+	return shared.UnzipFile(zipFilePath, outputDir)
+}
 
-		rc, err := f.Open()
-		if err != nil {
-			outFile.Close()
-			return cstmerr.NewArchiveError(fmt.Sprintf("Failed to open file in archive %s", f.Name), err)
+// prepareExtractionDir checks there is room to extract the archive into outputDir, then
+// removes a previous extraction there unless it is resumed. The previous extraction
+// counts as free space, so a failed check leaves it in place, and failing to remove it
+// is an error.
+func prepareExtractionDir(cfg *config.Config, zipFilePath string, outputDir string) error {
+	_, statErr := os.Stat(outputDir)
+	replacing := statErr == nil && !cfg.ExtractResume
+	if cfg.ExtractFreeSpaceCheck {
+		check := shared.CheckExtractionSpace
+		if replacing {
+			check = shared.CheckReplacingExtractionSpace
 		}
-
-		_, err = io.Copy(outFile, rc) //
-
-		closeErr1 := rc.Close()
-		closeErr2 := outFile.Close()
-
-		if err != nil {
-			return cstmerr.NewFileIOError(fmt.Sprintf("Failed to copy content to %s", outPath), err)
+		if err := check(zipFilePath, outputDir, cfg.ExtractFreeSpaceMargin); err != nil {
+			return err
 		}
-		if closeErr1 != nil {
-			return cstmerr.NewArchiveError(fmt.Sprintf("Failed to close archive file entry %s", f.Name), closeErr1)
-		}
-		if closeErr2 != nil {
-			return cstmerr.NewFileIOError(fmt.Sprintf("Failed to close output file %s", outPath), closeErr2)
-		}
-
-		if f.Mode()&os.ModeSymlink == 0 {
-			if err := os.Chmod(outPath, f.Mode()); err != nil { //
-				log.Printf("Warning: Failed to set permissions on %s: %v", outPath, err)
-			}
+	}
+	if replacing {
+		log.Printf("Removing existing extraction directory: %s", outputDir)
+		// The space check counted the previous extraction as free, so it must be gone.
+		if err := os.RemoveAll(outputDir); err != nil {
+			return cstmerr.NewFileIOError(fmt.Sprintf("Failed to remove existing extraction directory %s", outputDir), err)
 		}
 	}
-	log.Println("Unzipping done.")
 	return nil
 }
 
@@ -107,8 +82,21 @@ func runUpdateScript(cfg *config.Config, scriptPath string, workingDir string) e
 	cmd.Dir = workingDir
 	// Set environment variables, specifically DB_PASSWORD as in the Rust code
 	cmd.Env = append(os.Environ(), fmt.Sprintf("DB_PASSWORD=%s", cfg.DBPassword))
+	if cfg.ScriptRunAsUID >= 0 || cfg.ScriptRunAsGID >= 0 {
+		uid, gid := cfg.ScriptRunAsUID, cfg.ScriptRunAsGID
+		if uid < 0 {
+			uid = os.Getuid()
+		}
+		if gid < 0 {
+			gid = primaryGroup(uid)
+		}
+		log.Printf("Running update script as uid %d, gid %d", uid, gid)
+		cmd.SysProcAttr = &syscall.SysProcAttr{Credential: &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}}
+	}
 
 	output, err := cmd.CombinedOutput() // Gets both stdout and stderr
+	// The script receives DB_PASSWORD and may echo it or other secrets back.
+	output = []byte(shared.RedactSecrets(string(output), cfg.SecretValues()...))
 
 	if err != nil {
 		log.Printf("Update script failed.\nStatus: %s\nSTDOUT:\n%s\nSTDERR:\n%s",
@@ -125,7 +113,303 @@ func runUpdateScript(cfg *config.Config, scriptPath string, workingDir string) e
 	return nil
 }
 
-func runUpdateCycle(cfg *config.Config, apiClient *apiClient.APIClient, currentVersion int) error {
+// primaryGroup returns the primary group of the user uid, or the updater's own group
+// if the user can't be looked up.
+func primaryGroup(uid int) int {
+	u, err := user.LookupId(strconv.Itoa(uid))
+	if err == nil {
+		var gid int
+		if gid, err = strconv.Atoi(u.Gid); err == nil {
+			return gid
+		}
+	}
+	log.Printf("No primary group found for uid %d, keeping gid %d: %v", uid, os.Getgid(), err)
+	return os.Getgid()
+}
+
+// UPDATE_DIR_PREFIX prefixes the version number in extracted update directory names.
+const UPDATE_DIR_PREFIX = "update_v"
+
+func updateDirName(version int) string {
+	return fmt.Sprintf("%s%d", UPDATE_DIR_PREFIX, version)
+}
+
+// pruneOldUpdates removes extracted update directories in baseDir, keeping the
+// keep most recent versions. At least one version is always kept.
+func pruneOldUpdates(baseDir string, keep int) error {
+	if keep < 1 {
+		keep = 1
+	}
+
+	entries, err := os.ReadDir(baseDir)
+	if err != nil {
+		return cstmerr.NewFileIOError(fmt.Sprintf("Failed to read update directory %s", baseDir), err)
+	}
+
+	var versions []int
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), UPDATE_DIR_PREFIX) {
+			continue
+		}
+		version, err := strconv.Atoi(strings.TrimPrefix(entry.Name(), UPDATE_DIR_PREFIX))
+		if err != nil {
+			continue
+		}
+		versions = append(versions, version)
+	}
+	if len(versions) <= keep {
+		return nil
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+	for _, version := range versions[keep:] {
+		dirPath := filepath.Join(baseDir, updateDirName(version))
+		log.Printf("Pruning old update directory: %s", dirPath)
+		if err := os.RemoveAll(dirPath); err != nil {
+			return cstmerr.NewFileDeleteError(fmt.Sprintf("Failed to remove old update directory %s", dirPath), err)
+		}
+		if err := os.Remove(dirPath + ".zip"); err != nil && !os.IsNotExist(err) {
+			return cstmerr.NewFileDeleteError(fmt.Sprintf("Failed to remove old update archive %s.zip", dirPath), err)
+		}
+	}
+	return nil
+}
+
+// cleanupDownloads removes leftover .part files and .zip archives that were already
+// extracted next to themselves under baseDir, once older than maxAge. If the remaining
+// ones still take more than maxTotalBytes, the oldest are removed until they fit.
+// Update archives are left to pruneOldUpdates, since deltas are applied against them.
+// A maxAge or maxTotalBytes of 0 disables that limit.
+func cleanupDownloads(baseDir string, maxAge time.Duration, maxTotalBytes int64, now time.Time) error {
+	type download struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	if _, err := os.Stat(baseDir); os.IsNotExist(err) {
+		return nil
+	}
+	var downloads []download
+	err := filepath.WalkDir(baseDir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), UPDATE_DIR_PREFIX) {
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case apiClient.PART_FILE_SUFFIX:
+		case ".zip":
+			if info, err := os.Stat(strings.TrimSuffix(path, ".zip")); err != nil || !info.IsDir() {
+				return nil // Not extracted, so the archive is still the only copy.
+			}
+		default:
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		downloads = append(downloads, download{path: path, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return cstmerr.NewFileIOError(fmt.Sprintf("Failed to scan downloads in %s", baseDir), err)
+	}
+
+	sort.Slice(downloads, func(i, j int) bool { return downloads[i].modTime.Before(downloads[j].modTime) })
+	var total int64
+	for _, d := range downloads {
+		total += d.size
+	}
+	for _, d := range downloads {
+		expired := maxAge > 0 && now.Sub(d.modTime) > maxAge
+		overCap := maxTotalBytes > 0 && total > maxTotalBytes
+		if !expired && !overCap {
+			continue
+		}
+		log.Printf("Removing old download %s (%d bytes, modified %s)", d.path, d.size, d.modTime.Format(time.RFC3339))
+		if err := os.Remove(d.path); err != nil && !os.IsNotExist(err) {
+			return cstmerr.NewFileDeleteError(fmt.Sprintf("Failed to remove old download %s", d.path), err)
+		}
+		total -= d.size
+	}
+	return nil
+}
+
+func updateArchivePath(cfg *config.Config, version int) string {
+	return filepath.Join(cfg.DownloadBaseDir, updateDirName(version)+".zip")
+}
+
+// applyDeltaUpdate builds the archive for updateInfo at downloadPath by downloading the
+// advertised delta patch and applying it to the kept archive of currentVersion. An
+// error means no applicable delta was found or it could not be applied, and the full
+// archive should be downloaded instead.
+func applyDeltaUpdate(cfg *config.Config, apiClient *apiClient.APIClient, updateInfo *apiClient.UpdateInfo,
+	currentVersion int, downloadPath string) error {
+	if updateInfo.DeltaURL == "" || updateInfo.DeltaFromVersion != currentVersion {
+		return cstmerr.NewPatchError(fmt.Sprintf("no delta from version %d advertised", currentVersion), nil)
+	}
+	oldArchivePath := updateArchivePath(cfg, currentVersion)
+	if _, err := os.Stat(oldArchivePath); err != nil {
+		return cstmerr.NewPatchError(fmt.Sprintf("archive of version %d not kept", currentVersion), err)
+	}
+
+	patchPath := downloadPath + ".patch"
+	defer os.Remove(patchPath)
+	log.Printf("Downloading delta update %s to %s", updateInfo.DeltaURL, patchPath)
+	if err := apiClient.DownloadFile(updateInfo.DeltaURL, patchPath); err != nil {
+		return err
+	}
+
+	log.Printf("Applying delta from version %d to %s", currentVersion, oldArchivePath)
+	if err := shared.ApplyBsdiffPatch(oldArchivePath, patchPath, downloadPath, updateInfo.FileSHA256); err != nil {
+		return err
+	}
+	return nil
+}
+
+// verifyUpdateArchive checks the SHA-256 of the downloaded archive against the one
+// advertised by the server. A mismatch is a DownloadError, so the cycle is retried.
+func verifyUpdateArchive(archivePath string, expectedSHA256 string) error {
+	actual, err := shared.CalculateFileSHA256(archivePath)
+	if err != nil {
+		return cstmerr.NewFileIOError(fmt.Sprintf("Failed to hash update archive %s", archivePath), err)
+	}
+	if !strings.EqualFold(actual, expectedSHA256) {
+		return cstmerr.NewDownloadError(fmt.Sprintf("checksum of %s is %s, expected %s", archivePath, actual, expectedSHA256))
+	}
+	return nil
+}
+
+// reusableUpdateArchive reports whether archivePath already holds the advertised
+// update archive. Its size is compared with the one the server advertises first, so
+// a partial or different file is rejected without hashing it.
+func reusableUpdateArchive(apiClient *apiClient.APIClient, updateInfo *apiClient.UpdateInfo, archivePath string) bool {
+	if updateInfo.FileSHA256 == "" {
+		return false
+	}
+	fileInfo, err := os.Stat(archivePath)
+	if err != nil {
+		return false
+	}
+	info, err := apiClient.HeadInfo(updateInfo.FileURL)
+	if err != nil {
+		log.Printf("Could not get size of %s, downloading it again: %v", updateInfo.FileURL, err)
+		return false
+	}
+	if info.Size > 0 && info.Size != fileInfo.Size() {
+		return false
+	}
+	if err := verifyUpdateArchive(archivePath, updateInfo.FileSHA256); err != nil {
+		log.Printf("Existing update archive does not match, downloading it again: %v", err)
+		return false
+	}
+	return true
+}
+
+// rollbackUpdate re-runs the update script of a previously kept extraction to restore
+// that version.
+func rollbackUpdate(cfg *config.Config, version int) error {
+	rollbackDir := filepath.Join(cfg.DownloadBaseDir, updateDirName(version))
+	if _, err := os.Stat(rollbackDir); err != nil {
+		return cstmerr.NewFileIOError(fmt.Sprintf("No kept extraction for version %d at %s", version, rollbackDir), err)
+	}
+	log.Printf("Rolling back to version %d from %s", version, rollbackDir)
+	return runUpdateScript(cfg, filepath.Join(rollbackDir, cfg.UpdateScriptName), rollbackDir)
+}
+
+// startNTPReset runs reset in a goroutine to reset the NTP service, unless
+// ntp_reset_enabled turned that off, and reports whether it did.
+func startNTPReset(cfg *config.Config, reset func()) bool {
+	if !cfg.NTPResetEnabled {
+		log.Println("NTP reset is disabled.")
+		return false
+	}
+	go reset()
+	return true
+}
+
+// reportStartup reads the current version and reports the device online with it, so
+// the server knows the version right away, not only after an update. A failed report
+// is logged and startup goes on.
+func reportStartup(cfg *config.Config, apiClient *apiClient.APIClient) int {
+	currentVersion, err := config.GetCurrentVersion(cfg)
+	if err != nil {
+		log.Printf("Failed to get current version (assuming 0 and continuing): %v", err)
+		currentVersion = 0 // Default to 0
+	}
+	log.Printf("Current service version: %d", currentVersion)
+	if reportErr := apiClient.ReportStatus(currentVersion, "device online"); reportErr != nil {
+		log.Printf("Failed to report startup status: %v", reportErr)
+	}
+	return currentVersion
+}
+
+// maxReadinessBackoff caps the delay between readiness checks.
+const maxReadinessBackoff = 30 * time.Second
+
+// waitForReadiness blocks until both the database and the content API respond, or
+// timeout passes, backing off between checks. It returns the last failure on timeout.
+func waitForReadiness(clock shared.Clock, dbConn dbclient.DBClient, apiClientInstance *apiClient.APIClient,
+	timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	retryBackoff := backoff.Policy{Base: time.Second, Max: maxReadinessBackoff, Multiplier: 2}
+	for attempt := 1; ; attempt++ {
+		err := dbConn.Ping(ctx)
+		if err != nil {
+			err = cstmerr.NewDBConnectionError("database not reachable", err)
+		} else {
+			err = apiClientInstance.Ping()
+		}
+		if err == nil {
+			log.Printf("Database and content API reachable after %d attempt(s).", attempt)
+			return nil
+		}
+		delay := retryBackoff.Next()
+		log.Printf("Readiness check %d failed: %v. Retrying in %s.", attempt, err, delay)
+		if sleepErr := clock.Sleep(ctx, delay); sleepErr != nil {
+			return fmt.Errorf("not ready after %s: %w", timeout, err)
+		}
+	}
+}
+
+// maxDegradedPollInterval caps the poll interval while content sync is degraded.
+const maxDegradedPollInterval = time.Hour
+
+// degradedPollBackoff doubles the poll interval for each consecutive degraded cycle,
+// so a systemic outage isn't hammered with retries.
+func degradedPollBackoff(interval time.Duration) *backoff.Policy {
+	return &backoff.Policy{Base: 2 * interval, Max: maxDegradedPollInterval, Multiplier: 2}
+}
+
+// Poll intervals runUpdateCycle asks for after a failed update download.
+const (
+	downloadTimedOutPollInterval = time.Second // Most of the archive is kept, so resume right away
+	downloadFailedPollInterval   = 300 * time.Second
+)
+
+// Values of the decision field of an update decision record.
+const (
+	UPDATE_DECISION_UPDATE = "update"
+	UPDATE_DECISION_SKIP   = "skip"
+)
+
+// logUpdateDecision writes one key=value record of whether this cycle updates and why,
+// so the journal shows which version a device ran and when it moved on. An available
+// version of 0 means none is known.
+func logUpdateDecision(current int, available int, decision string, reason string) {
+	log.Printf("update_decision current_version=%d available_version=%d decision=%s reason=%q",
+		current, available, decision, reason)
+}
+
+// runUpdateCycle checks for, downloads and applies a firmware update. It returns the
+// interval to wait before the next poll when it should differ from the configured
+// one, or 0. The configuration is not modified, so the override only affects the
+// next sleep.
+func runUpdateCycle(cfg *config.Config, apiClient *apiClient.APIClient, currentVersion int) (time.Duration, error) {
 	log.Println("Starting update check cycle...")
 
 	updateInfo, err := apiClient.CheckForUpdates()
@@ -136,40 +420,44 @@ func runUpdateCycle(cfg *config.Config, apiClient *apiClient.APIClient, currentV
 			log.Printf("Error checking for updates: %v", err)
 		}
 
-		return fmt.Errorf("update check failed: %w", err)
+		logUpdateDecision(currentVersion, 0, UPDATE_DECISION_SKIP, "update check failed")
+		return 0, fmt.Errorf("update check failed: %w", err)
 	}
 
-	log.Printf("New version available: %d, URL: %s. Current version: %d",
+	log.Printf("Available version: %d, URL: %s. Current version: %d",
 		updateInfo.VersionCode, updateInfo.FileURL, currentVersion) //
 
 	if updateInfo.VersionCode > currentVersion {
-		fileNameParts := strings.Split(updateInfo.FileURL, "/")
-		fileNameWithExt := fileNameParts[len(fileNameParts)-1]
-
-		baseFileName := fileNameWithExt
-		if strings.HasSuffix(strings.ToLower(baseFileName), ".zip") {
-			baseFileName = baseFileName[:len(baseFileName)-4]
+		logUpdateDecision(currentVersion, updateInfo.VersionCode, UPDATE_DECISION_UPDATE, "newer version available")
+		// Archives are named by version so the next update can be applied as a delta against this one.
+		downloadPath := updateArchivePath(cfg, updateInfo.VersionCode)
+
+		// A previous attempt may already have downloaded this exact archive (e.g. before
+		// the update script failed); it is verified below, so skip downloading it again.
+		reused := reusableUpdateArchive(apiClient, updateInfo, downloadPath)
+		if reused {
+			log.Printf("Update archive %s already downloaded, skipping download", downloadPath)
+		} else {
+			err = applyDeltaUpdate(cfg, apiClient, updateInfo, currentVersion, downloadPath)
+			if err != nil {
+				log.Printf("Delta update not applied (%v), downloading full update %s to %s",
+					err, updateInfo.FileURL, downloadPath)
+				err = apiClient.DownloadFile(updateInfo.FileURL, downloadPath)
+			}
 		}
-
-		downloadFileName := fmt.Sprintf("%s.zip", baseFileName)
-		downloadPath := filepath.Join(cfg.DownloadBaseDir, downloadFileName)
-
-		log.Printf("Downloading update %s to %s", updateInfo.FileURL, downloadPath)
-		err = apiClient.DownloadFile(updateInfo.FileURL, downloadPath)
 		if err != nil {
 			log.Printf("Error downloading update: %v", err)
+			nextPoll := downloadFailedPollInterval
 			if _, ok := err.(*cstmerr.TimeoutError); ok { //
 				log.Println("Download timed out, will try again sooner.")
-				cfg.PollIntervalSeconds = 1 // Adjust a copy, or make cfg a pointer if it needs to be modified globally
-			} else {
-				cfg.PollIntervalSeconds = 300 //
+				nextPoll = downloadTimedOutPollInterval
 			}
 			// Report status on download failure
 			statusMsg := fmt.Sprintf("version %d download failed: %v", updateInfo.VersionCode, err)
 			if reportErr := apiClient.ReportStatus(currentVersion, statusMsg); reportErr != nil { //
 				log.Printf("Failed to report download failure status: %v", reportErr)
 			}
-			return fmt.Errorf("download failed: %w", err)
+			return nextPoll, fmt.Errorf("download failed: %w", err)
 		}
 		log.Println("File downloaded successfully.")
 		statusMsg := fmt.Sprintf("version %d downloaded successfully", updateInfo.VersionCode)
@@ -177,33 +465,64 @@ func runUpdateCycle(cfg *config.Config, apiClient *apiClient.APIClient, currentV
 			log.Printf("Failed to report download success status: %v", reportErr)
 		}
 
-		extractedDirName := baseFileName
+		if updateInfo.FileSHA256 != "" && !reused {
+			if err := verifyUpdateArchive(downloadPath, updateInfo.FileSHA256); err != nil {
+				log.Printf("Downloaded archive failed verification, removing it so the next cycle downloads it again: %v", err)
+				if removeErr := os.Remove(downloadPath); removeErr != nil {
+					log.Printf("Failed to remove unverified zip file %s: %v", downloadPath, removeErr)
+				}
+				statusMsg := fmt.Sprintf("downloaded file for version %d failed verification: %v", updateInfo.VersionCode, err)
+				if reportErr := apiClient.ReportStatus(currentVersion, statusMsg); reportErr != nil {
+					log.Printf("Failed to report archive verification status: %v", reportErr)
+				}
+				return 0, fmt.Errorf("archive verification failed: %w", err)
+			}
+		}
+
+		log.Printf("Validating downloaded archive %s", downloadPath)
+		if err := shared.ValidateZipFile(downloadPath); err != nil {
+			log.Printf("Downloaded archive is corrupt, removing it so the next cycle downloads it fresh: %v", err)
+			if removeErr := os.Remove(downloadPath); removeErr != nil {
+				log.Printf("Failed to remove corrupt zip file %s: %v", downloadPath, removeErr)
+			}
+			statusMsg := fmt.Sprintf("downloaded file for version %d is corrupt: %v", updateInfo.VersionCode, err)
+			if reportErr := apiClient.ReportStatus(currentVersion, statusMsg); reportErr != nil {
+				log.Printf("Failed to report corrupt archive status: %v", reportErr)
+			}
+			return 0, fmt.Errorf("archive validation failed: %w", err)
+		}
+
+		extractedDirName := updateDirName(updateInfo.VersionCode)
 		outExtractedPath := filepath.Join(cfg.DownloadBaseDir, extractedDirName)
 
 		log.Printf("Extracting update to %s", outExtractedPath)
-		// Clean up previous extraction if it exists, or handle this in unzipUpdate
-		if _, err := os.Stat(outExtractedPath); err == nil {
-			log.Printf("Removing existing extraction directory: %s", outExtractedPath)
-			if err := os.RemoveAll(outExtractedPath); err != nil {
-				log.Printf("Failed to remove existing extraction directory %s: %v", outExtractedPath, err)
-				// TODO:This could be a critical error, decide if to proceed or return
+		// Keep the archive: it is intact, and extraction can go ahead once space is freed.
+		if err := prepareExtractionDir(cfg, downloadPath, outExtractedPath); err != nil {
+			log.Printf("Not extracting update: %v", err)
+			statusMsg := fmt.Sprintf("cannot extract version %d: %v", updateInfo.VersionCode, err)
+			if reportErr := apiClient.ReportStatus(currentVersion, statusMsg); reportErr != nil {
+				log.Printf("Failed to report extraction preflight status: %v", reportErr)
 			}
+			return 0, fmt.Errorf("extraction preflight failed: %w", err)
 		}
 
-		if err := unzipUpdate(downloadPath, outExtractedPath); err != nil {
+		if err := unzipUpdate(downloadPath, outExtractedPath, cfg.ExtractResume); err != nil {
 			log.Printf("Error unzipping file: %v", err)
 			// Cleanup on unzip error as in Rust code
 			if removeErr := os.Remove(downloadPath); removeErr != nil {
 				log.Printf("Failed to remove downloaded zip file %s after unzip error: %v", downloadPath, removeErr)
 			}
-			if removeErr := os.RemoveAll(outExtractedPath); removeErr != nil {
+			if cfg.ExtractResume {
+				// Files extracted in full are verified against the archive when resuming.
+				log.Printf("Keeping extraction directory %s to resume from", outExtractedPath)
+			} else if removeErr := os.RemoveAll(outExtractedPath); removeErr != nil {
 				log.Printf("Failed to remove extraction directory %s after unzip error: %v", outExtractedPath, removeErr)
 			}
 			statusMsg := fmt.Sprintf("file extraction for version %d failed: %v", updateInfo.VersionCode, err)
 			if reportErr := apiClient.ReportStatus(currentVersion, statusMsg); reportErr != nil {
 				log.Printf("Failed to report extraction failure status: %v", reportErr)
 			}
-			return fmt.Errorf("unzip failed: %w", err)
+			return 0, fmt.Errorf("unzip failed: %w", err)
 		}
 		log.Println("File extracted successfully.")
 		statusMsg = fmt.Sprintf("file for version %d extracted successfully", updateInfo.VersionCode)
@@ -223,7 +542,7 @@ func runUpdateCycle(cfg *config.Config, apiClient *apiClient.APIClient, currentV
 				}
 			}
 			//TODO: handle role back
-			return fmt.Errorf("update script failed: %w", err)
+			return 0, fmt.Errorf("update script failed: %w", err)
 		}
 
 		log.Printf("Update script executed successfully. System should be updated to version %d.", updateInfo.VersionCode)
@@ -235,7 +554,22 @@ func runUpdateCycle(cfg *config.Config, apiClient *apiClient.APIClient, currentV
 		}
 		log.Printf("Current service version: %d", checkCurrentVersion)
 
-		if checkCurrentVersion != updateInfo.VersionCode {
+		if checkCurrentVersion != updateInfo.VersionCode && cfg.StrictVersionCheck {
+			mismatchErr := cstmerr.NewVersionMismatchError(updateInfo.VersionCode, checkCurrentVersion)
+			log.Printf("Update verification failed: %v", mismatchErr)
+			statusMsg = fmt.Sprintf("update from %d to %d failed verification: current version is %d",
+				currentVersion, updateInfo.VersionCode, checkCurrentVersion)
+			if rollbackErr := rollbackUpdate(cfg, currentVersion); rollbackErr != nil {
+				log.Printf("Rollback to version %d failed: %v", currentVersion, rollbackErr)
+				statusMsg = fmt.Sprintf("%s; rollback to %d failed: %v", statusMsg, currentVersion, rollbackErr)
+			} else {
+				statusMsg = fmt.Sprintf("%s; rolled back to %d", statusMsg, currentVersion)
+			}
+			if reportErr := apiClient.ReportStatus(checkCurrentVersion, statusMsg); reportErr != nil {
+				log.Printf("Failed to report update verification failure status: %v", reportErr)
+			}
+			return 0, fmt.Errorf("update verification failed: %w", mismatchErr)
+		} else if checkCurrentVersion != updateInfo.VersionCode {
 			statusMsg = fmt.Sprintf("updated successfully from %d to %d but checking the current version is %d",
 				currentVersion, updateInfo.VersionCode, checkCurrentVersion)
 			if reportErr := apiClient.ReportStatus(checkCurrentVersion, statusMsg); reportErr != nil {
@@ -246,31 +580,71 @@ func runUpdateCycle(cfg *config.Config, apiClient *apiClient.APIClient, currentV
 			if reportErr := apiClient.ReportStatus(checkCurrentVersion, statusMsg); reportErr != nil {
 				log.Printf("Failed to report successful update status: %v", reportErr)
 			}
+			// The new version is running, so older extractions beyond the rollback window can go.
+			if err := pruneOldUpdates(cfg.DownloadBaseDir, cfg.KeepUpdateVersions); err != nil {
+				log.Printf("Failed to prune old update directories: %v", err)
+			}
 		}
-
-		cfg.PollIntervalSeconds = 300 // Reset poll interval on successful update path
 	} else {
-		log.Println("No new update available or service is up-to-date.")
+		logUpdateDecision(currentVersion, updateInfo.VersionCode, UPDATE_DECISION_SKIP, "already up to date")
 	}
 
-	return nil
+	return 0, nil
 }
 
-func main() {
-	initLogging()
-	log.Println("Embedded Updater starting...")
+// pushReconnectDelay is how long to wait before reopening a dropped push notification stream.
+const pushReconnectDelay = 30 * time.Second
+
+// watchPushNotifications keeps a push notification stream open, reconnecting after
+// pushReconnectDelay whenever it drops. Content is still polled in the meantime.
+func watchPushNotifications(clock shared.Clock, apiClient *apiClient.APIClient, notify chan<- struct{}) {
+	for {
+		err := apiClient.WatchPushNotifications(context.Background(), notify)
+		log.Printf("Push notification stream closed (%v), reconnecting in %s", err, pushReconnectDelay)
+		clock.Sleep(context.Background(), pushReconnectDelay)
+	}
+}
+
+// serveMetrics serves the content sync metrics on addr for as long as the process runs.
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", controller.MetricsHandler())
+	log.Printf("Serving metrics on %s/metrics", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("Metrics endpoint on %s stopped: %v", addr, err)
+	}
+}
 
+func main() {
 	configPath := os.Getenv("PODBOX_UPDATE_CONF")
 	if configPath == "" {
 		configPath = "/etc/podbox_update/config.toml" // Default path
 	}
+	if isValidateConfigCommand() {
+		os.Exit(runValidateConfig(os.Args[2:], configPath))
+	}
+	if isDumpConfigCommand() {
+		os.Exit(runDumpConfig(os.Args[2:], configPath))
+	}
+	if isPurgeAllCommand() {
+		os.Exit(runPurgeAll(os.Args[2:], configPath))
+	}
+
+	initLogging()
+	log.Println("Embedded Updater starting...")
 
 	appConfig, err := config.Load(configPath)
 	if err != nil {
-		log.Fatalf("Failed to load configuration from %s: %v", configPath, err)
-		return // Redundant due to Fatalf
+		// Whatever went wrong reading it, the configuration is what's at fault.
+		exitWithError(cstmerr.NewConfigError(fmt.Sprintf("failed to load configuration from %s", configPath), err))
 	}
 	log.Printf("Configuration loaded for service: %s", appConfig.ServiceName)
+	log.Printf("Effective configuration:\n%s", config.Dump(appConfig))
+
+	controller.Configure(appConfig)
+	if err := controller.EnsureContentDirs(); err != nil {
+		exitWithError(err)
+	}
 
 	//TODO: move this to the controller for update
 	err = shared.CheckAndCreateDir(appConfig.DownloadBaseDir)
@@ -278,46 +652,142 @@ func main() {
 		return
 	}
 
-	dbConn, err := dbclient.NewDBClient(&appConfig.Database, "gorm")
+	clock := shared.RealClock{}
+	controller.SetClock(clock)
+
+	dbConn, err := dbclient.NewDBClient(&appConfig.Database, "gorm", clock)
 	if err != nil {
-		log.Fatalf("Failed to initialize GORM database client: %v", err)
+		exitWithError(fmt.Errorf("failed to initialize GORM database client: %w", err))
 	}
 	defer dbConn.Close()
+	dbConn = controller.NewThrottledDBClient(dbConn, appConfig.MaxConcurrentDBWrites)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Connection timeout
 	defer cancel()
 
+	models := []any{&shared.Updater{}, &shared.AssetMeta{}}
+	if appConfig.Database.DBAutoMigrate {
+		models = append(models, shared.AutoMigrateList...)
+	}
+	if err := dbConn.Migrate(ctx, models...); err != nil {
+		exitWithError(fmt.Errorf("failed to migrate database: %w", err))
+	}
+
 	var updater shared.Updater
 	err = dbConn.First(ctx, &updater)
 	if err != nil {
-		log.Fatalf("Failed to retrieve updater record from database: %v", err)
+		exitWithError(fmt.Errorf("failed to retrieve updater record from database: %w", err))
 		updater.LastFromTimeStamp = 0
 		//TODO: create instance of updater
 	}
 
-	go shared.UpdateNTPService() // Start NTP reset in a goroutine
+	if _, err := controller.ApplyResyncFrom(ctx, dbConn, &updater, appConfig.ResyncFrom); err != nil {
+		log.Printf("Not re-syncing from %d: %v", appConfig.ResyncFrom, err)
+	}
+
+	startNTPReset(appConfig, func() { shared.UpdateNTPService(clock) })
 
 	// Create API client
 	apiClientInstance := apiClient.New(appConfig, appConfig.DeviceToken)
+	apiClientInstance.SetClock(clock)
+
+	if appConfig.ReadinessTimeout > 0 {
+		if err := waitForReadiness(clock, dbConn, apiClientInstance, appConfig.ReadinessTimeout); err != nil {
+			log.Printf("Starting without confirmed readiness: %v", err)
+		}
+	}
 	// Main update loop
 
-	currentVersion, err := config.GetCurrentVersion(appConfig)
-	if err != nil {
-		log.Printf("Failed to get current version (assuming 0 and continuing): %v", err)
-		currentVersion = 0 // Default to 0
+	currentVersion := reportStartup(appConfig, apiClientInstance)
+	if appConfig.MetricsAddr != "" {
+		go serveMetrics(appConfig.MetricsAddr)
 	}
-	log.Printf("Current service version: %d", currentVersion)
-	//TODO: send a status to server, report the current version
+	// Stop between cycles on SIGINT or SIGTERM, sending the status reports still queued.
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
+	pushNotify := make(chan struct{}, 1)
+	if appConfig.PushNotifyURL != "" {
+		go watchPushNotifications(clock, apiClientInstance, pushNotify)
+	}
+	app := &controller.App{API: apiClientInstance, DB: dbConn, Updater: &updater,
+		RetryBudget: appConfig.RetryBudgetPerCycle}
+	degradedBackoff := degradedPollBackoff(time.Duration(appConfig.PollIntervalSeconds) * time.Second)
 	for {
+		updatePollInterval, err := runUpdateCycle(appConfig, apiClientInstance, currentVersion)
+		if err != nil {
+			log.Printf("Error in update cycle: %v. Will retry later.", err)
+		}
+		// An applied update changes the version reported from here on.
+		if version, err := config.GetCurrentVersion(appConfig); err == nil {
+			currentVersion = version
+		}
+
 		log.Println("Checking for content updates...")
-		err = controller.FetchAndProcessContentUpdates(
-			apiClientInstance, dbConn, &updater)
+		// The cycle's status reports share its retry budget.
+		cycleCtx := app.CycleContext(context.Background())
+		summary, err := app.RunCycle(cycleCtx)
+		log.Printf("Content sync %s: fetched %d, processed %d (%d new, %d changed), skipped %d, failed %d, resumed %d, watermark %d (lag %v)",
+			summary.RequestID, summary.Fetched, summary.Processed, summary.Created, summary.Updated, summary.Skipped, summary.Failed,
+			summary.Resumed, summary.NewWatermark, summary.WatermarkLag)
+		if summary.Abandoned > 0 {
+			log.Printf("WARNING: dropped %d deferred item(s) whose parent was never synced", summary.Abandoned)
+		}
 		if err != nil {
 			log.Printf("Error in content update cycle: %v. Will retry later.", err)
+			var schemaErr *cstmerr.SchemaVersionError
+			if errors.As(err, &schemaErr) {
+				if reportErr := apiClientInstance.ReportStatusContext(cycleCtx, currentVersion, schemaErr.Error()); reportErr != nil {
+					log.Printf("Failed to report content schema mismatch: %v", reportErr)
+				}
+			}
 		}
 
-		log.Printf("Update check cycle finished. Sleeping for %d seconds.",
-			appConfig.PollIntervalSeconds)
-		time.Sleep(time.Duration(appConfig.PollIntervalSeconds) * time.Second) //
+		if len(summary.Unreadable) > 0 {
+			statusMsg := fmt.Sprintf("warning: %d synced assets unreadable: %s",
+				len(summary.Unreadable), strings.Join(summary.Unreadable, ", "))
+			if reportErr := apiClientInstance.ReportStatusContext(cycleCtx, currentVersion, statusMsg); reportErr != nil {
+				log.Printf("Failed to report unreadable assets: %v", reportErr)
+			}
+		}
+
+		pollInterval := time.Duration(appConfig.PollIntervalSeconds) * time.Second
+		if summary.Degraded {
+			statusMsg := fmt.Sprintf("degraded: %d of %d content items failed", summary.Failed, summary.Attempted())
+			if reportErr := apiClientInstance.ReportStatusContext(cycleCtx, currentVersion, statusMsg); reportErr != nil {
+				log.Printf("Failed to report degraded status: %v", reportErr)
+			}
+			pollInterval = degradedBackoff.Next()
+		} else {
+			degradedBackoff.Reset()
+		}
+		if updatePollInterval > 0 {
+			// e.g. resume a timed-out update download right away.
+			pollInterval = updatePollInterval
+		}
+
+		for _, dir := range []string{appConfig.DownloadBaseDir, filepath.Join(controller.ContentBasePath(), "videos")} {
+			if err := cleanupDownloads(dir, appConfig.DownloadRetentionMaxAge,
+				appConfig.DownloadRetentionMaxBytes, clock.Now()); err != nil {
+				log.Printf("Failed to clean up downloads in %s: %v", dir, err)
+			}
+		}
+
+		// Don't leave this cycle's reports queued for the whole poll interval.
+		if flushErr := apiClientInstance.FlushStatusReports(); flushErr != nil {
+			log.Printf("Failed to flush status reports: %v", flushErr)
+		}
+
+		log.Printf("Update check cycle finished. Sleeping for %s.", pollInterval)
+		select {
+		case <-clock.After(pollInterval):
+		case <-pushNotify:
+			log.Println("Push notification received, syncing content now.")
+		case sig := <-shutdown:
+			log.Printf("Received %v, shutting down.", sig)
+			if flushErr := apiClientInstance.FlushStatusReports(); flushErr != nil {
+				log.Printf("Failed to flush status reports: %v", flushErr)
+			}
+			return
+		}
 	}
 }
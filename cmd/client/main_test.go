@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"embedup-go/configs/config"
+	"embedup-go/internal/apiclient"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const (
+	testCheckURL  = "http://updates.test/check"
+	testUpdateURL = "http://updates.test/update.zip"
+)
+
+// stallingBody returns data and then fails the way a read cut off by a deadline does.
+type stallingBody struct{ io.Reader }
+
+func (b stallingBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err == io.EOF {
+		return n, context.DeadlineExceeded
+	}
+	return n, err
+}
+
+func (stallingBody) Close() error { return nil }
+
+// errReader fails the way a dropped connection does.
+type errReader struct{}
+
+func (errReader) Read(p []byte) (int, error) { return 0, errors.New("connection reset by peer") }
+
+// updateServer advertises one update whose download, if stream is set, fails with
+// stream's error part way through. URLs in files are served whole instead, and every
+// URL streamed is recorded in fetched.
+type updateServer struct {
+	info    apiclient.UpdateInfo
+	size    int
+	stream  func() io.ReadCloser
+	files   map[string][]byte
+	fetched []string
+}
+
+func (s *updateServer) Get(url string, opts *apiclient.RequestOptions) (*apiclient.Response, error) {
+	if url != testCheckURL {
+		return &apiclient.Response{StatusCode: http.StatusNotFound}, nil
+	}
+	body, _ := json.Marshal(s.info)
+	if err := json.Unmarshal(body, opts.SuccessResult); err != nil {
+		return nil, err
+	}
+	return &apiclient.Response{StatusCode: http.StatusOK, Body: body}, nil
+}
+
+func (s *updateServer) Post(url string, opts *apiclient.RequestOptions) (*apiclient.Response, error) {
+	return &apiclient.Response{StatusCode: http.StatusOK}, nil
+}
+
+func (s *updateServer) Put(url string, opts *apiclient.RequestOptions) (*apiclient.Response, error) {
+	return &apiclient.Response{StatusCode: http.StatusOK}, nil
+}
+
+func (s *updateServer) Head(url string, opts *apiclient.RequestOptions) (*apiclient.Response, error) {
+	size := s.size
+	if content, ok := s.files[url]; ok {
+		size = len(content)
+	}
+	headers := http.Header{}
+	headers.Set("Content-Length", strconv.Itoa(size))
+	headers.Set("Accept-Ranges", "bytes")
+	return &apiclient.Response{StatusCode: http.StatusOK, Headers: headers}, nil
+}
+
+func (s *updateServer) GetStream(url string, opts *apiclient.RequestOptions) (*apiclient.StreamResponse, error) {
+	s.fetched = append(s.fetched, url)
+	if content, ok := s.files[url]; ok {
+		return &apiclient.StreamResponse{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(content)),
+			ContentLength: int64(len(content))}, nil
+	}
+	return &apiclient.StreamResponse{StatusCode: http.StatusOK, Body: s.stream(), ContentLength: int64(s.size)}, nil
+}
+
+func newUpdateTest(t *testing.T, server *updateServer) (*config.Config, *apiclient.APIClient) {
+	t.Helper()
+	cfg := &config.Config{UpdateCheckAPIURL: testCheckURL, DownloadBaseDir: t.TempDir()}
+	return cfg, apiclient.NewWithHTTPClient(cfg, "test-token", server)
+}
+
+func TestRunUpdateCycleDownloadTimeoutShortensPoll(t *testing.T) {
+	server := &updateServer{
+		info: apiclient.UpdateInfo{VersionCode: 2, FileURL: testUpdateURL},
+		size: 100,
+		stream: func() io.ReadCloser {
+			return stallingBody{bytes.NewReader(make([]byte, 40))}
+		},
+	}
+	cfg, client := newUpdateTest(t, server)
+
+	next, err := runUpdateCycle(cfg, client, 1)
+	if err == nil {
+		t.Fatal("expected the timed-out download to fail the cycle")
+	}
+	if next != downloadTimedOutPollInterval {
+		t.Fatalf("next poll = %v, want %v", next, downloadTimedOutPollInterval)
+	}
+}
+
+func TestRunUpdateCycleDownloadFailureBacksOff(t *testing.T) {
+	server := &updateServer{
+		info: apiclient.UpdateInfo{VersionCode: 2, FileURL: testUpdateURL},
+		size: 100,
+		stream: func() io.ReadCloser {
+			return io.NopCloser(io.MultiReader(bytes.NewReader(make([]byte, 40)), errReader{}))
+		},
+	}
+	cfg, client := newUpdateTest(t, server)
+
+	next, err := runUpdateCycle(cfg, client, 1)
+	if err == nil {
+		t.Fatal("expected the failed download to fail the cycle")
+	}
+	if next != downloadFailedPollInterval {
+		t.Fatalf("next poll = %v, want %v", next, downloadFailedPollInterval)
+	}
+}
+
+func TestRunUpdateCycleUpToDateKeepsPoll(t *testing.T) {
+	server := &updateServer{info: apiclient.UpdateInfo{VersionCode: 1, FileURL: testUpdateURL}}
+	cfg, client := newUpdateTest(t, server)
+
+	next, err := runUpdateCycle(cfg, client, 1)
+	if err != nil {
+		t.Fatalf("runUpdateCycle: %v", err)
+	}
+	if next != time.Duration(0) {
+		t.Fatalf("next poll = %v, want 0 to keep the configured interval", next)
+	}
+}
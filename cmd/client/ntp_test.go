@@ -0,0 +1,46 @@
+package main
+
+import (
+	"embedup-go/configs/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStartNTPReset(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		reset := make(chan struct{}, 1)
+		started := startNTPReset(&config.Config{NTPResetEnabled: enabled}, func() { reset <- struct{}{} })
+		if started != enabled {
+			t.Fatalf("enabled %t: started = %t", enabled, started)
+		}
+		select {
+		case <-reset:
+			if !enabled {
+				t.Fatal("NTP reset ran while disabled")
+			}
+		case <-time.After(100 * time.Millisecond):
+			if enabled {
+				t.Fatal("NTP reset did not run while enabled")
+			}
+		}
+	}
+}
+
+func TestNTPResetEnabledByDefault(t *testing.T) {
+	if !config.Default().NTPResetEnabled {
+		t.Fatal("ntp_reset_enabled is off by default, want on")
+	}
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("ntp_reset_enabled = false\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.NTPResetEnabled {
+		t.Fatal("ntp_reset_enabled = false was not applied")
+	}
+}
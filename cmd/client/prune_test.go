@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// listDir returns the names in dir, sorted.
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestPruneOldUpdates(t *testing.T) {
+	tests := []struct {
+		name string
+		keep int
+		want []string
+	}{
+		{"keep two", 2, []string{"logs", "update_v10", "update_v10.zip", "update_vlatest", "update_v9", "update_v9.zip"}},
+		{"keep all", 5, []string{"logs", "update_v10", "update_v10.zip", "update_v2", "update_v2.zip",
+			"update_v3", "update_v9", "update_v9.zip", "update_vlatest"}},
+		{"keep zero keeps one", 0, []string{"logs", "update_v10", "update_v10.zip", "update_vlatest"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			// Versions sort numerically: v10 is the newest even though "update_v9" sorts after it.
+			for _, name := range []string{"update_v2", "update_v3", "update_v9", "update_v10", "update_vlatest", "logs"} {
+				if err := os.MkdirAll(filepath.Join(dir, name, "app"), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, name := range []string{"update_v2.zip", "update_v9.zip", "update_v10.zip"} {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte("archive"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := pruneOldUpdates(dir, tt.keep); err != nil {
+				t.Fatalf("pruneOldUpdates: %v", err)
+			}
+			got := listDir(t, dir)
+			want := append([]string(nil), tt.want...)
+			sort.Strings(want)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("left %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestPruneOldUpdatesMissingDir(t *testing.T) {
+	if err := pruneOldUpdates(filepath.Join(t.TempDir(), "missing"), 2); err == nil {
+		t.Fatal("pruneOldUpdates of a missing directory succeeded")
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"embedup-go/configs/config"
+	"embedup-go/internal/controller"
+	"embedup-go/internal/cstmerr"
+	"embedup-go/internal/dbclient"
+	"embedup-go/internal/shared"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// PURGE_ALL_COMMAND is the subcommand that wipes all synced content for a factory
+// reset and exits. Being a command rather than a setting, it runs once, when invoked,
+// and never on a later start of the service.
+const PURGE_ALL_COMMAND = "purge-all"
+
+// runPurgeAll implements the purge-all subcommand and returns the exit code. -confirm
+// must spell out controller.PURGE_ALL_CONFIRMATION; nothing is touched otherwise.
+func runPurgeAll(args []string, defaultConfigPath string) int {
+	fs := flag.NewFlagSet(PURGE_ALL_COMMAND, flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file of the service")
+	confirmation := fs.String("confirm", "", fmt.Sprintf("must be %q to purge", controller.PURGE_ALL_CONFIRMATION))
+	if err := fs.Parse(args); err != nil {
+		return ExitConfigError
+	}
+	if *confirmation != controller.PURGE_ALL_CONFIRMATION {
+		err := cstmerr.NewConfigError(
+			fmt.Sprintf("refusing to purge all content without -confirm %s", controller.PURGE_ALL_CONFIRMATION), nil)
+		fmt.Fprintln(os.Stderr, err)
+		return exitCodeFor(err)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s is invalid: %v\n", *configPath, err)
+		return exitCodeFor(err)
+	}
+	controller.Configure(cfg)
+
+	dbConn, err := dbclient.NewDBClient(&cfg.Database, "gorm", shared.RealClock{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect to the database: %v\n", err)
+		return exitCodeFor(err)
+	}
+	defer dbConn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Connection timeout
+	defer cancel()
+	var updater shared.Updater
+	if err := dbConn.First(ctx, &updater); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to retrieve updater record from database: %v\n", err)
+		return exitCodeFor(err)
+	}
+
+	if err := controller.PurgeAll(dbConn, &updater, *confirmation); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to purge local content: %v\n", err)
+		return exitCodeFor(err)
+	}
+	fmt.Println("All local content purged")
+	return ExitOK
+}
+
+// isPurgeAllCommand reports whether the process was started with the purge-all subcommand.
+func isPurgeAllCommand() bool {
+	return len(os.Args) > 1 && os.Args[1] == PURGE_ALL_COMMAND
+}
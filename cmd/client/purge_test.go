@@ -0,0 +1,22 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPurgeAllCommandRequiresConfirmation(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(configPath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"-config", configPath},
+		{"-config", configPath, "-confirm", "yes"},
+	} {
+		if code := runPurgeAll(args, configPath); code != ExitConfigError {
+			t.Fatalf("runPurgeAll(%q) = %d, want %d", args, code, ExitConfigError)
+		}
+	}
+}
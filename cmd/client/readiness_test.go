@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"embedup-go/internal/apiclient"
+	"embedup-go/internal/clocktest"
+	"embedup-go/internal/cstmerr"
+	"embedup-go/internal/dbclient"
+	"embedup-go/internal/shared"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// downDB is a database that fails pings until it has been pinged failures times.
+type downDB struct {
+	dbclient.DBClient
+	failures int
+	pings    int
+}
+
+func (d *downDB) Ping(ctx context.Context) error {
+	d.pings++
+	if d.pings <= d.failures {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+// downAPI is a content API that fails HEAD requests until it has been asked failures times.
+type downAPI struct {
+	updateServer
+	failures int
+	heads    int
+}
+
+func (s *downAPI) Head(url string, opts *apiclient.RequestOptions) (*apiclient.Response, error) {
+	s.heads++
+	if s.heads <= s.failures {
+		return nil, cstmerr.NewAPIClientError(errors.New("no route to host"))
+	}
+	return s.updateServer.Head(url, opts)
+}
+
+func newReadinessTest(t *testing.T, dbFailures, apiFailures int) (*downDB, *downAPI, *apiclient.APIClient) {
+	t.Helper()
+	server := &downAPI{failures: apiFailures}
+	cfg, _ := newUpdateTest(t, &server.updateServer)
+	cfg.ContentUpdateAPIURL = "http://content.test/updates"
+	return &downDB{failures: dbFailures}, server, apiclient.NewWithHTTPClient(cfg, "test-token", server)
+}
+
+func TestWaitForReadinessAfterRetries(t *testing.T) {
+	db, server, client := newReadinessTest(t, 2, 1)
+	clock := clocktest.NewFakeClock(time.Unix(0, 0))
+
+	if err := waitForReadiness(clock, db, client, time.Minute); err != nil {
+		t.Fatalf("waitForReadiness: %v", err)
+	}
+	if db.pings != 4 || server.heads != 2 {
+		t.Fatalf("database pinged %d times, API %d times; want 4 and 2", db.pings, server.heads)
+	}
+	if want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}; !slices.Equal(clock.Sleeps, want) {
+		t.Fatalf("slept %v, want %v", clock.Sleeps, want)
+	}
+}
+
+func TestWaitForReadinessDeadline(t *testing.T) {
+	db, _, client := newReadinessTest(t, 1000, 0)
+
+	err := waitForReadiness(shared.RealClock{}, db, client, 50*time.Millisecond)
+	var dbErr *cstmerr.DBConnectionError
+	if !errors.As(err, &dbErr) {
+		t.Fatalf("waitForReadiness = %v, want the last DBConnectionError", err)
+	}
+}
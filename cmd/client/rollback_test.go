@@ -0,0 +1,74 @@
+package main
+
+import (
+	"embedup-go/configs/config"
+	"embedup-go/internal/apiclient"
+	"embedup-go/internal/cstmerr"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunUpdateCycleVerifiesVersion(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		installed    string // version file contents once the v2 script has run
+		strict       bool
+		wantMismatch bool
+		wantRollback bool
+	}{
+		{name: "match", installed: "2", strict: true},
+		{name: "mismatch strict", installed: "1", strict: true, wantMismatch: true, wantRollback: true},
+		{name: "mismatch lenient", installed: "1", strict: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := &updateServer{
+				info:  apiclient.UpdateInfo{VersionCode: 2, FileURL: testUpdateURL},
+				files: map[string][]byte{testUpdateURL: readTestdata(t, "update_v2.zip")},
+			}
+			cfg, client := newUpdateTest(t, server)
+			cfg.UpdateScriptName = "update.sh"
+			cfg.ScriptRunAsUID, cfg.ScriptRunAsGID = -1, -1
+			cfg.StrictVersionCheck = tc.strict
+			cfg.CurrentVersionFile = filepath.Join(t.TempDir(), "version")
+			if err := os.WriteFile(cfg.CurrentVersionFile, []byte(tc.installed), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			// The kept version 1 extraction, whose script marks that it ran.
+			marker := filepath.Join(t.TempDir(), "rolled-back")
+			rollbackDir := filepath.Join(cfg.DownloadBaseDir, updateDirName(1))
+			if err := os.MkdirAll(rollbackDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			script := fmt.Sprintf("#!/bin/sh\necho 1 > %s\ntouch %s\n", cfg.CurrentVersionFile, marker)
+			if err := os.WriteFile(filepath.Join(rollbackDir, "update.sh"), []byte(script), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := runUpdateCycle(cfg, client, 1)
+			var mismatchErr *cstmerr.VersionMismatchError
+			if errors.As(err, &mismatchErr) != tc.wantMismatch {
+				t.Fatalf("runUpdateCycle = %v, want version mismatch %t", err, tc.wantMismatch)
+			}
+			if !tc.wantMismatch && err != nil {
+				t.Fatalf("runUpdateCycle: %v", err)
+			}
+			if _, err := os.Stat(marker); (err == nil) != tc.wantRollback {
+				t.Fatalf("rollback ran = %t, want %t", err == nil, tc.wantRollback)
+			}
+		})
+	}
+}
+
+func TestRollbackUpdateWithoutKeptExtraction(t *testing.T) {
+	cfg := &config.Config{DownloadBaseDir: t.TempDir(), UpdateScriptName: "update.sh"}
+
+	err := rollbackUpdate(cfg, 1)
+	var ioErr *cstmerr.FileIOError
+	if !errors.As(err, &ioErr) {
+		t.Fatalf("rollbackUpdate = %v, want a FileIOError", err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"embedup-go/configs/config"
+	"embedup-go/internal/shared"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureLog returns the buffer the standard logger writes to until the test ends.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(previous) })
+	return &buf
+}
+
+// writeScript writes an executable shell script with body to a temporary directory.
+func writeScript(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "update.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunUpdateScriptRedactsSecrets(t *testing.T) {
+	cfg := config.Default()
+	cfg.DBPassword = "db-pa55word"
+	cfg.DeviceToken = "device-token-5ecret"
+	cfg.Database.Password = "db-conf-pa55word"
+	cfg.BearerToken = "bearer-5ecret"
+	echo := `echo "password=$DB_PASSWORD token=` + cfg.DeviceToken + ` conf=` + cfg.Database.Password +
+		` auth=Bearer ` + cfg.BearerToken + `"` + "\n"
+
+	for name, tc := range map[string]struct {
+		body    string
+		wantErr bool
+	}{
+		"succeeds": {body: echo},
+		"fails":    {body: echo + "exit 3\n", wantErr: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logs := captureLog(t)
+			script := writeScript(t, tc.body)
+			err := runUpdateScript(cfg, script, filepath.Dir(script))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("runUpdateScript error = %v, want error %t", err, tc.wantErr)
+			}
+			out := logs.String()
+			if err != nil {
+				out += err.Error()
+			}
+			if !strings.Contains(out, "password="+shared.REDACTED) {
+				t.Fatalf("script output is missing or unmasked:\n%s", out)
+			}
+			for _, secret := range cfg.SecretValues() {
+				if secret != "" && strings.Contains(out, secret) {
+					t.Errorf("secret %q was logged:\n%s", secret, out)
+				}
+			}
+		})
+	}
+}
+
+func TestRunUpdateScriptAsUser(t *testing.T) {
+	const nobody = 65534
+	for _, tc := range []struct {
+		name             string
+		uid, gid         int
+		wantUID, wantGID int
+		needsRoot        bool
+	}{
+		{name: "unchanged", uid: -1, gid: -1, wantUID: os.Getuid(), wantGID: os.Getgid()},
+		{name: "user and group", uid: nobody, gid: nobody, wantUID: nobody, wantGID: nobody, needsRoot: true},
+		{name: "group only", uid: -1, gid: nobody, wantUID: os.Getuid(), wantGID: nobody, needsRoot: true},
+		{name: "user only", uid: nobody, gid: -1, wantUID: nobody, wantGID: nobody, needsRoot: true}, // nobody's primary group
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.needsRoot && os.Getuid() != 0 {
+				t.Skip("switching user or group needs root")
+			}
+			cfg := config.Default()
+			cfg.ScriptRunAsUID, cfg.ScriptRunAsGID = tc.uid, tc.gid
+			logs := captureLog(t)
+			script := writeScript(t, "echo \"running as $(id -u):$(id -g)\"\n")
+			// The script must be reachable by the user it runs as.
+			for dir := filepath.Dir(script); dir != os.TempDir(); dir = filepath.Dir(dir) {
+				if err := os.Chmod(dir, 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := runUpdateScript(cfg, script, filepath.Dir(script)); err != nil {
+				t.Fatalf("runUpdateScript: %v", err)
+			}
+			if want := fmt.Sprintf("running as %d:%d", tc.wantUID, tc.wantGID); !strings.Contains(logs.String(), want) {
+				t.Fatalf("script output is missing %q:\n%s", want, logs.String())
+			}
+		})
+	}
+}
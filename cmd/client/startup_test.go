@@ -0,0 +1,50 @@
+package main
+
+import (
+	"embedup-go/internal/apiclient"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// statusServer records the status reports sent to it, answering them with status.
+type statusServer struct {
+	updateServer
+	status  int
+	reports []apiclient.StatusReportPayload
+}
+
+func (s *statusServer) Put(url string, opts *apiclient.RequestOptions) (*apiclient.Response, error) {
+	s.reports = append(s.reports, opts.Body.(apiclient.StatusReportPayload))
+	return &apiclient.Response{StatusCode: s.status}, nil
+}
+
+func TestReportStartup(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		status int
+	}{
+		{name: "reported", status: http.StatusOK},
+		{name: "report rejected", status: http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := &statusServer{status: tc.status}
+			cfg, _ := newUpdateTest(t, &server.updateServer)
+			cfg.StatusReportAPIURL = "http://status.test/report"
+			cfg.CurrentVersionFile = filepath.Join(t.TempDir(), "version")
+			if err := os.WriteFile(cfg.CurrentVersionFile, []byte("7\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			version := reportStartup(cfg, apiclient.NewWithHTTPClient(cfg, "test-token", server))
+			if version != 7 {
+				t.Fatalf("version = %d, want 7", version)
+			}
+			want := apiclient.StatusReportPayload{VersionCode: 7, StatusMessage: "device online"}
+			if len(server.reports) != 1 || server.reports[0] != want {
+				t.Fatalf("reports = %+v, want only %+v", server.reports, want)
+			}
+		})
+	}
+}
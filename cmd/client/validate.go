@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"embedup-go/configs/config"
+	"embedup-go/internal/dbclient"
+	"embedup-go/internal/shared"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// VALIDATE_CONFIG_COMMAND is the subcommand that checks a config file and exits
+// without starting the service.
+const VALIDATE_CONFIG_COMMAND = "validate-config"
+
+// runValidateConfig implements the validate-config subcommand: it loads the config,
+// prints every problem found and returns the exit code. Nothing is downloaded or
+// changed; with -check-db the database is connected to once and closed again.
+func runValidateConfig(args []string, defaultConfigPath string) int {
+	fs := flag.NewFlagSet(VALIDATE_CONFIG_COMMAND, flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file to validate")
+	checkDB := fs.Bool("check-db", false, "also check that the configured database is reachable")
+	if err := fs.Parse(args); err != nil {
+		return ExitConfigError
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Printf("%s is invalid:\n", *configPath)
+		problems := []error{err}
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			problems = joined.Unwrap()
+		}
+		for _, problem := range problems {
+			fmt.Printf("  - %v\n", problem)
+		}
+		return exitCodeFor(err)
+	}
+
+	if *checkDB {
+		if err := checkDatabase(cfg); err != nil {
+			fmt.Printf("%s is valid, but the database is not reachable: %v\n", *configPath, err)
+			return exitCodeFor(err)
+		}
+	}
+	fmt.Printf("%s is valid\n", *configPath)
+	return ExitOK
+}
+
+// checkDatabase connects to the configured database once, without retrying.
+func checkDatabase(cfg *config.Config) error {
+	dbConfig := cfg.Database
+	dbConfig.DBConnectMaxRetries = 0
+	dbConfig.DBReconnectMaxRetries = 0
+	dbConfig.DBHealthCheckInterval = 0
+	dbConn, err := dbclient.NewDBClient(&dbConfig, "gorm", shared.RealClock{})
+	if err != nil {
+		return err
+	}
+	defer dbConn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := dbConn.Ping(ctx); err != nil {
+		return fmt.Errorf("database ping failed: %w", err)
+	}
+	return nil
+}
+
+// isValidateConfigCommand reports whether the process was started with the
+// validate-config subcommand.
+func isValidateConfigCommand() bool {
+	return len(os.Args) > 1 && os.Args[1] == VALIDATE_CONFIG_COMMAND
+}
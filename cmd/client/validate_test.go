@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureStdout returns what fn prints to stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = previous }()
+	out := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- string(data)
+	}()
+	fn()
+	w.Close()
+	return <-out
+}
+
+func TestRunValidateConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		toml     string
+		args     []string
+		wantCode int
+		wantOut  []string
+	}{
+		{name: "valid", wantCode: ExitOK, wantOut: []string{"is valid"}},
+		{
+			name:     "invalid timeout",
+			toml:     "http_dial_timeout = \"-1s\"\n",
+			wantCode: ExitConfigError,
+			wantOut:  []string{"is invalid", "http_dial_timeout"},
+		},
+		{
+			name:     "several problems",
+			toml:     "http_dial_timeout = \"-1s\"\nauth_scheme = \"digest\"\n",
+			wantCode: ExitConfigError,
+			wantOut:  []string{"is invalid", "  - ", "http_dial_timeout", "digest"},
+		},
+		{
+			name:     "unreachable database",
+			toml:     "[database]\ndb_host = \"127.0.0.1\"\ndb_port = 1\n",
+			args:     []string{"-check-db"},
+			wantCode: ExitDBError,
+			wantOut:  []string{"is valid, but the database is not reachable"},
+		},
+		{name: "unknown flag", args: []string{"-no-such-flag"}, wantCode: ExitConfigError},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.toml")
+			if err := os.WriteFile(configPath, []byte(tc.toml), 0644); err != nil {
+				t.Fatal(err)
+			}
+			var code int
+			out := captureStdout(t, func() {
+				code = runValidateConfig(append([]string{"-config", configPath}, tc.args...), configPath)
+			})
+			if code != tc.wantCode {
+				t.Fatalf("exit code = %d, want %d; output:\n%s", code, tc.wantCode, out)
+			}
+			for _, want := range tc.wantOut {
+				if !strings.Contains(out, want) {
+					t.Errorf("output is missing %q:\n%s", want, out)
+				}
+			}
+		})
+	}
+}
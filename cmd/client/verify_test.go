@@ -0,0 +1,193 @@
+package main
+
+import (
+	"crypto/sha256"
+	"embedup-go/configs/config"
+	"embedup-go/internal/apiclient"
+	"embedup-go/internal/cstmerr"
+	"encoding/hex"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestVerifyUpdateArchive(t *testing.T) {
+	archive := readTestdata(t, "update_v2.zip")
+	path := filepath.Join(t.TempDir(), "update_v2.zip")
+	if err := os.WriteFile(path, archive, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sum := range []string{sha256Hex(archive), strings.ToUpper(sha256Hex(archive))} {
+		if err := verifyUpdateArchive(path, sum); err != nil {
+			t.Fatalf("verifyUpdateArchive(%s): %v", sum, err)
+		}
+	}
+
+	err := verifyUpdateArchive(path, sha256Hex([]byte("another archive")))
+	var downloadErr *cstmerr.DownloadError
+	if !errors.As(err, &downloadErr) {
+		t.Fatalf("mismatch error = %v, want a DownloadError so the download is retried", err)
+	}
+
+	err = verifyUpdateArchive(filepath.Join(t.TempDir(), "missing.zip"), sha256Hex(archive))
+	var ioErr *cstmerr.FileIOError
+	if !errors.As(err, &ioErr) {
+		t.Fatalf("missing archive error = %v, want a FileIOError", err)
+	}
+}
+
+func TestRunUpdateCycleRejectsMismatchedArchive(t *testing.T) {
+	archive := readTestdata(t, "update_v2.zip")
+	server := &updateServer{
+		info:  apiclient.UpdateInfo{VersionCode: 2, FileURL: testUpdateURL, FileSHA256: sha256Hex([]byte("tampered"))},
+		files: map[string][]byte{testUpdateURL: archive},
+	}
+	cfg, client := newUpdateTest(t, server)
+
+	nextPoll, err := runUpdateCycle(cfg, client, 1)
+	var downloadErr *cstmerr.DownloadError
+	if !errors.As(err, &downloadErr) {
+		t.Fatalf("runUpdateCycle = %v, want a DownloadError", err)
+	}
+	if nextPoll != 0 {
+		t.Fatalf("next poll = %v, want the configured interval", nextPoll)
+	}
+	if _, err := os.Stat(updateArchivePath(cfg, 2)); !os.IsNotExist(err) {
+		t.Fatalf("unverified archive kept: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.DownloadBaseDir, updateDirName(2))); !os.IsNotExist(err) {
+		t.Fatalf("unverified archive extracted: %v", err)
+	}
+	if exitCodeFor(err) != ExitUpdateFailed {
+		t.Fatalf("exit code = %d, want %d", exitCodeFor(err), ExitUpdateFailed)
+	}
+}
+
+func TestReusableUpdateArchive(t *testing.T) {
+	archive := readTestdata(t, "update_v2.zip")
+	sameSize := append([]byte(nil), archive...)
+	sameSize[len(sameSize)-1] ^= 0xff
+	tests := []struct {
+		name     string
+		existing []byte
+		want     bool
+	}{
+		{"same size and hash", archive, true},
+		{"wrong size", archive[:len(archive)-1], false},
+		{"same size, wrong hash", sameSize, false},
+		{"absent", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &updateServer{
+				info:  apiclient.UpdateInfo{VersionCode: 2, FileURL: testUpdateURL, FileSHA256: sha256Hex(archive)},
+				files: map[string][]byte{testUpdateURL: archive},
+			}
+			cfg, client := newUpdateTest(t, server)
+			path := updateArchivePath(cfg, 2)
+			if tt.existing != nil {
+				if err := os.WriteFile(path, tt.existing, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := reusableUpdateArchive(client, &server.info, path); got != tt.want {
+				t.Fatalf("reusableUpdateArchive = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunUpdateCycleReusesDownloadedArchive(t *testing.T) {
+	archive := readTestdata(t, "update_v2.zip")
+	stale := archive[:len(archive)/2]
+	for _, tt := range []struct {
+		name        string
+		existing    []byte
+		wantFetched int
+	}{
+		{"matching archive is not downloaded", archive, 0},
+		{"partial archive is downloaded again", stale, 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &updateServer{
+				info:  apiclient.UpdateInfo{VersionCode: 2, FileURL: testUpdateURL, FileSHA256: sha256Hex(archive)},
+				files: map[string][]byte{testUpdateURL: archive},
+			}
+			cfg, client := newUpdateTest(t, server)
+			cfg.UpdateScriptName = "update.sh"
+			cfg.ScriptRunAsUID, cfg.ScriptRunAsGID = -1, -1
+			cfg.CurrentVersionFile = filepath.Join(t.TempDir(), "version")
+			if err := os.WriteFile(cfg.CurrentVersionFile, []byte("2"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(updateArchivePath(cfg, 2), tt.existing, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := runUpdateCycle(cfg, client, 1); err != nil {
+				t.Fatalf("runUpdateCycle: %v", err)
+			}
+			if len(server.fetched) != tt.wantFetched {
+				t.Fatalf("fetched %v, want %d download(s)", server.fetched, tt.wantFetched)
+			}
+			if got, err := os.ReadFile(updateArchivePath(cfg, 2)); err != nil || sha256Hex(got) != sha256Hex(archive) {
+				t.Fatalf("archive after the cycle is not the advertised one: %v", err)
+			}
+		})
+	}
+}
+
+func TestPrepareExtractionDirKeepsPreviousExtractionWithoutSpace(t *testing.T) {
+	archive := filepath.Join("testdata", "update_v2.zip")
+	outDir := filepath.Join(t.TempDir(), "update_2")
+	previous := filepath.Join(outDir, "update.sh")
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(previous, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{ExtractFreeSpaceCheck: true, ExtractFreeSpaceMargin: math.MaxInt64 / 2}
+
+	err := prepareExtractionDir(cfg, archive, outDir)
+	var spaceErr *cstmerr.DiskSpaceError
+	if !errors.As(err, &spaceErr) {
+		t.Fatalf("err = %v, want a DiskSpaceError", err)
+	}
+	if _, err := os.Stat(previous); err != nil {
+		t.Fatalf("previous extraction removed by a failed space check: %v", err)
+	}
+
+	cfg.ExtractFreeSpaceMargin = 0
+	if err := prepareExtractionDir(cfg, archive, outDir); err != nil {
+		t.Fatalf("prepareExtractionDir: %v", err)
+	}
+	if _, err := os.Stat(outDir); !os.IsNotExist(err) {
+		t.Fatalf("previous extraction left in place: %v", err)
+	}
+}
+
+func TestPrepareExtractionDirFailsWhenPreviousExtractionStays(t *testing.T) {
+	outDir := filepath.Join(t.TempDir(), "update_2")
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// os.RemoveAll refuses a path ending in ".", even as root, while it still stats.
+	stuck := outDir + string(filepath.Separator) + "."
+	cfg := &config.Config{ExtractFreeSpaceCheck: true}
+
+	err := prepareExtractionDir(cfg, filepath.Join("testdata", "update_v2.zip"), stuck)
+	var ioErr *cstmerr.FileIOError
+	if !errors.As(err, &ioErr) {
+		t.Fatalf("err = %v, want a FileIOError", err)
+	}
+}
@@ -3,9 +3,16 @@ package config
 import (
 	"bytes"
 	"embedup-go/internal/cstmerr"
+	"embedup-go/internal/shared"
+	"errors"
 	"fmt"
 	"log"
+	"net"
+	"net/url"
 	"os"
+	"os/user"
+	"strconv"
+	"strings"
 	"time"
 
 	// Still useful for GetCurrentVersion
@@ -22,6 +29,18 @@ type DatabaseConfig struct {
 	SSLMode      string        `mapstructure:"db_sslmode"`
 	ReadTimeout  time.Duration `mapstructure:"db_read_timeout"`  // Example advanced option
 	WriteTimeout time.Duration `mapstructure:"db_write_timeout"` // Example advanced option
+	// DBConnectMaxRetries is how many extra connection attempts are made on
+	// transient failures (e.g. Postgres still starting on boot).
+	DBConnectMaxRetries int           `mapstructure:"db_connect_max_retries"`
+	DBConnectBackoff    time.Duration `mapstructure:"db_connect_backoff"` // Initial delay, doubled after each failed attempt
+	// DBReconnectMaxRetries is how many times an operation that lost the connection
+	// (e.g. Postgres restarted mid-cycle) reconnects and retries; 0 fails it right away.
+	DBReconnectMaxRetries int `mapstructure:"db_reconnect_max_retries"`
+	// DBHealthCheckInterval is how often the connection is pinged in the background and
+	// reconnected if stale; 0 disables the check.
+	DBHealthCheckInterval time.Duration `mapstructure:"db_health_check_interval"`
+	// DBAutoMigrate migrates every content model at startup, not only the updater table.
+	DBAutoMigrate bool `mapstructure:"db_auto_migrate"`
 }
 
 // Config matches the structure of your config file and environment variables.
@@ -31,40 +50,234 @@ type Config struct {
 	CurrentVersionFile  string         `mapstructure:"current_version_file"`
 	ContentUpdateAPIURL string         `mapstructure:"content_update_api_url"`
 	ContentDetailAPIURL string         `mapstructure:"content_detail_api_url"`
+	ContentItemAPIURL   string         `mapstructure:"content_item_api_url"`  // Single content item by ID
+	ContentUpdateMethod string         `mapstructure:"content_update_method"` // "GET" (query params) or "POST" (JSON body)
+	EnabledContentTypes []string       `mapstructure:"enabled_content_types"` // Sent as the POST filter's types
 	UpdateCheckAPIURL   string         `mapstructure:"update_check_api_url"`
 	StatusReportAPIURL  string         `mapstructure:"status_report_api_url"`
 	PollIntervalSeconds uint64         `mapstructure:"poll_interval_seconds"`
 	DownloadBaseDir     string         `mapstructure:"download_base_dir"`
+	KeepUpdateVersions  int            `mapstructure:"keep_update_versions"` // Extracted update dirs kept for rollback
+	StrictVersionCheck  bool           `mapstructure:"strict_version_check"` // Fail and roll back if the version doesn't match after an update
 	DecryptionKeyHex    string         `mapstructure:"decryption_key_hex"`
 	UpdateScriptName    string         `mapstructure:"update_script_name"`
 	DBPassword          string         `mapstructure:"db_password"`
 	DeviceToken         string         `mapstructure:"device_token"`
+	DeviceSerial        string         `mapstructure:"device_serial"` // Sent as X-Device-Serial when set
+	DeviceModel         string         `mapstructure:"device_model"`  // Sent as X-Device-Model when set
 	Database            DatabaseConfig `mapstructure:"database"`
+
+	// Run the update script as this user and group instead of the updater's own; -1
+	// keeps the updater's. With only the UID set, the user's primary group is used.
+	ScriptRunAsUID int `mapstructure:"script_run_as_uid"`
+	ScriptRunAsGID int `mapstructure:"script_run_as_gid"`
+
+	MaxConcurrentDBWrites  int `mapstructure:"max_concurrent_db_writes"` // 0 disables the limit
+	MaxConcurrentDownloads int `mapstructure:"max_concurrent_downloads"` // Across all content types; 0 disables the limit
+
+	// Fsync finished downloads before exposing them, and every DownloadFsyncEveryBytes
+	// while downloading (0 only at the end), so a resume after power loss starts from
+	// bytes actually on disk. Slow on SD cards.
+	DownloadFsync           bool  `mapstructure:"download_fsync"`
+	DownloadFsyncEveryBytes int64 `mapstructure:"download_fsync_every_bytes"`
+
+	// Make finished downloads read-only, so the media app or a stray write can't
+	// corrupt a verified asset. Write permission is restored to resume, replace or
+	// delete them.
+	AssetsReadOnly bool `mapstructure:"assets_read_only"`
+
+	// Check before extracting an update or zipped movie that its uncompressed size plus
+	// ExtractFreeSpaceMargin bytes fits on the destination filesystem.
+	ExtractFreeSpaceCheck  bool  `mapstructure:"extract_free_space_check"`
+	ExtractFreeSpaceMargin int64 `mapstructure:"extract_free_space_margin"`
+
+	// Resume an interrupted extraction of the same update version or zipped movie,
+	// keeping files already extracted with the archived size and CRC-32 instead of
+	// starting over.
+	ExtractResume bool `mapstructure:"extract_resume"`
+
+	// Downloaded archives already extracted and leftover .part files are removed once
+	// older than DownloadRetentionMaxAge (0 keeps them), and the oldest of them are removed
+	// while they take more than DownloadRetentionMaxBytes in total (0 sets no cap).
+	DownloadRetentionMaxAge   time.Duration `mapstructure:"download_retention_max_age"`
+	DownloadRetentionMaxBytes int64         `mapstructure:"download_retention_max_bytes"`
+
+	// Status reports are collected for up to StatusReportBatchWindow, or until
+	// StatusReportBatchSize are queued, and sent as one request to StatusReportBatchAPIURL.
+	// A window of 0 reports each status on its own.
+	StatusReportBatchAPIURL string        `mapstructure:"status_report_batch_api_url"`
+	StatusReportBatchWindow time.Duration `mapstructure:"status_report_batch_window"`
+	StatusReportBatchSize   int           `mapstructure:"status_report_batch_size"` // 0 flushes on the window only
+
+	// StatusHistoryAPIURL returns the status reports the server has recorded for this
+	// device, a page at a time.
+	StatusHistoryAPIURL string `mapstructure:"status_history_api_url"`
+
+	// Optional server-sent events stream; each event triggers a content sync right away
+	// instead of waiting for the next poll. Polling continues as a fallback.
+	PushNotifyURL string `mapstructure:"push_notify_url"`
+
+	// Header carrying a per-sync-cycle ID on every request, for correlating this
+	// device's cycles with server logs; empty disables it.
+	RequestIDHeader string `mapstructure:"request_id_header"`
+
+	// Retries allowed across all downloads and DB reconnects of one sync cycle; once
+	// spent, failures wait for the next poll. 0 leaves each operation its own limit.
+	RetryBudgetPerCycle int `mapstructure:"retry_budget_per_cycle"`
+
+	// Bounds processing a single content item, downloads included, so one huge item
+	// can't stall the whole cycle; 0 disables it.
+	ItemProcessingTimeout time.Duration `mapstructure:"item_processing_timeout"`
+
+	// Decode GET content update responses while they are read instead of buffering
+	// them whole, bounding memory on large catch-up pages.
+	ContentUpdateStreaming bool `mapstructure:"content_update_streaming"`
+	// What to do with content items of a type this client doesn't know; one of the
+	// UNKNOWN_TYPE_POLICY_* values.
+	UnknownTypePolicy string `mapstructure:"unknown_type_policy"`
+
+	// Re-sync window: when set earlier than the stored watermark, the first fetch after
+	// startup starts at this timestamp instead, re-processing everything updated since.
+	// Each value is applied once, not on every start, and the stored watermark itself
+	// is left alone.
+	ResyncFrom int64 `mapstructure:"resync_from"`
+
+	// Directory holding the content sync state kept between cycles and restarts, such
+	// as the items waiting for their parent. Created on first use.
+	StateDir string `mapstructure:"state_dir"`
+
+	// Set items whose parent isn't stored locally yet aside until it is, instead of
+	// failing them on the foreign key. Items still waiting after PendingMaxAge are
+	// dropped and counted as abandoned; 0 keeps them until their parent arrives.
+	DependencyOrder bool          `mapstructure:"dependency_order"`
+	PendingMaxAge   time.Duration `mapstructure:"pending_max_age"`
+
+	// Remove the assets of disabled content from disk, unless other content still uses them.
+	CleanupOnDisable bool `mapstructure:"cleanup_on_disable"`
+
+	// Keep the assets of disabled content this long before deleting them, so content
+	// enabled again within it reuses them instead of downloading them again; 0 deletes
+	// them right away.
+	DeletionGracePeriod time.Duration `mapstructure:"deletion_grace_period"`
+
+	// Sync batches in catch-up mode: items are processed in priority order and progress
+	// is journaled in the state directory, so a fresh device's initial sync resumes
+	// where it stopped after a reboot.
+	CatchUpMode bool `mapstructure:"catchup_mode"`
+
+	// Sort each batch so the device becomes usable as early as possible in a large
+	// sync: enabled items by their type's position in PriorityOrder, or the built-in
+	// order when it is empty, and disabled items last. Catch-up mode always sorts.
+	PrioritySort  bool     `mapstructure:"priority_sort"`
+	PriorityOrder []string `mapstructure:"priority_order"`
+
+	// Move disabled items ahead of enabled ones in each batch, so the space they free
+	// is available to the downloads that follow. Takes precedence over the priority
+	// sort putting them last.
+	DeletesFirst bool `mapstructure:"deletes_first"`
+
+	// Control file listing content IDs, one per line, whose assets are downloaded again
+	// even if they look intact; "*" forces every item. Read once per cycle, and each ID
+	// is removed once its item is re-downloaded.
+	ForceRedownloadFile string `mapstructure:"force_redownload_file"`
+
+	// Recover a panic while processing an item, quarantining the item so the rest of
+	// the batch goes on; disable to let the panic crash the process while debugging.
+	// Quarantined items are listed in the state directory and skipped from then on.
+	RecoverPanics bool `mapstructure:"recover_panics"`
+
+	// Check that every playlist and segment an extracted movie's master playlist refers
+	// to exists before saving the movie, so a partial package is retried rather than
+	// stored broken.
+	ValidateHLS bool `mapstructure:"validate_hls"`
+
+	// Name of an extracted movie's HLS master playlist, where {dir} is the name of the
+	// HLS directory. Only used when no playlist there references variant playlists.
+	MasterPlaylistPattern string `mapstructure:"master_playlist_pattern"`
+
+	// How many of the assets saved in a cycle are read back afterwards to catch storage
+	// corruption early; 0 disables the probe.
+	ProbeSampleSize int `mapstructure:"probe_sample_size"`
+
+	// How a batch goes on after an item fails; one of the ERROR_POLICY_* values.
+	ErrorPolicy string `mapstructure:"error_policy"`
+	// Failure ratio above which a batch counts as degraded, pointing at a systemic
+	// outage (e.g. the CDN is down) rather than one bad item; 0 disables it.
+	DegradedFailureRatio float64 `mapstructure:"degraded_failure_ratio"`
+
+	// Log a warning when the content watermark is further than this behind the newest
+	// content seen on the server; 0 never warns.
+	WatermarkLagWarning time.Duration `mapstructure:"watermark_lag_warning"`
+	// Address to serve content sync metrics on in the Prometheus text format, e.g.
+	// "127.0.0.1:9100"; empty disables the endpoint.
+	MetricsAddr string `mapstructure:"metrics_addr"`
+
+	// How long to wait at startup for the DB and content API to become reachable; 0 skips the wait.
+	ReadinessTimeout time.Duration `mapstructure:"readiness_timeout"`
+
+	// Restart the ntp service at startup; disable on devices that manage time otherwise or lack sudo.
+	NTPResetEnabled bool `mapstructure:"ntp_reset_enabled"`
+
+	HTTPDebug bool `mapstructure:"http_debug"` // Dump HTTP requests and responses, with secrets redacted
+
+	// Optional Authorization header sent in addition to device-token, for gateways in front of the CMS.
+	AuthScheme   string `mapstructure:"auth_scheme"` // One of the AUTH_SCHEME_* values
+	AuthUsername string `mapstructure:"auth_username"`
+	AuthPassword string `mapstructure:"auth_password"`
+	BearerToken  string `mapstructure:"bearer_token"`
+
+	// HTTP transport timeouts, tunable for slow or flaky links.
+	HTTPDialTimeout           time.Duration `mapstructure:"http_dial_timeout"`
+	HTTPIdleConnTimeout       time.Duration `mapstructure:"http_idle_conn_timeout"`
+	HTTPTLSHandshakeTimeout   time.Duration `mapstructure:"http_tls_handshake_timeout"`
+	HTTPResponseHeaderTimeout time.Duration `mapstructure:"http_response_header_timeout"` // 0 waits indefinitely
+
+	// Hosts whose TLS certificates are not verified, e.g. an internal asset server with a
+	// self-signed certificate. Bare host names or IPs, without scheme or port.
+	InsecureHosts []string `mapstructure:"insecure_hosts"`
+
+	// Redirect policy, so a redirect can't carry device-token/Authorization to another host.
+	HTTPMaxRedirects                 int  `mapstructure:"http_max_redirects"` // 0 disables redirects
+	HTTPStripAuthOnCrossHostRedirect bool `mapstructure:"http_strip_auth_on_cross_host_redirect"`
+
+	// Per-endpoint request timeouts, each covering the whole request-response cycle.
+	UpdateCheckTimeout   time.Duration `mapstructure:"update_check_timeout"`
+	ContentUpdateTimeout time.Duration `mapstructure:"content_update_timeout"`
+	StatusReportTimeout  time.Duration `mapstructure:"status_report_timeout"`
+
+	// Client-wide ceiling for requests without a per-endpoint timeout; must exceed all of
+	// them. Streamed downloads are not bounded by it. 0 disables it.
+	HTTPGlobalTimeout time.Duration `mapstructure:"http_global_timeout"`
 }
 
+const (
+	AUTH_SCHEME_NONE   = "none"
+	AUTH_SCHEME_BASIC  = "basic"
+	AUTH_SCHEME_BEARER = "bearer"
+)
+
+const (
+	UNKNOWN_TYPE_POLICY_SKIP       = "skip"       // Log and skip the item
+	UNKNOWN_TYPE_POLICY_ERROR      = "error"      // Fail the batch so the drift gets investigated
+	UNKNOWN_TYPE_POLICY_QUARANTINE = "quarantine" // Quarantine the item and move past it
+)
+
+const (
+	// Process the rest of the batch; the watermark stays before the first failed item
+	// so it is fetched again. The default, so one bad item doesn't hold back the rest
+	// on a device nobody is watching.
+	ERROR_POLICY_BEST_EFFORT = "best-effort"
+	ERROR_POLICY_FAIL_FAST   = "fail-fast" // Stop the batch at the first failed item
+)
+
+// maxHTTPTimeout is the upper bound accepted for any HTTP transport timeout.
+const maxHTTPTimeout = 10 * time.Minute
+
 // Load reads the configuration using Viper.
 // It will look for a config file (e.g., config.toml) in specified paths
 // and can also read from environment variables.
 func Load(configPath string) (*Config, error) {
-	v := viper.New()
-
-	// Set default values for database config
-	v.SetDefault("database.db_host", "localhost")
-	v.SetDefault("database.db_port", 5432)
-	v.SetDefault("database.db_user", "postgres")
-	v.SetDefault("database.db_name", "podbox")
-	v.SetDefault("database.db_sslmode", "disable") // Common default for local dev
-	v.SetDefault("database.db_read_timeout", "5s")
-	v.SetDefault("database.db_write_timeout", "5s")
-
-	// Set default values (optional, but good practice)
-	v.SetDefault("service_name", "PodboxUpdateService")
-	v.SetDefault("current_version_file", "/etc/podbox_update/version.txt")
-	v.SetDefault("update_check_api_url", "https://localhost:8080/check_update")
-	v.SetDefault("status_report_api_url", "https://localhost:8080/report_status")
-	v.SetDefault("poll_interval_seconds", 300)
-	v.SetDefault("download_base_dir", "/opt/updater_downloads")
-	v.SetDefault("update_script_name", "update.sh")
+	v := newViper()
 
 	if configPath != "" {
 		v.SetConfigFile(configPath)
@@ -89,15 +302,458 @@ func Load(configPath string) (*Config, error) {
 		}
 	}
 
+	if err := loadSecretFiles(v); err != nil {
+		return nil, err
+	}
+
 	var config Config
 	if err := v.Unmarshal(&config); err != nil {
 		return nil, cstmerr.NewConfigError("failed to unmarshal config", err)
 	}
 
-	log.Printf("Configuration loaded. Service Name: %s, Update URL: %s", config.ServiceName, config.UpdateCheckAPIURL)
+	if err := Validate(&config); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Configuration loaded. Service Name: %s, Update URL: %s",
+		config.ServiceName, shared.RedactSecrets(shared.RedactURL(config.UpdateCheckAPIURL), config.SecretValues()...))
 	return &config, nil
 }
 
+// Default returns the configuration made of the default values alone, for code that
+// runs without a loaded configuration, such as tests.
+func Default() *Config {
+	var config Config
+	if err := newViper().Unmarshal(&config); err != nil {
+		panic(fmt.Sprintf("invalid default configuration: %v", err))
+	}
+	return &config
+}
+
+// newViper returns a Viper instance with every default value set.
+func newViper() *viper.Viper {
+	v := viper.New()
+
+	// Set default values for database config
+	v.SetDefault("database.db_host", "localhost")
+	v.SetDefault("database.db_port", 5432)
+	v.SetDefault("database.db_user", "postgres")
+	v.SetDefault("database.db_name", "podbox")
+	v.SetDefault("database.db_sslmode", "disable") // Common default for local dev
+	v.SetDefault("database.db_read_timeout", "5s")
+	v.SetDefault("database.db_write_timeout", "5s")
+	v.SetDefault("database.db_connect_max_retries", 5)
+	v.SetDefault("database.db_connect_backoff", "2s")
+	v.SetDefault("database.db_reconnect_max_retries", 3)
+	v.SetDefault("database.db_health_check_interval", "1m")
+	v.SetDefault("database.db_auto_migrate", false)
+
+	// Set default values (optional, but good practice)
+	v.SetDefault("service_name", "PodboxUpdateService")
+	v.SetDefault("current_version_file", "/etc/podbox_update/version.txt")
+	v.SetDefault("update_check_api_url", "https://localhost:8080/check_update")
+	v.SetDefault("status_report_api_url", "https://localhost:8080/report_status")
+	v.SetDefault("poll_interval_seconds", 300)
+	v.SetDefault("download_base_dir", "/opt/updater_downloads")
+	v.SetDefault("update_script_name", "update.sh")
+	v.SetDefault("script_run_as_uid", -1)
+	v.SetDefault("script_run_as_gid", -1)
+	v.SetDefault("keep_update_versions", 2)
+	v.SetDefault("download_retention_max_age", "168h")
+	v.SetDefault("download_retention_max_bytes", 0)
+	v.SetDefault("strict_version_check", true)
+	v.SetDefault("content_update_method", "GET")
+	v.SetDefault("unknown_type_policy", UNKNOWN_TYPE_POLICY_SKIP)
+	v.SetDefault("resync_from", 0)
+	v.SetDefault("request_id_header", "X-Request-Id")
+	v.SetDefault("retry_budget_per_cycle", 100)
+	v.SetDefault("auth_scheme", AUTH_SCHEME_NONE)
+	v.SetDefault("max_concurrent_db_writes", 2)
+	v.SetDefault("max_concurrent_downloads", 4)
+	v.SetDefault("download_fsync", true)
+	v.SetDefault("download_fsync_every_bytes", 0)
+	v.SetDefault("assets_read_only", false)
+	v.SetDefault("extract_free_space_check", true)
+	v.SetDefault("extract_free_space_margin", 64<<20)
+	v.SetDefault("extract_resume", false)
+	v.SetDefault("readiness_timeout", "2m")
+	v.SetDefault("ntp_reset_enabled", true)
+	v.SetDefault("http_dial_timeout", "30s")
+	v.SetDefault("http_idle_conn_timeout", "30s")
+	v.SetDefault("http_tls_handshake_timeout", "60s")
+	v.SetDefault("http_response_header_timeout", "0s")
+	v.SetDefault("http_max_redirects", 10)
+	v.SetDefault("http_strip_auth_on_cross_host_redirect", true)
+	v.SetDefault("update_check_timeout", "30s")
+	v.SetDefault("content_update_timeout", "120s")
+	v.SetDefault("status_report_timeout", "15s")
+	v.SetDefault("http_global_timeout", "5m")
+	v.SetDefault("status_report_batch_window", "0s")
+	v.SetDefault("status_report_batch_size", 20)
+	v.SetDefault("item_processing_timeout", "30m")
+	v.SetDefault("state_dir", "/var/lib/podbox_update")
+	v.SetDefault("dependency_order", false)
+	v.SetDefault("pending_max_age", "168h")
+	v.SetDefault("cleanup_on_disable", true)
+	v.SetDefault("deletion_grace_period", "0s")
+	v.SetDefault("catchup_mode", false)
+	v.SetDefault("priority_sort", false)
+	v.SetDefault("deletes_first", false)
+	v.SetDefault("force_redownload_file", "/etc/podbox_update/force_redownload")
+	v.SetDefault("recover_panics", true)
+	v.SetDefault("validate_hls", true)
+	v.SetDefault("master_playlist_pattern", "master_{dir}.m3u8")
+	v.SetDefault("probe_sample_size", 0)
+	v.SetDefault("error_policy", ERROR_POLICY_BEST_EFFORT)
+	v.SetDefault("degraded_failure_ratio", 0.5)
+	v.SetDefault("watermark_lag_warning", "0s")
+	v.SetDefault("metrics_addr", "")
+	return v
+}
+
+// Validate checks cfg, normalizing the values it accepts in more than one spelling,
+// and returns every problem found, each a ConfigError, joined into one error.
+func Validate(cfg *Config) error {
+	var problems []error
+
+	if err := validateHTTPTimeouts(cfg); err != nil {
+		problems = append(problems, err)
+	}
+
+	if err := validateGlobalTimeout(cfg); err != nil {
+		problems = append(problems, err)
+	}
+
+	if err := validateAuth(cfg); err != nil {
+		problems = append(problems, err)
+	}
+
+	if err := validateScriptCredential(cfg); err != nil {
+		problems = append(problems, err)
+	}
+
+	if err := validateInsecureHosts(cfg); err != nil {
+		problems = append(problems, err)
+	}
+
+	if cfg.MaxConcurrentDownloads < 0 {
+		problems = append(problems, cstmerr.NewConfigError(
+			fmt.Sprintf("invalid max_concurrent_downloads %d, must not be negative", cfg.MaxConcurrentDownloads), nil))
+	}
+
+	if cfg.DownloadFsyncEveryBytes < 0 {
+		problems = append(problems, cstmerr.NewConfigError(
+			fmt.Sprintf("invalid download_fsync_every_bytes %d, must not be negative", cfg.DownloadFsyncEveryBytes), nil))
+	}
+
+	if cfg.ExtractFreeSpaceMargin < 0 {
+		problems = append(problems, cstmerr.NewConfigError(
+			fmt.Sprintf("invalid extract_free_space_margin %d, must not be negative", cfg.ExtractFreeSpaceMargin), nil))
+	}
+
+	if cfg.DownloadRetentionMaxAge < 0 || cfg.DownloadRetentionMaxBytes < 0 {
+		problems = append(problems, cstmerr.NewConfigError(
+			"invalid download_retention_max_age or download_retention_max_bytes, must not be negative", nil))
+	}
+
+	if cfg.ResyncFrom < 0 {
+		problems = append(problems, cstmerr.NewConfigError(
+			fmt.Sprintf("invalid resync_from %d, must not be negative", cfg.ResyncFrom), nil))
+	}
+
+	if cfg.RetryBudgetPerCycle < 0 {
+		problems = append(problems, cstmerr.NewConfigError(
+			fmt.Sprintf("invalid retry_budget_per_cycle %d, must not be negative", cfg.RetryBudgetPerCycle), nil))
+	}
+
+	if cfg.ItemProcessingTimeout < 0 {
+		problems = append(problems, cstmerr.NewConfigError(
+			fmt.Sprintf("invalid item_processing_timeout %s, must not be negative", cfg.ItemProcessingTimeout), nil))
+	}
+
+	if cfg.StateDir == "" {
+		problems = append(problems, cstmerr.NewConfigError("state_dir must be set", nil))
+	}
+
+	if cfg.PendingMaxAge < 0 {
+		problems = append(problems, cstmerr.NewConfigError(
+			fmt.Sprintf("invalid pending_max_age %s, must not be negative", cfg.PendingMaxAge), nil))
+	}
+
+	if cfg.DeletionGracePeriod < 0 {
+		problems = append(problems, cstmerr.NewConfigError(
+			fmt.Sprintf("invalid deletion_grace_period %s, must not be negative", cfg.DeletionGracePeriod), nil))
+	}
+
+	if cfg.HTTPMaxRedirects < 0 {
+		problems = append(problems, cstmerr.NewConfigError(
+			fmt.Sprintf("invalid http_max_redirects %d, must not be negative", cfg.HTTPMaxRedirects), nil))
+	}
+
+	if err := validateStatusReportBatching(cfg); err != nil {
+		problems = append(problems, err)
+	}
+
+	cfg.UnknownTypePolicy = strings.ToLower(cfg.UnknownTypePolicy)
+	switch cfg.UnknownTypePolicy {
+	case UNKNOWN_TYPE_POLICY_SKIP, UNKNOWN_TYPE_POLICY_ERROR, UNKNOWN_TYPE_POLICY_QUARANTINE:
+	default:
+		problems = append(problems, cstmerr.NewConfigError(
+			fmt.Sprintf("invalid unknown_type_policy %q, must be skip, error or quarantine", cfg.UnknownTypePolicy), nil))
+	}
+
+	if cfg.ForceRedownloadFile == "" {
+		problems = append(problems, cstmerr.NewConfigError("force_redownload_file must be set", nil))
+	}
+
+	if cfg.WatermarkLagWarning < 0 {
+		problems = append(problems, cstmerr.NewConfigError(
+			fmt.Sprintf("invalid watermark_lag_warning %s, must not be negative", cfg.WatermarkLagWarning), nil))
+	}
+
+	if cfg.MasterPlaylistPattern == "" {
+		problems = append(problems, cstmerr.NewConfigError("master_playlist_pattern must be set", nil))
+	}
+
+	if cfg.ProbeSampleSize < 0 {
+		problems = append(problems, cstmerr.NewConfigError(
+			fmt.Sprintf("invalid probe_sample_size %d, must not be negative", cfg.ProbeSampleSize), nil))
+	}
+
+	for _, contentType := range cfg.PriorityOrder {
+		if strings.TrimSpace(contentType) == "" {
+			problems = append(problems, cstmerr.NewConfigError("priority_order entries must not be empty", nil))
+			break
+		}
+	}
+
+	cfg.ErrorPolicy = strings.ToLower(cfg.ErrorPolicy)
+	switch cfg.ErrorPolicy {
+	case ERROR_POLICY_BEST_EFFORT, ERROR_POLICY_FAIL_FAST:
+	default:
+		problems = append(problems, cstmerr.NewConfigError(
+			fmt.Sprintf("invalid error_policy %q, must be best-effort or fail-fast", cfg.ErrorPolicy), nil))
+	}
+
+	if cfg.DegradedFailureRatio < 0 || cfg.DegradedFailureRatio > 1 {
+		problems = append(problems, cstmerr.NewConfigError(
+			fmt.Sprintf("invalid degraded_failure_ratio %g, must be between 0 and 1", cfg.DegradedFailureRatio), nil))
+	}
+
+	cfg.ContentUpdateMethod = strings.ToUpper(cfg.ContentUpdateMethod)
+	if cfg.ContentUpdateMethod != "GET" && cfg.ContentUpdateMethod != "POST" {
+		problems = append(problems, cstmerr.NewConfigError(
+			fmt.Sprintf("invalid content_update_method %q, must be GET or POST", cfg.ContentUpdateMethod), nil))
+	}
+
+	problems = append(problems, validateURLs(cfg)...)
+	return errors.Join(problems...)
+}
+
+// validateURLs checks that every endpoint URL that is set is an absolute http(s) URL.
+func validateURLs(cfg *Config) []error {
+	urls := []struct{ key, value string }{
+		{"content_update_api_url", cfg.ContentUpdateAPIURL},
+		{"content_detail_api_url", cfg.ContentDetailAPIURL},
+		{"content_item_api_url", cfg.ContentItemAPIURL},
+		{"update_check_api_url", cfg.UpdateCheckAPIURL},
+		{"status_report_api_url", cfg.StatusReportAPIURL},
+		{"status_report_batch_api_url", cfg.StatusReportBatchAPIURL},
+		{"status_history_api_url", cfg.StatusHistoryAPIURL},
+		{"push_notify_url", cfg.PushNotifyURL},
+	}
+	var problems []error
+	for _, u := range urls {
+		if u.value == "" {
+			continue
+		}
+		parsed, err := url.Parse(u.value)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			problems = append(problems, cstmerr.NewConfigError(
+				fmt.Sprintf("invalid %s %q, must be an absolute http or https URL", u.key, shared.RedactURL(u.value)), err))
+		}
+	}
+	return problems
+}
+
+// secretKeys maps each secret config key to the key naming a file it can be read
+// from instead, so the main config file can be kept free of secrets.
+var secretKeys = []struct {
+	key     string
+	fileKey string
+}{
+	{"db_password", "db_password_file"},
+	{"device_token", "device_token_file"},
+	{"database.db_password_conf", "database.db_password_conf_file"},
+	{"auth_password", "auth_password_file"},
+	{"bearer_token", "bearer_token_file"},
+}
+
+// loadSecretFiles injects the contents of every configured secret file into v, and
+// warns about secrets written in plaintext in the config file itself. A secret file
+// takes precedence over a plaintext value; surrounding whitespace is trimmed.
+func loadSecretFiles(v *viper.Viper) error {
+	for _, s := range secretKeys {
+		if v.InConfig(s.key) && v.GetString(s.key) != "" {
+			log.Printf("Warning: %s is stored in plaintext in the config file, consider %s instead", s.key, s.fileKey)
+		}
+		path := v.GetString(s.fileKey)
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cstmerr.NewFileIOError(fmt.Sprintf("failed to read %s %s", s.fileKey, path), err)
+		}
+		v.Set(s.key, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
+
+// validateHTTPTimeouts rejects HTTP transport timeouts outside of a sane range.
+// All timeouts must be positive, except the response header timeout which may be
+// 0 to wait indefinitely.
+func validateHTTPTimeouts(cfg *Config) error {
+	timeouts := []struct {
+		name      string
+		value     time.Duration
+		allowZero bool
+	}{
+		{"http_dial_timeout", cfg.HTTPDialTimeout, false},
+		{"http_idle_conn_timeout", cfg.HTTPIdleConnTimeout, false},
+		{"http_tls_handshake_timeout", cfg.HTTPTLSHandshakeTimeout, false},
+		{"http_response_header_timeout", cfg.HTTPResponseHeaderTimeout, true},
+	}
+	for _, t := range timeouts {
+		if t.value < 0 || (t.value == 0 && !t.allowZero) || t.value > maxHTTPTimeout {
+			return cstmerr.NewConfigError(
+				fmt.Sprintf("invalid %s %s, must be within (0, %s]", t.name, t.value, maxHTTPTimeout), nil)
+		}
+	}
+	return nil
+}
+
+// validateGlobalTimeout checks that the client-wide HTTP timeout, if set, is longer
+// than every per-endpoint timeout, so it only ever acts as a safety net.
+func validateGlobalTimeout(cfg *Config) error {
+	if cfg.HTTPGlobalTimeout < 0 {
+		return cstmerr.NewConfigError(
+			fmt.Sprintf("invalid http_global_timeout %s, must not be negative", cfg.HTTPGlobalTimeout), nil)
+	}
+	if cfg.HTTPGlobalTimeout == 0 {
+		return nil
+	}
+	endpointTimeouts := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"update_check_timeout", cfg.UpdateCheckTimeout},
+		{"content_update_timeout", cfg.ContentUpdateTimeout},
+		{"status_report_timeout", cfg.StatusReportTimeout},
+	}
+	for _, t := range endpointTimeouts {
+		if cfg.HTTPGlobalTimeout <= t.value {
+			return cstmerr.NewConfigError(
+				fmt.Sprintf("invalid http_global_timeout %s, must be larger than %s %s",
+					cfg.HTTPGlobalTimeout, t.name, t.value), nil)
+		}
+	}
+	return nil
+}
+
+// validateStatusReportBatching rejects negative batching limits and a window without an endpoint.
+func validateStatusReportBatching(cfg *Config) error {
+	if cfg.StatusReportBatchWindow < 0 {
+		return cstmerr.NewConfigError(
+			fmt.Sprintf("invalid status_report_batch_window %s, must not be negative", cfg.StatusReportBatchWindow), nil)
+	}
+	if cfg.StatusReportBatchSize < 0 {
+		return cstmerr.NewConfigError(
+			fmt.Sprintf("invalid status_report_batch_size %d, must not be negative", cfg.StatusReportBatchSize), nil)
+	}
+	if cfg.StatusReportBatchWindow > 0 && cfg.StatusReportBatchAPIURL == "" {
+		return cstmerr.NewConfigError("status_report_batch_window requires status_report_batch_api_url", nil)
+	}
+	return nil
+}
+
+// validateScriptCredential checks that the user and group the update script runs as
+// exist, filling in the user's primary group when only the UID is set.
+func validateScriptCredential(cfg *Config) error {
+	if cfg.ScriptRunAsUID >= 0 {
+		u, err := user.LookupId(strconv.Itoa(cfg.ScriptRunAsUID))
+		if err != nil {
+			return cstmerr.NewConfigError(
+				fmt.Sprintf("invalid script_run_as_uid %d, no such user", cfg.ScriptRunAsUID), err)
+		}
+		if cfg.ScriptRunAsGID < 0 {
+			gid, err := strconv.Atoi(u.Gid)
+			if err != nil {
+				return cstmerr.NewConfigError(
+					fmt.Sprintf("invalid primary group %q of script_run_as_uid %d", u.Gid, cfg.ScriptRunAsUID), err)
+			}
+			cfg.ScriptRunAsGID = gid
+		}
+	}
+	if cfg.ScriptRunAsGID >= 0 {
+		if _, err := user.LookupGroupId(strconv.Itoa(cfg.ScriptRunAsGID)); err != nil {
+			return cstmerr.NewConfigError(
+				fmt.Sprintf("invalid script_run_as_gid %d, no such group", cfg.ScriptRunAsGID), err)
+		}
+	}
+	return nil
+}
+
+// validateInsecureHosts checks that every insecure_hosts entry is a bare host name or
+// IP, since it is matched against the TLS server name.
+func validateInsecureHosts(cfg *Config) error {
+	for _, host := range cfg.InsecureHosts {
+		if host == "" || (strings.ContainsAny(host, "/:@ ") && net.ParseIP(host) == nil) {
+			return cstmerr.NewConfigError(
+				fmt.Sprintf("invalid insecure_hosts entry %q, must be a bare host name or IP", host), nil)
+		}
+	}
+	if len(cfg.InsecureHosts) > 0 {
+		log.Printf("Warning: TLS certificates are not verified for %s", strings.Join(cfg.InsecureHosts, ", "))
+	}
+	return nil
+}
+
+// validateAuth checks that exactly the credentials required by the auth scheme are set.
+func validateAuth(cfg *Config) error {
+	cfg.AuthScheme = strings.ToLower(cfg.AuthScheme)
+	hasBasic := cfg.AuthUsername != "" || cfg.AuthPassword != ""
+	hasBearer := cfg.BearerToken != ""
+	switch cfg.AuthScheme {
+	case AUTH_SCHEME_NONE:
+		if hasBasic || hasBearer {
+			return cstmerr.NewConfigError("auth credentials are set but auth_scheme is none", nil)
+		}
+	case AUTH_SCHEME_BASIC:
+		if cfg.AuthUsername == "" || cfg.AuthPassword == "" {
+			return cstmerr.NewConfigError("auth_scheme basic requires auth_username and auth_password", nil)
+		}
+		if hasBearer {
+			return cstmerr.NewConfigError("bearer_token cannot be used with auth_scheme basic", nil)
+		}
+	case AUTH_SCHEME_BEARER:
+		if !hasBearer {
+			return cstmerr.NewConfigError("auth_scheme bearer requires bearer_token", nil)
+		}
+		if hasBasic {
+			return cstmerr.NewConfigError("auth_username/auth_password cannot be used with auth_scheme bearer", nil)
+		}
+	default:
+		return cstmerr.NewConfigError(fmt.Sprintf("invalid auth_scheme %q, must be none, basic or bearer", cfg.AuthScheme), nil)
+	}
+	return nil
+}
+
+// SecretValues returns the configured secrets that must never appear in logs.
+func (c *Config) SecretValues() []string {
+	return []string{c.DBPassword, c.DeviceToken, c.Database.Password, c.AuthPassword, c.BearerToken}
+}
+
 // GetCurrentVersion reads the current version from the file specified in the config.
 // This function remains largely the same as it's reading a dynamic version file,
 // not a static config value typically handled by Viper at startup.
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"embedup-go/internal/cstmerr"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDefaultIsValid(t *testing.T) {
+	cfg := Default()
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("Validate(Default()): %v", err)
+	}
+	if cfg.ItemProcessingTimeout != 30*time.Minute {
+		t.Fatalf("item_processing_timeout = %v, want 30m", cfg.ItemProcessingTimeout)
+	}
+	if !cfg.RecoverPanics {
+		t.Fatal("recover_panics is off by default")
+	}
+	if !cfg.ValidateHLS {
+		t.Fatal("validate_hls is off by default")
+	}
+}
+
+func TestValidateRejectsNegativeItemProcessingTimeout(t *testing.T) {
+	cfg := Default()
+	cfg.ItemProcessingTimeout = -time.Second
+	var configErr *cstmerr.ConfigError
+	if err := Validate(cfg); !errors.As(err, &configErr) {
+		t.Fatalf("Validate error = %v, want a ConfigError", err)
+	}
+}
+
+func TestValidateRequiresStateDir(t *testing.T) {
+	cfg := Default()
+	cfg.StateDir = ""
+	var configErr *cstmerr.ConfigError
+	if err := Validate(cfg); !errors.As(err, &configErr) {
+		t.Fatalf("Validate error = %v, want a ConfigError", err)
+	}
+}
+
+func TestValidateRejectsNegativePendingMaxAge(t *testing.T) {
+	cfg := Default()
+	cfg.PendingMaxAge = -time.Hour
+	var configErr *cstmerr.ConfigError
+	if err := Validate(cfg); !errors.As(err, &configErr) {
+		t.Fatalf("Validate error = %v, want a ConfigError", err)
+	}
+}
+
+func TestValidateRejectsNegativeDeletionGracePeriod(t *testing.T) {
+	cfg := Default()
+	cfg.DeletionGracePeriod = -time.Hour
+	var configErr *cstmerr.ConfigError
+	if err := Validate(cfg); !errors.As(err, &configErr) {
+		t.Fatalf("Validate error = %v, want a ConfigError", err)
+	}
+}
+
+func TestValidateErrorPolicy(t *testing.T) {
+	cfg := Default()
+	cfg.ErrorPolicy = "Fail-Fast"
+	if err := Validate(cfg); err != nil || cfg.ErrorPolicy != ERROR_POLICY_FAIL_FAST {
+		t.Fatalf("Validate = %v, error_policy = %q; want it normalized to %q", err, cfg.ErrorPolicy, ERROR_POLICY_FAIL_FAST)
+	}
+	cfg.ErrorPolicy = "retry"
+	var configErr *cstmerr.ConfigError
+	if err := Validate(cfg); !errors.As(err, &configErr) {
+		t.Fatalf("Validate error = %v, want a ConfigError", err)
+	}
+}
+
+func TestValidateRejectsDegradedFailureRatioOutOfRange(t *testing.T) {
+	for _, ratio := range []float64{-0.1, 1.5} {
+		cfg := Default()
+		cfg.DegradedFailureRatio = ratio
+		var configErr *cstmerr.ConfigError
+		if err := Validate(cfg); !errors.As(err, &configErr) {
+			t.Fatalf("Validate error at %g = %v, want a ConfigError", ratio, err)
+		}
+	}
+}
+
+func TestValidateRejectsEmptyPriorityOrderEntry(t *testing.T) {
+	cfg := Default()
+	cfg.PriorityOrder = []string{"local-movie", " "}
+	var configErr *cstmerr.ConfigError
+	if err := Validate(cfg); !errors.As(err, &configErr) {
+		t.Fatalf("Validate error = %v, want a ConfigError", err)
+	}
+}
+
+func TestValidateRejectsNegativeProbeSampleSize(t *testing.T) {
+	cfg := Default()
+	cfg.ProbeSampleSize = -1
+	var configErr *cstmerr.ConfigError
+	if err := Validate(cfg); !errors.As(err, &configErr) {
+		t.Fatalf("Validate error = %v, want a ConfigError", err)
+	}
+}
+
+func TestValidateRequiresMasterPlaylistPattern(t *testing.T) {
+	cfg := Default()
+	cfg.MasterPlaylistPattern = ""
+	var configErr *cstmerr.ConfigError
+	if err := Validate(cfg); !errors.As(err, &configErr) {
+		t.Fatalf("Validate error = %v, want a ConfigError", err)
+	}
+}
+
+func TestValidateRejectsNegativeWatermarkLagWarning(t *testing.T) {
+	cfg := Default()
+	cfg.WatermarkLagWarning = -time.Minute
+	var configErr *cstmerr.ConfigError
+	if err := Validate(cfg); !errors.As(err, &configErr) {
+		t.Fatalf("Validate error = %v, want a ConfigError", err)
+	}
+}
+
+func TestValidateRequiresForceRedownloadFile(t *testing.T) {
+	cfg := Default()
+	cfg.ForceRedownloadFile = ""
+	var configErr *cstmerr.ConfigError
+	if err := Validate(cfg); !errors.As(err, &configErr) {
+		t.Fatalf("Validate error = %v, want a ConfigError", err)
+	}
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"embedup-go/internal/shared"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// dumpSecretKeys are the keys whose values Dump masks: the secrets that can be read
+// from files, and the update decryption key.
+func dumpSecretKeys() map[string]bool {
+	keys := map[string]bool{"decryption_key_hex": true}
+	for _, s := range secretKeys {
+		keys[s.key] = true
+	}
+	return keys
+}
+
+// Dump renders the effective config as one "key = value" line per setting, in the
+// order of the Config struct, for diagnostics. Secrets are masked, and so are
+// passwords embedded in URLs.
+func Dump(cfg *Config) string {
+	var b strings.Builder
+	dumpStruct(&b, reflect.ValueOf(cfg).Elem(), "", dumpSecretKeys())
+	return b.String()
+}
+
+func dumpStruct(b *strings.Builder, v reflect.Value, prefix string, secrets map[string]bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("mapstructure")
+		if name == "" || name == "-" || !field.IsExported() {
+			continue
+		}
+		key := prefix + name
+		value := v.Field(i)
+		if value.Kind() == reflect.Struct && value.Type().PkgPath() == t.PkgPath() {
+			dumpStruct(b, value, key+".", secrets)
+			continue
+		}
+		fmt.Fprintf(b, "%s = %s\n", key, dumpValue(value, secrets[key]))
+	}
+}
+
+func dumpValue(value reflect.Value, secret bool) string {
+	switch {
+	case secret && !value.IsZero():
+		return fmt.Sprintf("%q", shared.REDACTED)
+	case value.Kind() == reflect.String:
+		return fmt.Sprintf("%q", shared.RedactURL(value.String()))
+	case value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.String:
+		return fmt.Sprintf("%q", value.Interface())
+	}
+	return fmt.Sprintf("%v", value.Interface())
+}
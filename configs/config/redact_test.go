@@ -0,0 +1,52 @@
+package config
+
+import (
+	"bytes"
+	"embedup-go/internal/shared"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadLogsNoSecrets(t *testing.T) {
+	const (
+		dbPassword  = "db-pa55word"
+		dbConfPass  = "db-conf-pa55word"
+		deviceToken = "device-token-5ecret"
+		urlPassword = "url-pa55word"
+	)
+	path := filepath.Join(t.TempDir(), "config.toml")
+	toml := `db_password = "` + dbPassword + `"
+device_token = "` + deviceToken + `"
+update_check_api_url = "https://device:` + urlPassword + `@updates.test/check?token=` + deviceToken + `&db=` + dbPassword + `"
+
+[database]
+db_password_conf = "` + dbConfPass + `"
+`
+	if err := os.WriteFile(path, []byte(toml), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var logs bytes.Buffer
+	previous := log.Writer()
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(previous) })
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.DBPassword != dbPassword || cfg.DeviceToken != deviceToken || cfg.Database.Password != dbConfPass {
+		t.Fatalf("secrets were not loaded: %+v", cfg)
+	}
+	out := logs.String()
+	if !strings.Contains(out, "Configuration loaded") || !strings.Contains(out, shared.REDACTED) {
+		t.Fatalf("load line is missing or unmasked:\n%s", out)
+	}
+	for _, secret := range []string{dbPassword, dbConfPass, deviceToken, urlPassword} {
+		if strings.Contains(out, secret) {
+			t.Errorf("secret %q was logged:\n%s", secret, out)
+		}
+	}
+}
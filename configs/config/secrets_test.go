@@ -0,0 +1,101 @@
+package config
+
+import (
+	"bytes"
+	"embedup-go/internal/cstmerr"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadReadsSecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	path := filepath.Join(dir, "config.toml")
+	toml := `db_password = "plaintext-pa55word"
+db_password_file = "` + secretFile("db_password", "db-pa55word\n") + `"
+device_token_file = "` + secretFile("device_token", "  device-token-5ecret\n\n") + `"
+auth_scheme = "basic"
+auth_username = "device"
+auth_password_file = "` + secretFile("auth_password", "auth-pa55word\r\n") + `"
+
+[database]
+db_password_conf_file = "` + secretFile("db_password_conf", "db-conf-pa55word\n") + `"
+`
+	if err := os.WriteFile(path, []byte(toml), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var logs bytes.Buffer
+	previous := log.Writer()
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(previous) })
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	for name, got := range map[string][2]string{
+		"DBPassword":        {cfg.DBPassword, "db-pa55word"},
+		"DeviceToken":       {cfg.DeviceToken, "device-token-5ecret"},
+		"Database.Password": {cfg.Database.Password, "db-conf-pa55word"},
+		"AuthPassword":      {cfg.AuthPassword, "auth-pa55word"},
+	} {
+		if got[0] != got[1] {
+			t.Errorf("%s = %q, want %q", name, got[0], got[1])
+		}
+	}
+	out := logs.String()
+	if !strings.Contains(out, "db_password is stored in plaintext") {
+		t.Errorf("no plaintext warning for db_password:\n%s", out)
+	}
+	if strings.Contains(out, "device_token is stored in plaintext") {
+		t.Errorf("plaintext warning for a secret read from a file:\n%s", out)
+	}
+}
+
+func TestLoadReadsBearerTokenFile(t *testing.T) {
+	dir := t.TempDir()
+	tokenPath := filepath.Join(dir, "bearer_token")
+	if err := os.WriteFile(tokenPath, []byte("bearer-5ecret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.toml")
+	toml := `auth_scheme = "bearer"
+bearer_token_file = "` + tokenPath + `"
+`
+	if err := os.WriteFile(path, []byte(toml), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.BearerToken != "bearer-5ecret" {
+		t.Fatalf("BearerToken = %q, want %q", cfg.BearerToken, "bearer-5ecret")
+	}
+}
+
+func TestLoadMissingSecretFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	toml := `device_token_file = "` + filepath.Join(t.TempDir(), "missing") + `"
+`
+	if err := os.WriteFile(path, []byte(toml), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Load(path)
+	var ioErr *cstmerr.FileIOError
+	if !errors.As(err, &ioErr) {
+		t.Fatalf("Load = %v, want a FileIOError", err)
+	}
+}
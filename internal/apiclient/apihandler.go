@@ -1,10 +1,12 @@
 package apiclient
 
 import (
+	"bytes"
+	"context"
 	"embedup-go/configs/config"
 	"embedup-go/internal/cstmerr"
 	SharedModels "embedup-go/internal/shared"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -12,8 +14,10 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type UpdateInfo = SharedModels.UpdateInfo
@@ -25,21 +29,106 @@ type APIClient struct {
 	client HTTPClient
 	config *config.Config
 	token  string
+	clock  SharedModels.Clock
+	stats  *downloadStats
+
+	statusBatch statusBatch
+	// downloads holds a slot per running download; nil if downloads are not limited.
+	downloads chan struct{}
 }
 
 // New creates a new APIClient.
 func New(cfg *config.Config, token string) *APIClient {
-	client := NewRestyAdapter()
-	return &APIClient{
+	client := NewRestyAdapter(TransportTimeouts{
+		Dial:           cfg.HTTPDialTimeout,
+		IdleConn:       cfg.HTTPIdleConnTimeout,
+		TLSHandshake:   cfg.HTTPTLSHandshakeTimeout,
+		ResponseHeader: cfg.HTTPResponseHeaderTimeout,
+		Global:         cfg.HTTPGlobalTimeout,
+	}, RedirectSettings{
+		MaxRedirects:         cfg.HTTPMaxRedirects,
+		StripAuthOnCrossHost: cfg.HTTPStripAuthOnCrossHostRedirect,
+	})
+	switch cfg.AuthScheme {
+	case config.AUTH_SCHEME_BASIC:
+		client.SetBasicAuth(cfg.AuthUsername, cfg.AuthPassword)
+	case config.AUTH_SCHEME_BEARER:
+		client.SetBearerToken(cfg.BearerToken)
+	}
+	if cfg.HTTPDebug {
+		client.EnableDebug(cfg.SecretValues())
+	}
+	client.SetDefaultHeaders(deviceIdentityHeaders(cfg))
+	client.SetInsecureHosts(cfg.InsecureHosts)
+	return NewWithHTTPClient(cfg, token, client)
+}
+
+// NewWithHTTPClient creates a new APIClient that sends its requests through client,
+// e.g. a stub standing in for the server. The transport, auth and debug settings of
+// cfg only apply to clients created by New.
+func NewWithHTTPClient(cfg *config.Config, token string, client HTTPClient) *APIClient {
+	ac := &APIClient{
 		client: client,
 		config: cfg,
 		token:  token,
+		clock:  SharedModels.RealClock{},
+		stats:  newDownloadStats(),
+	}
+	if cfg.MaxConcurrentDownloads > 0 {
+		ac.downloads = make(chan struct{}, cfg.MaxConcurrentDownloads)
+	}
+	if cfg.RequestIDHeader != "" {
+		ac.client = &requestIDClient{HTTPClient: client, header: cfg.RequestIDHeader}
+	}
+	return ac
+}
+
+// deviceIdentityHeaders describes this device to the server for fleet analytics.
+// Serial and model are only sent when configured.
+func deviceIdentityHeaders(cfg *config.Config) map[string]string {
+	headers := map[string]string{
+		"X-Device-OS":   runtime.GOOS,
+		"X-Device-Arch": runtime.GOARCH,
+	}
+	if cfg.DeviceSerial != "" {
+		headers["X-Device-Serial"] = cfg.DeviceSerial
+	}
+	if cfg.DeviceModel != "" {
+		headers["X-Device-Model"] = cfg.DeviceModel
+	}
+	if osVersion := SharedModels.OSVersion(); osVersion != "" {
+		headers["X-Device-OS-Version"] = osVersion
+	}
+	return headers
+}
+
+// requireURL returns a ConfigError naming the config key if the endpoint URL it
+// configures is empty, so a misconfigured client fails clearly instead of sending
+// a request nowhere.
+func requireURL(key string, value string) error {
+	if strings.TrimSpace(value) == "" {
+		return cstmerr.NewConfigError(fmt.Sprintf("%s is not configured", key), nil)
 	}
+	return nil
+}
+
+// Stats returns a snapshot of the download telemetry recorded so far.
+func (ac *APIClient) Stats() DownloadStats {
+	return ac.stats.snapshot()
+}
+
+// SetClock replaces the clock used for retry backoff.
+func (ac *APIClient) SetClock(clock SharedModels.Clock) {
+	ac.clock = clock
 }
 
 // CheckForUpdates fetches update information from the API.
 func (ac *APIClient) CheckForUpdates() (*UpdateInfo, error) {
-	log.Printf("Checking for updates at: %s", ac.config.UpdateCheckAPIURL)
+	if err := requireURL("update_check_api_url", ac.config.UpdateCheckAPIURL); err != nil {
+		return nil, err
+	}
+	log.Printf("Checking for updates at: %s",
+		SharedModels.RedactSecrets(SharedModels.RedactURL(ac.config.UpdateCheckAPIURL), ac.config.SecretValues()...))
 	var updateInfo UpdateInfo
 	var apiErr UpdateErr // To capture error structure from API
 	headers := map[string]string{
@@ -51,6 +140,7 @@ func (ac *APIClient) CheckForUpdates() (*UpdateInfo, error) {
 		Headers:       headers,
 		SuccessResult: &updateInfo, // Tell the adapter to unmarshal success response here
 		ErrorResult:   &apiErr,     // Tell the adapter to unmarshal error response here
+		Timeout:       ac.config.UpdateCheckTimeout,
 	}
 
 	// Use the httpClient interface to make the GET request
@@ -84,9 +174,21 @@ func (ac *APIClient) CheckForUpdates() (*UpdateInfo, error) {
 	return &updateInfo, nil
 }
 
-// DownloadUpdate downloads a file from the given URL to the destination path.
-// It supports resuming downloads.
+// DownloadFile downloads a file from the given URL to the destination path.
+// It supports resuming downloads. A file:// URL or an absolute path is copied from
+// the local filesystem instead.
 func (ac *APIClient) DownloadFile(url string, destinationPath string) error {
+	return ac.DownloadFileContext(context.Background(), url, destinationPath)
+}
+
+// DownloadFileContext is DownloadFile that first waits for one of the
+// MaxConcurrentDownloads slots, giving up with ctx's error if ctx is done first.
+// The download itself is cancelled when ctx is done, keeping the part file to resume.
+func (ac *APIClient) DownloadFileContext(ctx context.Context, url string, destinationPath string) error {
+	if err := ac.acquireDownload(ctx); err != nil {
+		return cstmerr.NewDownloadError(fmt.Sprintf("gave up waiting for a download slot for %s: %v", url, err))
+	}
+	defer ac.releaseDownload()
 	log.Printf("Attempting to download from %s to %s", url, destinationPath)
 
 	// Ensure parent directory exists
@@ -97,49 +199,79 @@ func (ac *APIClient) DownloadFile(url string, destinationPath string) error {
 		}
 	}
 
+	if src, ok := localFilePath(url); ok {
+		return ac.copyLocalFile(ctx, src, destinationPath)
+	}
+
 	// Step 1: HEAD Request to get file info (size, range support)
-	headOpts := &RequestOptions{} // No special options needed for this HEAD
-	headResp, err := ac.client.Head(url, headOpts)
+	info, err := ac.headInfo(ctx, url)
 	if err != nil {
 		log.Printf("HEAD request for download failed: %v", err)
 		return err
 	}
+	totalSize, supportsRange := info.Size, info.SupportsRange
 
-	if headResp.StatusCode != http.StatusOK && headResp.StatusCode != http.StatusPartialContent { // Allow 206 for potential prior partial
-		// Servers might not support HEAD for ranged requests or return non-200 for other reasons
-		// For simplicity here, we proceed, but in a robust client, you might handle this differently
-		return cstmerr.NewHeadError(fmt.Sprintf("HEAD request failed with status: %d", headResp.StatusCode))
-	}
+	log.Printf("File size: %d, Supports range: %t", totalSize, supportsRange)
 
-	totalSizeStr := headResp.Headers.Get("X-Content-Length") // Or "Content-Length"
-	if totalSizeStr == "" {
-		totalSizeStr = headResp.Headers.Get("Content-Length")
+	// STEP 2: Determine current downloaded size. Data is written to a ".part" file and only
+	// renamed to destinationPath once complete, so an existing destinationPath is finalized.
+	partPath := destinationPath + PART_FILE_SUFFIX
+	if fileInfo, err := os.Stat(destinationPath); err == nil {
+		switch {
+		case totalSize <= 0 || fileInfo.Size() == totalSize:
+			log.Printf("File %s already fully downloaded (%d bytes).", destinationPath, fileInfo.Size())
+			return ac.protectDownload(destinationPath)
+		case fileInfo.Size() > totalSize:
+			// Larger than the remote file, so the asset changed on the server; start over.
+			log.Printf("File %s is larger than the remote file (%d > %d bytes), downloading it again.",
+				destinationPath, fileInfo.Size(), totalSize)
+			if err := SharedModels.RemoveAsset(destinationPath); err != nil && !os.IsNotExist(err) {
+				return cstmerr.NewFileDeleteError(fmt.Sprintf("failed to delete file: %s", destinationPath), err)
+			}
+			if err := os.Remove(partPath); err != nil && !os.IsNotExist(err) {
+				return cstmerr.NewFileDeleteError(fmt.Sprintf("failed to delete file: %s", partPath), err)
+			}
+		default:
+			// Left over from before downloads went through a part file; resume it as one.
+			if err := os.Rename(destinationPath, partPath); err != nil {
+				return cstmerr.NewFileIOError(fmt.Sprintf("failed to move partial file %s to %s", destinationPath, partPath), err)
+			}
+			if err := os.Chmod(partPath, SharedModels.WRITABLE_FILE_MODE); err != nil {
+				return cstmerr.NewFileIOError(fmt.Sprintf("failed to make partial file %s writable", partPath), err)
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return cstmerr.NewFileSystemError(fmt.Sprintf("failed to get metadata for existing file %s: %v", destinationPath, err))
 	}
-	totalSize, _ := strconv.ParseInt(totalSizeStr, 10, 64) // Error ignored for now, handle robustly
-
-	supportsRange := headResp.Headers.Get("Accept-Ranges") == "bytes"
 
-	log.Printf("File size: %d, Supports range: %t", totalSize, supportsRange)
-
-	// STEP 2: Determine current downloaded size
 	var currentOffset int64 = 0
-	fileInfo, err := os.Stat(destinationPath)
-	if err == nil { // File exists
+	fileInfo, err := os.Stat(partPath)
+	if err == nil { // Part file exists
 		currentOffset = fileInfo.Size()
 	} else if !os.IsNotExist(err) { // Some other error accessing the file
-		return cstmerr.NewFileSystemError(fmt.Sprintf("failed to get metadata for existing file %s: %v", destinationPath, err))
+		return cstmerr.NewFileSystemError(fmt.Sprintf("failed to get metadata for existing file %s: %v", partPath, err))
 	}
-	log.Printf("Current downloaded size for file %s is %d", destinationPath, currentOffset)
+	log.Printf("Current downloaded size for file %s is %d", partPath, currentOffset)
 
-	// Step 3: Compare downloaded size
-	if totalSize > 0 && currentOffset >= totalSize {
-		log.Printf("File %s already fully downloaded (%d bytes).", destinationPath, currentOffset)
-		return nil
+	// Step 3: Compare downloaded size. A part file larger than the remote file belongs
+	// to an older version of the asset, so it is discarded.
+	if totalSize > 0 && currentOffset == totalSize {
+		log.Printf("File %s already fully downloaded (%d bytes).", partPath, currentOffset)
+		return ac.finalizeDownload(partPath, destinationPath)
+	}
+	if totalSize > 0 && currentOffset > totalSize {
+		log.Printf("Partial file %s is larger than the remote file (%d > %d bytes), restarting the download.",
+			partPath, currentOffset, totalSize)
+		if err := os.Truncate(partPath, 0); err != nil {
+			return cstmerr.NewFileIOError(fmt.Sprintf("failed to truncate partial file %s", partPath), err)
+		}
+		currentOffset = 0
 	}
 
 	// Step 4: Make GET request (potentially ranged)
 	getStreamOpts := &RequestOptions{
 		Headers: make(map[string]string),
+		Context: ctx,
 	}
 	openMode := os.O_CREATE | os.O_WRONLY
 	if currentOffset > 0 && supportsRange {
@@ -157,10 +289,29 @@ func (ac *APIClient) DownloadFile(url string, destinationPath string) error {
 	if err != nil {
 		return cstmerr.NewDownloadError(fmt.Sprintf("download GET request failed: %v", err))
 	}
-	defer streamResp.Body.Close()
+
+	// A 416 means the part file is already larger than the remote file, so the asset
+	// changed or shrank on the server. Drop what we have and download it from the start.
+	if streamResp.StatusCode == http.StatusRequestedRangeNotSatisfiable && currentOffset > 0 {
+		DrainAndClose(streamResp.Body)
+		log.Printf("Server rejected range from offset %d (416), restarting download of %s from scratch.", currentOffset, url)
+		if err := os.Truncate(partPath, 0); err != nil && !os.IsNotExist(err) {
+			return cstmerr.NewFileIOError(fmt.Sprintf("failed to truncate partial file %s", partPath), err)
+		}
+		delete(getStreamOpts.Headers, "Range")
+		openMode = os.O_TRUNC | os.O_CREATE | os.O_WRONLY
+		currentOffset = 0
+		streamResp, err = ac.client.GetStream(url, getStreamOpts)
+		if err != nil {
+			return cstmerr.NewDownloadError(fmt.Sprintf("download GET request failed: %v", err))
+		}
+	}
+	// Error responses are returned before their body is read; drain it so the
+	// connection can be reused by the next download.
+	defer DrainAndClose(streamResp.Body)
 
 	if streamResp.StatusCode != http.StatusOK && streamResp.StatusCode != http.StatusPartialContent {
-		return cstmerr.NewDownloadError(fmt.Sprintf("download request failed with status: %d", streamResp.StatusCode))
+		return cstmerr.NewDownloadStatusError(streamResp.StatusCode)
 	}
 
 	// // If server sends 200 OK even when we asked for a range, it means it doesn't support/honor range for this request
@@ -170,54 +321,208 @@ func (ac *APIClient) DownloadFile(url string, destinationPath string) error {
 		openMode = os.O_TRUNC | os.O_CREATE | os.O_WRONLY
 		currentOffset = 0 // Our effective offset is now 0
 	}
-	destFile, err := os.OpenFile(destinationPath, openMode, 0644) // 0644 is rw for owner, r for group/other
+	destFile, err := os.OpenFile(partPath, openMode, 0644) // 0644 is rw for owner, r for group/other
 	if err != nil {
-		return cstmerr.NewFileIOError(fmt.Sprintf("failed to open/create destination file %s", destinationPath), err)
+		return cstmerr.NewFileIOError(fmt.Sprintf("failed to open/create destination file %s", partPath), err)
 	}
 	defer destFile.Close()
 
-	log.Printf("Downloading from %s to %s (offset: %d, server status: %d)", url, destinationPath, currentOffset, streamResp.StatusCode)
+	log.Printf("Downloading from %s to %s (offset: %d, server status: %d)", url, partPath, currentOffset, streamResp.StatusCode)
 
-	bytesWritten, err := io.Copy(destFile, streamResp.Body)
+	var dest io.Writer = destFile
+	if ac.config.DownloadFsync && ac.config.DownloadFsyncEveryBytes > 0 {
+		dest = &syncingWriter{file: destFile, every: ac.config.DownloadFsyncEveryBytes}
+	}
+	copyStart := ac.clock.Now()
+	bytesWritten, err := io.Copy(dest, streamResp.Body)
+	ac.stats.record(ctx, bytesWritten, ac.clock.Now().Sub(copyStart), err == nil)
 	if err != nil {
 		// Check for specific I/O errors or network interruptions during copy
 		// For example, "context deadline exceeded" can indicate a timeout during the copy operation
-		if strings.Contains(err.Error(), "context deadline exceeded") {
+		if ctx.Err() != nil || strings.Contains(err.Error(), "context deadline exceeded") {
 			return cstmerr.NewTimeoutError(err)
 		}
 		return cstmerr.NewDownloadError(fmt.Sprintf("error reading download stream or writing to file: %v", err))
 	}
 
-	log.Printf("Downloaded %d bytes to %s. Total size on disk now: %d", bytesWritten, destinationPath, currentOffset+bytesWritten)
+	log.Printf("Downloaded %d bytes to %s. Total size on disk now: %d", bytesWritten, partPath, currentOffset+bytesWritten)
+
+	// Step 5: Make sure nothing was silently truncated. The part file is kept so the next attempt can resume.
+	if totalSize > 0 && currentOffset+bytesWritten != totalSize {
+		return cstmerr.NewDownloadError(fmt.Sprintf("incomplete download of %s: %d of %d bytes on disk",
+			partPath, currentOffset+bytesWritten, totalSize))
+	}
+	if ac.config.DownloadFsync {
+		// Make sure the bytes are on disk before the file counts as complete.
+		if err := destFile.Sync(); err != nil {
+			return cstmerr.NewFileIOError(fmt.Sprintf("failed to sync downloaded file %s", partPath), err)
+		}
+	}
+	if err := destFile.Close(); err != nil {
+		return cstmerr.NewFileIOError(fmt.Sprintf("failed to close downloaded file %s", partPath), err)
+	}
+
+	// Step 6: Only now expose the file under its final name.
+	if err := ac.finalizeDownload(partPath, destinationPath); err != nil {
+		return err
+	}
 	log.Printf("Download complete: %s", destinationPath)
 	return nil
 }
 
+// syncingWriter writes to file, fsyncing it after every `every` bytes so an interrupted
+// download never claims more bytes than made it to disk.
+type syncingWriter struct {
+	file     *os.File
+	every    int64
+	unsynced int64
+}
+
+func (sw *syncingWriter) Write(p []byte) (int, error) {
+	n, err := sw.file.Write(p)
+	sw.unsynced += int64(n)
+	if err == nil && sw.unsynced >= sw.every {
+		err = sw.file.Sync()
+		sw.unsynced = 0
+	}
+	return n, err
+}
+
+// acquireDownload blocks until a download slot is free or ctx is done.
+func (ac *APIClient) acquireDownload(ctx context.Context) error {
+	if ac.downloads == nil {
+		return nil
+	}
+	select {
+	case ac.downloads <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (ac *APIClient) releaseDownload() {
+	if ac.downloads != nil {
+		<-ac.downloads
+	}
+}
+
+// finalizeDownload atomically moves a completed part file to its final name.
+func (ac *APIClient) finalizeDownload(partPath string, destinationPath string) error {
+	if err := os.Rename(partPath, destinationPath); err != nil {
+		return cstmerr.NewFileIOError(fmt.Sprintf("failed to move %s to %s", partPath, destinationPath), err)
+	}
+	return ac.protectDownload(destinationPath)
+}
+
+// protectDownload makes a finished download read-only when AssetsReadOnly is set.
+func (ac *APIClient) protectDownload(path string) error {
+	if !ac.config.AssetsReadOnly {
+		return nil
+	}
+	if err := os.Chmod(path, SharedModels.READ_ONLY_FILE_MODE); err != nil {
+		return cstmerr.NewFileIOError(fmt.Sprintf("failed to make %s read-only", path), err)
+	}
+	return nil
+}
+
+// PART_FILE_SUFFIX is appended to a destination path while its download is in progress.
+const PART_FILE_SUFFIX = ".part"
+
+// DownloadFileWithRetry retries DownloadFile up to 3 times, backing off between attempts.
 func (ac *APIClient) DownloadFileWithRetry(url string, destinationPath string) error {
-	var retryCount int = 0
-	for {
-		err := ac.DownloadFile(url, destinationPath)
-		if err == nil {
-			break
-		} else {
-			log.Printf("error in downloading file: %v", err)
-		}
-		if retryCount == 3 {
-			return cstmerr.NewRetryError("retry reached", err)
+	return ac.DownloadFileWithRetryMD5(url, destinationPath, "")
+}
+
+// DownloadFileWithRetryMD5 is DownloadFileWithRetry that also checks the whole
+// downloaded file against expectedMD5, unless it is empty. A file that doesn't match
+// is deleted and counts as a failed attempt.
+func (ac *APIClient) DownloadFileWithRetryMD5(url string, destinationPath string, expectedMD5 string) error {
+	return ac.DownloadFileWithRetryMD5Context(context.Background(), url, destinationPath, expectedMD5)
+}
+
+// DownloadFileWithRetryMD5Context is DownloadFileWithRetryMD5 that stops downloading,
+// and retrying, once ctx is done.
+// A download that fails permanently (see isRetryableDownloadError) is not retried, and
+// its partial file is removed so a later download doesn't resume from it.
+func (ac *APIClient) DownloadFileWithRetryMD5Context(ctx context.Context, url string, destinationPath string, expectedMD5 string) error {
+	err := SharedModels.RetryWithBackoff(ctx, ac.clock, 3, time.Second, isRetryableDownloadError,
+		func(attempt int) error {
+			err := ac.DownloadFileContext(ctx, url, destinationPath)
+			if err == nil && expectedMD5 != "" {
+				err = verifyFileMD5(destinationPath, expectedMD5)
+			}
+			if err != nil {
+				log.Printf("error in downloading file: %v", err)
+			}
+			return err
+		})
+	if err != nil {
+		if !isRetryableDownloadError(err) {
+			partPath := destinationPath + PART_FILE_SUFFIX
+			log.Printf("Download of %s failed permanently, removing partial file %s", url, partPath)
+			if removeErr := os.Remove(partPath); removeErr != nil && !os.IsNotExist(removeErr) {
+				log.Printf("Failed to remove partial file %s: %v", partPath, removeErr)
+			}
 		}
-		retryCount++
+		return cstmerr.NewRetryError("retry reached", err)
 	}
 	return nil
 }
 
+// isRetryableDownloadError reports whether a failed download may succeed if tried
+// again. The server answering with a client error status, other than 408 Request
+// Timeout or 429 Too Many Requests, is permanent; timeouts, connection errors, server
+// errors and local failures are not.
+func isRetryableDownloadError(err error) bool {
+	statusCode := 0
+	var downloadErr *cstmerr.DownloadError
+	var headErr *cstmerr.HeadError
+	switch {
+	case errors.As(err, &downloadErr):
+		statusCode = downloadErr.StatusCode
+	case errors.As(err, &headErr):
+		statusCode = headErr.StatusCode
+	}
+	if statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooManyRequests {
+		return true
+	}
+	return statusCode < 400 || statusCode >= 500
+}
+
+// verifyFileMD5 removes path and returns a ChecksumError if it doesn't hash to expectedMD5.
+func verifyFileMD5(path string, expectedMD5 string) error {
+	actual, err := SharedModels.CalculateFileMD5(path)
+	if err != nil {
+		return cstmerr.NewFileIOError(fmt.Sprintf("failed to hash downloaded file %s", path), err)
+	}
+	if strings.EqualFold(actual, expectedMD5) {
+		return nil
+	}
+	if err := SharedModels.RemoveAsset(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove corrupt download %s: %v", path, err)
+	}
+	return cstmerr.NewChecksumError(path, expectedMD5, actual)
+}
+
 func (ac *APIClient) GetFileInformation(url string) (SharedModels.FileInformation, error) {
+	return ac.getFileInformation(context.Background(), url)
+}
+
+func (ac *APIClient) getFileInformation(ctx context.Context, url string) (SharedModels.FileInformation, error) {
+	if path, ok := localFilePath(url); ok {
+		return localFileInformation(path)
+	}
 	info := SharedModels.FileInformation{}
-	headOpts := &RequestOptions{} // No special options needed for this HEAD
+	headOpts := &RequestOptions{Context: ctx}
 	headResp, err := ac.client.Head(url, headOpts)
 	if err != nil {
 		log.Printf("HEAD request for download failed: %v", err)
 		return info, err
 	}
+	if !headResp.IsSuccess() {
+		return info, cstmerr.NewHeadStatusError(headResp.StatusCode)
+	}
 	hash := headResp.Headers.Get("x-content-md5")
 	if hash == "" {
 		return info, cstmerr.NewProcessError(cstmerr.PROCESS_HASH_FIND, nil)
@@ -227,21 +532,140 @@ func (ac *APIClient) GetFileInformation(url string) (SharedModels.FileInformatio
 	return info, nil
 }
 
-// ReportStatus sends a status update to the API.
+// GetFileInformationWithRetry is GetFileInformation that retries failed HEAD requests
+// up to 3 times, backing off between attempts. Like downloads, only transport errors,
+// server errors, 408 and 429 are retried; a response without a hash is not.
+func (ac *APIClient) GetFileInformationWithRetry(ctx context.Context, url string) (SharedModels.FileInformation, error) {
+	var info SharedModels.FileInformation
+	isRetryableHeadError := func(err error) bool {
+		var headErr *cstmerr.HeadError
+		return errors.As(err, &headErr) && isRetryableDownloadError(err)
+	}
+	err := SharedModels.RetryWithBackoff(ctx, ac.clock, 3, time.Second, isRetryableHeadError,
+		func(attempt int) error {
+			var err error
+			info, err = ac.getFileInformation(ctx, url)
+			return err
+		})
+	return info, err
+}
+
+// advertisedSize returns the size of url's content from its response headers, or 0 if
+// none is advertised. X-Content-Length is preferred, since some proxies drop or
+// rewrite Content-Length, but only when it is a positive integer: a bogus value such
+// as "0" would otherwise make any partial file look complete.
+func advertisedSize(url string, headers http.Header) int64 {
+	if raw := headers.Get("X-Content-Length"); raw != "" {
+		if size, err := strconv.ParseInt(raw, 10, 64); err == nil && size > 0 {
+			log.Printf("Size of %s from X-Content-Length: %d", url, size)
+			return size
+		}
+		log.Printf("Ignoring invalid X-Content-Length %q for %s", raw, url)
+	}
+	size, err := strconv.ParseInt(headers.Get("Content-Length"), 10, 64)
+	if err != nil || size < 0 {
+		return 0
+	}
+	log.Printf("Size of %s from Content-Length: %d", url, size)
+	return size
+}
+
+// DownloadInfo is the metadata a server advertises for a download in a HEAD response.
+type DownloadInfo struct {
+	Size          int64 // 0 if not advertised
+	SupportsRange bool  // Whether a ranged GET can resume a partial download
+	ContentType   string
+	ETag          string
+}
+
+// HeadInfo sends a HEAD request for url and returns the download metadata it advertises.
+func (ac *APIClient) HeadInfo(url string) (*DownloadInfo, error) {
+	return ac.headInfo(context.Background(), url)
+}
+
+func (ac *APIClient) headInfo(ctx context.Context, url string) (*DownloadInfo, error) {
+	if path, ok := localFilePath(url); ok {
+		return localDownloadInfo(path)
+	}
+	headResp, err := ac.client.Head(url, &RequestOptions{Context: ctx})
+	if err != nil {
+		return nil, err
+	}
+	if headResp.StatusCode != http.StatusOK && headResp.StatusCode != http.StatusPartialContent { // Allow 206 for potential prior partial
+		return nil, cstmerr.NewHeadStatusError(headResp.StatusCode)
+	}
+
+	return &DownloadInfo{
+		Size:          advertisedSize(url, headResp.Headers),
+		SupportsRange: headResp.Headers.Get("Accept-Ranges") == "bytes",
+		ContentType:   headResp.Headers.Get("Content-Type"),
+		ETag:          headResp.Headers.Get("ETag"),
+	}, nil
+}
+
+// Ping checks that the content API is reachable with a HEAD request. Any HTTP
+// response counts as reachable; only transport-level failures are returned.
+func (ac *APIClient) Ping() error {
+	if err := requireURL("content_update_api_url", ac.config.ContentUpdateAPIURL); err != nil {
+		return err
+	}
+	opts := &RequestOptions{
+		Headers: map[string]string{
+			"device-token": ac.token,
+		},
+		Timeout: ac.config.UpdateCheckTimeout,
+	}
+	_, err := ac.client.Head(ac.config.ContentUpdateAPIURL, opts)
+	return err
+}
+
+// ReportStatus sends a status update to the API. When status batching is configured
+// the report is queued and sent with the next batch instead.
 func (ac *APIClient) ReportStatus(versionCode int, statusMessage string) error {
+	return ac.ReportStatusContext(context.Background(), versionCode, statusMessage)
+}
+
+// ReportStatusContext is ReportStatus that retries a report failing transiently up to
+// 3 times, backing off between attempts, drawing the retries from the budget of ctx.
+func (ac *APIClient) ReportStatusContext(ctx context.Context, versionCode int, statusMessage string) error {
 	payload := StatusReportPayload{
 		VersionCode:   versionCode,
 		StatusMessage: statusMessage,
 	}
+	if ac.statusBatchingEnabled() {
+		return ac.queueStatus(payload)
+	}
+	if err := requireURL("status_report_api_url", ac.config.StatusReportAPIURL); err != nil {
+		return err
+	}
+	return SharedModels.RetryWithBackoff(ctx, ac.clock, 3, time.Second, isRetryableStatusError,
+		func(attempt int) error {
+			return ac.reportStatus(ctx, payload)
+		})
+}
 
+// isRetryableStatusError reports whether a failed status report may succeed if sent
+// again: the server was unreachable, overloaded or failing.
+func isRetryableStatusError(err error) bool {
+	var apiErr *cstmerr.APIRequestFailedError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusRequestTimeout || apiErr.StatusCode == http.StatusTooManyRequests ||
+			apiErr.StatusCode >= 500
+	}
+	return true
+}
+
+func (ac *APIClient) reportStatus(ctx context.Context, payload StatusReportPayload) error {
 	log.Printf("Reporting status: %+v to %s", payload, ac.config.StatusReportAPIURL)
 	headers := map[string]string{
 		"device-token": ac.token,
 		"Content-Type": "application/json", // Explicitly set Content-Type for JSON payload
 	}
 	opts := &RequestOptions{
+		Context: ctx,
 		Headers: headers,
 		Body:    payload, // The adapter (RestyAdapter) will marshal this to JSON
+		Timeout: ac.config.StatusReportTimeout,
 		// No SuccessResult or ErrorResult needed if we primarily check status code
 		// and use raw body for error messages, as in the original code.
 	}
@@ -262,36 +686,105 @@ func (ac *APIClient) ReportStatus(versionCode int, statusMessage string) error {
 	return nil
 }
 
+// GetStatusHistory fetches up to limit of the status reports the server has recorded
+// for this device, newest first, skipping the first offset. Fewer than limit events
+// means the history is exhausted.
+func (ac *APIClient) GetStatusHistory(limit int, offset int) ([]SharedModels.StatusEvent, error) {
+	if err := requireURL("status_history_api_url", ac.config.StatusHistoryAPIURL); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || offset < 0 {
+		return nil, fmt.Errorf("invalid status history page: limit %d, offset %d", limit, offset)
+	}
+	var history SharedModels.StatusHistoryResponse
+	var apiErr UpdateErr
+	opts := &RequestOptions{
+		Headers: map[string]string{
+			"device-token": ac.token,
+		},
+		QueryParams: map[string]string{
+			"limit":  strconv.Itoa(limit),
+			"offset": strconv.Itoa(offset),
+		},
+		SuccessResult: &history,
+		ErrorResult:   &apiErr,
+		Timeout:       ac.config.StatusReportTimeout,
+	}
+	resp, err := ac.client.Get(ac.config.StatusHistoryAPIURL, opts)
+	if err != nil {
+		log.Printf("Error during HTTP GET for status history: %v", err)
+		return nil, err
+	}
+	if !resp.IsSuccess() {
+		errMsg := apiErr.Message
+		if errMsg == "" {
+			errMsg = string(resp.Body)
+		}
+		log.Printf("Status history API request failed with status %d: %s", resp.StatusCode, errMsg)
+		return nil, cstmerr.NewAPIRequestFailedError(resp.StatusCode, errMsg)
+	}
+	log.Printf("Fetched %d status event(s) at offset %d of %d", len(history.Events), offset, history.Count)
+	return history.Events, nil
+}
+
 // FetchContentUpdates fetches content changes from the server.
 func (ac *APIClient) FetchContentUpdates(
 	params SharedModels.ContentUpdateRequestParams) (*SharedModels.ContentUpdateResponse,
 	[]SharedModels.ProcessedContentSchema, error) {
+	return ac.FetchContentUpdatesContext(context.Background(), params)
+}
+
+// FetchContentUpdatesContext is FetchContentUpdates with the request made under ctx.
+func (ac *APIClient) FetchContentUpdatesContext(ctx context.Context,
+	params SharedModels.ContentUpdateRequestParams) (*SharedModels.ContentUpdateResponse,
+	[]SharedModels.ProcessedContentSchema, error) {
+	if err := requireURL("content_update_api_url", ac.config.ContentUpdateAPIURL); err != nil {
+		return nil, nil, err
+	}
 	log.Printf("Fetching content updates from: %s with params: %+v\n",
 		ac.config.ContentUpdateAPIURL, params)
+	if ac.config.ContentUpdateStreaming && ac.config.ContentUpdateMethod != http.MethodPost {
+		return ac.fetchContentUpdatesStreamed(ctx, params)
+	}
 
 	var contentResp SharedModels.ContentUpdateResponse
 	var apiErr UpdateErr
 
 	headers := map[string]string{
-		"device-token": ac.token,
-	}
-
-	queryParams := map[string]string{
-		"from":   strconv.FormatInt(params.From, 10),
-		"size":   strconv.Itoa(params.Size),
-		"offset": strconv.Itoa(params.Offset),
+		"device-token":     ac.token,
+		"X-Schema-Version": strconv.Itoa(CONTENT_SCHEMA_VERSION),
 	}
 
 	opts := &RequestOptions{
 		Headers:       headers,
-		QueryParams:   queryParams,
 		SuccessResult: &contentResp, // Resty/HTTPClient adapter should unmarshal into this
 		ErrorResult:   &apiErr,
+		Timeout:       ac.config.ContentUpdateTimeout,
+		Context:       ctx,
 	}
 
-	resp, err := ac.client.Get(ac.config.ContentUpdateAPIURL, opts)
+	var resp *Response
+	var err error
+	if ac.config.ContentUpdateMethod == http.MethodPost {
+		// Some CMS deployments expect the filter as a JSON body instead of query params.
+		headers["Content-Type"] = "application/json"
+		opts.Body = SharedModels.ContentUpdateFilter{
+			From:   params.From,
+			Size:   params.Size,
+			Offset: params.Offset,
+			Types:  ac.config.EnabledContentTypes,
+		}
+		resp, err = ac.client.Post(ac.config.ContentUpdateAPIURL, opts)
+	} else {
+		opts.QueryParams = map[string]string{
+			"from":   strconv.FormatInt(params.From, 10),
+			"size":   strconv.Itoa(params.Size),
+			"offset": strconv.Itoa(params.Offset),
+		}
+		resp, err = ac.client.Get(ac.config.ContentUpdateAPIURL, opts)
+	}
 	if err != nil {
-		log.Printf("Error during HTTP GET for content updates: %v", err)
+		log.Printf("Error during HTTP %s for content updates: %v", ac.config.ContentUpdateMethod, err)
 		return nil, nil, err
 	}
 
@@ -310,195 +803,183 @@ func (ac *APIClient) FetchContentUpdates(
 		return nil, nil, cstmerr.NewAPIRequestFailedError(resp.StatusCode, errMsg)
 	}
 
-	// TODO: handle empty contents array
-	if len(contentResp.Contents) == 0 && len(resp.Body) > 0 { // Check if unmarshalling might have been skipped by adapter
-		if err := json.Unmarshal(resp.Body, &contentResp); err != nil {
-			log.Printf("Failed to unmarshal content update response body: %v. Body: %s", err, string(resp.Body))
-			return nil, nil, cstmerr.NewAPIClientError(fmt.Errorf("failed to unmarshal response: %w", err))
-		}
+	log.Printf("Received content update response. Count: %d, Items: %d, Schema version: %d",
+		contentResp.Count, len(contentResp.Contents), contentResp.SchemaVersion)
+
+	if contentResp.SchemaVersion > CONTENT_SCHEMA_VERSION {
+		// Parsing a newer shape would silently drop or mangle items, so stop before anything is processed.
+		schemaErr := cstmerr.NewSchemaVersionError(contentResp.SchemaVersion, CONTENT_SCHEMA_VERSION)
+		log.Printf("Content update response rejected: %v", schemaErr)
+		return nil, nil, schemaErr
 	}
 
-	log.Printf("Received content update response. Count: %d, Items: %d", contentResp.Count, len(contentResp.Contents))
+	if len(contentResp.Contents) == 0 {
+		// Nothing changed since 'from'; a valid response, not an error.
+		return &contentResp, nil, nil
+	}
 
 	var processedItems []SharedModels.ProcessedContentSchema
 	for _, item := range contentResp.Contents {
-		var specificContent any
-		var parseErr error
-		log.Printf("Extracting content item ID: %d, Type: %s, UpdatedAt: %d, Enabled: %t",
-			item.ID, item.Type, item.UpdatedAt, item.Enable)
-		switch item.Type {
-		case "local-advertisement":
-			var adContent SharedModels.LocalAdvertisementSchema
-			if err := json.Unmarshal(item.Content, &adContent); err != nil {
-				parseErr = fmt.Errorf("failed to parse 'local-advertisement' content for ID %d: %w", item.ID, err)
-			} else {
-				specificContent = adContent
-			}
-		case "local-page":
-			var pageContent SharedModels.LocalPageSchema
-			if err := json.Unmarshal(item.Content, &pageContent); err != nil {
-				parseErr = fmt.Errorf("failed to parse 'local-page' content for ID %d: %w", item.ID, err)
-			} else {
-				specificContent = pageContent
-			}
-		case "local-movie":
-			var movieContent SharedModels.LocalMovieSchema
-			if err := json.Unmarshal(item.Content, &movieContent); err != nil {
-				parseErr = fmt.Errorf("failed to parse 'local-movie' content for ID %d: %w", item.ID, err)
-			} else {
-				specificContent = movieContent
-			}
-		case "local-section":
-			var sectionContent SharedModels.LocalSectionSchema
-			if err := json.Unmarshal(item.Content, &sectionContent); err != nil {
-				parseErr = fmt.Errorf("failed to parse 'local-section' content for ID %d: %w", item.ID, err)
-			} else {
-				specificContent = sectionContent
-			}
-		case "local-series":
-			var seriesContent SharedModels.LocalSeriesSchema
-			if err := json.Unmarshal(item.Content, &seriesContent); err != nil {
-				parseErr = fmt.Errorf("failed to parse 'local-series' content for ID %d: %w", item.ID, err)
-			} else {
-				specificContent = seriesContent
-			}
-		case "local-series-episode":
-			var episodeContent SharedModels.LocalSeriesEpisodeSchema
-			if err := json.Unmarshal(item.Content, &episodeContent); err != nil {
-				parseErr = fmt.Errorf("failed to parse 'local-series-episode' content for ID %d: %w", item.ID, err)
-			} else {
-				specificContent = episodeContent
-			}
-		case "local-series-season":
-			var seasonContent SharedModels.LocalSeriesSeasonSchema
-			if err := json.Unmarshal(item.Content, &seasonContent); err != nil {
-				parseErr = fmt.Errorf("failed to parse 'local-series-season' content for ID %d: %w", item.ID, err)
-			} else {
-				specificContent = seasonContent
-			}
-		case "local-slider":
-			var sliderContent SharedModels.LocalSliderSchema
-			if err := json.Unmarshal(item.Content, &sliderContent); err != nil {
-				parseErr = fmt.Errorf("failed to parse 'local-slider' content for ID %d: %w", item.ID, err)
-			} else {
-				specificContent = sliderContent
-			}
-		case "local-tab":
-			var tabContent SharedModels.LocalTabSchema
-			if err := json.Unmarshal(item.Content, &tabContent); err != nil {
-				parseErr = fmt.Errorf("failed to parse 'local-tab' content for ID %d: %w", item.ID, err)
-			} else {
-				specificContent = tabContent
-			}
-		case "local-movie-genre":
-			var movieGenreContent SharedModels.LocalMovieGenreSchema
-			if err := json.Unmarshal(item.Content, &movieGenreContent); err != nil {
-				parseErr = fmt.Errorf("failed to parse 'local-movie-genre' content for ID %d: %w", item.ID, err)
-			} else {
-				specificContent = movieGenreContent
-			}
-		case "local-poll":
-			var pollContent SharedModels.LocalPollSchema
-			if err := json.Unmarshal(item.Content, &pollContent); err != nil {
-				parseErr = fmt.Errorf("failed to parse 'local-poll' content for ID %d: %w", item.ID, err)
-			} else {
-				specificContent = pollContent
-			}
-		case "local-section-content":
-			var sectionContentContent SharedModels.LocalSectionContentSchema
-			if err := json.Unmarshal(item.Content, &sectionContentContent); err != nil {
-				parseErr = fmt.Errorf("failed to parse 'local-section-content' content for ID %d: %w", item.ID, err)
-			} else {
-				specificContent = sectionContentContent
-			}
-		case "local-podcast":
-			var podcastContent SharedModels.LocalPodcastSchema
-			if err := json.Unmarshal(item.Content, &podcastContent); err != nil {
-				parseErr = fmt.Errorf("failed to parse 'local-podcast' content for ID %d: %w", item.ID, err)
-			} else {
-				specificContent = podcastContent
-			}
-		case "local-podcastparent":
-			var podcastParentContent SharedModels.LocalPodcastParentSchema
-			if err := json.Unmarshal(item.Content, &podcastParentContent); err != nil {
-				parseErr = fmt.Errorf("failed to parse 'local-podcastparent' content for ID %d: %w", item.ID, err)
-			} else {
-				specificContent = podcastParentContent
-			}
-		case "local-audiobook":
-			var audiobookContent SharedModels.LocalAudiobookSchema
-			if err := json.Unmarshal(item.Content, &audiobookContent); err != nil {
-				parseErr = fmt.Errorf("failed to parse 'local-audiobook' content for ID %d: %w", item.ID, err)
-			} else {
-				specificContent = audiobookContent
-			}
-		case "local-audiobookparent":
-			var audiobookParentContent SharedModels.LocalAudiobookParentSchema
-			if err := json.Unmarshal(item.Content, &audiobookParentContent); err != nil {
-				parseErr = fmt.Errorf("failed to parse 'local-audiobookparent' content for ID %d: %w", item.ID, err)
-			} else {
-				specificContent = audiobookParentContent
-			}
-		case "local-music":
-			var musicContent SharedModels.LocalMusicSchema
-			if err := json.Unmarshal(item.Content, &musicContent); err != nil {
-				parseErr = fmt.Errorf("failed to parse 'local-music' content for ID %d: %w", item.ID, err)
-			} else {
-				specificContent = musicContent
-			}
-		case "local-album":
-			var albumContent SharedModels.LocalAlbumSchema
-			if err := json.Unmarshal(item.Content, &albumContent); err != nil {
-				parseErr = fmt.Errorf("failed to parse 'local-album' content for ID %d: %w", item.ID, err)
-			} else {
-				specificContent = albumContent
-			}
-		case "local-device-update":
-			var deviceUpdateContent SharedModels.LocalDeviceUpdateSchema
-			if err := json.Unmarshal(item.Content, &deviceUpdateContent); err != nil {
-				parseErr = fmt.Errorf("failed to parse 'local-device-update' content for ID %d: %w", item.ID, err)
-			} else {
-				specificContent = deviceUpdateContent
-			}
-		case "local-terms-conditions":
-			var termsContent SharedModels.LocalTermsConditionsSchema
-			if err := json.Unmarshal(item.Content, &termsContent); err != nil {
-				parseErr = fmt.Errorf("failed to parse 'local-terms-conditions' content for ID %d: %w", item.ID, err)
-			} else {
-				specificContent = termsContent
-			}
-			// case "local-news":
-		// 	var newsContent SharedModels.LocalNewsSchema
-		// case "local-magazine":
-		// 	var magazineContent SharedModels.LocalMagazineSchema
-		default:
-			log.Printf("Unknown content type '%s' for item ID %d. Skipping.", item.Type, item.ID)
-			continue // Skip to the next item
+		processed, err := ac.extractContentItem(item)
+		if err != nil {
+			return nil, nil, err
+		}
+		if processed != nil {
+			processedItems = append(processedItems, *processed)
 		}
+	}
+
+	log.Printf("Successfully processed %d content items.", len(processedItems))
+	return &contentResp, processedItems, nil
+}
 
-		if parseErr != nil {
-			log.Printf("Error parsing content item: %v", parseErr)
-			// Decide if you want to stop processing or just skip this item
-			// For now, we log and skip.
-			continue
+// extractContentItem parses item, returning nil for items that are skipped: items that
+// fail to parse, and items of unknown content types unless UnknownTypePolicy says to
+// fail the batch or to hand them on for quarantine.
+func (ac *APIClient) extractContentItem(item SharedModels.GenericContentItem) (*SharedModels.ProcessedContentSchema, error) {
+	log.Printf("Extracting content item ID: %d, Type: %s, UpdatedAt: %d, Enabled: %t, Deleted: %t",
+		item.ID, item.Type, item.UpdatedAt, item.Enable, item.Deleted)
+	if _, ok := lookupContentParser(item.Type); !ok {
+		switch ac.config.UnknownTypePolicy {
+		case config.UNKNOWN_TYPE_POLICY_ERROR:
+			return nil, cstmerr.NewAPIClientError(
+				fmt.Errorf("unknown content type '%s' for item ID %d", item.Type, item.ID))
+		case config.UNKNOWN_TYPE_POLICY_QUARANTINE:
+			return &SharedModels.ProcessedContentSchema{
+				ID:          item.ID,
+				Type:        item.Type,
+				UpdatedAt:   item.UpdatedAt,
+				UnknownType: true,
+			}, nil
 		}
+	}
+	processed, parseErr := parseContentItem(item)
+	if parseErr != nil {
+		log.Printf("Error parsing content item: %v", parseErr)
+		// Decide if you want to stop processing or just skip this item
+		// For now, we log and skip.
+		return nil, nil
+	}
+	return processed, nil
+}
 
-		if specificContent != nil {
-			processedItems = append(processedItems, SharedModels.ProcessedContentSchema{
-				ID:        item.ID,
-				Type:      item.Type,
-				UpdatedAt: item.UpdatedAt,
-				Enable:    item.Enable,
-				Details:   specificContent,
-			})
+// CONTENT_SCHEMA_VERSION is the newest content schema this client can parse. It is sent
+// in the X-Schema-Version header so the server can serve a compatible shape.
+const CONTENT_SCHEMA_VERSION = 1
+
+// parseContentItem decodes the type-specific content of item. It returns nil without
+// an error for unknown content types, which callers skip.
+func parseContentItem(item SharedModels.GenericContentItem) (*SharedModels.ProcessedContentSchema, error) {
+	parser, ok := lookupContentParser(item.Type)
+	if !ok {
+		log.Printf("Unknown content type '%s' for item ID %d. Skipping.", item.Type, item.ID)
+		return nil, nil
+	}
+	if item.Deleted {
+		// A tombstone only identifies the item; there is no content to parse.
+		return &SharedModels.ProcessedContentSchema{
+			ID:        item.ID,
+			Type:      item.Type,
+			UpdatedAt: item.UpdatedAt,
+			Deleted:   true,
+		}, nil
+	}
+	// Missing or null content would otherwise decode into a zero value that processes
+	// as if valid, e.g. a movie with ID 0.
+	if raw := bytes.TrimSpace(item.Content); len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, fmt.Errorf("empty '%s' content for ID %d", item.Type, item.ID)
+	}
+	specificContent, err := parser(item.Content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse '%s' content for ID %d: %w", item.Type, item.ID, err)
+	}
+	return &SharedModels.ProcessedContentSchema{
+		ID:        item.ID,
+		Type:      item.Type,
+		UpdatedAt: item.UpdatedAt,
+		Enable:    item.Enable,
+		Details:   specificContent,
+	}, nil
+}
+
+// GetContentItem fetches a single content item by ID from the per-item endpoint.
+// A 404 from the server is returned as a ContentNotFoundError.
+func (ac *APIClient) GetContentItem(id int64) (*SharedModels.ProcessedContentSchema, error) {
+	return ac.GetContentItemContext(context.Background(), id)
+}
+
+// GetContentItemContext is GetContentItem with the request made under ctx.
+func (ac *APIClient) GetContentItemContext(ctx context.Context, id int64) (*SharedModels.ProcessedContentSchema, error) {
+	if err := requireURL("content_item_api_url", ac.config.ContentItemAPIURL); err != nil {
+		return nil, err
+	}
+
+	var item SharedModels.GenericContentItem
+	var apiErr UpdateErr
+
+	headers := map[string]string{
+		"device-token":     ac.token,
+		"X-Schema-Version": strconv.Itoa(CONTENT_SCHEMA_VERSION),
+	}
+
+	opts := &RequestOptions{
+		Headers:       headers,
+		SuccessResult: &item,
+		ErrorResult:   &apiErr,
+		Timeout:       ac.config.ContentUpdateTimeout,
+		Context:       ctx,
+	}
+	itemURL, err := url.JoinPath(ac.config.ContentItemAPIURL, strconv.FormatInt(id, 10))
+	if err != nil {
+		log.Printf("Error joining path %s and content id %d :%v",
+			ac.config.ContentItemAPIURL, id, err)
+		return nil, err
+	}
+
+	resp, err := ac.client.Get(itemURL, opts)
+	if err != nil {
+		log.Printf("Error during HTTP GET for content item %d: %v", id, err)
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, cstmerr.NewContentNotFoundError(id)
+	}
+
+	if resp.IsError() {
+		errMsg := apiErr.Message
+		if errMsg == "" {
+			errMsg = string(resp.Body)
 		}
+		log.Printf("Content item API request failed with status %d: %s", resp.StatusCode, errMsg)
+		return nil, cstmerr.NewAPIRequestFailedError(resp.StatusCode, errMsg)
 	}
 
-	log.Printf("Successfully processed %d content items.", len(processedItems))
-	return &contentResp, processedItems, nil
+	if !resp.IsSuccess() {
+		errMsg := fmt.Sprintf("Content item API request returned non-success status %d. Body: %s", resp.StatusCode, string(resp.Body))
+		log.Println(errMsg)
+		return nil, cstmerr.NewAPIRequestFailedError(resp.StatusCode, errMsg)
+	}
+
+	processed, err := parseContentItem(item)
+	if err != nil {
+		return nil, err
+	}
+	if processed == nil {
+		return nil, fmt.Errorf("content item %d has unsupported type '%s'", id, item.Type)
+	}
+	return processed, nil
 }
 
 func (ac *APIClient) GetMovieDetail(movieId int) (SharedModels.LocalMovieContentDetailSchema, error) {
+	return ac.GetMovieDetailContext(context.Background(), movieId)
+}
+
+// GetMovieDetailContext is GetMovieDetail with the request made under ctx.
+func (ac *APIClient) GetMovieDetailContext(ctx context.Context, movieId int) (SharedModels.LocalMovieContentDetailSchema, error) {
+	if err := requireURL("content_detail_api_url", ac.config.ContentDetailAPIURL); err != nil {
+		return SharedModels.LocalMovieContentDetailSchema{}, err
+	}
 
 	var contentResp SharedModels.LocalMovieContentSchema
 	var apiErr UpdateErr
@@ -511,6 +992,7 @@ func (ac *APIClient) GetMovieDetail(movieId int) (SharedModels.LocalMovieContent
 		Headers:       headers,
 		SuccessResult: &contentResp,
 		ErrorResult:   &apiErr,
+		Context:       ctx,
 	}
 	url, err := url.JoinPath(ac.config.ContentDetailAPIURL, fmt.Sprint(movieId))
 	if err != nil {
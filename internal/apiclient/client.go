@@ -1,6 +1,7 @@
 package apiclient
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"time"
@@ -13,7 +14,9 @@ type RequestOptions struct {
 	Body          any           // For POST, PUT, PATCH - will be JSON marshaled by adapter
 	SuccessResult any           // Pointer to struct to unmarshal success JSON response
 	ErrorResult   any           // Pointer to struct to unmarshal error JSON response
-	Timeout       time.Duration // Optional per-request timeout (behavior depends on adapter)
+	Timeout       time.Duration // Optional per-request timeout covering the whole request-response cycle
+	// Context, if set, cancels the request (and, for GetStream, reading its body) when done.
+	Context context.Context
 }
 
 // Response represents a general HTTP response.
@@ -48,6 +51,50 @@ func (sr *StreamResponse) IsSuccess() bool {
 	return sr.StatusCode >= 200 && sr.StatusCode < 300
 }
 
+// maxDrainBytes caps how much of an unwanted body DrainAndClose reads to keep its
+// connection reusable; a longer body is cheaper to drop along with the connection.
+const maxDrainBytes = 64 << 10
+
+// DrainAndClose reads what is left of a response body, up to maxDrainBytes, and closes
+// it, so the connection goes back to the pool instead of being torn down.
+func DrainAndClose(body io.ReadCloser) {
+	io.Copy(io.Discard, io.LimitReader(body, maxDrainBytes))
+	body.Close()
+}
+
+// TransportTimeouts holds the connection-level timeouts of the underlying HTTP transport.
+type TransportTimeouts struct {
+	Dial           time.Duration
+	IdleConn       time.Duration
+	TLSHandshake   time.Duration
+	ResponseHeader time.Duration // 0 waits indefinitely for response headers
+	// Global bounds a whole request-response cycle when the request sets no Timeout of
+	// its own. It does not apply to GetStream, whose body is read long after the call
+	// returns; streams are only bounded by their own Timeout. 0 disables it.
+	Global time.Duration
+}
+
+// DefaultTransportTimeouts returns the timeouts used when none are configured.
+func DefaultTransportTimeouts() TransportTimeouts {
+	return TransportTimeouts{
+		Dial:         30 * time.Second,
+		IdleConn:     30 * time.Second,
+		TLSHandshake: 60 * time.Second,
+	}
+}
+
+// RedirectSettings controls how redirects are followed.
+type RedirectSettings struct {
+	MaxRedirects int // 0 disables redirects
+	// StripAuthOnCrossHost drops credential headers when a redirect leaves the original host.
+	StripAuthOnCrossHost bool
+}
+
+// DefaultRedirectSettings returns the redirect policy used when none is configured.
+func DefaultRedirectSettings() RedirectSettings {
+	return RedirectSettings{MaxRedirects: 10, StripAuthOnCrossHost: true}
+}
+
 // HTTPClient defines the interface for a generic HTTP client.
 // Implementations of this interface will handle the actual HTTP communication.
 type HTTPClient interface {
@@ -0,0 +1,101 @@
+package apiclient
+
+import (
+	"context"
+	"embedup-go/configs/config"
+	"embedup-go/internal/cstmerr"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingClient holds every HEAD request, the first step of a download, until release
+// is closed, tracking how many run at once. The downloads then fail with a 404.
+type blockingClient struct {
+	HTTPClient
+	release   chan struct{}
+	started   chan struct{}
+	mu        sync.Mutex
+	active    int
+	maxActive int
+}
+
+func newBlockingClient() *blockingClient {
+	return &blockingClient{release: make(chan struct{}), started: make(chan struct{}, 100)}
+}
+
+func (c *blockingClient) Head(url string, opts *RequestOptions) (*Response, error) {
+	c.mu.Lock()
+	c.active++
+	c.maxActive = max(c.maxActive, c.active)
+	c.mu.Unlock()
+	c.started <- struct{}{}
+	<-c.release
+	c.mu.Lock()
+	c.active--
+	c.mu.Unlock()
+	return &Response{StatusCode: http.StatusNotFound, RequestURL: url}, nil
+}
+
+func TestDownloadsLimitedToMaxConcurrent(t *testing.T) {
+	const maxDownloads = 2
+	stub := newBlockingClient()
+	client := NewWithHTTPClient(&config.Config{MaxConcurrentDownloads: maxDownloads}, "test-token", stub)
+	dir := t.TempDir()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.DownloadFileContext(context.Background(), testAssetURL, filepath.Join(dir, "asset.bin"))
+		}()
+	}
+	for i := 0; i < maxDownloads; i++ {
+		<-stub.started
+	}
+	select {
+	case <-stub.started:
+		t.Fatalf("a download beyond the limit of %d started", maxDownloads)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(stub.release)
+	wg.Wait()
+	if stub.maxActive != maxDownloads {
+		t.Fatalf("%d downloads ran at once, want %d", stub.maxActive, maxDownloads)
+	}
+}
+
+func TestDownloadWaiterGivesUpOnCancel(t *testing.T) {
+	stub := newBlockingClient()
+	client := NewWithHTTPClient(&config.Config{MaxConcurrentDownloads: 1}, "test-token", stub)
+	dir := t.TempDir()
+	go client.DownloadFileContext(context.Background(), testAssetURL, filepath.Join(dir, "first.bin"))
+	<-stub.started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- client.DownloadFileContext(ctx, testAssetURL, filepath.Join(dir, "second.bin")) }()
+	cancel()
+	select {
+	case err := <-done:
+		var downloadErr *cstmerr.DownloadError
+		if !errors.As(err, &downloadErr) {
+			t.Fatalf("DownloadFileContext = %v, want a DownloadError", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("canceled download still waiting for a slot")
+	}
+	close(stub.release)
+
+	// The canceled waiter took no slot, so the next download starts.
+	err := client.DownloadFileContext(context.Background(), testAssetURL, filepath.Join(dir, "third.bin"))
+	var headErr *cstmerr.HeadError
+	if !errors.As(err, &headErr) {
+		t.Fatalf("DownloadFileContext after the slot was freed = %v, want the stub's HeadError", err)
+	}
+}
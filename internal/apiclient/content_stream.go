@@ -0,0 +1,143 @@
+package apiclient
+
+import (
+	"context"
+	"embedup-go/internal/cstmerr"
+	SharedModels "embedup-go/internal/shared"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+)
+
+// fetchContentUpdatesStreamed is the GET path of FetchContentUpdates that decodes the
+// response while it is read, parsing each item as soon as it arrives, so the raw body
+// and its generic items are never held in memory all at once. The returned response
+// has no Contents; the parsed items are returned instead.
+func (ac *APIClient) fetchContentUpdatesStreamed(ctx context.Context,
+	params SharedModels.ContentUpdateRequestParams) (*SharedModels.ContentUpdateResponse,
+	[]SharedModels.ProcessedContentSchema, error) {
+	opts := &RequestOptions{
+		Headers: map[string]string{
+			"device-token":     ac.token,
+			"X-Schema-Version": strconv.Itoa(CONTENT_SCHEMA_VERSION),
+		},
+		QueryParams: map[string]string{
+			"from":   strconv.FormatInt(params.From, 10),
+			"size":   strconv.Itoa(params.Size),
+			"offset": strconv.Itoa(params.Offset),
+		},
+		Timeout: ac.config.ContentUpdateTimeout,
+		Context: ctx,
+	}
+	streamResp, err := ac.client.GetStream(ac.config.ContentUpdateAPIURL, opts)
+	if err != nil {
+		log.Printf("Error during streamed HTTP GET for content updates: %v", err)
+		return nil, nil, err
+	}
+	defer DrainAndClose(streamResp.Body)
+
+	if !streamResp.IsSuccess() {
+		body, _ := io.ReadAll(io.LimitReader(streamResp.Body, maxDrainBytes))
+		var apiErr UpdateErr
+		errMsg := string(body)
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Message != "" {
+			errMsg = apiErr.Message
+		}
+		log.Printf("Content update API request failed with status %d: %s", streamResp.StatusCode, errMsg)
+		return nil, nil, cstmerr.NewAPIRequestFailedError(streamResp.StatusCode, errMsg)
+	}
+
+	var processedItems []SharedModels.ProcessedContentSchema
+	fetched := 0
+	contentResp, err := decodeContentStream(streamResp.Body, func(item SharedModels.GenericContentItem) error {
+		fetched++
+		processed, err := ac.extractContentItem(item)
+		if processed != nil {
+			processedItems = append(processedItems, *processed)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Printf("Received streamed content update response. Count: %d, Items: %d, Schema version: %d",
+		contentResp.Count, fetched, contentResp.SchemaVersion)
+	// Nothing has been processed yet, so a schema version sent after the items is still in time.
+	if contentResp.SchemaVersion > CONTENT_SCHEMA_VERSION {
+		schemaErr := cstmerr.NewSchemaVersionError(contentResp.SchemaVersion, CONTENT_SCHEMA_VERSION)
+		log.Printf("Content update response rejected: %v", schemaErr)
+		return nil, nil, schemaErr
+	}
+	log.Printf("Successfully processed %d content items.", len(processedItems))
+	return contentResp, processedItems, nil
+}
+
+// decodeContentStream decodes a content update response from r, passing each element
+// of "contents" to handle as soon as it is decoded instead of collecting them. Other
+// fields are decoded into the returned response. An error from handle stops decoding.
+func decodeContentStream(r io.Reader,
+	handle func(SharedModels.GenericContentItem) error) (*SharedModels.ContentUpdateResponse, error) {
+	dec := json.NewDecoder(r)
+	var contentResp SharedModels.ContentUpdateResponse
+	decodeErr := func(err error) error {
+		return cstmerr.NewAPIClientError(fmt.Errorf("failed to decode content update stream: %w", err))
+	}
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, decodeErr(err)
+	}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, decodeErr(err)
+		}
+		key, _ := token.(string)
+		switch key {
+		case "contents":
+			if err := expectDelim(dec, '['); err != nil {
+				return nil, decodeErr(err)
+			}
+			for dec.More() {
+				var item SharedModels.GenericContentItem
+				if err := dec.Decode(&item); err != nil {
+					return nil, decodeErr(err)
+				}
+				if err := handle(item); err != nil {
+					return nil, err
+				}
+			}
+			if err := expectDelim(dec, ']'); err != nil {
+				return nil, decodeErr(err)
+			}
+		case "count":
+			err = dec.Decode(&contentResp.Count)
+		case "schemaVersion":
+			err = dec.Decode(&contentResp.SchemaVersion)
+		default:
+			var skipped json.RawMessage
+			err = dec.Decode(&skipped)
+		}
+		if err != nil {
+			return nil, decodeErr(err)
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, decodeErr(err)
+	}
+	return &contentResp, nil
+}
+
+// expectDelim reads the next token from dec and checks that it is delim.
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := token.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("expected %q, got %v", delim, token)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package apiclient
+
+import (
+	"embedup-go/configs/config"
+	"embedup-go/internal/cstmerr"
+	SharedModels "embedup-go/internal/shared"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// itemStream generates a content update response of n advertisements as it is read,
+// counting the bytes handed out and where each item ends.
+type itemStream struct {
+	n, next   int
+	pending   string
+	generated int
+	read      int
+	itemEnds  []int
+}
+
+func (s *itemStream) Read(p []byte) (int, error) {
+	for s.pending == "" {
+		switch {
+		case s.next == 0:
+			s.pending = `{"count": 7, "contents": [`
+		case s.next <= s.n:
+			s.pending = fmt.Sprintf(`{"id": %d, "type": "local-advertisement", "updatedAt": 1000, "enable": true,
+				"content": {"fileLink": "https://cdn.test/ad-%d.mp4", "skipDuration": 5}}`, s.next, s.next)
+			if s.next < s.n {
+				s.pending += ","
+			}
+			s.itemEnds = append(s.itemEnds, s.generated+len(s.pending))
+		case s.next == s.n+1:
+			s.pending = `], "schemaVersion": 1}`
+		default:
+			return 0, io.EOF
+		}
+		s.generated += len(s.pending)
+		s.next++
+	}
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	s.read += n
+	return n, nil
+}
+
+func TestDecodeContentStreamReadsIncrementally(t *testing.T) {
+	const items = 20000 // A few MB of JSON
+	stream := &itemStream{n: items}
+	handled := 0
+	maxAhead := 0
+	resp, err := decodeContentStream(stream, func(item SharedModels.GenericContentItem) error {
+		handled++
+		if item.ID != int64(handled) {
+			return fmt.Errorf("item %d arrived as item %d", item.ID, handled)
+		}
+		// Items are handled as they are read, so the decoder never reads far ahead.
+		maxAhead = max(maxAhead, stream.read-stream.itemEnds[handled-1])
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("decodeContentStream: %v", err)
+	}
+	if handled != items || resp.Count != 7 || resp.SchemaVersion != 1 || resp.Contents != nil {
+		t.Fatalf("decoded %d items and %+v", handled, resp)
+	}
+	if maxAhead > 64<<10 {
+		t.Fatalf("decoder read %d bytes ahead of the items handled, want it bounded by its buffer", maxAhead)
+	}
+}
+
+func TestDecodeContentStreamErrors(t *testing.T) {
+	stop := errors.New("stop")
+	_, err := decodeContentStream(&itemStream{n: 10}, func(item SharedModels.GenericContentItem) error {
+		if item.ID == 3 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("decodeContentStream with a failing handler = %v, want its error", err)
+	}
+
+	for _, body := range []string{``, `[]`, `{"contents": {}}`, `{"contents": [{"id": "x"}]}`, `{"contents": [`, `{"count": 1`} {
+		_, err := decodeContentStream(strings.NewReader(body), func(SharedModels.GenericContentItem) error { return nil })
+		var clientErr *cstmerr.APIClientError
+		if !errors.As(err, &clientErr) {
+			t.Errorf("decodeContentStream(%q) = %v, want an APIClientError", body, err)
+		}
+	}
+}
+
+func TestFetchContentUpdatesStreamed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("size") != "50" || r.Header.Get("X-Schema-Version") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		io.Copy(w, &itemStream{n: 50})
+	}))
+	defer server.Close()
+	client := New(&config.Config{ContentUpdateAPIURL: server.URL, ContentUpdateStreaming: true}, "test-token")
+
+	resp, items, err := client.FetchContentUpdates(SharedModels.ContentUpdateRequestParams{Size: 50})
+	if err != nil {
+		t.Fatalf("FetchContentUpdates: %v", err)
+	}
+	if resp.Count != 7 || resp.Contents != nil || len(items) != 50 || items[49].ID != 50 {
+		t.Fatalf("FetchContentUpdates = %+v with %d items", resp, len(items))
+	}
+	if details, ok := items[0].Details.(SharedModels.LocalAdvertisementSchema); !ok || details.FileLink != "https://cdn.test/ad-1.mp4" {
+		t.Fatalf("first item details = %+v", items[0].Details)
+	}
+}
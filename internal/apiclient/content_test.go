@@ -0,0 +1,213 @@
+package apiclient
+
+import (
+	"context"
+	"embedup-go/configs/config"
+	"embedup-go/internal/cstmerr"
+	SharedModels "embedup-go/internal/shared"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// stubRequest is a request stubClient received.
+type stubRequest struct {
+	method string
+	url    string
+	opts   *RequestOptions
+}
+
+// stubClient answers every request with respond and headers, decoding its JSON body
+// into the request's SuccessResult or ErrorResult as the resty adapter does, and
+// records it.
+type stubClient struct {
+	HTTPClient
+	mu       sync.Mutex
+	requests []stubRequest
+	respond  func(method, url string) (int, string)
+	headers  http.Header
+}
+
+func (s *stubClient) do(method, url string, opts *RequestOptions) (*Response, error) {
+	s.mu.Lock()
+	s.requests = append(s.requests, stubRequest{method: method, url: url, opts: opts})
+	s.mu.Unlock()
+	status, body := s.respond(method, url)
+	if opts != nil {
+		result := opts.SuccessResult
+		if status >= 400 {
+			result = opts.ErrorResult
+		}
+		if result != nil && body != "" {
+			if err := json.Unmarshal([]byte(body), result); err != nil {
+				return nil, err
+			}
+		}
+	}
+	headers := s.headers.Clone()
+	if headers == nil {
+		headers = http.Header{}
+	}
+	return &Response{StatusCode: status, Body: []byte(body), Headers: headers, RequestURL: url}, nil
+}
+
+func (s *stubClient) Get(url string, opts *RequestOptions) (*Response, error) {
+	return s.do(http.MethodGet, url, opts)
+}
+
+func (s *stubClient) Post(url string, opts *RequestOptions) (*Response, error) {
+	return s.do(http.MethodPost, url, opts)
+}
+
+func (s *stubClient) Put(url string, opts *RequestOptions) (*Response, error) {
+	return s.do(http.MethodPut, url, opts)
+}
+
+func (s *stubClient) Head(url string, opts *RequestOptions) (*Response, error) {
+	return s.do(http.MethodHead, url, opts)
+}
+
+// lastRequest returns the most recent request.
+func (s *stubClient) lastRequest(t *testing.T) stubRequest {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		t.Fatal("no request sent")
+	}
+	return s.requests[len(s.requests)-1]
+}
+
+const testItemURL = "https://api.test/content/items"
+
+func TestGetContentItemContext(t *testing.T) {
+	stub := &stubClient{respond: func(method, url string) (int, string) {
+		switch url {
+		case testItemURL + "/42":
+			return http.StatusOK, `{"id": 42, "type": "local-advertisement", "updatedAt": 1700000000000, "enable": true,
+				"content": {"fileLink": "https://cdn.test/ad.mp4", "skipDuration": 5}}`
+		case testItemURL + "/43":
+			return http.StatusInternalServerError, `{"message": "database is down"}`
+		}
+		return http.StatusNotFound, `{"message": "no such item"}`
+	}}
+	client := NewWithHTTPClient(&config.Config{ContentItemAPIURL: testItemURL}, "test-token", stub)
+
+	item, err := client.GetContentItemContext(context.Background(), 42)
+	if err != nil {
+		t.Fatalf("GetContentItemContext(42): %v", err)
+	}
+	want := SharedModels.LocalAdvertisementSchema{FileLink: "https://cdn.test/ad.mp4", SkipDuration: 5}
+	if item.ID != 42 || item.Type != "local-advertisement" || item.UpdatedAt != 1700000000000 || !item.Enable ||
+		item.Details != want {
+		t.Fatalf("item = %+v", item)
+	}
+	req := stub.lastRequest(t)
+	if req.method != http.MethodGet || req.opts.Headers["device-token"] != "test-token" {
+		t.Fatalf("request = %s %s with headers %v", req.method, req.url, req.opts.Headers)
+	}
+
+	_, err = client.GetContentItemContext(context.Background(), 7)
+	var notFound *cstmerr.ContentNotFoundError
+	if !errors.As(err, &notFound) {
+		t.Fatalf("GetContentItemContext(7) = %v, want a ContentNotFoundError", err)
+	}
+
+	_, err = client.GetContentItemContext(context.Background(), 43)
+	var apiErr *cstmerr.APIRequestFailedError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError || apiErr.Message != "database is down" {
+		t.Fatalf("GetContentItemContext(43) = %v, want the server's 500", err)
+	}
+}
+
+func TestParseContentItemTombstone(t *testing.T) {
+	for _, content := range []string{``, `null`, `{"fileLink": "https://cdn.test/ad.mp4"}`} {
+		item := SharedModels.GenericContentItem{ID: 9, Type: "local-advertisement", UpdatedAt: 3000, Deleted: true,
+			Content: json.RawMessage(content)}
+		processed, err := parseContentItem(item)
+		if err != nil {
+			t.Fatalf("parseContentItem(tombstone with content %q): %v", content, err)
+		}
+		want := SharedModels.ProcessedContentSchema{ID: 9, Type: "local-advertisement", UpdatedAt: 3000, Deleted: true}
+		if processed == nil || !reflect.DeepEqual(*processed, want) {
+			t.Fatalf("parseContentItem(tombstone with content %q) = %+v, want %+v", content, processed, want)
+		}
+	}
+
+	// A tombstone of a type this client does not know is skipped like any such item.
+	processed, err := parseContentItem(SharedModels.GenericContentItem{ID: 10, Type: "local-hologram", Deleted: true})
+	if err != nil || processed != nil {
+		t.Fatalf("parseContentItem(unknown tombstone) = %+v, %v; want it skipped", processed, err)
+	}
+}
+
+func TestGenericContentItemDecodesDeleted(t *testing.T) {
+	var item SharedModels.GenericContentItem
+	if err := json.Unmarshal([]byte(`{"id": 9, "type": "local-movie", "updatedAt": 3000, "deleted": true}`), &item); err != nil {
+		t.Fatal(err)
+	}
+	if !item.Deleted || item.Content != nil {
+		t.Fatalf("item = %+v, want a tombstone without content", item)
+	}
+}
+
+func TestParseContentItemRejectsEmptyContent(t *testing.T) {
+	for _, content := range []string{``, `null`, "  \n\t ", " null "} {
+		item := SharedModels.GenericContentItem{ID: 11, Type: "local-movie", Enable: true, Content: json.RawMessage(content)}
+		if processed, err := parseContentItem(item); err == nil {
+			t.Errorf("parseContentItem(content %q) = %+v, want an error", content, processed)
+		}
+	}
+
+	item := SharedModels.GenericContentItem{ID: 11, Type: "local-movie", Enable: true,
+		Content: json.RawMessage(` {"movieId": 77, "fileLink": "https://cdn.test/m.zip"} `)}
+	processed, err := parseContentItem(item)
+	if err != nil {
+		t.Fatalf("parseContentItem: %v", err)
+	}
+	if movie, ok := processed.Details.(SharedModels.LocalMovieSchema); !ok || movie.MovieID != 77 {
+		t.Fatalf("details = %+v, want movie 77", processed.Details)
+	}
+}
+
+func TestExtractContentItemUnknownTypePolicy(t *testing.T) {
+	unknown := SharedModels.GenericContentItem{ID: 12, Type: "local-hologram", UpdatedAt: 4000, Enable: true,
+		Content: json.RawMessage(`{"beam": 3}`)}
+	known := SharedModels.GenericContentItem{ID: 13, Type: "local-advertisement", UpdatedAt: 5000, Enable: true,
+		Content: json.RawMessage(`{"fileLink": "https://cdn.test/ad.mp4", "skipDuration": 5}`)}
+	tests := []struct {
+		policy      string
+		wantErr     bool
+		wantUnknown *SharedModels.ProcessedContentSchema
+	}{
+		{config.UNKNOWN_TYPE_POLICY_SKIP, false, nil},
+		{"", false, nil},
+		{config.UNKNOWN_TYPE_POLICY_ERROR, true, nil},
+		{config.UNKNOWN_TYPE_POLICY_QUARANTINE, false,
+			&SharedModels.ProcessedContentSchema{ID: 12, Type: "local-hologram", UpdatedAt: 4000, UnknownType: true}},
+	}
+	for _, tt := range tests {
+		t.Run("policy "+tt.policy, func(t *testing.T) {
+			client := NewWithHTTPClient(&config.Config{UnknownTypePolicy: tt.policy}, "test-token", &stubClient{})
+
+			processed, err := client.extractContentItem(unknown)
+			var clientErr *cstmerr.APIClientError
+			if tt.wantErr != errors.As(err, &clientErr) {
+				t.Fatalf("extractContentItem(unknown) error = %v, want an APIClientError: %t", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(processed, tt.wantUnknown) {
+				t.Fatalf("extractContentItem(unknown) = %+v, want %+v", processed, tt.wantUnknown)
+			}
+
+			// Known types parse the same under every policy.
+			processed, err = client.extractContentItem(known)
+			if err != nil || processed == nil || processed.UnknownType ||
+				processed.Details != (SharedModels.LocalAdvertisementSchema{FileLink: "https://cdn.test/ad.mp4", SkipDuration: 5}) {
+				t.Fatalf("extractContentItem(known) = %+v, %v", processed, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package apiclient
+
+import (
+	SharedModels "embedup-go/internal/shared"
+	"encoding/json"
+	"sync"
+)
+
+// ContentParser decodes the raw "content" of a GenericContentItem into its schema struct.
+type ContentParser func(raw json.RawMessage) (any, error)
+
+var (
+	contentParsersMu sync.RWMutex
+	contentParsers   = map[string]ContentParser{}
+)
+
+// RegisterContentType maps a content type string to its parser, replacing any
+// parser already registered for it.
+func RegisterContentType(contentType string, parser ContentParser) {
+	contentParsersMu.Lock()
+	defer contentParsersMu.Unlock()
+	contentParsers[contentType] = parser
+}
+
+// RegisterContentSchema registers a content type whose content decodes directly into T.
+func RegisterContentSchema[T any](contentType string) {
+	RegisterContentType(contentType, func(raw json.RawMessage) (any, error) {
+		var schema T
+		if err := json.Unmarshal(raw, &schema); err != nil {
+			return nil, err
+		}
+		return schema, nil
+	})
+}
+
+func lookupContentParser(contentType string) (ContentParser, bool) {
+	contentParsersMu.RLock()
+	defer contentParsersMu.RUnlock()
+	parser, ok := contentParsers[contentType]
+	return parser, ok
+}
+
+func init() {
+	RegisterContentSchema[SharedModels.LocalAdvertisementSchema]("local-advertisement")
+	RegisterContentSchema[SharedModels.LocalPageSchema]("local-page")
+	RegisterContentSchema[SharedModels.LocalMovieSchema]("local-movie")
+	RegisterContentSchema[SharedModels.LocalSectionSchema]("local-section")
+	RegisterContentSchema[SharedModels.LocalSeriesSchema]("local-series")
+	RegisterContentSchema[SharedModels.LocalSeriesEpisodeSchema]("local-series-episode")
+	RegisterContentSchema[SharedModels.LocalSeriesSeasonSchema]("local-series-season")
+	RegisterContentSchema[SharedModels.LocalSliderSchema]("local-slider")
+	RegisterContentSchema[SharedModels.LocalTabSchema]("local-tab")
+	RegisterContentSchema[SharedModels.LocalMovieGenreSchema]("local-movie-genre")
+	RegisterContentSchema[SharedModels.LocalPollSchema]("local-poll")
+	RegisterContentSchema[SharedModels.LocalSectionContentSchema]("local-section-content")
+	RegisterContentSchema[SharedModels.LocalPodcastSchema]("local-podcast")
+	RegisterContentSchema[SharedModels.LocalPodcastParentSchema]("local-podcastparent")
+	RegisterContentSchema[SharedModels.LocalAudiobookSchema]("local-audiobook")
+	RegisterContentSchema[SharedModels.LocalAudiobookParentSchema]("local-audiobookparent")
+	RegisterContentSchema[SharedModels.LocalMusicSchema]("local-music")
+	RegisterContentSchema[SharedModels.LocalAlbumSchema]("local-album")
+	RegisterContentSchema[SharedModels.LocalDeviceUpdateSchema]("local-device-update")
+	RegisterContentSchema[SharedModels.LocalTermsConditionsSchema]("local-terms-conditions")
+	// TODO: local-news and local-magazine once their schemas exist
+}
@@ -0,0 +1,88 @@
+package apiclient
+
+import (
+	SharedModels "embedup-go/internal/shared"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// registerForTest registers parser for contentType until the test ends.
+func registerForTest(t *testing.T, contentType string, parser ContentParser) {
+	t.Helper()
+	RegisterContentType(contentType, parser)
+	t.Cleanup(func() {
+		contentParsersMu.Lock()
+		defer contentParsersMu.Unlock()
+		delete(contentParsers, contentType)
+	})
+}
+
+func TestLookupContentParserBuiltIn(t *testing.T) {
+	parser, ok := lookupContentParser("local-movie")
+	if !ok {
+		t.Fatal("local-movie has no parser")
+	}
+	parsed, err := parser(json.RawMessage(`{"id": 7}`))
+	if err != nil {
+		t.Fatalf("parsing local-movie: %v", err)
+	}
+	if _, ok := parsed.(SharedModels.LocalMovieSchema); !ok {
+		t.Fatalf("local-movie parsed into %T, want LocalMovieSchema", parsed)
+	}
+	if _, ok := lookupContentParser("local-news"); ok {
+		t.Fatal("local-news has a parser, want none")
+	}
+}
+
+func TestRegisterContentType(t *testing.T) {
+	type widget struct {
+		Name string `json:"name"`
+	}
+	failure := errors.New("unsupported widget")
+	registerForTest(t, "test-widget", func(raw json.RawMessage) (any, error) {
+		var w widget
+		if err := json.Unmarshal(raw, &w); err != nil {
+			return nil, err
+		}
+		if w.Name == "" {
+			return nil, failure
+		}
+		return w, nil
+	})
+
+	item := SharedModels.GenericContentItem{ID: 3, Type: "test-widget", UpdatedAt: 10, Enable: true,
+		Content: json.RawMessage(`{"name": "clock"}`)}
+	processed, err := parseContentItem(item)
+	if err != nil {
+		t.Fatalf("parseContentItem: %v", err)
+	}
+	want := &SharedModels.ProcessedContentSchema{ID: 3, Type: "test-widget", UpdatedAt: 10, Enable: true,
+		Details: widget{Name: "clock"}}
+	if !reflect.DeepEqual(processed, want) {
+		t.Fatalf("parseContentItem = %+v, want %+v", processed, want)
+	}
+
+	item.Content = json.RawMessage(`{"name": ""}`)
+	if _, err := parseContentItem(item); !errors.Is(err, failure) {
+		t.Fatalf("parseContentItem = %v, want the parser's error", err)
+	}
+}
+
+func TestRegisterContentSchemaReplacesParser(t *testing.T) {
+	type widgetV1 struct{ Name string }
+	type widgetV2 struct{ Title string }
+	registerForTest(t, "test-widget", nil)
+	RegisterContentSchema[widgetV1]("test-widget")
+	RegisterContentSchema[widgetV2]("test-widget")
+
+	processed, err := parseContentItem(SharedModels.GenericContentItem{ID: 1, Type: "test-widget",
+		Content: json.RawMessage(`{"Title": "clock"}`)})
+	if err != nil {
+		t.Fatalf("parseContentItem: %v", err)
+	}
+	if got, want := processed.Details, (widgetV2{Title: "clock"}); got != want {
+		t.Fatalf("Details = %#v, want %#v", got, want)
+	}
+}
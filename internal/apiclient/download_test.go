@@ -0,0 +1,414 @@
+package apiclient
+
+import (
+	"bytes"
+	"crypto/md5"
+	"embedup-go/configs/config"
+	"embedup-go/internal/clocktest"
+	"embedup-go/internal/cstmerr"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestClient returns an APIClient with the real HTTP adapter and default transport
+// settings, for use against an httptest server.
+func newTestClient(t *testing.T, cfg *config.Config) *APIClient {
+	t.Helper()
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	client := NewRestyAdapter(DefaultTransportTimeouts(), DefaultRedirectSettings())
+	return NewWithHTTPClient(cfg, "test-token", client)
+}
+
+// serveFile serves content under any path, with HEAD, Range and Content-Length support.
+func serveFile(t *testing.T, content []byte) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "asset.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func readFile(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	return data
+}
+
+func TestDownloadFileKeepsCompleteFile(t *testing.T) {
+	content := []byte("the remote asset")
+	server := serveFile(t, content)
+	dest := filepath.Join(t.TempDir(), "asset.bin")
+	if err := os.WriteFile(dest, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := newTestClient(t, nil).DownloadFile(server.URL, dest); err != nil {
+		t.Fatalf("DownloadFile: %v", err)
+	}
+	if got := readFile(t, dest); !bytes.Equal(got, content) {
+		t.Fatalf("content = %q, want %q", got, content)
+	}
+}
+
+func TestDownloadFileReplacesFileLargerThanRemote(t *testing.T) {
+	content := []byte("new asset")
+	server := serveFile(t, content)
+	dest := filepath.Join(t.TempDir(), "asset.bin")
+	if err := os.WriteFile(dest, []byte("an older, longer version of the asset"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := newTestClient(t, nil).DownloadFile(server.URL, dest); err != nil {
+		t.Fatalf("DownloadFile: %v", err)
+	}
+	if got := readFile(t, dest); !bytes.Equal(got, content) {
+		t.Fatalf("content = %q, want %q", got, content)
+	}
+}
+
+func TestDownloadFileRestartsPartFileLargerThanRemote(t *testing.T) {
+	content := []byte("new asset")
+	server := serveFile(t, content)
+	dest := filepath.Join(t.TempDir(), "asset.bin")
+	if err := os.WriteFile(dest+PART_FILE_SUFFIX, []byte("an older, longer partial download"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := newTestClient(t, nil).DownloadFile(server.URL, dest); err != nil {
+		t.Fatalf("DownloadFile: %v", err)
+	}
+	if got := readFile(t, dest); !bytes.Equal(got, content) {
+		t.Fatalf("content = %q, want %q", got, content)
+	}
+	if _, err := os.Stat(dest + PART_FILE_SUFFIX); !os.IsNotExist(err) {
+		t.Fatalf("part file left behind: %v", err)
+	}
+}
+
+func TestDownloadFileResumesPartFile(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 10))
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			ranges = append(ranges, r.Header.Get("Range"))
+		}
+		http.ServeContent(w, r, "asset.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+	dest := filepath.Join(t.TempDir(), "asset.bin")
+	if err := os.WriteFile(dest+PART_FILE_SUFFIX, content[:40], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := newTestClient(t, nil).DownloadFile(server.URL, dest); err != nil {
+		t.Fatalf("DownloadFile: %v", err)
+	}
+	if got := readFile(t, dest); !bytes.Equal(got, content) {
+		t.Fatalf("content = %q, want %q", got, content)
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=40-" {
+		t.Fatalf("GET ranges = %q, want one resumed from byte 40", ranges)
+	}
+}
+
+func TestLocalFilePath(t *testing.T) {
+	tests := []struct {
+		url    string
+		want   string
+		wantOK bool
+	}{
+		{"file:///media/usb/movie.zip", "/media/usb/movie.zip", true},
+		{"file:///media/usb/with%20space.zip", "/media/usb/with space.zip", true},
+		{"/media/usb/movie.zip", "/media/usb/movie.zip", true},
+		{"file://", "", false},
+		{"https://cdn.test/movie.zip", "", false},
+		{"media/usb/movie.zip", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := localFilePath(tt.url)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("localFilePath(%q) = %q, %t; want %q, %t", tt.url, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestDownloadFileCopiesLocalFile(t *testing.T) {
+	content := []byte("movie copied from a usb stick")
+	sum := md5.Sum(content)
+	wantMD5 := hex.EncodeToString(sum[:])
+	src := filepath.Join(t.TempDir(), "movie.zip")
+	if err := os.WriteFile(src, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, url := range map[string]string{"file URL": "file://" + src, "absolute path": src} {
+		t.Run(name, func(t *testing.T) {
+			client := newTestClient(t, nil)
+			info, err := client.GetFileInformation(url)
+			if err != nil || info.MD5 != wantMD5 {
+				t.Fatalf("GetFileInformation = %+v, %v; want MD5 %s", info, err, wantMD5)
+			}
+			dest := filepath.Join(t.TempDir(), "movie.zip")
+			if err := client.DownloadFileWithRetryMD5(url, dest, info.MD5); err != nil {
+				t.Fatalf("DownloadFileWithRetryMD5: %v", err)
+			}
+			if got := readFile(t, dest); !bytes.Equal(got, content) {
+				t.Fatalf("copied content = %q, want %q", got, content)
+			}
+			if _, err := os.Stat(dest + PART_FILE_SUFFIX); !os.IsNotExist(err) {
+				t.Fatalf("part file left behind: %v", err)
+			}
+		})
+	}
+}
+
+func TestDownloadFileLocalFileChecksumMismatch(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "movie.zip")
+	if err := os.WriteFile(src, []byte("corrupted on the stick"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	client := newTestClient(t, nil)
+	client.SetClock(clocktest.NewFakeClock(time.Unix(0, 0)))
+	dest := filepath.Join(t.TempDir(), "movie.zip")
+
+	err := client.DownloadFileWithRetryMD5(src, dest, "00000000000000000000000000000000")
+	var checksumErr *cstmerr.ChecksumError
+	if !errors.As(err, &checksumErr) {
+		t.Fatalf("DownloadFileWithRetryMD5 = %v, want a ChecksumError", err)
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Fatalf("copy that failed verification kept: %v", err)
+	}
+}
+
+func TestDownloadFileMissingLocalFile(t *testing.T) {
+	err := newTestClient(t, nil).DownloadFile("file:///nonexistent/movie.zip", filepath.Join(t.TempDir(), "movie.zip"))
+	var downloadErr *cstmerr.DownloadError
+	if !errors.As(err, &downloadErr) {
+		t.Fatalf("DownloadFile = %v, want a DownloadError", err)
+	}
+}
+
+func TestSyncingWriterResetsAfterEvery(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "asset.bin.part"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	sw := &syncingWriter{file: file, every: 10}
+
+	for _, step := range []struct {
+		write        int
+		wantUnsynced int64
+	}{
+		{4, 4},
+		{4, 8},
+		{4, 0}, // 12 bytes reach every, so the file is synced
+		{9, 9},
+		{1, 0}, // Exactly every
+		{25, 0},
+	} {
+		n, err := sw.Write(bytes.Repeat([]byte("x"), step.write))
+		if err != nil || n != step.write {
+			t.Fatalf("Write(%d) = %d, %v", step.write, n, err)
+		}
+		if sw.unsynced != step.wantUnsynced {
+			t.Fatalf("after writing %d: unsynced = %d, want %d", step.write, sw.unsynced, step.wantUnsynced)
+		}
+	}
+	if info, err := file.Stat(); err != nil || info.Size() != 47 {
+		t.Fatalf("file size = %v, %v; want 47", info, err)
+	}
+}
+
+func TestDownloadFileWithFsync(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	server := serveFile(t, content)
+	dest := filepath.Join(t.TempDir(), "asset.bin")
+	cfg := &config.Config{DownloadFsync: true, DownloadFsyncEveryBytes: 64}
+
+	if err := newTestClient(t, cfg).DownloadFile(server.URL, dest); err != nil {
+		t.Fatalf("DownloadFile: %v", err)
+	}
+	if got := readFile(t, dest); !bytes.Equal(got, content) {
+		t.Fatalf("content = %d bytes, want %d", len(got), len(content))
+	}
+	if _, err := os.Stat(dest + PART_FILE_SUFFIX); !os.IsNotExist(err) {
+		t.Fatalf("part file left behind: %v", err)
+	}
+}
+
+// serveTruncated advertises size bytes but answers every GET with only the first half.
+func serveTruncated(t *testing.T, size int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", strconv.Itoa(size))
+			return
+		}
+		w.Write(bytes.Repeat([]byte("x"), size/2))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDownloadKeepsPartFileAfterRetryableFailure(t *testing.T) {
+	server := serveTruncated(t, 100)
+	client := newTestClient(t, nil)
+	clock := clocktest.NewFakeClock(time.Unix(0, 0))
+	client.SetClock(clock)
+	dest := filepath.Join(t.TempDir(), "asset.bin")
+
+	err := client.DownloadFileWithRetryMD5(server.URL, dest, "")
+	var downloadErr *cstmerr.DownloadError
+	if !errors.As(err, &downloadErr) || !isRetryableDownloadError(err) {
+		t.Fatalf("DownloadFileWithRetryMD5 = %v, want a retryable DownloadError", err)
+	}
+	if len(clock.Sleeps) != 3 {
+		t.Fatalf("retried %d times, want 3", len(clock.Sleeps))
+	}
+	if got := readFile(t, dest+PART_FILE_SUFFIX); len(got) != 50 {
+		t.Fatalf("part file = %d bytes, want the 50 received kept for the next attempt", len(got))
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Fatalf("incomplete download finalized: %v", err)
+	}
+}
+
+func TestDownloadRemovesPartFileAfterClientError(t *testing.T) {
+	for _, status := range []int{http.StatusForbidden, http.StatusNotFound, http.StatusGone} {
+		t.Run(strconv.Itoa(status), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodHead {
+					w.Header().Set("Content-Length", "100")
+					w.Header().Set("Accept-Ranges", "bytes")
+					return
+				}
+				w.WriteHeader(status)
+			}))
+			t.Cleanup(server.Close)
+			client := newTestClient(t, nil)
+			clock := clocktest.NewFakeClock(time.Unix(0, 0))
+			client.SetClock(clock)
+			dest := filepath.Join(t.TempDir(), "asset.bin")
+			if err := os.WriteFile(dest+PART_FILE_SUFFIX, []byte("partial"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := client.DownloadFileWithRetryMD5(server.URL, dest, "")
+			var downloadErr *cstmerr.DownloadError
+			if !errors.As(err, &downloadErr) || downloadErr.StatusCode != status {
+				t.Fatalf("DownloadFileWithRetryMD5 = %v, want a DownloadError with status %d", err, status)
+			}
+			if len(clock.Sleeps) != 0 {
+				t.Fatalf("a %d was retried %d times", status, len(clock.Sleeps))
+			}
+			if _, err := os.Stat(dest + PART_FILE_SUFFIX); !os.IsNotExist(err) {
+				t.Fatalf("part file kept after a %d: %v", status, err)
+			}
+		})
+	}
+}
+
+func TestVerifyFileMD5(t *testing.T) {
+	content := []byte("the downloaded asset")
+	sum := md5.Sum(content)
+	wantMD5 := hex.EncodeToString(sum[:])
+	path := filepath.Join(t.TempDir(), "asset.bin")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{wantMD5, strings.ToUpper(wantMD5)} {
+		if err := verifyFileMD5(path, expected); err != nil {
+			t.Fatalf("verifyFileMD5(%s): %v", expected, err)
+		}
+	}
+	if got := readFile(t, path); !bytes.Equal(got, content) {
+		t.Fatal("verified file changed")
+	}
+
+	const wrongMD5 = "0123456789abcdef0123456789abcdef"
+	err := verifyFileMD5(path, wrongMD5)
+	var checksumErr *cstmerr.ChecksumError
+	if !errors.As(err, &checksumErr) || checksumErr.Expected != wrongMD5 || checksumErr.Actual != wantMD5 {
+		t.Fatalf("verifyFileMD5 = %v, want a ChecksumError of %s against %s", err, wantMD5, wrongMD5)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("corrupt file kept: %v", err)
+	}
+}
+
+// serveSized advertises headLength on HEAD (or no length if it is negative) and
+// answers every GET with body bytes.
+func serveSized(t *testing.T, headLength int, body int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			if headLength >= 0 {
+				w.Header().Set("Content-Length", strconv.Itoa(headLength))
+			} else {
+				w.(http.Flusher).Flush() // Sends the headers without a length
+			}
+			return
+		}
+		w.Write(bytes.Repeat([]byte("x"), body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDownloadFileChecksAdvertisedLength(t *testing.T) {
+	tests := []struct {
+		name       string
+		headLength int
+		body       int
+		wantErr    bool
+	}{
+		{"match", 100, 100, false},
+		{"short", 100, 60, true},
+		{"long", 100, 140, true},
+		{"unknown length", -1, 80, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := serveSized(t, tt.headLength, tt.body)
+			dest := filepath.Join(t.TempDir(), "asset.bin")
+
+			err := newTestClient(t, nil).DownloadFile(server.URL, dest)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("DownloadFile: %v", err)
+				}
+				if got := readFile(t, dest); len(got) != tt.body {
+					t.Fatalf("downloaded %d bytes, want %d", len(got), tt.body)
+				}
+				return
+			}
+			var downloadErr *cstmerr.DownloadError
+			if !errors.As(err, &downloadErr) || !strings.Contains(err.Error(), "incomplete download") {
+				t.Fatalf("DownloadFile = %v, want an incomplete download error", err)
+			}
+			if _, err := os.Stat(dest); !os.IsNotExist(err) {
+				t.Fatalf("download of the wrong size finalized: %v", err)
+			}
+			if got := readFile(t, dest+PART_FILE_SUFFIX); len(got) != tt.body {
+				t.Fatalf("part file = %d bytes, want the %d received", len(got), tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package apiclient
+
+import (
+	"context"
+	"embedup-go/configs/config"
+	"embedup-go/internal/cstmerr"
+	SharedModels "embedup-go/internal/shared"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEndpointsRequireURL(t *testing.T) {
+	tests := []struct {
+		key  string
+		call func(ac *APIClient) error
+	}{
+		{"update_check_api_url", func(ac *APIClient) error {
+			_, err := ac.CheckForUpdates()
+			return err
+		}},
+		{"content_update_api_url", func(ac *APIClient) error { return ac.Ping() }},
+		{"content_update_api_url", func(ac *APIClient) error {
+			_, _, err := ac.FetchContentUpdatesContext(context.Background(), SharedModels.ContentUpdateRequestParams{Size: 10})
+			return err
+		}},
+		{"status_report_api_url", func(ac *APIClient) error { return ac.ReportStatus(1, "ok") }},
+		{"status_report_batch_api_url", func(ac *APIClient) error {
+			return ac.sendStatusBatch([]StatusReportPayload{{VersionCode: 1, StatusMessage: "ok"}})
+		}},
+		{"status_history_api_url", func(ac *APIClient) error {
+			_, err := ac.GetStatusHistory(10, 0)
+			return err
+		}},
+		{"content_item_api_url", func(ac *APIClient) error {
+			_, err := ac.GetContentItemContext(context.Background(), 1)
+			return err
+		}},
+		{"content_detail_api_url", func(ac *APIClient) error {
+			_, err := ac.GetMovieDetail(77)
+			return err
+		}},
+		{"push_notify_url", func(ac *APIClient) error {
+			return ac.WatchPushNotifications(context.Background(), make(chan struct{}, 1))
+		}},
+	}
+	for _, tt := range tests {
+		for _, value := range []string{"", "  "} {
+			t.Run(tt.key, func(t *testing.T) {
+				cfg := &config.Config{
+					UpdateCheckAPIURL: value, ContentUpdateAPIURL: value, StatusReportAPIURL: value,
+					StatusReportBatchAPIURL: value, StatusHistoryAPIURL: value, ContentItemAPIURL: value,
+					ContentDetailAPIURL: value, PushNotifyURL: value,
+				}
+				stub := &stubClient{}
+				err := tt.call(NewWithHTTPClient(cfg, "test-token", stub))
+				var configErr *cstmerr.ConfigError
+				if !errors.As(err, &configErr) || !strings.Contains(err.Error(), tt.key) {
+					t.Fatalf("error = %v, want a ConfigError naming %s", err, tt.key)
+				}
+				if len(stub.requests) != 0 {
+					t.Fatalf("sent %d request(s) to an unconfigured endpoint", len(stub.requests))
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package apiclient
+
+import (
+	"context"
+	"embedup-go/configs/config"
+	"embedup-go/internal/clocktest"
+	"embedup-go/internal/cstmerr"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const testAssetURL = "https://cdn.test/movie.zip"
+
+func TestHeadInfo(t *testing.T) {
+	stub := &stubClient{
+		respond: func(method, url string) (int, string) { return http.StatusOK, "" },
+		headers: http.Header{
+			"Content-Length": {"1048576"},
+			"Accept-Ranges":  {"bytes"},
+			"Content-Type":   {"application/zip"},
+			"Etag":           {`"v2-abc"`},
+		},
+	}
+	client := NewWithHTTPClient(&config.Config{}, "test-token", stub)
+
+	info, err := client.HeadInfo(testAssetURL)
+	if err != nil {
+		t.Fatalf("HeadInfo: %v", err)
+	}
+	want := DownloadInfo{Size: 1048576, SupportsRange: true, ContentType: "application/zip", ETag: `"v2-abc"`}
+	if *info != want {
+		t.Fatalf("HeadInfo = %+v, want %+v", *info, want)
+	}
+	if req := stub.lastRequest(t); req.method != http.MethodHead || req.url != testAssetURL {
+		t.Fatalf("request = %s %s, want HEAD %s", req.method, req.url, testAssetURL)
+	}
+}
+
+func TestHeadInfoWithoutOptionalHeaders(t *testing.T) {
+	stub := &stubClient{respond: func(method, url string) (int, string) { return http.StatusOK, "" },
+		headers: http.Header{"Accept-Ranges": {"none"}}}
+	info, err := NewWithHTTPClient(&config.Config{}, "test-token", stub).HeadInfo(testAssetURL)
+	if err != nil {
+		t.Fatalf("HeadInfo: %v", err)
+	}
+	if *info != (DownloadInfo{}) {
+		t.Fatalf("HeadInfo = %+v, want nothing advertised", *info)
+	}
+}
+
+func TestHeadInfoErrorStatus(t *testing.T) {
+	for _, status := range []int{http.StatusNotFound, http.StatusForbidden, http.StatusInternalServerError, http.StatusNoContent} {
+		stub := &stubClient{respond: func(method, url string) (int, string) { return status, "" }}
+		_, err := NewWithHTTPClient(&config.Config{}, "test-token", stub).HeadInfo(testAssetURL)
+		var headErr *cstmerr.HeadError
+		if !errors.As(err, &headErr) || headErr.StatusCode != status {
+			t.Errorf("HeadInfo with status %d = %v, want a HeadError with that status", status, err)
+		}
+	}
+}
+
+func TestAdvertisedSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers http.Header
+		want    int64
+	}{
+		{"X-Content-Length", http.Header{"X-Content-Length": {"2048"}, "Content-Length": {"0"}}, 2048},
+		{"X-Content-Length over Content-Length", http.Header{"X-Content-Length": {"2048"}, "Content-Length": {"512"}}, 2048},
+		{"zero X-Content-Length falls back", http.Header{"X-Content-Length": {"0"}, "Content-Length": {"512"}}, 512},
+		{"negative X-Content-Length falls back", http.Header{"X-Content-Length": {"-1"}, "Content-Length": {"512"}}, 512},
+		{"malformed X-Content-Length falls back", http.Header{"X-Content-Length": {"2k"}, "Content-Length": {"512"}}, 512},
+		{"missing X-Content-Length", http.Header{"Content-Length": {"512"}}, 512},
+		{"zero Content-Length", http.Header{"Content-Length": {"0"}}, 0},
+		{"malformed Content-Length", http.Header{"Content-Length": {"big"}}, 0},
+		{"neither", http.Header{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := advertisedSize(testAssetURL, tt.headers); got != tt.want {
+				t.Fatalf("advertisedSize = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetFileInformationWithRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int // Per attempt, the last one repeated
+		wantErr      bool
+		wantAttempts int
+	}{
+		{"transient then success", []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}, false, 3},
+		{"request timeout", []int{http.StatusRequestTimeout, http.StatusOK}, false, 2},
+		{"not found", []int{http.StatusNotFound}, true, 1},
+		{"forbidden", []int{http.StatusForbidden}, true, 1},
+		{"server error exhausts retries", []int{http.StatusBadGateway}, true, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempt := 0
+			stub := &stubClient{
+				respond: func(method, url string) (int, string) {
+					status := tt.statuses[min(attempt, len(tt.statuses)-1)]
+					attempt++
+					return status, ""
+				},
+				headers: http.Header{"X-Content-Md5": {"0123456789abcdef0123456789abcdef"}},
+			}
+			client := NewWithHTTPClient(&config.Config{}, "test-token", stub)
+			client.SetClock(clocktest.NewFakeClock(time.Unix(0, 0)))
+
+			info, err := client.GetFileInformationWithRetry(context.Background(), testAssetURL)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("GetFileInformationWithRetry = %+v, %v; want error: %t", info, err, tt.wantErr)
+			}
+			if !tt.wantErr && info.MD5 != "0123456789abcdef0123456789abcdef" {
+				t.Fatalf("MD5 = %q", info.MD5)
+			}
+			if attempt != tt.wantAttempts {
+				t.Fatalf("%d HEAD requests, want %d", attempt, tt.wantAttempts)
+			}
+		})
+	}
+}
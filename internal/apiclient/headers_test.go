@@ -0,0 +1,105 @@
+package apiclient
+
+import (
+	"embedup-go/configs/config"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// recordHeaders serves 200s to every request and keeps the headers of the last one.
+func recordHeaders(t *testing.T) (*httptest.Server, func() http.Header) {
+	t.Helper()
+	var mu sync.Mutex
+	var last http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		last = r.Header.Clone()
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	return server, func() http.Header {
+		mu.Lock()
+		defer mu.Unlock()
+		return last
+	}
+}
+
+func TestDeviceIdentityHeaders(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        config.Config
+		wantSerial string
+		wantModel  string
+	}{
+		{"neither configured", config.Config{}, "", ""},
+		{"serial only", config.Config{DeviceSerial: "SN-0042"}, "SN-0042", ""},
+		{"model only", config.Config{DeviceModel: "podbox-2"}, "", "podbox-2"},
+		{"both", config.Config{DeviceSerial: "SN-0042", DeviceModel: "podbox-2"}, "SN-0042", "podbox-2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := deviceIdentityHeaders(&tt.cfg)
+			if headers["X-Device-OS"] != runtime.GOOS || headers["X-Device-Arch"] != runtime.GOARCH {
+				t.Fatalf("headers = %v, want the OS and architecture", headers)
+			}
+			serial, hasSerial := headers["X-Device-Serial"]
+			if serial != tt.wantSerial || hasSerial != (tt.wantSerial != "") {
+				t.Fatalf("X-Device-Serial = %q (present %t), want %q", serial, hasSerial, tt.wantSerial)
+			}
+			model, hasModel := headers["X-Device-Model"]
+			if model != tt.wantModel || hasModel != (tt.wantModel != "") {
+				t.Fatalf("X-Device-Model = %q (present %t), want %q", model, hasModel, tt.wantModel)
+			}
+
+			// New sends them with every request.
+			server, lastHeaders := recordHeaders(t)
+			cfg := tt.cfg
+			cfg.ContentUpdateAPIURL = server.URL
+			if err := New(&cfg, "test-token").Ping(); err != nil {
+				t.Fatalf("Ping: %v", err)
+			}
+			sent := lastHeaders()
+			if sent.Get("X-Device-OS") != runtime.GOOS || sent.Get("X-Device-Serial") != tt.wantSerial ||
+				sent.Get("X-Device-Model") != tt.wantModel {
+				t.Fatalf("sent headers = %v", sent)
+			}
+			if _, ok := sent["X-Device-Serial"]; ok != (tt.wantSerial != "") {
+				t.Fatalf("X-Device-Serial sent: %t, want %t", ok, tt.wantSerial != "")
+			}
+		})
+	}
+}
+
+func TestAuthorizationHeaderPerScheme(t *testing.T) {
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("gateway:s3cret"))
+	tests := []struct {
+		scheme string
+		want   string
+	}{
+		{"", ""},
+		{config.AUTH_SCHEME_NONE, ""},
+		{config.AUTH_SCHEME_BASIC, basic},
+		{config.AUTH_SCHEME_BEARER, "Bearer gw-token"},
+	}
+	for _, tt := range tests {
+		t.Run("scheme "+tt.scheme, func(t *testing.T) {
+			server, lastHeaders := recordHeaders(t)
+			cfg := &config.Config{ContentUpdateAPIURL: server.URL, AuthScheme: tt.scheme,
+				AuthUsername: "gateway", AuthPassword: "s3cret", BearerToken: "gw-token"}
+			if err := New(cfg, "test-token").Ping(); err != nil {
+				t.Fatalf("Ping: %v", err)
+			}
+			sent := lastHeaders()
+			if got := sent.Get("Authorization"); got != tt.want {
+				t.Fatalf("Authorization = %q, want %q", got, tt.want)
+			}
+			if got := sent.Get("device-token"); got != "test-token" {
+				t.Fatalf("device-token = %q, want it sent alongside Authorization", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package apiclient
+
+import (
+	"embedup-go/configs/config"
+	SharedModels "embedup-go/internal/shared"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// serveHistory returns a client whose status history API pages through events, and
+// the offsets it was asked for.
+func serveHistory(t *testing.T, events []SharedModels.StatusEvent) (*APIClient, *[]int) {
+	t.Helper()
+	var offsets []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		offsets = append(offsets, offset)
+		w.Header().Set("Content-Type", "application/json")
+		page := events[min(offset, len(events)):min(offset+limit, len(events))]
+		json.NewEncoder(w).Encode(SharedModels.StatusHistoryResponse{Events: page, Count: len(events)})
+	}))
+	t.Cleanup(server.Close)
+	return New(&config.Config{StatusHistoryAPIURL: server.URL}, "test-token"), &offsets
+}
+
+// readHistory pages through the whole history the way callers do, stopping at the
+// first short page.
+func readHistory(client *APIClient, limit int) ([]SharedModels.StatusEvent, error) {
+	var all []SharedModels.StatusEvent
+	for offset := 0; ; offset += limit {
+		page, err := client.GetStatusHistory(limit, offset)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if len(page) < limit {
+			return all, nil
+		}
+	}
+}
+
+func TestGetStatusHistoryPaging(t *testing.T) {
+	var events []SharedModels.StatusEvent
+	for i := 0; i < 5; i++ {
+		events = append(events, SharedModels.StatusEvent{VersionCode: 10 - i, StatusMessage: "online", CreatedAt: int64(5000 - i)})
+	}
+	tests := []struct {
+		name        string
+		events      []SharedModels.StatusEvent
+		limit       int
+		wantOffsets []int
+	}{
+		{"empty", nil, 10, []int{0}},
+		{"single page", events[:3], 10, []int{0}},
+		{"several pages", events, 2, []int{0, 2, 4}},
+		{"exact pages", events[:4], 2, []int{0, 2, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, offsets := serveHistory(t, tt.events)
+			got, err := readHistory(client, tt.limit)
+			if err != nil {
+				t.Fatalf("reading the history: %v", err)
+			}
+			if len(got) != len(tt.events) || (len(got) > 0 && !reflect.DeepEqual(got, tt.events)) {
+				t.Fatalf("history = %+v, want %+v", got, tt.events)
+			}
+			if !reflect.DeepEqual(*offsets, tt.wantOffsets) {
+				t.Fatalf("requested offsets %v, want %v", *offsets, tt.wantOffsets)
+			}
+		})
+	}
+}
+
+func TestGetStatusHistoryErrors(t *testing.T) {
+	client, offsets := serveHistory(t, nil)
+	for _, page := range [][2]int{{0, 0}, {-1, 0}, {10, -1}} {
+		if _, err := client.GetStatusHistory(page[0], page[1]); err == nil {
+			t.Errorf("GetStatusHistory(%d, %d) accepted an invalid page", page[0], page[1])
+		}
+	}
+	if len(*offsets) != 0 {
+		t.Fatalf("invalid pages were requested: %v", *offsets)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"malformed", http.StatusOK, `{"events": [{"versionCode": "ten"}]}`},
+		{"truncated", http.StatusOK, `{"events": [`},
+		{"server error", http.StatusBadGateway, `{"message": "upstream down"}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+			client := New(&config.Config{StatusHistoryAPIURL: server.URL}, "test-token")
+			if events, err := client.GetStatusHistory(10, 0); err == nil {
+				t.Fatalf("GetStatusHistory = %+v, want an error", events)
+			}
+		})
+	}
+}
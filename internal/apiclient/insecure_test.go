@@ -0,0 +1,33 @@
+package apiclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInsecureHostsSkipVerificationOnlyForThem(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// The httptest certificate is self-signed, so only hosts listed as insecure reach it.
+	ra := NewRestyAdapter(DefaultTransportTimeouts(), DefaultRedirectSettings())
+	ra.SetInsecureHosts([]string{"Assets.Example.com"})
+	routeHosts(t, ra, server)
+
+	if resp, err := ra.Get("https://assets.example.com/file", nil); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Get from an insecure host = %+v, %v", resp, err)
+	}
+	if _, err := ra.Get("https://api.example.com/file", nil); err == nil {
+		t.Fatal("Get from a verified host accepted a self-signed certificate")
+	}
+
+	// Verified hosts still accept certificates from trusted roots.
+	trusted := NewRestyAdapter(DefaultTransportTimeouts(), DefaultRedirectSettings())
+	trustServer(trusted, server)
+	trusted.SetInsecureHosts([]string{"assets.example.com"})
+	routeHosts(t, trusted, server)
+	if resp, err := trusted.Get("https://api.example.com/file", nil); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Get from a verified host with a trusted certificate = %+v, %v", resp, err)
+	}
+}
@@ -0,0 +1,102 @@
+package apiclient
+
+import (
+	"context"
+	"embedup-go/internal/cstmerr"
+	SharedModels "embedup-go/internal/shared"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// localFilePath returns the local path rawURL refers to, if it is a file:// URL or an
+// absolute path, e.g. on a mounted USB stick for devices updated offline.
+func localFilePath(rawURL string) (string, bool) {
+	if strings.HasPrefix(rawURL, "file://") {
+		u, err := url.Parse(rawURL)
+		if err != nil || u.Path == "" {
+			return "", false
+		}
+		return u.Path, true
+	}
+	if filepath.IsAbs(rawURL) {
+		return rawURL, true
+	}
+	return "", false
+}
+
+// localFileInformation hashes a local file for GetFileInformation.
+func localFileInformation(path string) (SharedModels.FileInformation, error) {
+	hash, err := SharedModels.CalculateFileMD5(path)
+	if err != nil {
+		return SharedModels.FileInformation{}, cstmerr.NewFileIOError(fmt.Sprintf("failed to hash local file %s", path), err)
+	}
+	return SharedModels.FileInformation{MD5: hash}, nil
+}
+
+// localDownloadInfo describes a local file as HeadInfo would describe a remote one.
+func localDownloadInfo(path string) (*DownloadInfo, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, cstmerr.NewDownloadError(fmt.Sprintf("local file %s is not available: %v", path, err))
+	}
+	return &DownloadInfo{Size: info.Size(), SupportsRange: true}, nil
+}
+
+// copyLocalFile is DownloadFileContext for a local source: it copies src to
+// destinationPath through the part file, like a download, so a copy interrupted by
+// unplugging the stick is never taken for a complete file.
+func (ac *APIClient) copyLocalFile(ctx context.Context, src string, destinationPath string) error {
+	srcInfo, err := localDownloadInfo(src)
+	if err != nil {
+		return err
+	}
+	if fileInfo, err := os.Stat(destinationPath); err == nil && fileInfo.Size() == srcInfo.Size {
+		log.Printf("File %s already fully copied (%d bytes).", destinationPath, fileInfo.Size())
+		return ac.protectDownload(destinationPath)
+	}
+	if err := ctx.Err(); err != nil {
+		return cstmerr.NewTimeoutError(err)
+	}
+
+	source, err := os.Open(src)
+	if err != nil {
+		return cstmerr.NewDownloadError(fmt.Sprintf("failed to open local file %s: %v", src, err))
+	}
+	defer source.Close()
+
+	partPath := destinationPath + PART_FILE_SUFFIX
+	destFile, err := os.OpenFile(partPath, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, SharedModels.WRITABLE_FILE_MODE)
+	if err != nil {
+		return cstmerr.NewFileIOError(fmt.Sprintf("failed to open/create destination file %s", partPath), err)
+	}
+	defer destFile.Close()
+
+	log.Printf("Copying local file %s to %s", src, partPath)
+	copyStart := ac.clock.Now()
+	bytesWritten, err := io.Copy(destFile, source)
+	ac.stats.record(ctx, bytesWritten, ac.clock.Now().Sub(copyStart), err == nil)
+	if err != nil {
+		return cstmerr.NewDownloadError(fmt.Sprintf("error copying local file %s: %v", src, err))
+	}
+	if bytesWritten != srcInfo.Size {
+		return cstmerr.NewDownloadError(fmt.Sprintf("incomplete copy of %s: %d of %d bytes", src, bytesWritten, srcInfo.Size))
+	}
+	if ac.config.DownloadFsync {
+		if err := destFile.Sync(); err != nil {
+			return cstmerr.NewFileIOError(fmt.Sprintf("failed to sync copied file %s", partPath), err)
+		}
+	}
+	if err := destFile.Close(); err != nil {
+		return cstmerr.NewFileIOError(fmt.Sprintf("failed to close copied file %s", partPath), err)
+	}
+	if err := ac.finalizeDownload(partPath, destinationPath); err != nil {
+		return err
+	}
+	log.Printf("Copy complete: %s", destinationPath)
+	return nil
+}
@@ -0,0 +1,62 @@
+package apiclient
+
+import (
+	"bufio"
+	"context"
+	"embedup-go/internal/cstmerr"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// WatchPushNotifications opens a server-sent events stream to PushNotifyURL and sends
+// on notify whenever the server signals new content. Lines starting with ':' are
+// keep-alives and ignored; any other event triggers a notification once its
+// terminating blank line arrives. A notification already pending is not duplicated.
+// It blocks until the stream ends, fails, or ctx is done.
+func (ac *APIClient) WatchPushNotifications(ctx context.Context, notify chan<- struct{}) error {
+	if err := requireURL("push_notify_url", ac.config.PushNotifyURL); err != nil {
+		return err
+	}
+	opts := &RequestOptions{
+		Headers: map[string]string{
+			"device-token": ac.token,
+			"Accept":       "text/event-stream",
+		},
+		Context: ctx,
+	}
+	streamResp, err := ac.client.GetStream(ac.config.PushNotifyURL, opts)
+	if err != nil {
+		return err
+	}
+	defer streamResp.Body.Close()
+	if !streamResp.IsSuccess() {
+		return cstmerr.NewAPIRequestFailedError(streamResp.StatusCode,
+			fmt.Sprintf("push notification stream failed with status: %d", streamResp.StatusCode))
+	}
+	log.Printf("Listening for push notifications from %s", ac.config.PushNotifyURL)
+
+	pending := false
+	scanner := bufio.NewScanner(streamResp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if pending {
+				pending = false
+				select {
+				case notify <- struct{}{}:
+				default:
+				}
+			}
+		case strings.HasPrefix(line, ":"):
+			// Keep-alive comment.
+		default:
+			pending = true
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return cstmerr.NewAPIClientError(fmt.Errorf("reading push notification stream failed: %w", err))
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,118 @@
+package apiclient
+
+import (
+	"context"
+	"embedup-go/configs/config"
+	"embedup-go/internal/cstmerr"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// sseServer streams each string sent on lines to the client, then ends the stream
+// when lines is closed.
+func sseServer(t *testing.T, lines <-chan string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "text/event-stream" || r.Header.Get("device-token") != "test-token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					return
+				}
+				io.WriteString(w, line)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(server.CloseClientConnections)
+	return server
+}
+
+func expectNotification(t *testing.T, notify <-chan struct{}, want bool) {
+	t.Helper()
+	select {
+	case <-notify:
+		if !want {
+			t.Fatal("unexpected notification")
+		}
+	case <-time.After(100 * time.Millisecond):
+		if want {
+			t.Fatal("no notification")
+		}
+	}
+}
+
+func TestWatchPushNotifications(t *testing.T) {
+	lines := make(chan string)
+	server := sseServer(t, lines)
+	client := New(&config.Config{PushNotifyURL: server.URL}, "test-token")
+	notify := make(chan struct{}, 1)
+	done := make(chan error, 1)
+	go func() { done <- client.WatchPushNotifications(context.Background(), notify) }()
+
+	lines <- ": keep-alive\n\n"
+	expectNotification(t, notify, false)
+
+	// An event notifies only once its blank line arrives.
+	lines <- "event: content\ndata: {\"id\": 3}\n"
+	expectNotification(t, notify, false)
+	lines <- "\n"
+	expectNotification(t, notify, true)
+
+	// A notification already pending is not duplicated.
+	lines <- "data: 1\n\n"
+	time.Sleep(50 * time.Millisecond)
+	lines <- "data: 2\n\n"
+	time.Sleep(50 * time.Millisecond)
+	expectNotification(t, notify, true)
+	expectNotification(t, notify, false)
+
+	close(lines)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("WatchPushNotifications after the stream ended = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WatchPushNotifications still running after the stream ended")
+	}
+}
+
+func TestWatchPushNotificationsStops(t *testing.T) {
+	server := sseServer(t, make(chan string))
+	client := New(&config.Config{PushNotifyURL: server.URL}, "test-token")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- client.WatchPushNotifications(ctx, make(chan struct{}, 1)) }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("WatchPushNotifications after cancel = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WatchPushNotifications still running after cancel")
+	}
+
+	// A rejected stream is an error.
+	client = New(&config.Config{PushNotifyURL: server.URL}, "wrong-token")
+	err := client.WatchPushNotifications(context.Background(), make(chan struct{}, 1))
+	var apiErr *cstmerr.APIRequestFailedError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("WatchPushNotifications of a rejected stream = %v, want the 400", err)
+	}
+}
@@ -0,0 +1,70 @@
+package apiclient
+
+import (
+	"bytes"
+	"embedup-go/configs/config"
+	SharedModels "embedup-go/internal/shared"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func fileMode(t *testing.T, path string) os.FileMode {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Mode().Perm()
+}
+
+func TestDownloadFileAssetsReadOnly(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "asset.bin")
+	cfg := &config.Config{AssetsReadOnly: true}
+	client := newTestClient(t, cfg)
+
+	if err := client.DownloadFile(serveFile(t, []byte("the first and longest version")).URL, dest); err != nil {
+		t.Fatalf("DownloadFile: %v", err)
+	}
+	if mode := fileMode(t, dest); mode != SharedModels.READ_ONLY_FILE_MODE {
+		t.Fatalf("downloaded asset mode = %v, want %v", mode, SharedModels.READ_ONLY_FILE_MODE)
+	}
+
+	// A read-only asset is still replaced by a different remote one.
+	if err := client.DownloadFile(serveFile(t, []byte("second version")).URL, dest); err != nil {
+		t.Fatalf("DownloadFile over a read-only asset: %v", err)
+	}
+	if got := readFile(t, dest); !bytes.Equal(got, []byte("second version")) {
+		t.Fatalf("content = %q, want the second version", got)
+	}
+	if mode := fileMode(t, dest); mode != SharedModels.READ_ONLY_FILE_MODE {
+		t.Fatalf("replaced asset mode = %v, want %v", mode, SharedModels.READ_ONLY_FILE_MODE)
+	}
+
+	// With the guard disabled, a replaced asset is left writable.
+	cfg.AssetsReadOnly = false
+	if err := client.DownloadFile(serveFile(t, []byte("third")).URL, dest); err != nil {
+		t.Fatalf("DownloadFile: %v", err)
+	}
+	if mode := fileMode(t, dest); mode&0200 == 0 {
+		t.Fatalf("asset mode = %v with the guard disabled, want it writable", mode)
+	}
+}
+
+func TestRemoveAssetDeletesReadOnlyAsset(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "asset.bin")
+	if err := os.WriteFile(path, []byte("asset"), SharedModels.READ_ONLY_FILE_MODE); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SharedModels.RemoveAsset(path); err != nil {
+		t.Fatalf("RemoveAsset: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("read-only asset was kept: %v", err)
+	}
+	if err := SharedModels.RemoveAsset(path); !os.IsNotExist(err) {
+		t.Fatalf("RemoveAsset of a missing asset = %v, want not exist", err)
+	}
+}
@@ -0,0 +1,74 @@
+package apiclient
+
+import (
+	"bytes"
+	"embedup-go/configs/config"
+	"embedup-go/internal/shared"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// captureLog returns the buffer the standard logger writes to until the test ends.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(previous) })
+	return &buf
+}
+
+func TestDebugDumpRedactsSecrets(t *testing.T) {
+	const (
+		deviceToken = "device-token-5ecret"
+		dbPassword  = "db-pa55word"
+		dbConfPass  = "db-conf-pa55word"
+		bearer      = "bearer-5ecret"
+		basicPass   = "basic-pa55word"
+	)
+	// The server echoes every secret back, in its headers and its body.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session="+deviceToken)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"message":"` + strings.Join([]string{deviceToken, dbPassword, dbConfPass, bearer, basicPass}, " ") + `"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	for _, scheme := range []string{config.AUTH_SCHEME_BEARER, config.AUTH_SCHEME_BASIC} {
+		t.Run(scheme, func(t *testing.T) {
+			cfg := &config.Config{
+				UpdateCheckAPIURL: server.URL + "/check?token=" + deviceToken,
+				HTTPDebug:         true,
+				AuthScheme:        scheme,
+				AuthUsername:      "device",
+				AuthPassword:      basicPass,
+				BearerToken:       bearer,
+				DeviceToken:       deviceToken,
+				DBPassword:        dbPassword,
+				Database:          config.DatabaseConfig{Password: dbConfPass},
+			}
+			logs := captureLog(t)
+			New(cfg, deviceToken).CheckForUpdates()
+
+			dump := logs.String()
+			if !strings.Contains(dump, "DEBUG RESTY") {
+				t.Fatalf("no debug dump was logged:\n%s", dump)
+			}
+			basic := base64.StdEncoding.EncodeToString([]byte("device:" + basicPass))
+			for _, secret := range []string{deviceToken, dbPassword, dbConfPass, bearer, basicPass, basic} {
+				if strings.Contains(dump, secret) {
+					t.Errorf("secret %q was logged:\n%s", secret, dump)
+				}
+			}
+			for _, header := range []string{"Authorization", "Device-Token", "Set-Cookie"} {
+				if !strings.Contains(dump, header+": "+shared.REDACTED) {
+					t.Errorf("%s is not masked:\n%s", header, dump)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package apiclient
+
+import (
+	"context"
+	"crypto/x509"
+	"embedup-go/configs/config"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// routeHosts makes the adapter's transport dial server for every host name, so requests
+// to e.g. https://api.example.com reach it while keeping their host name for TLS
+// verification and redirects. The httptest certificate covers *.example.com.
+func routeHosts(t *testing.T, ra *RestyAdapter, server *httptest.Server) {
+	t.Helper()
+	transport, err := ra.client.HTTPTransport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := server.Listener.Addr().String()
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	}
+}
+
+// trustServer makes the adapter verify certificates against server's certificate.
+func trustServer(ra *RestyAdapter, server *httptest.Server) {
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	tlsConfig := ra.client.TLSClientConfig().Clone()
+	tlsConfig.RootCAs = roots
+	ra.client.SetTLSClientConfig(tlsConfig)
+}
+
+func TestRedirectStripsCredentialsAcrossHosts(t *testing.T) {
+	var lastHeaders http.Header
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/same-host":
+			http.Redirect(w, r, "https://api.example.com/target", http.StatusFound)
+		case "/cross-host":
+			http.Redirect(w, r, "https://cdn.example.com/target", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/target":
+			lastHeaders = r.Header.Clone()
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		path      string
+		settings  RedirectSettings
+		wantToken bool
+		wantAuth  bool
+	}{
+		{"same host keeps credentials", "/same-host", DefaultRedirectSettings(), true, true},
+		{"cross host strips credentials", "/cross-host", DefaultRedirectSettings(), false, false},
+		// net/http itself drops Authorization when the redirect leaves the domain.
+		{"cross host keeps device-token when allowed", "/cross-host", RedirectSettings{MaxRedirects: 10}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lastHeaders = nil
+			ra := NewRestyAdapter(DefaultTransportTimeouts(), tt.settings)
+			ra.SetBearerToken("gw-token")
+			routeHosts(t, ra, server)
+			trustServer(ra, server)
+
+			resp, err := ra.Get("https://api.example.com"+tt.path,
+				&RequestOptions{Headers: map[string]string{"device-token": "test-token"}})
+			if err != nil || resp.StatusCode != http.StatusOK {
+				t.Fatalf("Get = %+v, %v", resp, err)
+			}
+			if lastHeaders == nil {
+				t.Fatal("redirect target not reached")
+			}
+			if got := lastHeaders.Get("device-token") != ""; got != tt.wantToken {
+				t.Fatalf("device-token sent to the target: %t, want %t", got, tt.wantToken)
+			}
+			if got := lastHeaders.Get("Authorization") != ""; got != tt.wantAuth {
+				t.Fatalf("Authorization sent to the target: %t, want %t", got, tt.wantAuth)
+			}
+		})
+	}
+
+	t.Run("redirect limit", func(t *testing.T) {
+		cfg := &config.Config{ContentUpdateAPIURL: "https://api.example.com/loop", HTTPMaxRedirects: 3}
+		client := New(cfg, "test-token")
+		ra := client.client.(*RestyAdapter)
+		routeHosts(t, ra, server)
+		trustServer(ra, server)
+		if err := client.Ping(); err == nil {
+			t.Fatal("Ping followed a redirect loop without error")
+		}
+	})
+}
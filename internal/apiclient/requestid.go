@@ -0,0 +1,87 @@
+package apiclient
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"maps"
+)
+
+type requestIDKey struct{}
+
+// NewRequestID returns a random ID for correlating one sync cycle's requests with the
+// server's logs.
+func NewRequestID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		log.Printf("Failed to generate request ID: %v", err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, or "" if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// EnsureRequestID returns ctx if it carries a request ID, or a copy carrying a new one.
+func EnsureRequestID(ctx context.Context) context.Context {
+	if RequestIDFromContext(ctx) != "" {
+		return ctx
+	}
+	return WithRequestID(ctx, NewRequestID())
+}
+
+// requestIDClient sends the request ID of each request's context in the configured
+// header. Requests made without one, e.g. outside a sync cycle, send none.
+type requestIDClient struct {
+	HTTPClient
+	header string
+}
+
+// withRequestID returns a copy of opts with the request ID header set. The caller's
+// headers are left untouched.
+func (c *requestIDClient) withRequestID(opts *RequestOptions) *RequestOptions {
+	if opts == nil {
+		return opts
+	}
+	id := RequestIDFromContext(opts.Context)
+	if id == "" {
+		return opts
+	}
+	withID := *opts
+	withID.Headers = make(map[string]string, len(opts.Headers)+1)
+	maps.Copy(withID.Headers, opts.Headers)
+	withID.Headers[c.header] = id
+	return &withID
+}
+
+func (c *requestIDClient) Get(url string, opts *RequestOptions) (*Response, error) {
+	return c.HTTPClient.Get(url, c.withRequestID(opts))
+}
+
+func (c *requestIDClient) Post(url string, opts *RequestOptions) (*Response, error) {
+	return c.HTTPClient.Post(url, c.withRequestID(opts))
+}
+
+func (c *requestIDClient) Put(url string, opts *RequestOptions) (*Response, error) {
+	return c.HTTPClient.Put(url, c.withRequestID(opts))
+}
+
+func (c *requestIDClient) Head(url string, opts *RequestOptions) (*Response, error) {
+	return c.HTTPClient.Head(url, c.withRequestID(opts))
+}
+
+func (c *requestIDClient) GetStream(url string, opts *RequestOptions) (*StreamResponse, error) {
+	return c.HTTPClient.GetStream(url, c.withRequestID(opts))
+}
@@ -0,0 +1,67 @@
+package apiclient
+
+import (
+	"context"
+	"embedup-go/configs/config"
+	SharedModels "embedup-go/internal/shared"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// recordRequestIDs serves an empty content update to every request and records the
+// X-Request-Id header each one carried, by method.
+func recordRequestIDs(t *testing.T) (*httptest.Server, func() map[string]string) {
+	t.Helper()
+	var mu sync.Mutex
+	ids := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ids[r.Method] = r.Header.Get("X-Request-Id")
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"contents":[],"count":0}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() map[string]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return ids
+	}
+}
+
+func TestRequestIDOnlySentWithinItsContext(t *testing.T) {
+	server, ids := recordRequestIDs(t)
+	client := newTestClient(t, &config.Config{
+		RequestIDHeader:     "X-Request-Id",
+		ContentUpdateAPIURL: server.URL,
+		StatusReportAPIURL:  server.URL,
+	})
+
+	ctx := WithRequestID(context.Background(), "cycle-1")
+	if _, _, err := client.FetchContentUpdatesContext(ctx, SharedModels.ContentUpdateRequestParams{Size: 50}); err != nil {
+		t.Fatalf("FetchContentUpdatesContext: %v", err)
+	}
+	if err := client.ReportStatus(1, "after the cycle"); err != nil {
+		t.Fatalf("ReportStatus: %v", err)
+	}
+
+	got := ids()
+	if got[http.MethodGet] != "cycle-1" {
+		t.Fatalf("content update request ID = %q, want cycle-1", got[http.MethodGet])
+	}
+	if got[http.MethodPut] != "" {
+		t.Fatalf("status report after the cycle sent request ID %q", got[http.MethodPut])
+	}
+}
+
+func TestEnsureRequestIDKeepsExistingID(t *testing.T) {
+	ctx := WithRequestID(context.Background(), "given")
+	if id := RequestIDFromContext(EnsureRequestID(ctx)); id != "given" {
+		t.Fatalf("request ID = %q, want given", id)
+	}
+	if id := RequestIDFromContext(EnsureRequestID(context.Background())); id == "" {
+		t.Fatal("no request ID generated")
+	}
+}
@@ -5,10 +5,15 @@ import (
 	// If cstmerr is in 'your_module_path/internal/cstmerr', it would be:
 	// "your_module_path/internal/cstmerr"
 	// For now, using the path from your original code.
+	"crypto/tls"
+	"crypto/x509"
 	"embedup-go/internal/cstmerr"
+	"embedup-go/internal/shared"
 	"fmt"
+	"log"
+	"net/http"
 	"strconv"
-	"time"
+	"strings"
 
 	"resty.dev/v3"
 )
@@ -18,16 +23,18 @@ type RestyAdapter struct {
 	client *resty.Client
 }
 
-// NewRestyAdapter creates a new RestyAdapter with default transport settings.
-// These settings mirror the ones from your original code.
-func NewRestyAdapter() *RestyAdapter {
+// NewRestyAdapter creates a new RestyAdapter with the given transport timeouts and
+// redirect policy. Zero-valued timeouts fall back to Resty's defaults.
+func NewRestyAdapter(timeouts TransportTimeouts, redirects RedirectSettings) *RestyAdapter {
 	transportSettings := &resty.TransportSettings{
-		IdleConnTimeout:     30 * time.Second,
-		TLSHandshakeTimeout: 60 * time.Second,
+		DialerTimeout:         timeouts.Dial,
+		IdleConnTimeout:       timeouts.IdleConn,
+		TLSHandshakeTimeout:   timeouts.TLSHandshake,
+		ResponseHeaderTimeout: timeouts.ResponseHeader,
 	}
 	client := resty.NewWithTransportSettings(transportSettings)
-	// You can enable Resty debugging if needed:
-	// client.SetDebug(true)
+	client.SetRedirectPolicy(redirectPolicy(redirects))
+	client.SetTimeout(timeouts.Global)
 	return &RestyAdapter{
 		client: client,
 	}
@@ -38,11 +45,111 @@ func NewRestyAdapter() *RestyAdapter {
 func NewRestyAdapterWithClient(client *resty.Client) *RestyAdapter {
 	if client == nil {
 		// Fallback to default if nil client is passed, or panic, or return error
-		return NewRestyAdapter()
+		return NewRestyAdapter(DefaultTransportTimeouts(), DefaultRedirectSettings())
 	}
 	return &RestyAdapter{client: client}
 }
 
+// crossHostSensitiveHeaders are removed from a redirected request that changes host.
+var crossHostSensitiveHeaders = []string{"device-token", "Authorization", "Proxy-Authorization", "Cookie"}
+
+// redirectPolicy limits the number of redirects and, if configured, strips credential
+// headers when a redirect points to a different host than the original request.
+func redirectPolicy(settings RedirectSettings) resty.RedirectPolicy {
+	return resty.RedirectPolicyFunc(func(req *http.Request, via []*http.Request) error {
+		if len(via) >= settings.MaxRedirects {
+			return fmt.Errorf("stopped after %d redirects", settings.MaxRedirects)
+		}
+		if settings.StripAuthOnCrossHost && !strings.EqualFold(req.URL.Hostname(), via[0].URL.Hostname()) {
+			for _, header := range crossHostSensitiveHeaders {
+				req.Header.Del(header)
+			}
+		}
+		return nil
+	})
+}
+
+// EnableDebug dumps every request and response to the log. Secret headers and any
+// of the given secret values are masked before the dump is written.
+func (ra *RestyAdapter) EnableDebug(secrets []string) {
+	ra.client.SetLogger(stdLogger{})
+	ra.client.SetDebug(true)
+	ra.client.OnDebugLog(func(dl *resty.DebugLog) {
+		if dl.Request != nil {
+			dl.Request.Header = shared.RedactHeaders(dl.Request.Header)
+			dl.Request.URI = shared.RedactSecrets(shared.RedactURL(dl.Request.URI), secrets...)
+			dl.Request.CurlCmd = shared.RedactSecrets(dl.Request.CurlCmd, secrets...)
+			dl.Request.Body = shared.RedactSecrets(dl.Request.Body, secrets...)
+		}
+		if dl.Response != nil {
+			dl.Response.Header = shared.RedactHeaders(dl.Response.Header)
+			dl.Response.Body = shared.RedactSecrets(dl.Response.Body, secrets...)
+		}
+	})
+}
+
+// stdLogger writes Resty's messages to the standard logger, next to our own.
+type stdLogger struct{}
+
+func (stdLogger) Errorf(format string, v ...any) { log.Printf("ERROR RESTY "+format, v...) }
+func (stdLogger) Warnf(format string, v ...any)  { log.Printf("WARN RESTY "+format, v...) }
+func (stdLogger) Debugf(format string, v ...any) { log.Printf("DEBUG RESTY "+format, v...) }
+
+// SetDefaultHeaders adds headers sent on every request. Per-request headers with the
+// same name take precedence.
+func (ra *RestyAdapter) SetDefaultHeaders(headers map[string]string) {
+	ra.client.SetHeaders(headers)
+}
+
+// SetBasicAuth makes every request carry an HTTP Basic Authorization header.
+func (ra *RestyAdapter) SetBasicAuth(username string, password string) {
+	ra.client.SetBasicAuth(username, password)
+}
+
+// SetBearerToken makes every request carry an "Authorization: Bearer" header.
+func (ra *RestyAdapter) SetBearerToken(token string) {
+	ra.client.SetAuthToken(token)
+}
+
+// SetInsecureHosts skips TLS certificate verification for the given hosts only, e.g.
+// an internal asset server with a self-signed certificate. Every other host is still
+// verified as usual, by doing the standard verification in VerifyConnection.
+func (ra *RestyAdapter) SetInsecureHosts(hosts []string) {
+	if len(hosts) == 0 {
+		return
+	}
+	insecure := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		insecure[strings.ToLower(host)] = true
+	}
+	tlsConfig := ra.client.TLSClientConfig()
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	tlsConfig = tlsConfig.Clone()
+	roots := tlsConfig.RootCAs
+	tlsConfig.InsecureSkipVerify = true
+	tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+		if insecure[strings.ToLower(cs.ServerName)] {
+			return nil
+		}
+		if len(cs.PeerCertificates) == 0 {
+			return fmt.Errorf("tls: no certificate from %s", cs.ServerName)
+		}
+		intermediates := x509.NewCertPool()
+		for _, cert := range cs.PeerCertificates[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+			DNSName:       cs.ServerName,
+			Roots:         roots,
+			Intermediates: intermediates,
+		})
+		return err
+	}
+	ra.client.SetTLSClientConfig(tlsConfig)
+}
+
 // buildRequest is a helper to configure a resty request from RequestOptions.
 func (ra *RestyAdapter) buildRequest(baseRequest *resty.Request, opts *RequestOptions) *resty.Request {
 	req := baseRequest
@@ -58,16 +165,21 @@ func (ra *RestyAdapter) buildRequest(baseRequest *resty.Request, opts *RequestOp
 		}
 		if opts.SuccessResult != nil {
 			req.SetResult(opts.SuccessResult)
+			// Decode as JSON even if the server omits the Content-Type header,
+			// so results are always unmarshalled here (or the request fails).
+			req.SetExpectResponseContentType("application/json")
 		}
 		if opts.ErrorResult != nil {
 			// Resty's SetError unmarshals the response body into ErrorResult if the HTTP status indicates an error.
 			req.SetError(opts.ErrorResult)
 		}
-		// Note on opts.Timeout: Resty's client-level SetTimeout applies to the whole request-response cycle.
-		// Per-request timeouts in Resty are typically handled via context.Context.
-		// The transport settings provide robust connection/handshake timeouts.
-		// If granular per-request timeout is needed, the interface and adapter would need enhancement (e.g., pass context).
-		// For now, we rely on transport timeouts and any client-wide timeout set on the resty.Client.
+		if opts.Timeout > 0 {
+			// Resty applies this as a context deadline over the whole request-response cycle.
+			req.SetTimeout(opts.Timeout)
+		}
+		if opts.Context != nil {
+			req.SetContext(opts.Context)
+		}
 	}
 	return req
 }
@@ -135,6 +247,12 @@ func (ra *RestyAdapter) Head(url string, opts *RequestOptions) (*Response, error
 		if opts.QueryParams != nil {
 			restyReq.SetQueryParams(opts.QueryParams)
 		}
+		if opts.Timeout > 0 {
+			restyReq.SetTimeout(opts.Timeout)
+		}
+		if opts.Context != nil {
+			restyReq.SetContext(opts.Context)
+		}
 	}
 
 	restyResp, err := restyReq.Head(url)
@@ -152,7 +270,9 @@ func (ra *RestyAdapter) Head(url string, opts *RequestOptions) (*Response, error
 
 // GetStream implements the HTTPClient interface GetStream method.
 func (ra *RestyAdapter) GetStream(url string, opts *RequestOptions) (*StreamResponse, error) {
-	restyReq := ra.client.R()
+	// The client-wide timeout would cut long downloads off mid-stream, so streams are
+	// only bounded by their own timeout.
+	restyReq := ra.client.R().SetTimeout(0)
 	if opts != nil {
 		if opts.Headers != nil {
 			restyReq.SetHeaders(opts.Headers)
@@ -160,12 +280,23 @@ func (ra *RestyAdapter) GetStream(url string, opts *RequestOptions) (*StreamResp
 		if opts.QueryParams != nil {
 			restyReq.SetQueryParams(opts.QueryParams)
 		}
+		if opts.Timeout > 0 {
+			// Note that this bounds reading the whole stream, not just receiving the headers.
+			restyReq.SetTimeout(opts.Timeout)
+		}
+		if opts.Context != nil {
+			restyReq.SetContext(opts.Context)
+		}
 	}
 	// Crucial for streaming: tell Resty not to parse or automatically close the response body.
 	restyReq.SetDoNotParseResponse(true)
 
 	restyResp, err := restyReq.Get(url)
 	if err != nil {
+		// With an unparsed response nothing else will close a body that came with the error.
+		if restyResp != nil && restyResp.RawResponse != nil && restyResp.RawResponse.Body != nil {
+			DrainAndClose(restyResp.RawResponse.Body)
+		}
 		return nil, cstmerr.NewDownloadError(fmt.Sprintf("HTTP GET (stream) request to %s failed: %v", url, err))
 	}
 
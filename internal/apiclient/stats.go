@@ -0,0 +1,81 @@
+package apiclient
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// UNKNOWN_CONTENT_TYPE counts downloads made without a content type, e.g. update archives.
+const UNKNOWN_CONTENT_TYPE = "unknown"
+
+type contentTypeKey struct{}
+
+// WithContentType returns a copy of ctx whose downloads are counted under contentType.
+func WithContentType(ctx context.Context, contentType string) context.Context {
+	return context.WithValue(ctx, contentTypeKey{}, contentType)
+}
+
+// ContentTypeFromContext returns the content type carried by ctx, or UNKNOWN_CONTENT_TYPE.
+func ContentTypeFromContext(ctx context.Context) string {
+	if ctx != nil {
+		if contentType, _ := ctx.Value(contentTypeKey{}).(string); contentType != "" {
+			return contentType
+		}
+	}
+	return UNKNOWN_CONTENT_TYPE
+}
+
+// DownloadStats is a snapshot of the download telemetry recorded by an APIClient.
+type DownloadStats struct {
+	Downloads             int64            // Number of completed transfers
+	TotalBytes            int64            // Bytes received across all transfers, including failed ones
+	TotalDuration         time.Duration    // Time spent copying response bodies to disk
+	AverageBytesPerSecond float64          // TotalBytes / TotalDuration
+	CountByContentType    map[string]int64 // Completed transfers keyed by content type, e.g. "MOVIE"
+}
+
+// downloadStats accumulates download telemetry; it is safe for concurrent use.
+type downloadStats struct {
+	mu                 sync.Mutex
+	downloads          int64
+	totalBytes         int64
+	totalDuration      time.Duration
+	countByContentType map[string]int64
+}
+
+func newDownloadStats() *downloadStats {
+	return &downloadStats{countByContentType: make(map[string]int64)}
+}
+
+// record adds a single transfer of n bytes that took elapsed, counted under the content
+// type carried by ctx.
+func (ds *downloadStats) record(ctx context.Context, n int64, elapsed time.Duration, completed bool) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.totalBytes += n
+	ds.totalDuration += elapsed
+	if !completed {
+		return
+	}
+	ds.downloads++
+	ds.countByContentType[ContentTypeFromContext(ctx)]++
+}
+
+func (ds *downloadStats) snapshot() DownloadStats {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	stats := DownloadStats{
+		Downloads:          ds.downloads,
+		TotalBytes:         ds.totalBytes,
+		TotalDuration:      ds.totalDuration,
+		CountByContentType: make(map[string]int64, len(ds.countByContentType)),
+	}
+	if ds.totalDuration > 0 {
+		stats.AverageBytesPerSecond = float64(ds.totalBytes) / ds.totalDuration.Seconds()
+	}
+	for k, v := range ds.countByContentType {
+		stats.CountByContentType[k] = v
+	}
+	return stats
+}
@@ -0,0 +1,84 @@
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDownloadStatsRecordsKnownPayload(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+	server := serveFile(t, content)
+	client := newTestClient(t, nil)
+	dir := t.TempDir()
+
+	ctx := WithContentType(context.Background(), "local-movie")
+	if err := client.DownloadFileContext(ctx, server.URL, filepath.Join(dir, "movie.zip")); err != nil {
+		t.Fatalf("DownloadFileContext: %v", err)
+	}
+	if err := client.DownloadFile(server.URL, filepath.Join(dir, "update.tar")); err != nil {
+		t.Fatalf("DownloadFile: %v", err)
+	}
+
+	stats := client.Stats()
+	if stats.Downloads != 2 || stats.TotalBytes != int64(2*len(content)) {
+		t.Fatalf("stats = %+v, want 2 downloads of %d bytes", stats, 2*len(content))
+	}
+	want := map[string]int64{"local-movie": 1, UNKNOWN_CONTENT_TYPE: 1}
+	if len(stats.CountByContentType) != len(want) {
+		t.Fatalf("count by content type = %v, want %v", stats.CountByContentType, want)
+	}
+	for contentType, count := range want {
+		if stats.CountByContentType[contentType] != count {
+			t.Fatalf("count by content type = %v, want %v", stats.CountByContentType, want)
+		}
+	}
+	if stats.TotalDuration <= 0 || stats.AverageBytesPerSecond <= 0 {
+		t.Fatalf("throughput = %g B/s over %v, want positive", stats.AverageBytesPerSecond, stats.TotalDuration)
+	}
+	if got := float64(stats.TotalBytes) / stats.TotalDuration.Seconds(); got != stats.AverageBytesPerSecond {
+		t.Fatalf("average = %g B/s, want %g", stats.AverageBytesPerSecond, got)
+	}
+}
+
+func TestDownloadStatsCountsOnlyCompletedTransfers(t *testing.T) {
+	ds := newDownloadStats()
+	ctx := WithContentType(context.Background(), "local-advertisement")
+	ds.record(ctx, 300, time.Second, true)
+	ds.record(ctx, 100, time.Second, false)
+
+	stats := ds.snapshot()
+	if stats.Downloads != 1 || stats.TotalBytes != 400 || stats.AverageBytesPerSecond != 200 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if stats.CountByContentType["local-advertisement"] != 1 {
+		t.Fatalf("count by content type = %v", stats.CountByContentType)
+	}
+}
+
+func TestDownloadStatsConcurrentRecords(t *testing.T) {
+	ds := newDownloadStats()
+	types := []string{"local-movie", "local-genre", ""}
+	var wg sync.WaitGroup
+	for i := 0; i < 90; i++ {
+		wg.Add(1)
+		go func(contentType string) {
+			defer wg.Done()
+			ds.record(WithContentType(context.Background(), contentType), 10, time.Millisecond, true)
+		}(types[i%len(types)])
+	}
+	wg.Wait()
+
+	stats := ds.snapshot()
+	if stats.Downloads != 90 || stats.TotalBytes != 900 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	for _, contentType := range []string{"local-movie", "local-genre", UNKNOWN_CONTENT_TYPE} {
+		if stats.CountByContentType[contentType] != 30 {
+			t.Fatalf("count by content type = %v", stats.CountByContentType)
+		}
+	}
+}
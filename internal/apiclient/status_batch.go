@@ -0,0 +1,133 @@
+package apiclient
+
+import (
+	"embedup-go/internal/cstmerr"
+	SharedModels "embedup-go/internal/shared"
+	"log"
+	"sync"
+)
+
+type StatusReportBatchPayload = SharedModels.StatusReportBatchPayload
+
+// statusBatch collects status reports until they are flushed; it is safe for concurrent use.
+type statusBatch struct {
+	mu      sync.Mutex
+	pending []StatusReportPayload
+	// generation is bumped on every flush so a stale window timer does not flush a newer batch.
+	generation int
+}
+
+// maxQueuedStatusReports bounds the pending reports kept while the batch endpoint is
+// unreachable; the oldest are dropped beyond it.
+const maxQueuedStatusReports = 500
+
+// take removes and returns all pending reports.
+func (sb *statusBatch) take() []StatusReportPayload {
+	reports := sb.pending
+	sb.pending = nil
+	sb.generation++
+	return reports
+}
+
+// requeue puts reports that failed to send back ahead of the pending ones, so they go
+// out with the next flush.
+func (sb *statusBatch) requeue(reports []StatusReportPayload) {
+	sb.pending = append(reports, sb.pending...)
+	if over := len(sb.pending) - maxQueuedStatusReports; over > 0 {
+		log.Printf("Dropping %d oldest status report(s) that could not be sent", over)
+		sb.pending = sb.pending[over:]
+	}
+}
+
+func (ac *APIClient) statusBatchingEnabled() bool {
+	return ac.config.StatusReportBatchWindow > 0
+}
+
+// queueStatus adds payload to the pending batch. The batch is sent right away once it
+// holds StatusReportBatchSize reports, otherwise when the window opened by its first
+// report passes.
+func (ac *APIClient) queueStatus(payload StatusReportPayload) error {
+	ac.statusBatch.mu.Lock()
+	ac.statusBatch.pending = append(ac.statusBatch.pending, payload)
+	if size := ac.config.StatusReportBatchSize; size > 0 && len(ac.statusBatch.pending) >= size {
+		reports := ac.statusBatch.take()
+		ac.statusBatch.mu.Unlock()
+		return ac.sendOrRequeueStatus(reports)
+	}
+	if len(ac.statusBatch.pending) == 1 {
+		go ac.flushStatusAfterWindow(ac.statusBatch.generation)
+	}
+	ac.statusBatch.mu.Unlock()
+	return nil
+}
+
+func (ac *APIClient) flushStatusAfterWindow(generation int) {
+	<-ac.clock.After(ac.config.StatusReportBatchWindow)
+	ac.statusBatch.mu.Lock()
+	if ac.statusBatch.generation != generation {
+		// Already flushed because the batch filled up or FlushStatusReports was called.
+		ac.statusBatch.mu.Unlock()
+		return
+	}
+	reports := ac.statusBatch.take()
+	ac.statusBatch.mu.Unlock()
+	if err := ac.sendOrRequeueStatus(reports); err != nil {
+		log.Printf("Failed to send batched status reports, keeping them for the next flush: %v", err)
+	}
+}
+
+// FlushStatusReports sends any status reports still waiting for their batch window,
+// including those a failed flush kept. It does nothing when batching is disabled or
+// nothing is pending.
+func (ac *APIClient) FlushStatusReports() error {
+	ac.statusBatch.mu.Lock()
+	reports := ac.statusBatch.take()
+	ac.statusBatch.mu.Unlock()
+	return ac.sendOrRequeueStatus(reports)
+}
+
+// sendOrRequeueStatus sends reports as one batch, putting them back in the pending
+// batch if that fails.
+func (ac *APIClient) sendOrRequeueStatus(reports []StatusReportPayload) error {
+	err := ac.sendStatusBatch(reports)
+	if err != nil {
+		ac.statusBatch.mu.Lock()
+		ac.statusBatch.requeue(reports)
+		ac.statusBatch.mu.Unlock()
+	}
+	return err
+}
+
+// sendStatusBatch sends reports in a single request to the batch status endpoint.
+func (ac *APIClient) sendStatusBatch(reports []StatusReportPayload) error {
+	if len(reports) == 0 {
+		return nil
+	}
+	if err := requireURL("status_report_batch_api_url", ac.config.StatusReportBatchAPIURL); err != nil {
+		return err
+	}
+	log.Printf("Reporting %d batched statuses to %s", len(reports), ac.config.StatusReportBatchAPIURL)
+	opts := &RequestOptions{
+		Headers: map[string]string{
+			"device-token": ac.token,
+			"Content-Type": "application/json",
+		},
+		Body:    StatusReportBatchPayload{Reports: reports},
+		Timeout: ac.config.StatusReportTimeout,
+	}
+	resp, err := ac.client.Put(ac.config.StatusReportBatchAPIURL, opts)
+	if err != nil {
+		return err
+	}
+	if !resp.IsSuccess() {
+		errorMessage := string(resp.Body)
+		if errorMessage == "" {
+			errorMessage = "Unknown error from API"
+		}
+		log.Printf("Batch status report API request failed with status %d: %s", resp.StatusCode, errorMessage)
+		return cstmerr.NewAPIRequestFailedError(resp.StatusCode, errorMessage)
+	}
+
+	log.Println("Batch status report successful")
+	return nil
+}
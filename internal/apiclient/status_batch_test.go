@@ -0,0 +1,148 @@
+package apiclient
+
+import (
+	"embedup-go/configs/config"
+	"embedup-go/internal/clocktest"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchServer records the batches PUT to it. While failing, it rejects them.
+type batchServer struct {
+	mu      sync.Mutex
+	batches [][]string
+	failing bool
+	got     chan struct{}
+}
+
+func newBatchServer(t *testing.T) (*batchServer, *httptest.Server) {
+	t.Helper()
+	bs := &batchServer{got: make(chan struct{}, 10)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs.mu.Lock()
+		defer bs.mu.Unlock()
+		if bs.failing {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var payload StatusReportBatchPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var messages []string
+		for _, report := range payload.Reports {
+			messages = append(messages, report.StatusMessage)
+		}
+		bs.batches = append(bs.batches, messages)
+		bs.got <- struct{}{}
+	}))
+	t.Cleanup(server.Close)
+	return bs, server
+}
+
+func (bs *batchServer) setFailing(failing bool) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bs.failing = failing
+}
+
+func (bs *batchServer) sent() [][]string {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	return append([][]string(nil), bs.batches...)
+}
+
+func newBatchingClient(t *testing.T, url string, size int) *APIClient {
+	t.Helper()
+	return newTestClient(t, &config.Config{
+		StatusReportBatchAPIURL: url,
+		StatusReportBatchWindow: time.Minute,
+		StatusReportBatchSize:   size,
+		StatusReportTimeout:     5 * time.Second,
+	})
+}
+
+func reportAll(t *testing.T, ac *APIClient, messages ...string) error {
+	t.Helper()
+	var err error
+	for _, message := range messages {
+		if reportErr := ac.ReportStatus(1, message); reportErr != nil {
+			err = reportErr
+		}
+	}
+	return err
+}
+
+func TestStatusBatchFlushesWhenFull(t *testing.T) {
+	bs, server := newBatchServer(t)
+	ac := newBatchingClient(t, server.URL, 2)
+
+	if err := reportAll(t, ac, "a", "b", "c"); err != nil {
+		t.Fatalf("ReportStatus: %v", err)
+	}
+	if got := bs.sent(); len(got) != 1 || len(got[0]) != 2 || got[0][0] != "a" || got[0][1] != "b" {
+		t.Fatalf("batches = %v, want [[a b]] before the window passes", got)
+	}
+}
+
+func TestStatusBatchFlushesAfterWindow(t *testing.T) {
+	bs, server := newBatchServer(t)
+	ac := newBatchingClient(t, server.URL, 0)
+	clock := clocktest.NewFakeClock(time.Unix(0, 0))
+	ac.SetClock(clock)
+
+	if err := reportAll(t, ac, "a", "b"); err != nil {
+		t.Fatalf("ReportStatus: %v", err)
+	}
+	deadline := time.After(5 * time.Second)
+	for len(bs.sent()) == 0 {
+		clock.Advance(time.Minute)
+		select {
+		case <-bs.got:
+		case <-time.After(10 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("batch not sent after the window")
+		}
+	}
+	if got := bs.sent(); len(got) != 1 || len(got[0]) != 2 {
+		t.Fatalf("batches = %v, want [[a b]]", got)
+	}
+}
+
+func TestFailedStatusBatchIsKeptForNextFlush(t *testing.T) {
+	bs, server := newBatchServer(t)
+	ac := newBatchingClient(t, server.URL, 2)
+
+	bs.setFailing(true)
+	if err := reportAll(t, ac, "a", "b"); err == nil {
+		t.Fatal("ReportStatus succeeded with the batch endpoint failing")
+	}
+	// The kept reports fill the batch again, so the next report retries them.
+	if err := reportAll(t, ac, "c"); err == nil {
+		t.Fatal("ReportStatus succeeded with the batch endpoint failing")
+	}
+
+	bs.setFailing(false)
+	if err := ac.FlushStatusReports(); err != nil {
+		t.Fatalf("FlushStatusReports: %v", err)
+	}
+	got := bs.sent()
+	if len(got) != 1 || len(got[0]) != 3 || got[0][0] != "a" || got[0][2] != "c" {
+		t.Fatalf("batches = %v, want the failed reports sent ahead of the newer one", got)
+	}
+}
+
+func TestStatusBatchRequeueIsBounded(t *testing.T) {
+	var sb statusBatch
+	sb.requeue(make([]StatusReportPayload, maxQueuedStatusReports))
+	sb.pending = append(sb.pending, StatusReportPayload{StatusMessage: "newest"})
+	sb.requeue(nil)
+	if len(sb.pending) != maxQueuedStatusReports || sb.pending[len(sb.pending)-1].StatusMessage != "newest" {
+		t.Fatalf("kept %d reports, want the newest %d", len(sb.pending), maxQueuedStatusReports)
+	}
+}
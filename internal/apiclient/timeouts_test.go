@@ -0,0 +1,158 @@
+package apiclient
+
+import (
+	"embedup-go/configs/config"
+	SharedModels "embedup-go/internal/shared"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// restyAdapterOf returns the adapter New built for client.
+func restyAdapterOf(t *testing.T, client *APIClient) *RestyAdapter {
+	t.Helper()
+	ra, ok := client.client.(*RestyAdapter)
+	if !ok {
+		t.Fatalf("client uses %T, want a RestyAdapter", client.client)
+	}
+	return ra
+}
+
+// slowServer answers every request with body after delay, or gives up when the
+// client does.
+func slowServer(t *testing.T, delay time.Duration, body string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNewAppliesTransportTimeouts(t *testing.T) {
+	cfg := &config.Config{
+		HTTPDialTimeout:           5 * time.Second,
+		HTTPIdleConnTimeout:       7 * time.Second,
+		HTTPTLSHandshakeTimeout:   11 * time.Second,
+		HTTPResponseHeaderTimeout: 13 * time.Second,
+	}
+	transport, err := restyAdapterOf(t, New(cfg, "test-token")).client.HTTPTransport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The dial timeout lives in the transport's dialer, which is not inspectable.
+	if transport.IdleConnTimeout != 7*time.Second || transport.TLSHandshakeTimeout != 11*time.Second ||
+		transport.ResponseHeaderTimeout != 13*time.Second {
+		t.Fatalf("transport timeouts = idle %v, TLS handshake %v, response header %v",
+			transport.IdleConnTimeout, transport.TLSHandshakeTimeout, transport.ResponseHeaderTimeout)
+	}
+	if transport.DialContext == nil {
+		t.Fatal("transport has no dialer")
+	}
+
+	defaults := DefaultTransportTimeouts()
+	transport, err = NewRestyAdapter(defaults, DefaultRedirectSettings()).client.HTTPTransport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transport.IdleConnTimeout != defaults.IdleConn || transport.TLSHandshakeTimeout != defaults.TLSHandshake ||
+		transport.ResponseHeaderTimeout != 0 {
+		t.Fatalf("default transport timeouts = idle %v, TLS handshake %v, response header %v",
+			transport.IdleConnTimeout, transport.TLSHandshakeTimeout, transport.ResponseHeaderTimeout)
+	}
+}
+
+func TestResponseHeaderTimeout(t *testing.T) {
+	server := slowServer(t, time.Second, `{}`)
+	ra := NewRestyAdapter(TransportTimeouts{ResponseHeader: 50 * time.Millisecond}, DefaultRedirectSettings())
+
+	start := time.Now()
+	if _, err := ra.Get(server.URL, nil); err == nil {
+		t.Fatal("Get waited past the response header timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Get gave up after %v, want about 50ms", elapsed)
+	}
+}
+
+func TestEndpointTimeouts(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	tests := []struct {
+		name string
+		call func(cfg *config.Config, url string) error
+	}{
+		{"update check", func(cfg *config.Config, url string) error {
+			cfg.UpdateCheckAPIURL, cfg.UpdateCheckTimeout = url, timeout
+			_, err := New(cfg, "test-token").CheckForUpdates()
+			return err
+		}},
+		{"content updates", func(cfg *config.Config, url string) error {
+			cfg.ContentUpdateAPIURL, cfg.ContentUpdateTimeout = url, timeout
+			_, _, err := New(cfg, "test-token").FetchContentUpdates(SharedModels.ContentUpdateRequestParams{})
+			return err
+		}},
+		{"status history", func(cfg *config.Config, url string) error {
+			cfg.StatusHistoryAPIURL, cfg.StatusReportTimeout = url, timeout
+			_, err := New(cfg, "test-token").GetStatusHistory(10, 0)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := slowServer(t, time.Second, `{}`)
+			start := time.Now()
+			if err := tt.call(&config.Config{}, server.URL); err == nil {
+				t.Fatal("request outlived its endpoint timeout")
+			}
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Fatalf("request gave up after %v, want about %v", elapsed, timeout)
+			}
+		})
+	}
+
+	// Other endpoints are not bound by these timeouts.
+	server := slowServer(t, 100*time.Millisecond, `{"status": "ok"}`)
+	cfg := &config.Config{UpdateCheckAPIURL: server.URL, ContentUpdateTimeout: timeout, StatusReportTimeout: timeout}
+	if _, err := New(cfg, "test-token").CheckForUpdates(); err != nil {
+		t.Fatalf("CheckForUpdates with only other endpoints' timeouts set: %v", err)
+	}
+}
+
+func TestGlobalTimeout(t *testing.T) {
+	const global = 50 * time.Millisecond
+	server := slowServer(t, 200*time.Millisecond, `{"content": {}}`)
+
+	ra := restyAdapterOf(t, New(&config.Config{HTTPGlobalTimeout: global}, "test-token"))
+	if ra.client.Timeout() != global {
+		t.Fatalf("client timeout = %v, want %v", ra.client.Timeout(), global)
+	}
+
+	// The movie detail endpoint has no timeout of its own.
+	cfg := &config.Config{ContentDetailAPIURL: server.URL, HTTPGlobalTimeout: global}
+	start := time.Now()
+	if _, err := New(cfg, "test-token").GetMovieDetail(7); err == nil {
+		t.Fatal("GetMovieDetail outlived the global timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Fatalf("GetMovieDetail gave up after %v, want about %v", elapsed, global)
+	}
+
+	// A per-endpoint timeout replaces it.
+	cfg = &config.Config{UpdateCheckAPIURL: server.URL, UpdateCheckTimeout: time.Second, HTTPGlobalTimeout: global}
+	if _, err := New(cfg, "test-token").CheckForUpdates(); err != nil {
+		t.Fatalf("CheckForUpdates with a longer endpoint timeout: %v", err)
+	}
+
+	// Without one, slow requests complete.
+	cfg = &config.Config{ContentDetailAPIURL: server.URL}
+	if _, err := New(cfg, "test-token").GetMovieDetail(7); err != nil {
+		t.Fatalf("GetMovieDetail without a global timeout: %v", err)
+	}
+}
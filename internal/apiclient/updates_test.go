@@ -0,0 +1,167 @@
+package apiclient
+
+import (
+	"context"
+	"embedup-go/configs/config"
+	"embedup-go/internal/cstmerr"
+	SharedModels "embedup-go/internal/shared"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+const testUpdatesURL = "https://api.test/content/updates"
+
+// serveUpdates returns a client whose content update API answers with body.
+func serveUpdates(cfg *config.Config, body string) (*APIClient, *stubClient) {
+	cfg.ContentUpdateAPIURL = testUpdatesURL
+	stub := &stubClient{respond: func(method, url string) (int, string) { return http.StatusOK, body }}
+	return NewWithHTTPClient(cfg, "test-token", stub), stub
+}
+
+const oneAdvertisement = `{"id": 1, "type": "local-advertisement", "updatedAt": 1000, "enable": true,
+	"content": {"fileLink": "https://cdn.test/ad.mp4", "skipDuration": 5}}`
+
+func TestFetchContentUpdatesSchemaVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		wantErr bool
+	}{
+		{"not reported", ``, false},
+		{"older", `"schemaVersion": 0,`, false},
+		{"supported", fmt.Sprintf(`"schemaVersion": %d,`, CONTENT_SCHEMA_VERSION), false},
+		{"newer", fmt.Sprintf(`"schemaVersion": %d,`, CONTENT_SCHEMA_VERSION+1), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, stub := serveUpdates(&config.Config{},
+				`{`+tt.version+` "count": 0, "contents": [`+oneAdvertisement+`]}`)
+
+			resp, items, err := client.FetchContentUpdatesContext(context.Background(), SharedModels.ContentUpdateRequestParams{Size: 10})
+			var schemaErr *cstmerr.SchemaVersionError
+			if tt.wantErr {
+				if !errors.As(err, &schemaErr) || schemaErr.Version != CONTENT_SCHEMA_VERSION+1 ||
+					schemaErr.Supported != CONTENT_SCHEMA_VERSION {
+					t.Fatalf("error = %v, want a SchemaVersionError for version %d", err, CONTENT_SCHEMA_VERSION+1)
+				}
+				if resp != nil || items != nil {
+					t.Fatalf("items of a rejected schema returned: %+v", items)
+				}
+			} else if err != nil || len(items) != 1 {
+				t.Fatalf("FetchContentUpdatesContext = %d items, %v; want 1 item", len(items), err)
+			}
+
+			if got := stub.lastRequest(t).opts.Headers["X-Schema-Version"]; got != strconv.Itoa(CONTENT_SCHEMA_VERSION) {
+				t.Fatalf("X-Schema-Version = %q, want %d", got, CONTENT_SCHEMA_VERSION)
+			}
+		})
+	}
+}
+
+func TestContentItemSendsSchemaVersion(t *testing.T) {
+	stub := &stubClient{respond: func(method, url string) (int, string) { return http.StatusOK, oneAdvertisement }}
+	client := NewWithHTTPClient(&config.Config{ContentItemAPIURL: testItemURL}, "test-token", stub)
+	if _, err := client.GetContentItemContext(context.Background(), 1); err != nil {
+		t.Fatalf("GetContentItemContext: %v", err)
+	}
+	if got := stub.lastRequest(t).opts.Headers["X-Schema-Version"]; got != strconv.Itoa(CONTENT_SCHEMA_VERSION) {
+		t.Fatalf("X-Schema-Version = %q, want %d", got, CONTENT_SCHEMA_VERSION)
+	}
+}
+
+func TestFetchContentUpdatesMethod(t *testing.T) {
+	params := SharedModels.ContentUpdateRequestParams{From: 1700000000000, Size: 50, Offset: 100}
+
+	t.Run("GET sends query params", func(t *testing.T) {
+		client, stub := serveUpdates(&config.Config{ContentUpdateMethod: http.MethodGet,
+			EnabledContentTypes: []string{"local-movie"}}, `{"count": 0, "contents": []}`)
+		if _, _, err := client.FetchContentUpdatesContext(context.Background(), params); err != nil {
+			t.Fatalf("FetchContentUpdatesContext: %v", err)
+		}
+		req := stub.lastRequest(t)
+		want := map[string]string{"from": "1700000000000", "size": "50", "offset": "100"}
+		if req.method != http.MethodGet || req.url != testUpdatesURL || !reflect.DeepEqual(req.opts.QueryParams, want) {
+			t.Fatalf("request = %s %s with params %v, want GET with %v", req.method, req.url, req.opts.QueryParams, want)
+		}
+		if req.opts.Body != nil {
+			t.Fatalf("GET sent a body: %+v", req.opts.Body)
+		}
+	})
+
+	t.Run("POST sends a JSON filter", func(t *testing.T) {
+		client, stub := serveUpdates(&config.Config{ContentUpdateMethod: http.MethodPost,
+			EnabledContentTypes: []string{"local-movie", "local-advertisement"}}, `{"count": 0, "contents": []}`)
+		if _, _, err := client.FetchContentUpdatesContext(context.Background(), params); err != nil {
+			t.Fatalf("FetchContentUpdatesContext: %v", err)
+		}
+		req := stub.lastRequest(t)
+		if req.method != http.MethodPost || req.url != testUpdatesURL || len(req.opts.QueryParams) != 0 {
+			t.Fatalf("request = %s %s with params %v, want POST without params", req.method, req.url, req.opts.QueryParams)
+		}
+		if req.opts.Headers["Content-Type"] != "application/json" {
+			t.Fatalf("Content-Type = %q", req.opts.Headers["Content-Type"])
+		}
+		body, err := json.Marshal(req.opts.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `{"from":1700000000000,"size":50,"offset":100,"types":["local-movie","local-advertisement"]}`
+		if string(body) != want {
+			t.Fatalf("body = %s, want %s", body, want)
+		}
+	})
+
+	t.Run("POST without types omits them", func(t *testing.T) {
+		client, stub := serveUpdates(&config.Config{ContentUpdateMethod: http.MethodPost}, `{"count": 0, "contents": []}`)
+		if _, _, err := client.FetchContentUpdatesContext(context.Background(), params); err != nil {
+			t.Fatalf("FetchContentUpdatesContext: %v", err)
+		}
+		body, _ := json.Marshal(stub.lastRequest(t).opts.Body)
+		if want := `{"from":1700000000000,"size":50,"offset":100}`; string(body) != want {
+			t.Fatalf("body = %s, want %s", body, want)
+		}
+	})
+}
+
+func TestFetchContentUpdatesDecodesBody(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantItems int
+		wantErr   bool
+	}{
+		{"empty contents", `{"count": 0, "contents": []}`, 0, false},
+		{"null contents", `{"count": 0, "contents": null}`, 0, false},
+		{"no contents", `{"count": 0}`, 0, false},
+		{"one item", `{"count": 0, "contents": [` + oneAdvertisement + `]}`, 1, false},
+		{"malformed", `{"count": 0, "contents": [`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// No Content-Type, as some servers send, so the real adapter must still decode the body.
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header()["Content-Type"] = nil
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+			client := New(&config.Config{ContentUpdateAPIURL: server.URL}, "test-token")
+
+			resp, items, err := client.FetchContentUpdates(SharedModels.ContentUpdateRequestParams{Size: 10})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("FetchContentUpdates = %+v, want an error", resp)
+				}
+				return
+			}
+			if err != nil || resp == nil || len(items) != tt.wantItems {
+				t.Fatalf("FetchContentUpdates = %+v, %d items, %v; want %d items", resp, len(items), err, tt.wantItems)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+// Package backoff computes the delays between retries of a failing operation.
+package backoff
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Stop is returned by Policy.Next once MaxAttempts delays have been handed out.
+const Stop time.Duration = -1
+
+// Policy hands out exponentially growing delays: Base, Base*Multiplier,
+// Base*Multiplier², ... capped at Max, each randomized by up to ±Jitter of itself.
+// It is not safe for concurrent use; each retry loop uses its own Policy.
+type Policy struct {
+	Base        time.Duration
+	Max         time.Duration  // 0 sets no cap
+	Multiplier  float64        // Values below 1 are treated as 1
+	Jitter      float64        // Fraction in [0, 1]; 0 disables jitter
+	MaxAttempts int            // Number of delays before Next returns Stop; 0 for no limit
+	Rand        func() float64 // Jitter source in [0, 1); nil uses math/rand
+
+	attempt int
+	current time.Duration
+}
+
+// Next returns the delay before the next retry, or Stop if no retries are left.
+func (p *Policy) Next() time.Duration {
+	if p.MaxAttempts > 0 && p.attempt >= p.MaxAttempts {
+		return Stop
+	}
+	if p.attempt == 0 {
+		p.current = p.Base
+	} else if p.Max <= 0 || p.current < p.Max {
+		p.current = time.Duration(float64(p.current) * max(p.Multiplier, 1))
+	}
+	if p.Max > 0 && p.current > p.Max {
+		p.current = p.Max
+	}
+	p.attempt++
+	return p.jittered(p.current)
+}
+
+// Attempt returns the number of delays handed out since the last Reset.
+func (p *Policy) Attempt() int {
+	return p.attempt
+}
+
+// Reset starts over from Base, e.g. after the operation succeeded.
+func (p *Policy) Reset() {
+	p.attempt = 0
+	p.current = 0
+}
+
+func (p *Policy) jittered(d time.Duration) time.Duration {
+	if p.Jitter <= 0 {
+		return d
+	}
+	random := p.Rand
+	if random == nil {
+		random = rand.Float64
+	}
+	jitter := min(p.Jitter, 1)
+	return time.Duration(float64(d) * (1 + jitter*(2*random()-1)))
+}
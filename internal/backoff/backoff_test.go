@@ -0,0 +1,166 @@
+package backoff
+
+import (
+	"context"
+	"math/rand"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// delays returns the first n delays of p, Stop included.
+func delays(p *Policy, n int) []time.Duration {
+	got := make([]time.Duration, n)
+	for i := range got {
+		got[i] = p.Next()
+	}
+	return got
+}
+
+func TestPolicyNext(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		want   []time.Duration
+	}{
+		{
+			name:   "grows by multiplier",
+			policy: Policy{Base: time.Second, Multiplier: 2},
+			want:   []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second},
+		},
+		{
+			name:   "fractional multiplier",
+			policy: Policy{Base: 2 * time.Second, Multiplier: 1.5},
+			want:   []time.Duration{2 * time.Second, 3 * time.Second, 4500 * time.Millisecond},
+		},
+		{
+			name:   "multiplier below 1 is treated as 1",
+			policy: Policy{Base: time.Second, Multiplier: 0.5},
+			want:   []time.Duration{time.Second, time.Second, time.Second},
+		},
+		{
+			name:   "zero multiplier is treated as 1",
+			policy: Policy{Base: time.Second},
+			want:   []time.Duration{time.Second, time.Second},
+		},
+		{
+			name:   "clamped at max",
+			policy: Policy{Base: time.Second, Max: 5 * time.Second, Multiplier: 2},
+			want:   []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second},
+		},
+		{
+			name:   "base above max",
+			policy: Policy{Base: 10 * time.Second, Max: 3 * time.Second, Multiplier: 2},
+			want:   []time.Duration{3 * time.Second, 3 * time.Second},
+		},
+		{
+			name:   "stops after max attempts",
+			policy: Policy{Base: time.Second, Multiplier: 2, MaxAttempts: 2},
+			want:   []time.Duration{time.Second, 2 * time.Second, Stop, Stop},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := delays(&tt.policy, len(tt.want)); !slices.Equal(got, tt.want) {
+				t.Fatalf("delays = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyJitter(t *testing.T) {
+	tests := []struct {
+		name   string
+		jitter float64
+		random float64
+		want   time.Duration
+	}{
+		{"lowest draw", 0.25, 0, 750 * time.Millisecond},
+		{"middle draw", 0.25, 0.5, time.Second},
+		{"high draw", 0.25, 0.75, 1125 * time.Millisecond},
+		{"jitter above 1 is capped", 3, 0, 0},
+		{"no jitter ignores the source", 0, 0, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Policy{Base: time.Second, Jitter: tt.jitter, Rand: func() float64 { return tt.random }}
+			if got := p.Next(); got != tt.want {
+				t.Fatalf("Next = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyJitterBounds(t *testing.T) {
+	const jitter = 0.2
+	p := Policy{Base: 100 * time.Millisecond, Max: 10 * time.Second, Multiplier: 2, Jitter: jitter,
+		Rand: rand.New(rand.NewSource(1)).Float64}
+	unjittered := Policy{Base: p.Base, Max: p.Max, Multiplier: p.Multiplier}
+	for i := 0; i < 50; i++ {
+		d, got := unjittered.Next(), p.Next()
+		low, high := time.Duration(float64(d)*(1-jitter)), time.Duration(float64(d)*(1+jitter))
+		if got < low || got > high {
+			t.Fatalf("delay %d = %v, want within [%v, %v]", i, got, low, high)
+		}
+	}
+}
+
+func TestPolicyReset(t *testing.T) {
+	p := Policy{Base: time.Second, Multiplier: 2, MaxAttempts: 2}
+	delays(&p, 3)
+	if p.Attempt() != 2 {
+		t.Fatalf("Attempt = %d, want 2", p.Attempt())
+	}
+	p.Reset()
+	if p.Attempt() != 0 {
+		t.Fatalf("Attempt after Reset = %d, want 0", p.Attempt())
+	}
+	if got, want := delays(&p, 3), []time.Duration{time.Second, 2 * time.Second, Stop}; !slices.Equal(got, want) {
+		t.Fatalf("delays after Reset = %v, want %v", got, want)
+	}
+}
+
+func TestBudget(t *testing.T) {
+	b := NewBudget(2)
+	for i, want := range []bool{true, true, false, false} {
+		if got := b.Take(); got != want {
+			t.Fatalf("Take %d = %t, want %t", i, got, want)
+		}
+	}
+	if b.Remaining() != 0 {
+		t.Fatalf("Remaining = %d, want 0", b.Remaining())
+	}
+}
+
+func TestBudgetConcurrentTakes(t *testing.T) {
+	b := NewBudget(10)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	taken := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if b.Take() {
+				mu.Lock()
+				taken++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if taken != 10 || b.Remaining() != 0 {
+		t.Fatalf("taken %d, remaining %d, want 10 and 0", taken, b.Remaining())
+	}
+}
+
+func TestBudgetFromContext(t *testing.T) {
+	if BudgetFrom(context.Background()) != nil {
+		t.Fatal("a plain context has a budget")
+	}
+	b := NewBudget(1)
+	if BudgetFrom(WithBudget(context.Background(), b)) != b {
+		t.Fatal("BudgetFrom did not return the budget of the context")
+	}
+}
@@ -0,0 +1,43 @@
+package backoff
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Budget caps the retries made across every operation sharing it, so a systemic
+// outage costs a bounded number of extra requests instead of every retry loop
+// spending its own allowance. It is safe for concurrent use.
+type Budget struct {
+	remaining atomic.Int64
+}
+
+// NewBudget returns a Budget allowing n retries.
+func NewBudget(n int) *Budget {
+	b := &Budget{}
+	b.remaining.Store(int64(n))
+	return b
+}
+
+// Take uses up one retry, reporting false if none were left.
+func (b *Budget) Take() bool {
+	return b.remaining.Add(-1) >= 0
+}
+
+// Remaining returns how many retries are left.
+func (b *Budget) Remaining() int {
+	return int(max(b.remaining.Load(), 0))
+}
+
+type budgetKey struct{}
+
+// WithBudget returns a copy of ctx whose retries are drawn from b.
+func WithBudget(ctx context.Context, b *Budget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, b)
+}
+
+// BudgetFrom returns the Budget of ctx, or nil if retries through it are unbounded.
+func BudgetFrom(ctx context.Context) *Budget {
+	b, _ := ctx.Value(budgetKey{}).(*Budget)
+	return b
+}
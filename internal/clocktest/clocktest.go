@@ -0,0 +1,72 @@
+// Package clocktest provides a fake shared.Clock for tests.
+package clocktest
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// FakeClock is a manually driven shared.Clock. Sleep returns immediately after advancing
+// the fake time, and records the requested duration in Sleeps.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+	Sleeps  []time.Duration
+}
+
+type fakeWaiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// NewFakeClock creates a FakeClock starting at now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (fc *FakeClock) Now() time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.now
+}
+
+func (fc *FakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	fc.mu.Lock()
+	fc.Sleeps = append(fc.Sleeps, d)
+	fc.mu.Unlock()
+	fc.Advance(d)
+	return nil
+}
+
+func (fc *FakeClock) After(d time.Duration) <-chan time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- fc.now
+		return ch
+	}
+	fc.waiters = append(fc.waiters, fakeWaiter{deadline: fc.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the fake time forward by d and fires any After channels that are due.
+func (fc *FakeClock) Advance(d time.Duration) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.now = fc.now.Add(d)
+	pending := fc.waiters[:0]
+	for _, w := range fc.waiters {
+		if !w.deadline.After(fc.now) {
+			w.ch <- fc.now
+		} else {
+			pending = append(pending, w)
+		}
+	}
+	fc.waiters = pending
+}
@@ -0,0 +1,112 @@
+package clocktest_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"embedup-go/internal/clocktest"
+	"embedup-go/internal/shared"
+)
+
+var _ shared.Clock = (*clocktest.FakeClock)(nil)
+
+var start = time.Unix(1700000000, 0)
+
+func TestSleepAdvancesAndRecords(t *testing.T) {
+	clock := clocktest.NewFakeClock(start)
+	for _, d := range []time.Duration{time.Second, 3 * time.Second} {
+		if err := clock.Sleep(context.Background(), d); err != nil {
+			t.Fatalf("Sleep(%v): %v", d, err)
+		}
+	}
+	if got := clock.Now(); !got.Equal(start.Add(4 * time.Second)) {
+		t.Fatalf("Now = %v, want %v", got, start.Add(4*time.Second))
+	}
+	if want := []time.Duration{time.Second, 3 * time.Second}; !slices.Equal(clock.Sleeps, want) {
+		t.Fatalf("Sleeps = %v, want %v", clock.Sleeps, want)
+	}
+}
+
+func TestSleepWithDoneContext(t *testing.T) {
+	clock := clocktest.NewFakeClock(start)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := clock.Sleep(ctx, time.Second); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Sleep = %v, want %v", err, context.Canceled)
+	}
+	if !clock.Now().Equal(start) || len(clock.Sleeps) != 0 {
+		t.Fatalf("a canceled Sleep moved the clock to %v, sleeps %v", clock.Now(), clock.Sleeps)
+	}
+}
+
+func TestAfterFiresOnceDue(t *testing.T) {
+	clock := clocktest.NewFakeClock(start)
+	ch := clock.After(2 * time.Second)
+
+	clock.Advance(time.Second)
+	select {
+	case got := <-ch:
+		t.Fatalf("After fired early at %v", got)
+	default:
+	}
+
+	clock.Advance(time.Second)
+	select {
+	case got := <-ch:
+		if !got.Equal(start.Add(2 * time.Second)) {
+			t.Fatalf("After fired at %v, want %v", got, start.Add(2*time.Second))
+		}
+	default:
+		t.Fatal("After did not fire once due")
+	}
+}
+
+func TestAfterWithoutDelayFiresImmediately(t *testing.T) {
+	clock := clocktest.NewFakeClock(start)
+	select {
+	case got := <-clock.After(0):
+		if !got.Equal(start) {
+			t.Fatalf("After(0) fired at %v, want %v", got, start)
+		}
+	default:
+		t.Fatal("After(0) did not fire")
+	}
+}
+
+func TestRetryWithBackoffSleepsOnFakeClock(t *testing.T) {
+	clock := clocktest.NewFakeClock(start)
+	failure := errors.New("unavailable")
+	attempts := 0
+	err := shared.RetryWithBackoff(context.Background(), clock, 3, time.Second, nil, func(int) error {
+		attempts++
+		return failure
+	})
+	if !errors.Is(err, failure) || attempts != 4 {
+		t.Fatalf("RetryWithBackoff = %v after %d attempts, want %v after 4", err, attempts, failure)
+	}
+	if want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}; !slices.Equal(clock.Sleeps, want) {
+		t.Fatalf("Sleeps = %v, want %v", clock.Sleeps, want)
+	}
+	if got := clock.Now(); !got.Equal(start.Add(7 * time.Second)) {
+		t.Fatalf("Now = %v, want %v", got, start.Add(7*time.Second))
+	}
+}
+
+func TestRetryWithBackoffWithoutRetries(t *testing.T) {
+	clock := clocktest.NewFakeClock(start)
+	failure := errors.New("unavailable")
+	attempts := 0
+	err := shared.RetryWithBackoff(context.Background(), clock, 0, time.Second, nil, func(int) error {
+		attempts++
+		return failure
+	})
+	if !errors.Is(err, failure) || attempts != 1 {
+		t.Fatalf("RetryWithBackoff = %v after %d attempts, want %v after 1", err, attempts, failure)
+	}
+	if len(clock.Sleeps) != 0 {
+		t.Fatalf("Sleeps = %v, want none", clock.Sleeps)
+	}
+}
@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"context"
+	ApiClient "embedup-go/internal/apiclient"
+	"embedup-go/internal/backoff"
+	"embedup-go/internal/dbclient"
+	SharedModels "embedup-go/internal/shared"
+	"log"
+)
+
+// App wires together what a content sync cycle needs, so the whole pipeline can be
+// driven by the service's main loop or, with a stub HTTPClient behind API and a
+// scratch database, end to end in isolation.
+type App struct {
+	API     *ApiClient.APIClient
+	DB      dbclient.DBClient
+	Updater *SharedModels.Updater
+	// RetryBudget caps the retries of all operations of one cycle; 0 leaves each
+	// retry loop to its own limit.
+	RetryBudget int
+}
+
+// CycleContext returns ctx carrying a fresh RetryBudget, so the operations run with it
+// around a cycle, such as its status reports, share the cycle's retries.
+func (a *App) CycleContext(ctx context.Context) context.Context {
+	if a.RetryBudget <= 0 {
+		return ctx
+	}
+	return backoff.WithBudget(ctx, backoff.NewBudget(a.RetryBudget))
+}
+
+// RunCycle fetches and processes one batch of content updates, advancing the updater's
+// watermark. Its requests carry the request ID of ctx, or a new one for the cycle, and
+// draw their retries from the budget of ctx, or a new one from CycleContext. It does
+// nothing if ctx is already done.
+func (a *App) RunCycle(ctx context.Context) (SyncSummary, error) {
+	if err := ctx.Err(); err != nil {
+		return SyncSummary{NewWatermark: a.Updater.LastFromTimeStamp}, err
+	}
+	// The ID lives only in ctx, so requests made after the cycle don't carry it.
+	ctx = ApiClient.EnsureRequestID(ctx)
+	requestID := ApiClient.RequestIDFromContext(ctx)
+	log.Printf("Starting content sync cycle %s", requestID)
+	if backoff.BudgetFrom(ctx) == nil {
+		ctx = a.CycleContext(ctx)
+	}
+	summary, err := FetchAndProcessContentUpdatesContext(ctx, a.API, a.DB, a.Updater)
+	summary.RequestID = requestID
+	log.Printf("Finished content sync cycle %s", requestID)
+	return summary, err
+}
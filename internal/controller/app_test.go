@@ -0,0 +1,553 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"embedup-go/configs/config"
+	ApiClient "embedup-go/internal/apiclient"
+	"embedup-go/internal/cstmerr"
+	"embedup-go/internal/dbclient"
+	SharedModels "embedup-go/internal/shared"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// The pipeline tests run App.RunCycle against memDB and stubHTTP. The schema uses
+// Postgres types (jsonb, timestamptz), so SQLite would not run it as is, and memDB
+// keeps the tests free of a database driver.
+
+// memDB is an in-memory DBClient. Rows are kept per table and keyed by their primary
+// key: ContentId for content models, Path for AssetMeta. Raw SQL is not supported.
+type memDB struct {
+	dbclient.DBClient
+	mu     sync.Mutex
+	tables map[reflect.Type]map[any]reflect.Value
+	// failSave makes saving the content item with this ID fail, panicSave makes it panic.
+	failSave  map[int64]bool
+	panicSave map[int64]bool
+	// written lists the content IDs saved or deleted, in order.
+	written []int64
+}
+
+func newMemDB() *memDB {
+	return &memDB{tables: make(map[reflect.Type]map[any]reflect.Value),
+		failSave: make(map[int64]bool), panicSave: make(map[int64]bool)}
+}
+
+// rowKey returns the primary key of row, a struct value.
+func rowKey(row reflect.Value) any {
+	if id := row.FieldByName("ContentId"); id.IsValid() {
+		return id.Int()
+	}
+	return row.FieldByName("Path").String()
+}
+
+func (db *memDB) table(t reflect.Type) map[any]reflect.Value {
+	if db.tables[t] == nil {
+		db.tables[t] = make(map[any]reflect.Value)
+	}
+	return db.tables[t]
+}
+
+// put stores a copy of *model and reports whether it is a new row.
+func (db *memDB) put(model interface{}) (bool, error) {
+	row := reflect.ValueOf(model).Elem()
+	if id, ok := rowKey(row).(int64); ok && db.failSave[id] {
+		return false, fmt.Errorf("saving content %d failed", id)
+	}
+	if id, ok := rowKey(row).(int64); ok && db.panicSave[id] {
+		panic(fmt.Sprintf("saving content %d panicked", id))
+	}
+	if id, ok := rowKey(row).(int64); ok {
+		db.written = append(db.written, id)
+	}
+	copied := reflect.New(row.Type()).Elem()
+	copied.Set(row)
+	table := db.table(row.Type())
+	_, existed := table[rowKey(row)]
+	table[rowKey(row)] = copied
+	return !existed, nil
+}
+
+func (db *memDB) Connect(ctx context.Context) error { return nil }
+func (db *memDB) Close() error                      { return nil }
+func (db *memDB) Ping(ctx context.Context) error    { return nil }
+
+func (db *memDB) Migrate(ctx context.Context, models ...interface{}) error { return nil }
+
+func (db *memDB) Create(ctx context.Context, model interface{}) error {
+	return db.Save(ctx, model)
+}
+
+func (db *memDB) Save(ctx context.Context, model interface{}) error {
+	_, err := db.SaveReturning(ctx, model)
+	return err
+}
+
+func (db *memDB) SaveReturning(ctx context.Context, model interface{}) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.put(model)
+}
+
+// Updates sets the fields of the stored row of modelWithPK named by the column keys of data.
+func (db *memDB) Updates(ctx context.Context, modelWithPK interface{}, data interface{}) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	row := reflect.ValueOf(modelWithPK).Elem()
+	stored, ok := db.table(row.Type())[rowKey(row)]
+	if !ok {
+		return cstmerr.NewDBNotFoundError("record not found", nil)
+	}
+	for column, value := range data.(map[string]interface{}) {
+		for i := 0; i < stored.NumField(); i++ {
+			if strings.Contains(stored.Type().Field(i).Tag.Get("gorm"), "column:"+column) {
+				stored.Field(i).Set(reflect.ValueOf(value).Convert(stored.Field(i).Type()))
+			}
+		}
+	}
+	return nil
+}
+
+// Delete deletes the row of model, or with the "path IN ?" condition, those asset_meta rows.
+func (db *memDB) Delete(ctx context.Context, model interface{}, conditions ...interface{}) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	row := reflect.ValueOf(model).Elem()
+	table := db.table(row.Type())
+	if len(conditions) == 2 && conditions[0] == "path IN ?" {
+		for _, path := range conditions[1].([]string) {
+			delete(table, path)
+		}
+		return nil
+	}
+	if id, ok := rowKey(row).(int64); ok {
+		db.written = append(db.written, id)
+	}
+	delete(table, rowKey(row))
+	return nil
+}
+
+func (db *memDB) DeleteByContentId(ctx context.Context, model interface{}, contentId int64) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	table := db.table(reflect.TypeOf(model).Elem())
+	if _, ok := table[contentId]; !ok {
+		return cstmerr.NewDBNotFoundError("record not found", nil)
+	}
+	db.written = append(db.written, contentId)
+	delete(table, contentId)
+	return nil
+}
+
+func (db *memDB) Truncate(ctx context.Context, models ...interface{}) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, model := range models {
+		delete(db.tables, reflect.TypeOf(model).Elem())
+	}
+	return nil
+}
+
+// First loads the stored row with the primary key of model.
+func (db *memDB) First(ctx context.Context, model interface{}, conditions ...interface{}) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	row := reflect.ValueOf(model).Elem()
+	stored, ok := db.table(row.Type())[rowKey(row)]
+	if !ok {
+		return cstmerr.NewDBNotFoundError("record not found", nil)
+	}
+	row.Set(stored)
+	return nil
+}
+
+// Exists looks up the primary key of model or, with the condition assetShared uses,
+// other rows whose column holds the pattern, matched against the row as JSON.
+func (db *memDB) Exists(ctx context.Context, model interface{}, conditions ...interface{}) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	row := reflect.ValueOf(model).Elem()
+	table := db.table(row.Type())
+	if len(conditions) == 0 {
+		_, ok := table[rowKey(row)]
+		return ok, nil
+	}
+	if len(conditions) != 3 || !strings.Contains(conditions[0].(string), "LIKE") {
+		return false, fmt.Errorf("memDB: unsupported condition %v", conditions[0])
+	}
+	pattern := strings.Trim(conditions[2].(string), "%")
+	for key, stored := range table {
+		data, err := json.Marshal(stored.Interface())
+		if err != nil {
+			return false, err
+		}
+		if key != conditions[1] && strings.Contains(string(data), pattern) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Find loads every row of the collection's table, ordered by primary key.
+func (db *memDB) Find(ctx context.Context, collection interface{}, conditions ...interface{}) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	slice := reflect.ValueOf(collection).Elem()
+	table := db.table(slice.Type().Elem())
+	keys := make([]any, 0, len(table))
+	for key := range table {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+	for _, key := range keys {
+		slice.Set(reflect.Append(slice, table[key]))
+	}
+	return nil
+}
+
+// RunInTransaction runs fn against db itself; a failed fn is not rolled back.
+func (db *memDB) RunInTransaction(ctx context.Context, fn func(ctx context.Context, txClient dbclient.DBClient) error) error {
+	return fn(ctx, db)
+}
+
+func (db *memDB) CreateAssosiate(ctx context.Context, model interface{}, assosiation string, assosiate interface{}) error {
+	return nil
+}
+
+func (db *memDB) DeleteAssosiate(ctx context.Context, model interface{}, assosiation string, assosiate interface{}) error {
+	return nil
+}
+
+// rows returns the stored rows of model's table.
+func (db *memDB) rows(model interface{}) int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return len(db.table(reflect.TypeOf(model).Elem()))
+}
+
+// stubHTTP stands in for the server and CDN: it answers GETs of json with the body
+// stored under the URL, and serves files with the MD5 header the CDN sends.
+type stubHTTP struct {
+	mu        sync.Mutex
+	json      map[string][]byte
+	files     map[string][]byte
+	downloads map[string]int // File downloads started, per URL
+}
+
+func newStubHTTP() *stubHTTP {
+	return &stubHTTP{json: make(map[string][]byte), files: make(map[string][]byte), downloads: make(map[string]int)}
+}
+
+// downloadCount returns how many downloads of url were started.
+func (s *stubHTTP) downloadCount(url string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.downloads[url]
+}
+
+func (s *stubHTTP) setJSON(url string, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.json[url] = body
+}
+
+func (s *stubHTTP) setFile(url string, content []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[url] = content
+}
+
+func (s *stubHTTP) file(url string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	content, ok := s.files[url]
+	return content, ok
+}
+
+func (s *stubHTTP) Get(url string, opts *ApiClient.RequestOptions) (*ApiClient.Response, error) {
+	s.mu.Lock()
+	body, ok := s.json[url]
+	s.mu.Unlock()
+	if !ok {
+		return &ApiClient.Response{StatusCode: http.StatusNotFound, RequestURL: url}, nil
+	}
+	if opts != nil && opts.SuccessResult != nil {
+		if err := json.Unmarshal(body, opts.SuccessResult); err != nil {
+			return nil, err
+		}
+	}
+	return &ApiClient.Response{StatusCode: http.StatusOK, Body: body, RequestURL: url}, nil
+}
+
+func (s *stubHTTP) Post(url string, opts *ApiClient.RequestOptions) (*ApiClient.Response, error) {
+	return &ApiClient.Response{StatusCode: http.StatusOK, RequestURL: url}, nil
+}
+
+func (s *stubHTTP) Put(url string, opts *ApiClient.RequestOptions) (*ApiClient.Response, error) {
+	return &ApiClient.Response{StatusCode: http.StatusOK, RequestURL: url}, nil
+}
+
+func (s *stubHTTP) Head(url string, opts *ApiClient.RequestOptions) (*ApiClient.Response, error) {
+	content, ok := s.file(url)
+	if !ok {
+		return &ApiClient.Response{StatusCode: http.StatusNotFound, RequestURL: url}, nil
+	}
+	sum := md5.Sum(content)
+	headers := http.Header{}
+	headers.Set("Content-Length", strconv.Itoa(len(content)))
+	headers.Set("x-content-md5", hex.EncodeToString(sum[:]))
+	return &ApiClient.Response{StatusCode: http.StatusOK, Headers: headers, RequestURL: url}, nil
+}
+
+func (s *stubHTTP) GetStream(url string, opts *ApiClient.RequestOptions) (*ApiClient.StreamResponse, error) {
+	s.mu.Lock()
+	s.downloads[url]++
+	s.mu.Unlock()
+	content, ok := s.file(url)
+	if !ok {
+		return &ApiClient.StreamResponse{StatusCode: http.StatusNotFound, Body: io.NopCloser(bytes.NewReader(nil)), RequestURL: url}, nil
+	}
+	return &ApiClient.StreamResponse{
+		StatusCode:    http.StatusOK,
+		Body:          io.NopCloser(bytes.NewReader(content)),
+		Headers:       http.Header{},
+		ContentLength: int64(len(content)),
+		RequestURL:    url,
+	}, nil
+}
+
+const (
+	testUpdatesURL = "https://api.test/content/updates"
+	testDetailURL  = "https://api.test/content/movies"
+)
+
+// pipeline is an App wired to memDB, stubHTTP and a temporary content directory.
+type pipeline struct {
+	app    *App
+	db     *memDB
+	server *stubHTTP
+}
+
+func newPipeline(t *testing.T) *pipeline {
+	t.Helper()
+	t.Setenv("PODBOX_UPDATE_CONTENT_BASE_PATH", t.TempDir())
+	if err := EnsureContentDirs(); err != nil {
+		t.Fatal(err)
+	}
+	db := newMemDB()
+	// The service reads the updater row at startup, so it exists before the first cycle.
+	if err := db.Save(context.Background(), &SharedModels.Updater{}); err != nil {
+		t.Fatal(err)
+	}
+	db.written = nil
+	server := newStubHTTP()
+	cfg := &config.Config{ContentUpdateAPIURL: testUpdatesURL, ContentDetailAPIURL: testDetailURL}
+	return &pipeline{
+		app: &App{
+			API:     ApiClient.NewWithHTTPClient(cfg, "test-token", server),
+			DB:      db,
+			Updater: &SharedModels.Updater{},
+		},
+		db:     db,
+		server: server,
+	}
+}
+
+// serveFeed makes the content update API return the named testdata fixture.
+func (p *pipeline) serveFeed(t *testing.T, fixture string) {
+	t.Helper()
+	p.server.setJSON(testUpdatesURL, readFixture(t, fixture))
+}
+
+// feedItem is a content item as the content update API returns it.
+type feedItem struct {
+	ID        int64          `json:"id"`
+	Type      string         `json:"type"`
+	UpdatedAt int64          `json:"updatedAt"`
+	Enable    bool           `json:"enable"`
+	Deleted   bool           `json:"deleted,omitempty"`
+	Content   map[string]any `json:"content"`
+}
+
+// adItem returns advertisement id, updated at 1000*id, with a file of its own.
+func (p *pipeline) adItem(id int64, enable bool) feedItem {
+	url := fmt.Sprintf("https://cdn.test/ads/%d.mp4", id)
+	p.server.setFile(url, []byte(fmt.Sprintf("advertisement %d", id)))
+	return feedItem{ID: id, Type: "local-advertisement", UpdatedAt: 1000 * id, Enable: enable,
+		Content: map[string]any{"fileLink": url, "skipDuration": 5}}
+}
+
+// serveItems makes the content update API return items.
+func (p *pipeline) serveItems(t *testing.T, items []feedItem) {
+	t.Helper()
+	body, err := json.Marshal(map[string]any{"count": 0, "contents": items})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.server.setJSON(testUpdatesURL, body)
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func (p *pipeline) runCycle(t *testing.T) SyncSummary {
+	t.Helper()
+	summary, err := p.app.RunCycle(context.Background())
+	if err != nil {
+		t.Fatalf("RunCycle: %v", err)
+	}
+	return summary
+}
+
+const adURL = "https://cdn.test/ads/spring-sale.mp4"
+
+var adContent = []byte("spring sale advertisement video")
+
+func TestPipelineEnablesThenDisablesAdvertisement(t *testing.T) {
+	p := newPipeline(t)
+	p.server.setFile(adURL, adContent)
+
+	p.serveFeed(t, "feed_advertisement_enabled.json")
+	summary := p.runCycle(t)
+	if summary.Processed != 1 || summary.Created != 1 || summary.NewWatermark != 1700000000000 {
+		t.Fatalf("enable summary = %+v", summary)
+	}
+	ad := SharedModels.Advertisement{ContentId: 101}
+	if err := p.db.First(context.Background(), &ad); err != nil {
+		t.Fatalf("advertisement not saved: %v", err)
+	}
+	sum := md5.Sum(adContent)
+	wantPlayLink := filepath.Join("ads", hex.EncodeToString(sum[:])+".mp4")
+	if ad.SkipDuration != 5 || ad.Link.PlayLink != wantPlayLink || ad.Link.OriginalLink != adURL || ad.Link.LinkType != "MP4" {
+		t.Fatalf("advertisement = %+v, want play link %s", ad, wantPlayLink)
+	}
+	adPath := filepath.Join(ContentBasePath(), "videos", wantPlayLink)
+	if got, err := os.ReadFile(adPath); err != nil || !bytes.Equal(got, adContent) {
+		t.Fatalf("advertisement file = %q, %v", got, err)
+	}
+	if p.db.rows(&SharedModels.AssetMeta{}) != 1 {
+		t.Fatal("advertisement size not recorded")
+	}
+
+	p.serveFeed(t, "feed_advertisement_disabled.json")
+	summary = p.runCycle(t)
+	if summary.Processed != 1 || summary.NewWatermark != 1700000100000 {
+		t.Fatalf("disable summary = %+v", summary)
+	}
+	if p.db.rows(&SharedModels.Advertisement{}) != 0 {
+		t.Fatal("advertisement row left behind")
+	}
+	if _, err := os.Stat(adPath); !os.IsNotExist(err) {
+		t.Fatalf("advertisement file left behind: %v", err)
+	}
+	if p.db.rows(&SharedModels.AssetMeta{}) != 0 {
+		t.Fatal("advertisement size left behind")
+	}
+}
+
+func TestPipelineDisablingUnknownAdvertisementIsSkipped(t *testing.T) {
+	p := newPipeline(t)
+
+	p.serveFeed(t, "feed_advertisement_disabled.json")
+	summary := p.runCycle(t)
+	if summary.Skipped != 1 || summary.Failed != 0 || summary.NewWatermark != 1700000100000 {
+		t.Fatalf("summary = %+v, want the item skipped and the watermark moved past it", summary)
+	}
+}
+
+// movieImages are the images of the movie in the movie_detail.json fixture.
+var movieImages = map[string][]byte{
+	"https://cdn.test/images/night-train-poster.jpg": []byte("poster"),
+	"https://cdn.test/images/night-train-banner.jpg": []byte("banner"),
+	"https://cdn.test/images/night-train-mobile.jpg": []byte("mobile banner"),
+}
+
+// serveMovie makes the APIs serve the feed_movie.json fixture, its detail and images,
+// and a zipped package holding files, and returns the package.
+func (p *pipeline) serveMovie(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	archive := filepath.Join(t.TempDir(), "movie.zip")
+	writeZip(t, archive, files)
+	zipped, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.server.setFile("https://cdn.test/movies/night-train.zip", zipped)
+	for url, content := range movieImages {
+		p.server.setFile(url, content)
+	}
+	p.server.setJSON(testDetailURL+"/77", readFixture(t, "movie_detail.json"))
+	p.serveFeed(t, "feed_movie.json")
+	return zipped
+}
+
+func TestPipelineCountsDownloadsByContentType(t *testing.T) {
+	p := newPipeline(t)
+	p.serveItems(t, []feedItem{p.adItem(1, true), p.adItem(2, true)})
+
+	if summary := p.runCycle(t); summary.Processed != 2 || summary.Failed != 0 {
+		t.Fatalf("summary = %+v", summary)
+	}
+	stats := p.app.API.Stats()
+	wantBytes := int64(len("advertisement 1") + len("advertisement 2"))
+	if stats.Downloads != 2 || stats.TotalBytes != wantBytes {
+		t.Fatalf("stats = %+v, want 2 downloads of %d bytes", stats, wantBytes)
+	}
+	if len(stats.CountByContentType) != 1 || stats.CountByContentType["local-advertisement"] != 2 {
+		t.Fatalf("count by content type = %v", stats.CountByContentType)
+	}
+}
+
+func TestPipelineSyncsMovie(t *testing.T) {
+	p := newPipeline(t)
+	zipped := p.serveMovie(t, movieFiles)
+
+	summary := p.runCycle(t)
+	if summary.Processed != 1 || summary.Created != 1 || summary.NewWatermark != 1700000200000 {
+		t.Fatalf("summary = %+v", summary)
+	}
+	movie := SharedModels.Movie{ContentId: 201}
+	if err := p.db.First(context.Background(), &movie); err != nil {
+		t.Fatalf("movie not saved: %v", err)
+	}
+	zipSum := md5.Sum(zipped)
+	wantPlayLink := filepath.Join(hex.EncodeToString(zipSum[:]), "hls", "master_hls.m3u8")
+	if movie.NameFa != "قطار شب" || movie.NameEn == nil || *movie.NameEn != "Night Train" || movie.Link.PlayLink != wantPlayLink {
+		t.Fatalf("movie = %+v, want play link %s", movie, wantPlayLink)
+	}
+	for name, want := range movieFiles {
+		path := filepath.Join(ContentBasePath(), "videos", hex.EncodeToString(zipSum[:]), filepath.FromSlash(name))
+		if got, err := os.ReadFile(path); err != nil || string(got) != want {
+			t.Fatalf("%s = %q, %v", name, got, err)
+		}
+	}
+	for url, content := range movieImages {
+		sum := md5.Sum(content)
+		path := filepath.Join(ContentBasePath(), "images", hex.EncodeToString(sum[:])+".jpg")
+		if got, err := os.ReadFile(path); err != nil || !bytes.Equal(got, content) {
+			t.Fatalf("image %s = %q, %v", url, got, err)
+		}
+	}
+	if movie.Image.ImageURL == "" || movie.Image.BannerUrl == nil || movie.Image.MobileBannerUrl == nil {
+		t.Fatalf("movie images = %+v", movie.Image)
+	}
+}
@@ -0,0 +1,240 @@
+package controller
+
+import (
+	"context"
+	"embedup-go/internal/dbclient"
+	SharedModels "embedup-go/internal/shared"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+const (
+	ASSET_KIND_IMAGE     = "images"
+	ASSET_KIND_VIDEO     = "videos"
+	ASSET_KIND_EXTRACTED = "extracted" // A directory under videos, e.g. an HLS movie
+)
+
+// contentAsset is a file or directory on disk that belongs to a content entity.
+type contentAsset struct {
+	kind    string // One of the ASSET_KIND_* values
+	relPath string // Relative to the kind's directory
+}
+
+// assetOwner is a model that can reference assets, and the column referencing them.
+type assetOwner struct {
+	model  interface{}
+	column string
+}
+
+// assetOwners lists, per asset kind, every model that can reference an asset of that
+// kind, so an asset shared between content of different types is found too.
+var assetOwners = map[string][]assetOwner{
+	ASSET_KIND_IMAGE: {
+		{&SharedModels.Movie{}, "image"},
+		{&SharedModels.Genre{}, `"imageUrl"`},
+		{&SharedModels.Slider{}, "image"},
+	},
+	ASSET_KIND_VIDEO:     {{&SharedModels.Advertisement{}, "link"}},
+	ASSET_KIND_EXTRACTED: {{&SharedModels.Movie{}, "link"}},
+}
+
+// contentAssets lists the on-disk assets referenced by a loaded content model.
+func contentAssets(model interface{}) []contentAsset {
+	var assets []contentAsset
+	addImage := func(relPath *string) {
+		if relPath != nil && *relPath != "" {
+			assets = append(assets, contentAsset{kind: ASSET_KIND_IMAGE, relPath: *relPath})
+		}
+	}
+
+	switch m := model.(type) {
+	case *SharedModels.Advertisement:
+		if m.Link.PlayLink != "" {
+			assets = append(assets, contentAsset{kind: ASSET_KIND_VIDEO, relPath: m.Link.PlayLink})
+		}
+	case *SharedModels.Movie:
+		if m.Link.PlayLink != "" {
+			assets = append(assets, contentAsset{kind: ASSET_KIND_EXTRACTED, relPath: movieExtractedDir(m.Link.PlayLink)})
+		}
+		addImage(&m.Image.ImageURL)
+		addImage(m.Image.BannerUrl)
+		addImage(m.Image.MobileBannerUrl)
+	case *SharedModels.Genre:
+		addImage(m.ImageURL)
+	case *SharedModels.Slider:
+		addImage(&m.Image.ImageURL)
+		addImage(m.Image.MediumImageUrl)
+		addImage(m.Image.SmallImageUrl)
+		addImage(m.Image.LogoImageUrl)
+	}
+	return assets
+}
+
+// deleteContentAssets removes the assets of a content entity that is being disabled
+// and returns the paths removed. Assets still referenced by other content are kept, and assets already missing from disk are not treated as an error.
+// With a deletion grace period, assets are scheduled for deletion instead.
+func deleteContentAssets(ctx context.Context, dbConnection dbclient.DBClient,
+	model interface{}, contentID int64) ([]string, error) {
+	var removed []string
+	if !settings.CleanupOnDisable {
+		return removed, nil
+	}
+	for _, asset := range contentAssets(model) {
+		shared, err := assetShared(ctx, dbConnection, contentID, asset)
+		if err != nil {
+			return removed, err
+		}
+		if shared {
+			log.Printf("Keeping %s/%s, still used by other content", asset.kind, asset.relPath)
+			continue
+		}
+
+		if grace := settings.DeletionGracePeriod; grace > 0 {
+			if err := scheduleDeletion(asset, clock.Now().Add(grace)); err != nil {
+				return removed, err
+			}
+			continue
+		}
+		path, err := deleteAsset(asset)
+		if err != nil {
+			return removed, err
+		}
+		if err := forgetAssetMeta(ctx, dbConnection, asset); err != nil {
+			return removed, err
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// assetPath returns where the asset lives on disk.
+func assetPath(asset contentAsset) string {
+	switch asset.kind {
+	case ASSET_KIND_IMAGE:
+		return filepath.Join(ContentBasePath(), "images", asset.relPath)
+	default:
+		return filepath.Join(ContentBasePath(), "videos", asset.relPath)
+	}
+}
+
+// deleteAsset removes the asset from disk and returns its path. An asset already
+// missing is not an error.
+func deleteAsset(asset contentAsset) (string, error) {
+	var err error
+	switch asset.kind {
+	case ASSET_KIND_IMAGE:
+		err = DeleteImage(asset.relPath)
+	case ASSET_KIND_VIDEO:
+		err = DeleteVideo(asset.relPath)
+	case ASSET_KIND_EXTRACTED:
+		err = DeleteExtractedDir(asset.relPath)
+		if err == nil {
+			// The archive it was extracted from, if the movie came zipped.
+			err = DeleteVideo(asset.relPath + ".zip")
+		}
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	return assetPath(asset), nil
+}
+
+// assetMetaPaths returns the asset_meta paths recorded for the asset. An extracted
+// directory is recorded along with the archive it was extracted from.
+func assetMetaPaths(asset contentAsset) []string {
+	if asset.kind == ASSET_KIND_EXTRACTED {
+		dir := filepath.Join("videos", asset.relPath)
+		return []string{dir, dir + ".zip"}
+	}
+	return []string{filepath.Join(asset.kind, asset.relPath)}
+}
+
+// forgetAssetMeta deletes the size and hash recorded for a deleted asset.
+func forgetAssetMeta(ctx context.Context, dbConnection dbclient.DBClient, asset contentAsset) error {
+	return dbConnection.Delete(ctx, &SharedModels.AssetMeta{}, "path IN ?", assetMetaPaths(asset))
+}
+
+// assetShared reports whether content other than contentID, of any type, references
+// the asset. Asset file names are content hashes, so identical media downloaded for two
+// items ends up at the same path. Content IDs are unique across content types.
+func assetShared(ctx context.Context, dbConnection dbclient.DBClient,
+	contentID int64, asset contentAsset) (bool, error) {
+	for _, owner := range assetOwners[asset.kind] {
+		others := reflect.New(reflect.TypeOf(owner.model).Elem()).Interface()
+		shared, err := dbConnection.Exists(ctx, others,
+			fmt.Sprintf(`"contentId" <> ? AND CAST(%s AS text) LIKE ?`, owner.column),
+			contentID, "%"+asset.relPath+"%")
+		if err != nil || shared {
+			return shared, err
+		}
+	}
+	return false, nil
+}
+
+// recordAssetMeta stores the size and hash of a downloaded file. knownMD5, if not empty,
+// is the file's verified MD5; otherwise it is hashed, unless it is already recorded with
+// the same size.
+func recordAssetMeta(ctx context.Context, dbConnection dbclient.DBClient, path string, knownMD5 string) error {
+	relPath, err := filepath.Rel(ContentBasePath(), path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	meta := SharedModels.AssetMeta{Path: relPath}
+	if knownMD5 == "" {
+		if err := dbConnection.First(ctx, &meta); err == nil && meta.Size == info.Size() {
+			return nil
+		}
+		if knownMD5, err = SharedModels.CalculateFileMD5(path); err != nil {
+			return err
+		}
+	}
+	meta.Size = info.Size()
+	meta.Hash = strings.ToLower(knownMD5)
+	meta.UpdatedAt = clock.Now()
+	return dbConnection.Save(ctx, &meta)
+}
+
+// recordExtractedMeta stores the total size of an extracted directory, with the hash
+// recorded for the archive it was extracted from, so the inventory reports the
+// directory the movie is played from.
+func recordExtractedMeta(ctx context.Context, dbConnection dbclient.DBClient, dir string, zipPath string) error {
+	relDir, err := filepath.Rel(ContentBasePath(), dir)
+	if err != nil {
+		return err
+	}
+	relZip, err := filepath.Rel(ContentBasePath(), zipPath)
+	if err != nil {
+		return err
+	}
+	archive := SharedModels.AssetMeta{Path: relZip}
+	if err := dbConnection.First(ctx, &archive); err != nil {
+		return err
+	}
+	var size int64
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return dbConnection.Save(ctx, &SharedModels.AssetMeta{
+		Path: relDir, Size: size, Hash: archive.Hash, UpdatedAt: clock.Now()})
+}
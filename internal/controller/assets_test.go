@@ -0,0 +1,229 @@
+package controller
+
+import (
+	"context"
+	"embedup-go/configs/config"
+	"embedup-go/internal/cstmerr"
+	"embedup-go/internal/dbclient"
+	SharedModels "embedup-go/internal/shared"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// metaDB keeps asset_meta rows in memory. No content row shares an asset.
+type metaDB struct {
+	dbclient.DBClient
+	metas map[string]SharedModels.AssetMeta
+}
+
+func newMetaDB() *metaDB {
+	return &metaDB{metas: make(map[string]SharedModels.AssetMeta)}
+}
+
+func (db *metaDB) First(ctx context.Context, model interface{}, conditions ...interface{}) error {
+	meta := model.(*SharedModels.AssetMeta)
+	stored, ok := db.metas[meta.Path]
+	if !ok {
+		return cstmerr.NewDBNotFoundError("record not found", nil)
+	}
+	*meta = stored
+	return nil
+}
+
+func (db *metaDB) Save(ctx context.Context, model interface{}) error {
+	meta := model.(*SharedModels.AssetMeta)
+	db.metas[meta.Path] = *meta
+	return nil
+}
+
+func (db *metaDB) Delete(ctx context.Context, model interface{}, conditions ...interface{}) error {
+	for _, path := range conditions[1].([]string) {
+		delete(db.metas, path)
+	}
+	return nil
+}
+
+func (db *metaDB) Exists(ctx context.Context, model interface{}, conditions ...interface{}) (bool, error) {
+	return false, nil
+}
+
+// downloadMovie downloads movieFiles zipped, recording asset sizes in db, and returns
+// the extracted directory's name under videos.
+func downloadMovie(t *testing.T, db dbclient.DBClient) string {
+	t.Helper()
+	archive := filepath.Join(t.TempDir(), "movie.zip")
+	writeZip(t, archive, movieFiles)
+	extracted, _, err := downloadZippedVideo(context.Background(), db, localClient(), "file://"+archive, false)
+	if err != nil {
+		t.Fatalf("downloadZippedVideo: %v", err)
+	}
+	return filepath.Base(extracted)
+}
+
+func TestZippedMovieRecordsExtractedDir(t *testing.T) {
+	t.Setenv("PODBOX_UPDATE_CONTENT_BASE_PATH", t.TempDir())
+	db := newMetaDB()
+	dir := downloadMovie(t, db)
+
+	archive, ok := db.metas[filepath.Join("videos", dir+".zip")]
+	if !ok {
+		t.Fatal("archive size not recorded")
+	}
+	extracted, ok := db.metas[filepath.Join("videos", dir)]
+	if !ok {
+		t.Fatal("extracted directory size not recorded")
+	}
+	var want int64
+	for _, content := range movieFiles {
+		want += int64(len(content))
+	}
+	if extracted.Size != want || extracted.Hash != archive.Hash {
+		t.Fatalf("extracted meta = %+v, want size %d and hash %s", extracted, want, archive.Hash)
+	}
+
+	inventory, err := ExportInventory(&inventoryDB{metaDB: db, movie: SharedModels.Movie{
+		ContentId: 1, Link: SharedModels.MovieLink{PlayLink: dir + "/hls/master_hls.m3u8"}}})
+	if err != nil {
+		t.Fatalf("ExportInventory: %v", err)
+	}
+	if len(inventory) != 1 || len(inventory[0].Assets) != 1 || inventory[0].Assets[0].Size != want {
+		t.Fatalf("inventory = %+v, want the movie's directory with size %d", inventory, want)
+	}
+}
+
+// inventoryDB lists movie as the only content row.
+type inventoryDB struct {
+	*metaDB
+	movie SharedModels.Movie
+}
+
+func (db *inventoryDB) Find(ctx context.Context, dest interface{}, conditions ...interface{}) error {
+	switch rows := dest.(type) {
+	case *[]SharedModels.AssetMeta:
+		for _, meta := range db.metas {
+			*rows = append(*rows, meta)
+		}
+	case *[]SharedModels.Movie:
+		*rows = append(*rows, db.movie)
+	}
+	return nil
+}
+
+func TestDisableCleanupForgetsAssetMeta(t *testing.T) {
+	t.Setenv("PODBOX_UPDATE_CONTENT_BASE_PATH", t.TempDir())
+	db := newMetaDB()
+	dir := downloadMovie(t, db)
+	movie := SharedModels.Movie{ContentId: 1, Link: SharedModels.MovieLink{PlayLink: dir + "/hls/master_hls.m3u8"}}
+
+	if _, err := deleteContentAssets(context.Background(), db, &movie, movie.ContentId); err != nil {
+		t.Fatalf("deleteContentAssets: %v", err)
+	}
+	if len(db.metas) != 0 {
+		t.Fatalf("asset meta left behind: %v", db.metas)
+	}
+	for _, path := range []string{dir, dir + ".zip"} {
+		if _, err := os.Stat(filepath.Join(ContentBasePath(), "videos", path)); !os.IsNotExist(err) {
+			t.Fatalf("%s still on disk: %v", path, err)
+		}
+	}
+}
+
+func TestExpiredDeletionForgetsAssetMeta(t *testing.T) {
+	t.Setenv("PODBOX_UPDATE_CONTENT_BASE_PATH", t.TempDir())
+	withSettings(t, nil)
+	db := newMetaDB()
+	dir := downloadMovie(t, db)
+	asset := contentAsset{kind: ASSET_KIND_EXTRACTED, relPath: dir}
+	if err := scheduleDeletion(asset, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := deleteExpiredAssets(context.Background(), db, time.Now())
+	if err != nil || len(removed) != 1 {
+		t.Fatalf("deleteExpiredAssets = %v, %v; want the movie removed", removed, err)
+	}
+	if len(db.metas) != 0 {
+		t.Fatalf("asset meta left behind: %v", db.metas)
+	}
+}
+
+func TestDisableCleanupPerContentType(t *testing.T) {
+	banner, logo := "banner.jpg", "logo.jpg"
+	tests := []struct {
+		name  string
+		model interface{}
+		files []string // Relative to the content base path
+	}{
+		{"advertisement", &SharedModels.Advertisement{ContentId: 1,
+			Link: SharedModels.AdvertisementLink{PlayLink: "ads/spot.mp4"}},
+			[]string{"videos/ads/spot.mp4"}},
+		{"movie", &SharedModels.Movie{ContentId: 1,
+			Link:  SharedModels.MovieLink{PlayLink: "abc/hls/master_hls.m3u8"},
+			Image: SharedModels.MovieImage{ImageURL: "poster.jpg", BannerUrl: &banner}},
+			[]string{"videos/abc/hls/master_hls.m3u8", "videos/abc.zip", "images/poster.jpg", "images/banner.jpg"}},
+		{"genre", &SharedModels.Genre{ContentId: 1, ImageURL: &logo},
+			[]string{"images/logo.jpg"}},
+		{"slider", &SharedModels.Slider{ContentId: 1,
+			Image: SharedModels.SliderImage{ImageURL: "slide.jpg", LogoImageUrl: &logo}},
+			[]string{"images/slide.jpg", "images/logo.jpg"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withSettings(t, nil)
+			t.Setenv("PODBOX_UPDATE_CONTENT_BASE_PATH", t.TempDir())
+			files := make(map[string]string)
+			for _, name := range tt.files {
+				files[name] = name
+			}
+			writeFiles(t, ContentBasePath(), files)
+
+			if _, err := deleteContentAssets(context.Background(), newMemDB(), tt.model, 1); err != nil {
+				t.Fatalf("deleteContentAssets: %v", err)
+			}
+			for _, name := range tt.files {
+				if _, err := os.Stat(filepath.Join(ContentBasePath(), filepath.FromSlash(name))); !os.IsNotExist(err) {
+					t.Fatalf("%s still on disk: %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestDisableCleanupKeepsAssetsSharedAcrossTypes(t *testing.T) {
+	withSettings(t, nil)
+	t.Setenv("PODBOX_UPDATE_CONTENT_BASE_PATH", t.TempDir())
+	writeFiles(t, ContentBasePath(), map[string]string{"images/shared.jpg": "shared", "images/own.jpg": "own"})
+	shared := "shared.jpg"
+	db := newMemDB()
+	if err := db.Save(context.Background(), &SharedModels.Genre{ContentId: 2, ImageURL: &shared}); err != nil {
+		t.Fatal(err)
+	}
+	movie := SharedModels.Movie{ContentId: 1, Image: SharedModels.MovieImage{ImageURL: "own.jpg", BannerUrl: &shared}}
+
+	removed, err := deleteContentAssets(context.Background(), db, &movie, movie.ContentId)
+	if err != nil {
+		t.Fatalf("deleteContentAssets: %v", err)
+	}
+	if len(removed) != 1 || removed[0] != filepath.Join(ContentBasePath(), "images", "own.jpg") {
+		t.Fatalf("removed = %v, want only the movie's own image", removed)
+	}
+	if _, err := os.Stat(filepath.Join(ContentBasePath(), "images", "shared.jpg")); err != nil {
+		t.Fatalf("image shared with a genre was deleted: %v", err)
+	}
+}
+
+func TestDisableCleanupCanBeTurnedOff(t *testing.T) {
+	withSettings(t, func(cfg *config.Config) { cfg.CleanupOnDisable = false })
+	t.Setenv("PODBOX_UPDATE_CONTENT_BASE_PATH", t.TempDir())
+	writeFiles(t, ContentBasePath(), map[string]string{"videos/ads/spot.mp4": "spot"})
+	ad := SharedModels.Advertisement{ContentId: 1, Link: SharedModels.AdvertisementLink{PlayLink: "ads/spot.mp4"}}
+
+	if removed, err := deleteContentAssets(context.Background(), newMemDB(), &ad, ad.ContentId); err != nil || len(removed) != 0 {
+		t.Fatalf("deleteContentAssets = %v, %v; want nothing removed", removed, err)
+	}
+	if _, err := os.Stat(filepath.Join(ContentBasePath(), "videos", "ads", "spot.mp4")); err != nil {
+		t.Fatalf("advertisement deleted with cleanup turned off: %v", err)
+	}
+}
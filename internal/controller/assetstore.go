@@ -0,0 +1,85 @@
+package controller
+
+import (
+	SharedModels "embedup-go/internal/shared"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// AssetStore holds synced assets under slash-separated paths relative to the content
+// root, e.g. "images/slider/<hash>.jpg". Downloads and archive extraction still write
+// to the local filesystem through the API client; the store covers what is done with
+// stored assets afterwards: checking that they are there and removing them.
+//
+// Delete removes a file or a whole directory, such as an extracted movie, and returns
+// an error matching os.ErrNotExist if nothing is stored at path.
+type AssetStore interface {
+	Exists(path string) (bool, error)
+	Delete(path string) error
+}
+
+// LocalAssetStore is an AssetStore on the local filesystem under Root, or under
+// ContentBasePath if Root is empty.
+type LocalAssetStore struct {
+	Root string
+}
+
+func (s LocalAssetStore) fullPath(path string) string {
+	root := s.Root
+	if root == "" {
+		root = ContentBasePath()
+	}
+	return filepath.Join(root, filepath.FromSlash(path))
+}
+
+func (s LocalAssetStore) Exists(path string) (bool, error) {
+	_, err := os.Stat(s.fullPath(path))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Delete removes the file or directory at path. Files made read-only get write
+// permission back first.
+func (s LocalAssetStore) Delete(path string) error {
+	full := s.fullPath(path)
+	info, err := os.Lstat(full)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return os.RemoveAll(full)
+	}
+	return SharedModels.RemoveAsset(full)
+}
+
+var (
+	assetStoreMu sync.RWMutex
+	assetStore   AssetStore = LocalAssetStore{}
+)
+
+// SetAssetStore replaces the store assets are kept in, by default a LocalAssetStore
+// under ContentBasePath.
+func SetAssetStore(store AssetStore) {
+	assetStoreMu.Lock()
+	defer assetStoreMu.Unlock()
+	assetStore = store
+}
+
+// assets returns the current AssetStore.
+func assets() AssetStore {
+	assetStoreMu.RLock()
+	defer assetStoreMu.RUnlock()
+	return assetStore
+}
+
+// storePath returns the asset store path of path, an absolute path under ContentBasePath.
+func storePath(path string) (string, error) {
+	rel, err := filepath.Rel(ContentBasePath(), path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
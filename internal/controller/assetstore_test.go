@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"context"
+	SharedModels "embedup-go/internal/shared"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memAssetStore is an in-memory AssetStore. A directory exists while any path under it
+// is stored.
+type memAssetStore struct {
+	mu    sync.Mutex
+	paths map[string]bool
+}
+
+func newMemAssetStore(paths ...string) *memAssetStore {
+	store := &memAssetStore{paths: make(map[string]bool)}
+	for _, path := range paths {
+		store.paths[path] = true
+	}
+	return store
+}
+
+func (s *memAssetStore) Exists(path string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for stored := range s.paths {
+		if stored == path || strings.HasPrefix(stored, path+"/") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *memAssetStore) Delete(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found := false
+	for stored := range s.paths {
+		if stored == path || strings.HasPrefix(stored, path+"/") {
+			delete(s.paths, stored)
+			found = true
+		}
+	}
+	if !found {
+		return os.ErrNotExist
+	}
+	return nil
+}
+
+func (s *memAssetStore) stored() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var paths []string
+	for path := range s.paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// withAssetStore runs the test with store as the asset store.
+func withAssetStore(t *testing.T, store AssetStore) {
+	t.Helper()
+	previous := assets()
+	SetAssetStore(store)
+	t.Cleanup(func() { SetAssetStore(previous) })
+}
+
+func TestLocalAssetStore(t *testing.T) {
+	store := LocalAssetStore{Root: t.TempDir()}
+	writeFiles(t, store.Root, map[string]string{"images/a.jpg": "a", "videos/movie/hls/master.m3u8": "#EXTM3U"})
+
+	for path, want := range map[string]bool{"images/a.jpg": true, "videos/movie": true, "images/b.jpg": false} {
+		if got, err := store.Exists(path); err != nil || got != want {
+			t.Fatalf("Exists(%s) = %t, %v; want %t", path, got, err, want)
+		}
+	}
+	for _, path := range []string{"images/a.jpg", "videos/movie"} {
+		if err := store.Delete(path); err != nil {
+			t.Fatalf("Delete(%s): %v", path, err)
+		}
+		if got, _ := store.Exists(path); got {
+			t.Fatalf("%s still stored after Delete", path)
+		}
+	}
+	if err := store.Delete("images/a.jpg"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Delete of a missing asset = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestDisableCleanupDeletesFromAssetStore(t *testing.T) {
+	withSettings(t, nil)
+	store := newMemAssetStore("videos/abc/hls/master_hls.m3u8", "videos/abc.zip", "images/poster.jpg", "images/other.jpg")
+	withAssetStore(t, store)
+	movie := SharedModels.Movie{ContentId: 1, Link: SharedModels.MovieLink{PlayLink: "abc/hls/master_hls.m3u8"},
+		Image: SharedModels.MovieImage{ImageURL: "poster.jpg"}}
+
+	if _, err := deleteContentAssets(context.Background(), newMemDB(), &movie, movie.ContentId); err != nil {
+		t.Fatalf("deleteContentAssets: %v", err)
+	}
+	if got, want := store.stored(), []string{"images/other.jpg"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("stored = %v, want %v", got, want)
+	}
+}
+
+func TestFindExtractedMovieChecksAssetStore(t *testing.T) {
+	db := newMemDB()
+	movie := SharedModels.Movie{ContentId: 1, Link: SharedModels.MovieLink{PlayLink: "abc/hls/master_hls.m3u8"}}
+	if err := db.Save(context.Background(), &movie); err != nil {
+		t.Fatal(err)
+	}
+
+	withAssetStore(t, newMemAssetStore("videos/abc/hls/master_hls.m3u8"))
+	dir, found, err := findExtractedMovie(context.Background(), db, 1)
+	if err != nil || !found || dir != filepath.Join(ContentBasePath(), "videos", "abc") {
+		t.Fatalf("findExtractedMovie = %q, %t, %v; want the stored directory", dir, found, err)
+	}
+
+	withAssetStore(t, newMemAssetStore())
+	if _, found, err := findExtractedMovie(context.Background(), db, 1); err != nil || found {
+		t.Fatalf("findExtractedMovie = %t, %v; want the missing directory not found", found, err)
+	}
+}
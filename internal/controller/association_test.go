@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"context"
+	"embedup-go/internal/cstmerr"
+	"embedup-go/internal/dbclient"
+	SharedModels "embedup-go/internal/shared"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// txDB is memDB with transactions that roll back: writes made inside one land in a
+// scratch memDB and are copied over only if fn succeeds. With failAssociate set,
+// CreateAssosiate fails.
+type txDB struct {
+	*memDB
+	failAssociate bool
+	associated    int
+}
+
+func (db *txDB) RunInTransaction(ctx context.Context, fn func(ctx context.Context, txClient dbclient.DBClient) error) error {
+	tx := &txDB{memDB: newMemDB(), failAssociate: db.failAssociate}
+	if err := fn(ctx, tx); err != nil {
+		return err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for rowType, rows := range tx.tables {
+		for key, row := range rows {
+			db.table(rowType)[key] = row
+		}
+	}
+	db.associated += tx.associated
+	return nil
+}
+
+func (db *txDB) CreateAssosiate(ctx context.Context, model interface{}, assosiation string, assosiate interface{}) error {
+	if db.failAssociate {
+		return errors.New("connection reset while inserting the join rows")
+	}
+	db.associated++
+	return nil
+}
+
+func TestFailedAssociationRollsBackParent(t *testing.T) {
+	p := newPipeline(t)
+	for _, url := range []string{"https://cdn.test/slider.jpg", "https://cdn.test/slider-m.jpg", "https://cdn.test/slider-s.jpg"} {
+		p.server.setFile(url, []byte(url))
+	}
+	tests := []struct {
+		name    string
+		model   interface{}
+		wantMsg string
+		process func(db dbclient.DBClient) (ProcessResult, error)
+	}{
+		{"slider", &SharedModels.Slider{}, "failed to create assosiate slider tab",
+			func(db dbclient.DBClient) (ProcessResult, error) {
+				content := SharedModels.ProcessedContentSchema{ID: 1, Enable: true, Details: SharedModels.LocalSliderSchema{
+					ImageURL: "https://cdn.test/slider.jpg", MediumImageURL: "https://cdn.test/slider-m.jpg",
+					SmallImageURL: "https://cdn.test/slider-s.jpg", LocalTabIDs: []int{7}}}
+				return ProcessLocalSlider(context.Background(), content, db, p.app.API)
+			}},
+		{"tab", &SharedModels.Tab{}, "failed to create assosiate tab page",
+			func(db dbclient.DBClient) (ProcessResult, error) {
+				content := SharedModels.ProcessedContentSchema{ID: 2, Enable: true,
+					Details: SharedModels.LocalTabSchema{LocalPageIDs: []int{10, 11}}}
+				return ProcessLocalTab(context.Background(), content, db)
+			}},
+		{"section", &SharedModels.Section{}, "failed to create assosiate section tab",
+			func(db dbclient.DBClient) (ProcessResult, error) {
+				content := SharedModels.ProcessedContentSchema{ID: 3, Enable: true,
+					Details: SharedModels.LocalSectionSchema{LocalTabIDs: []int{7}}}
+				return ProcessLocalSection(context.Background(), content, db)
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &txDB{memDB: newMemDB(), failAssociate: true}
+			_, err := tt.process(db)
+			var processErr *cstmerr.ProcessError
+			if !errors.As(err, &processErr) || !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Fatalf("process error = %v, want a ProcessError %q", err, tt.wantMsg)
+			}
+			if n := db.rows(tt.model); n != 0 {
+				t.Fatalf("%d %s row(s) saved without their associations", n, tt.name)
+			}
+
+			db.failAssociate = false
+			if _, err := tt.process(db); err != nil {
+				t.Fatalf("process: %v", err)
+			}
+			if db.rows(tt.model) != 1 || db.associated != 1 {
+				t.Fatalf("%d %s row(s) and %d association(s) saved, want 1 and 1", db.rows(tt.model), tt.name, db.associated)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"context"
+	"database/sql/driver"
+	"embedup-go/configs/config"
+	ApiClient "embedup-go/internal/apiclient"
+	"embedup-go/internal/backoff"
+	"embedup-go/internal/clocktest"
+	"embedup-go/internal/cstmerr"
+	"embedup-go/internal/dbclient"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyHTTP is stubHTTP with a download whose first attempt drops the connection,
+// and a status report endpoint that is always unavailable.
+type flakyHTTP struct {
+	*stubHTTP
+	mu      sync.Mutex
+	streams int
+	puts    int
+}
+
+func (f *flakyHTTP) GetStream(url string, opts *ApiClient.RequestOptions) (*ApiClient.StreamResponse, error) {
+	f.mu.Lock()
+	f.streams++
+	first := f.streams == 1
+	f.mu.Unlock()
+	if first {
+		return nil, &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}
+	}
+	return f.stubHTTP.GetStream(url, opts)
+}
+
+func (f *flakyHTTP) Put(url string, opts *ApiClient.RequestOptions) (*ApiClient.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.puts++
+	return &ApiClient.Response{StatusCode: http.StatusServiceUnavailable, Body: []byte("try later"), RequestURL: url}, nil
+}
+
+// flakyDB is memDB failing its first failures saves as if the connection was lost.
+type flakyDB struct {
+	*memDB
+	failures int
+	saves    int
+}
+
+func (db *flakyDB) SaveReturning(ctx context.Context, model interface{}) (bool, error) {
+	db.saves++
+	if db.saves <= db.failures {
+		return false, driver.ErrBadConn
+	}
+	return db.memDB.SaveReturning(ctx, model)
+}
+
+func TestCycleRetriesShareBudget(t *testing.T) {
+	const budget = 5
+	p := newPipeline(t)
+	clock := clocktest.NewFakeClock(time.Unix(0, 0))
+	server := &flakyHTTP{stubHTTP: p.server}
+	cfg := &config.Config{ContentUpdateAPIURL: testUpdatesURL, ContentDetailAPIURL: testDetailURL,
+		StatusReportAPIURL: "https://api.test/status"}
+	p.app.API = ApiClient.NewWithHTTPClient(cfg, "test-token", server)
+	p.app.API.SetClock(clock)
+	db := &flakyDB{memDB: p.db, failures: 2}
+	p.app.DB = dbclient.NewReconnectingClient(db, clock, 3, time.Second)
+	p.app.RetryBudget = budget
+	p.serveItems(t, []feedItem{p.adItem(1, true)})
+
+	ctx := p.app.CycleContext(context.Background())
+	summary, err := p.app.RunCycle(ctx)
+	if err != nil {
+		t.Fatalf("RunCycle: %v", err)
+	}
+	if summary.Processed != 1 {
+		t.Fatalf("summary = %+v, want the advertisement processed after its retries", summary)
+	}
+	err = p.app.API.ReportStatusContext(ctx, 1, "degraded")
+	var apiErr *cstmerr.APIRequestFailedError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("ReportStatusContext = %v, want the 503 of the unavailable server", err)
+	}
+
+	// Each operation retries once per failure until the budget runs out: the download
+	// once, the save twice, and the status report with what is left.
+	downloadRetries := server.streams - 1
+	saveRetries := db.saves - 1
+	statusRetries := server.puts - 1
+	if downloadRetries != 1 || saveRetries != 2 || statusRetries != budget-3 {
+		t.Fatalf("retries: download %d, save %d, status %d", downloadRetries, saveRetries, statusRetries)
+	}
+	if total := downloadRetries + saveRetries + statusRetries; total != budget {
+		t.Fatalf("cycle made %d retries, want its budget of %d", total, budget)
+	}
+	if left := backoff.BudgetFrom(ctx).Remaining(); left != 0 {
+		t.Fatalf("budget has %d retries left, want 0", left)
+	}
+	if len(clock.Sleeps) != budget {
+		t.Fatalf("slept %d times, want once per retry: %v", len(clock.Sleeps), clock.Sleeps)
+	}
+}
+
+// TestRunCycleUsesContextBudget checks a cycle draws from the budget it is given
+// rather than starting a new one.
+func TestRunCycleUsesContextBudget(t *testing.T) {
+	p := newPipeline(t)
+	clock := clocktest.NewFakeClock(time.Unix(0, 0))
+	p.app.DB = dbclient.NewReconnectingClient(&flakyDB{memDB: p.db, failures: 1}, clock, 3, time.Second)
+	p.app.RetryBudget = 5
+	p.serveItems(t, []feedItem{p.adItem(1, true)})
+
+	b := backoff.NewBudget(2)
+	if _, err := p.app.RunCycle(backoff.WithBudget(context.Background(), b)); err != nil {
+		t.Fatalf("RunCycle: %v", err)
+	}
+	if b.Remaining() != 1 {
+		t.Fatalf("budget has %d retries left, want 1", b.Remaining())
+	}
+}
@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"embedup-go/internal/cstmerr"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var catchUpMu sync.Mutex
+
+// catchUpFile returns the catch-up journal in the state directory. Its first line is
+// "from <watermark>" for the batch in progress, followed by the IDs of the items of
+// that batch already processed, one per line.
+func catchUpFile() string {
+	return statePath("catchup")
+}
+
+// catchUpJournal is the progress of the batch fetched from watermark From.
+type catchUpJournal struct {
+	From int64
+	Done map[int64]bool
+}
+
+// readCatchUpJournal loads the journal. A missing file means no batch is in progress.
+func readCatchUpJournal() (catchUpJournal, error) {
+	catchUpMu.Lock()
+	defer catchUpMu.Unlock()
+	journal := catchUpJournal{Done: make(map[int64]bool)}
+	data, err := os.ReadFile(catchUpFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return journal, nil
+		}
+		return journal, cstmerr.NewFileIOError(fmt.Sprintf("failed to read %s", catchUpFile()), err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if from, ok := strings.CutPrefix(line, "from "); ok {
+			if journal.From, err = strconv.ParseInt(from, 10, 64); err != nil {
+				log.Printf("Ignoring malformed catch-up watermark %q", line)
+			}
+			continue
+		}
+		id, err := strconv.ParseInt(line, 10, 64)
+		if err != nil {
+			log.Printf("Ignoring malformed catch-up entry %q", line)
+			continue
+		}
+		journal.Done[id] = true
+	}
+	return journal, scanner.Err()
+}
+
+// startCatchUpBatch resets the journal for a batch fetched from watermark from.
+func startCatchUpBatch(from int64) error {
+	catchUpMu.Lock()
+	defer catchUpMu.Unlock()
+	return writeStateFile(catchUpFile(), []byte(fmt.Sprintf("from %d\n", from)))
+}
+
+// markCatchUpDone records that the item with the given ID was processed.
+func markCatchUpDone(id int64) error {
+	catchUpMu.Lock()
+	defer catchUpMu.Unlock()
+	file, err := os.OpenFile(catchUpFile(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return cstmerr.NewFileIOError(fmt.Sprintf("failed to open %s", catchUpFile()), err)
+	}
+	defer file.Close()
+	if _, err := fmt.Fprintf(file, "%d\n", id); err != nil {
+		return cstmerr.NewFileIOError(fmt.Sprintf("failed to write %s", catchUpFile()), err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"context"
+	"embedup-go/configs/config"
+	SharedModels "embedup-go/internal/shared"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCatchUpResumesBatchFromJournal(t *testing.T) {
+	withSettings(t, func(cfg *config.Config) { cfg.CatchUpMode = true })
+	p := newPipeline(t)
+	p.serveAds(t, 4)
+	p.db.failSave[3] = true
+
+	if _, err := p.app.RunCycle(context.Background()); err == nil {
+		t.Fatal("RunCycle succeeded with a failing item")
+	}
+	if filepath.Dir(catchUpFile()) != settings.StateDir {
+		t.Fatalf("catch-up journal %s is outside the state dir", catchUpFile())
+	}
+	journal, err := readCatchUpJournal()
+	if err != nil || len(journal.Done) != 3 || journal.Done[3] {
+		t.Fatalf("journal = %+v, %v; want every item but 3 done", journal, err)
+	}
+
+	delete(p.db.failSave, 3)
+	summary := p.runCycle(t)
+	if summary.Resumed != 3 || summary.Processed != 1 || summary.NewWatermark != 4000 {
+		t.Fatalf("summary = %+v, want 3 resumed and item 3 processed", summary)
+	}
+}
+
+// storedWatermark returns the watermark saved in the updater row.
+func (p *pipeline) storedWatermark(t *testing.T) int64 {
+	t.Helper()
+	var updater SharedModels.Updater
+	if err := p.db.First(context.Background(), &updater); err != nil {
+		t.Fatalf("updater row: %v", err)
+	}
+	return updater.LastFromTimeStamp
+}
+
+func TestCatchUpResumesFromSavedWatermarkAfterCrash(t *testing.T) {
+	withSettings(t, func(cfg *config.Config) { cfg.CatchUpMode = true })
+	p := newPipeline(t)
+	p.serveItems(t, []feedItem{p.adItem(1, true), p.adItem(2, true)})
+	p.runCycle(t)
+	if got := p.storedWatermark(t); got != 2000 {
+		t.Fatalf("stored watermark = %d after a complete batch, want 2000", got)
+	}
+
+	// The next batch crashes at item 4, after items 3 and 5 were processed.
+	p.serveItems(t, []feedItem{p.adItem(3, true), p.adItem(4, true), p.adItem(5, true)})
+	p.db.failSave[4] = true
+	if _, err := p.app.RunCycle(context.Background()); err == nil {
+		t.Fatal("RunCycle succeeded with a failing item")
+	}
+	if got := p.storedWatermark(t); got != 2000 {
+		t.Fatalf("stored watermark = %d after an incomplete batch, want 2000", got)
+	}
+
+	// After a reboot the updater is read back from its row.
+	delete(p.db.failSave, 4)
+	var updater SharedModels.Updater
+	if err := p.db.First(context.Background(), &updater); err != nil {
+		t.Fatal(err)
+	}
+	p.app = &App{API: p.app.API, DB: p.db, Updater: &updater}
+	p.db.written = nil
+	summary := p.runCycle(t)
+	if summary.Resumed != 2 || summary.Processed != 1 || summary.NewWatermark != 5000 {
+		t.Fatalf("summary = %+v, want items 3 and 5 resumed and item 4 processed", summary)
+	}
+	if !reflect.DeepEqual(p.db.written, []int64{4}) {
+		t.Fatalf("wrote %v after the reboot, want only item 4", p.db.written)
+	}
+	if got := p.storedWatermark(t); got != 5000 {
+		t.Fatalf("stored watermark = %d, want 5000", got)
+	}
+}
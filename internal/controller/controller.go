@@ -1,42 +1,119 @@
 package controller
 
 import (
+	"bytes"
 	"context"
+	"embedup-go/configs/config"
 	ApiClient "embedup-go/internal/apiclient"
 	"embedup-go/internal/cstmerr"
 	"embedup-go/internal/dbclient"
 	SharedModels "embedup-go/internal/shared"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime/debug"
+	"strings"
 	"time"
 )
 
-func DeleteAudio(filePath string) error {
-	// Delete the file at the specified filePath
+// ProcessResult describes what processing a single content item produced.
+type ProcessResult struct {
+	EntityID   int64
+	AssetPaths []string // Files or directories written to (or removed from) the content base path
+	Hash       string   // Hash of the primary asset, if any
+	Action     string   // One of the PROCESS_ACTION_* values
+	Created    bool     // A save inserted a new row rather than updating one
+}
+
+const (
+	PROCESS_ACTION_SAVE   = "save"
+	PROCESS_ACTION_DELETE = "delete"
+	PROCESS_ACTION_SKIP   = "skip"
+	// PROCESS_ACTION_QUARANTINE marks an item set aside: with an error when its processing
+	// panicked, without one when the unknown type policy quarantined it.
+	PROCESS_ACTION_QUARANTINE = "quarantine"
+)
+
+// SyncSummary describes the outcome of one content update cycle.
+type SyncSummary struct {
+	Fetched      int
+	Processed    int    // Items saved or deleted
+	Skipped      int    // Items with no local action
+	Failed       int    // Items whose processing returned an error
+	Resumed      int    // Items skipped because the catch-up journal shows them already processed
+	Deferred     int    // Items set aside until their parent is synced
+	Resolved     int    // Previously deferred items processed once their parent was synced
+	Abandoned    int    // Deferred items dropped after waiting the pending max age for their parent
+	NewWatermark int64  // Updater timestamp after the cycle
+	RequestID    string // Request ID sent with the cycle's requests
+	Degraded     bool   // More than the degraded failure ratio of the batch failed
+	// Created and Updated split the saved items into new and changed ones.
+	Created int
+	Updated int
+	// Unreadable lists sampled assets saved this cycle that could not be read back.
+	Unreadable []string
+	// WatermarkLag is how far the watermark is behind the newest content seen on the
+	// server; Lagging reports that it is above the configured warning threshold.
+	WatermarkLag time.Duration
+	Lagging      bool
+}
+
+// Attempted returns how many items were processed this cycle, successfully or not.
+// Items a fail-fast batch never got to don't count.
+func (s SyncSummary) Attempted() int {
+	return s.Processed + s.Skipped + s.Failed
+}
+
+// FailureRatio returns the fraction of attempted items that failed.
+func (s SyncSummary) FailureRatio() float64 {
+	if s.Attempted() == 0 {
+		return 0
+	}
+	return float64(s.Failed) / float64(s.Attempted())
+}
+
+// ContentBasePath returns the directory synced assets are stored under, taken from
+// PODBOX_UPDATE_CONTENT_BASE_PATH.
+func ContentBasePath() string {
 	contentBasePath := os.Getenv("PODBOX_UPDATE_CONTENT_BASE_PATH")
 	if contentBasePath == "" {
 		contentBasePath = "/mnt/sdcard/assets/"
 	}
-	dest := filepath.Join(contentBasePath, "audios", filePath)
-	err := os.Remove(dest)
-	if err != nil {
-		log.Printf("Error deleting file %s: %v", dest, err)
-		return cstmerr.NewFileDeleteError(fmt.Sprintf("failed to delete file: %s", dest), err)
+	return contentBasePath
+}
+
+// contentDirs are the directories under ContentBasePath that assets are written to.
+var contentDirs = []string{
+	"images",
+	filepath.Join("images", "genre"),
+	filepath.Join("images", "slider"),
+	"videos",
+	filepath.Join("videos", "ads"),
+	"audios",
+}
+
+// EnsureContentDirs creates ContentBasePath and the directories assets are written to,
+// if missing, so a content path that can't be created fails at startup rather than on the
+// first download.
+func EnsureContentDirs() error {
+	for _, dir := range append([]string{""}, contentDirs...) {
+		path := filepath.Join(ContentBasePath(), dir)
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return cstmerr.NewFileSystemError(fmt.Sprintf("failed to create content directory %s: %v", path, err))
+		}
 	}
 	return nil
 }
 
-func DeleteVideo(filePath string) error {
-	// Delete the file at the specified filePath
-	contentBasePath := os.Getenv("PODBOX_UPDATE_CONTENT_BASE_PATH")
-	if contentBasePath == "" {
-		contentBasePath = "/mnt/sdcard/assets/"
-	}
-	dest := filepath.Join(contentBasePath, "videos", filePath)
-	err := os.Remove(dest)
+// deleteStoredFile deletes the file at filePath under the kind's directory from the
+// asset store.
+func deleteStoredFile(kind string, filePath string) error {
+	dest := filepath.ToSlash(filepath.Join(kind, filePath))
+	err := assets().Delete(dest)
 	if err != nil {
 		log.Printf("Error deleting file %s: %v", dest, err)
 		return cstmerr.NewFileDeleteError(fmt.Sprintf("failed to delete file: %s", dest), err)
@@ -44,64 +121,146 @@ func DeleteVideo(filePath string) error {
 	return nil
 }
 
-func DeleteImage(filePath string) error {
-	// Delete the file at the specified filePath
-	contentBasePath := os.Getenv("PODBOX_UPDATE_CONTENT_BASE_PATH")
-	if contentBasePath == "" {
-		contentBasePath = "/mnt/sdcard/assets/"
+func DeleteAudio(filePath string) error {
+	return deleteStoredFile("audios", filePath)
+}
+
+func DeleteVideo(filePath string) error {
+	return deleteStoredFile("videos", filePath)
+}
+
+// DeleteExtractedDir removes an extracted content directory (e.g. an HLS movie)
+// located under the videos directory. Absolute paths and paths escaping the videos
+// directory are rejected, and a directory that is already gone is not treated as an
+// error.
+func DeleteExtractedDir(relPath string) error {
+	contentBasePath := ContentBasePath()
+	videosPath := filepath.Join(contentBasePath, "videos")
+	dest := filepath.Join(videosPath, relPath)
+	if filepath.IsAbs(relPath) || !strings.HasPrefix(dest, filepath.Clean(videosPath)+string(os.PathSeparator)) {
+		return cstmerr.NewFileDeleteError(fmt.Sprintf("illegal directory path: %s", relPath), nil)
 	}
-	dest := filepath.Join(contentBasePath, "images", filePath)
-	err := os.Remove(dest)
-	if err != nil {
-		log.Printf("Error deleting file %s: %v", dest, err)
-		return cstmerr.NewFileDeleteError(fmt.Sprintf("failed to delete file: %s", dest), err)
+	err := assets().Delete(filepath.ToSlash(filepath.Join("videos", relPath)))
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Error deleting directory %s: %v", dest, err)
+		return cstmerr.NewFileDeleteError(fmt.Sprintf("failed to delete directory: %s", dest), err)
 	}
 	return nil
 }
 
-func DownloadImage(apiclient *ApiClient.APIClient, url string, dir ...string) (string, string, error) {
+func DeleteImage(filePath string) error {
+	return deleteStoredFile("images", filePath)
+}
 
-	contentBasePath := os.Getenv("PODBOX_UPDATE_CONTENT_BASE_PATH")
-	if contentBasePath == "" {
-		contentBasePath = "/mnt/sdcard/assets/"
-	}
-	destinationPath := filepath.Join(append([]string{contentBasePath, "images"}, dir...)...)
+func DownloadImage(dbConnection dbclient.DBClient, apiclient *ApiClient.APIClient, url string, dir ...string) (string, string, error) {
+	return downloadContentFile(context.Background(), dbConnection, apiclient, url, "images", ".jpg", false, true, dir...)
+}
 
-	log.Printf("destination path for download file : %s \n", destinationPath)
-	err := SharedModels.CheckAndCreateDir(destinationPath)
-	if err != nil {
-		log.Printf("Error in creating path %s: %v", destinationPath, err)
-	}
+func DownloadVideo(dbConnection dbclient.DBClient, apiclient *ApiClient.APIClient, url string, dir ...string) (string, string, error) {
+	return downloadContentFile(context.Background(), dbConnection, apiclient, url, "videos", ".mp4", false, false, dir...)
+}
 
-	fileInformation, err := apiclient.GetFileInformation(url)
+func DownloadZippedVideo(dbConnection dbclient.DBClient, apiclient *ApiClient.APIClient, url string, dir ...string) (string, string, error) {
+	return downloadZippedVideo(context.Background(), dbConnection, apiclient, url, false, dir...)
+}
 
+func downloadZippedVideo(ctx context.Context, dbConnection dbclient.DBClient, apiclient *ApiClient.APIClient, url string, force bool, dir ...string) (string, string, error) {
+	destinationFile, fileNameWithPrefix, err := downloadContentFile(ctx, dbConnection, apiclient, url, "videos", ".zip", force, false, dir...)
 	if err != nil {
-		fileInformation.MD5 = SharedModels.CalculateStringMD5(url)
+		return "", "", err
 	}
+	destinationExtracted := strings.TrimSuffix(destinationFile, ".zip")
+	if force {
+		if err := deleteStoredDir(destinationExtracted); err != nil {
+			return "", "", cstmerr.NewFileDeleteError(fmt.Sprintf("failed to delete directory: %s", destinationExtracted), err)
+		}
+	}
+	if settings.ExtractFreeSpaceCheck {
+		// An intact archive is kept, and the next cycle extracts it once space is freed.
+		if err := SharedModels.CheckExtractionSpace(destinationFile, destinationExtracted, settings.ExtractFreeSpaceMargin); err != nil {
+			return "", "", dropCorruptArchive(destinationFile, err)
+		}
+	}
+	if settings.ExtractResume {
+		// Files a previous attempt extracted in full are kept; huge HLS packages aren't redone.
+		err = SharedModels.UnzipFileResume(destinationFile, destinationExtracted)
+	} else {
+		err = SharedModels.UnzipFile(destinationFile, destinationExtracted)
+	}
+	if err != nil {
+		return "", "", dropCorruptArchive(destinationFile, err)
+	}
+	if dbConnection != nil {
+		metaCtx, cancel := dbContext(ctx)
+		err := recordExtractedMeta(metaCtx, dbConnection, destinationExtracted, destinationFile)
+		cancel()
+		if err != nil {
+			log.Printf("Failed to record size of %s: %v", destinationExtracted, err)
+		}
+	}
+	return destinationExtracted, fileNameWithPrefix, nil
+}
 
-	fileNameWithPrefix := fileInformation.MD5 + ".jpg"
-
-	destinationFile := filepath.Join(destinationPath, fileNameWithPrefix)
-	log.Printf("destination file: %s", destinationFile)
-
-	err = apiclient.DownloadFileWithRetry(url, destinationFile)
+// dropCorruptArchive returns err, the failure to extract the archive at path, unless
+// the archive is corrupt. A corrupt archive counts as downloaded and would fail the
+// same way every cycle, so it is removed for the next cycle to download it again, and
+// the corruption is returned instead.
+func dropCorruptArchive(path string, err error) error {
+	validateErr := SharedModels.ValidateZipFile(path)
+	if validateErr == nil {
+		return err
+	}
+	log.Printf("Archive %s is corrupt, removing it: %v", path, validateErr)
+	if removeErr := deleteStoredDir(path); removeErr != nil {
+		log.Printf("Failed to remove corrupt archive %s: %v", path, removeErr)
+	}
+	return validateErr
+}
 
+// deleteStoredDir deletes the directory, or file, at path, under ContentBasePath, from
+// the asset store. One that is already gone is not an error.
+func deleteStoredDir(path string) error {
+	rel, err := storePath(path)
 	if err != nil {
-		log.Printf("error in downloading hash")
-		return "", "", cstmerr.NewDownloadError(
-			fmt.Sprintf("failed to download multiple times: %s", url))
+		return err
+	}
+	if err := assets().Delete(rel); err != nil && !os.IsNotExist(err) {
+		return err
 	}
+	return nil
+}
 
-	return destinationFile, fileNameWithPrefix, nil
+// HLS_DIRECTORY_SUBDIR is the subdirectory an HLS package served as a directory is
+// downloaded into, mirroring the single top-level directory of a zipped package.
+const HLS_DIRECTORY_SUBDIR = "hls"
+
+// downloadHLSMovie downloads the HLS package under masterURL into a videos directory
+// named after the URL's MD5, laid out like an extracted zipped package, and returns
+// that directory. With force, an existing copy is discarded first.
+func downloadHLSMovie(ctx context.Context, apiclient *ApiClient.APIClient, masterURL string, force bool) (string, error) {
+	extractedPath := filepath.Join(ContentBasePath(), "videos", SharedModels.CalculateStringMD5(masterURL))
+	if force {
+		if err := deleteStoredDir(extractedPath); err != nil {
+			return "", cstmerr.NewFileDeleteError(fmt.Sprintf("failed to delete directory: %s", extractedPath), err)
+		}
+	}
+	hlsDir := filepath.Join(extractedPath, HLS_DIRECTORY_SUBDIR)
+	if err := downloadHLSDirectory(ctx, apiclient, masterURL, hlsDir, masterPlaylistName(HLS_DIRECTORY_SUBDIR)); err != nil {
+		return "", err
+	}
+	return extractedPath, nil
 }
 
-func DownloadVideo(apiclient *ApiClient.APIClient, url string, dir ...string) (string, string, error) {
+// downloadContentFile downloads url into <content base>/<kind>/<dir...>, naming the
+// file after the server-provided MD5 (or the URL's MD5) with the given extension.
+// An existing file is resumed or kept as is, unless force is set, in which case it
+// is removed and downloaded again. With verify, a file named after the server-provided
+// MD5 must hash to it in full, otherwise the download is retried.
+func downloadContentFile(ctx context.Context, dbConnection dbclient.DBClient, apiclient *ApiClient.APIClient, url string, kind string, ext string,
+	force bool, verify bool, dir ...string) (string, string, error) {
 
-	contentBasePath := os.Getenv("PODBOX_UPDATE_CONTENT_BASE_PATH")
-	if contentBasePath == "" {
-		contentBasePath = "/mnt/sdcard/assets/"
-	}
-	destinationPath := filepath.Join(append([]string{contentBasePath, "videos"}, dir...)...)
+	contentBasePath := ContentBasePath()
+	destinationPath := filepath.Join(append([]string{contentBasePath, kind}, dir...)...)
 
 	log.Printf("destination path for download file : %s \n", destinationPath)
 	err := SharedModels.CheckAndCreateDir(destinationPath)
@@ -109,156 +268,455 @@ func DownloadVideo(apiclient *ApiClient.APIClient, url string, dir ...string) (s
 		log.Printf("Error in creating path %s: %v", destinationPath, err)
 	}
 
-	fileInformation, err := apiclient.GetFileInformation(url)
+	fileInformation, err := apiclient.GetFileInformationWithRetry(ctx, url)
 
+	expectedMD5 := ""
 	if err != nil {
+		// Always the same name for the same URL, so a later sync reuses this file, but it
+		// won't dedupe with copies named after the server's hash.
+		log.Printf("WARNING: no file information for %s, naming it after the URL instead of its hash: %v", url, err)
 		fileInformation.MD5 = SharedModels.CalculateStringMD5(url)
+	} else if verify {
+		// The URL fallback is only a name, not a hash of the content, so it can't be checked.
+		expectedMD5 = fileInformation.MD5
 	}
 
-	fileNameWithPrefix := fileInformation.MD5 + ".mp4"
+	fileNameWithPrefix := fileInformation.MD5 + ext
 
 	destinationFile := filepath.Join(destinationPath, fileNameWithPrefix)
 	log.Printf("destination file: %s", destinationFile)
 
-	err = apiclient.DownloadFileWithRetry(url, destinationFile)
+	if force {
+		log.Printf("Forced re-download, removing existing file %s", destinationFile)
+		for _, path := range []string{destinationFile, destinationFile + ApiClient.PART_FILE_SUFFIX} {
+			rel, err := storePath(path)
+			if err == nil {
+				err = assets().Delete(rel)
+			}
+			if err != nil && !os.IsNotExist(err) {
+				return "", "", cstmerr.NewFileDeleteError(fmt.Sprintf("failed to delete file: %s", path), err)
+			}
+		}
+	}
+
+	err = apiclient.DownloadFileWithRetryMD5Context(ctx, url, destinationFile, expectedMD5)
 
 	if err != nil {
 		log.Printf("error in downloading hash")
+		if ctx.Err() != nil {
+			return "", "", cstmerr.NewTimeoutError(err)
+		}
 		return "", "", cstmerr.NewDownloadError(
 			fmt.Sprintf("failed to download multiple times: %s", url))
 	}
 
+	if dbConnection != nil {
+		metaCtx, cancel := dbContext(ctx)
+		err := recordAssetMeta(metaCtx, dbConnection, destinationFile, expectedMD5)
+		cancel()
+		if err != nil {
+			log.Printf("Failed to record size of %s: %v", destinationFile, err)
+		}
+	}
+
 	return destinationFile, fileNameWithPrefix, nil
 }
 
-func DownloadZippedVideo(apiclient *ApiClient.APIClient, url string, dir ...string) (string, string, error) {
-
-	contentBasePath := os.Getenv("PODBOX_UPDATE_CONTENT_BASE_PATH")
-	if contentBasePath == "" {
-		contentBasePath = "/mnt/sdcard/assets/"
+// downloadAndHashImage downloads an image into the images/<subdir> directory and
+// returns its path relative to the images directory along with its MD5 hash.
+// With force, an existing copy is discarded and downloaded again.
+func downloadAndHashImage(ctx context.Context, dbConnection dbclient.DBClient, apiclient *ApiClient.APIClient, url string, subdir string, force bool) (string, string, error) {
+	destinationFile, fileName, err := downloadContentFile(ctx, dbConnection, apiclient, url, "images", ".jpg", force, true, subdir)
+	if err != nil {
+		return "", "", cstmerr.NewProcessError(fmt.Sprintf(cstmerr.PROCESS_DOWNLOAD_ERROR, url), err)
 	}
-	destinationPath := filepath.Join(append([]string{contentBasePath, "videos"}, dir...)...)
-
-	log.Printf("destination path for download file : %s \n", destinationPath)
-	err := SharedModels.CheckAndCreateDir(destinationPath)
+	hash, err := SharedModels.CalculateMD5(destinationFile, 1025)
 	if err != nil {
-		log.Printf("Error in creating path %s: %v", destinationPath, err)
+		return "", "", cstmerr.NewProcessError(cstmerr.PROCESS_HASH_ERROR, err)
 	}
+	return filepath.Join(subdir, fileName), hex.EncodeToString(hash), nil
+}
 
-	fileInformation, err := apiclient.GetFileInformation(url)
-
+// downloadAndHashMedia downloads a video into the videos/<subdir> directory and
+// returns its path relative to the videos directory along with its MD5 hash.
+// With force, an existing copy is discarded and downloaded again.
+func downloadAndHashMedia(ctx context.Context, dbConnection dbclient.DBClient, apiclient *ApiClient.APIClient, url string, subdir string, force bool) (string, string, error) {
+	destinationFile, fileName, err := downloadContentFile(ctx, dbConnection, apiclient, url, "videos", ".mp4", force, false, subdir)
 	if err != nil {
-		fileInformation.MD5 = SharedModels.CalculateStringMD5(url)
+		return "", "", cstmerr.NewProcessError(fmt.Sprintf(cstmerr.PROCESS_DOWNLOAD_ERROR, url), err)
 	}
-
-	fileNameWithPrefix := fileInformation.MD5 + ".zip"
-
-	destinationFile := filepath.Join(destinationPath, fileNameWithPrefix)
-	log.Printf("destination file: %s", destinationFile)
-
-	err = apiclient.DownloadFileWithRetry(url, destinationFile)
-
+	hash, err := SharedModels.CalculateMD5(destinationFile, 1025)
 	if err != nil {
-		log.Printf("error in downloading hash")
-		return "", "", cstmerr.NewDownloadError(
-			fmt.Sprintf("failed to download multiple times: %s", url))
+		return "", "", cstmerr.NewProcessError(cstmerr.PROCESS_HASH_ERROR, err)
 	}
-	destinationExtracted := filepath.Join(destinationPath, fileInformation.MD5)
-	//TODO: enable this
-	// err = SharedModels.UnzipFile(destinationFile, destinationExtracted)
-	// if err != nil {
-	// 	return "", "", err
-	// }
-	return destinationExtracted, fileNameWithPrefix, nil
+	return filepath.Join(subdir, fileName), hex.EncodeToString(hash), nil
 }
 
 func FetchAndProcessContentUpdates(apiClientInstance *ApiClient.APIClient,
 	dbConnection dbclient.DBClient,
-	updater *SharedModels.Updater) error {
+	updater *SharedModels.Updater) (SyncSummary, error) {
+	return FetchAndProcessContentUpdatesContext(context.Background(), apiClientInstance, dbConnection, updater)
+}
+
+// FetchAndProcessContentUpdatesContext is FetchAndProcessContentUpdates with items
+// processed under ctx, so they share its request ID and retry budget.
+func FetchAndProcessContentUpdatesContext(ctx context.Context, apiClientInstance *ApiClient.APIClient,
+	dbConnection dbclient.DBClient,
+	updater *SharedModels.Updater) (SyncSummary, error) {
+	catchUp := settings.CatchUpMode
+	var journal catchUpJournal
+	if catchUp {
+		var err error
+		journal, err = readCatchUpJournal()
+		if err != nil {
+			return SyncSummary{NewWatermark: updater.LastFromTimeStamp}, err
+		}
+	}
+
+	summary := SyncSummary{NewWatermark: updater.LastFromTimeStamp}
 	params := SharedModels.ContentUpdateRequestParams{
 		From:   updater.LastFromTimeStamp,
 		Size:   50,
 		Offset: 0,
 	}
 
-	response, processedItems, err := apiClientInstance.FetchContentUpdates(params)
+	response, processedItems, err := apiClientInstance.FetchContentUpdatesContext(ctx, params)
 	if err != nil {
-		log.Printf("Failed to fetch content updates: %v", err)
-		return err
+		log.Printf("Failed to fetch content updates (request %s): %v", ApiClient.RequestIDFromContext(ctx), err)
+		return summary, err
 	}
 
 	if response == nil {
 		log.Printf("No response received from content updates fetch.")
-		return fmt.Errorf("nil response from FetchContentUpdates")
+		return summary, fmt.Errorf("nil response from FetchContentUpdates")
 	}
 
+	summary.Fetched = len(processedItems)
 	log.Printf("Fetched %d items, %d remaining in total on server.", len(processedItems), response.Count)
 
-	for _, item := range processedItems {
-		err := ProcessContentItem(item, dbConnection, apiClientInstance)
-		if err != nil {
-			return err
+	if catchUp {
+		if journal.From != params.From {
+			// A different batch than the journaled one, so none of its items are done yet.
+			journal.Done = map[int64]bool{}
+			if err := startCatchUpBatch(params.From); err != nil {
+				return summary, err
+			}
+		} else if len(journal.Done) > 0 {
+			log.Printf("Resuming catch-up from watermark %d with %d item(s) already processed",
+				journal.From, len(journal.Done))
+		}
+	}
+	sorted := catchUp || settings.PrioritySort
+	if sorted {
+		sortByPriority(processedItems)
+	}
+	if settings.DeletesFirst {
+		sortDeletesFirst(processedItems)
+		sorted = true
+	}
+
+	// Unless the error policy is fail-fast, failed items don't stop the batch, but the
+	// watermark stays before the first one so it is fetched again next cycle.
+	var firstErr error
+	var dbUnavailable *cstmerr.DBConnectionError
+	var savedAssets []string
+	depOrder := settings.DependencyOrder
+	quarantined, err := quarantinedContent()
+	if err != nil {
+		log.Printf("Failed to read quarantined items, processing them all: %v", err)
+	}
+	forced, err := readForceRedownloads()
+	if err != nil {
+		log.Printf("Failed to read forced re-downloads, not forcing any: %v", err)
+	}
+	failFast := settings.ErrorPolicy == config.ERROR_POLICY_FAIL_FAST
+batch:
+	for i, item := range processedItems {
+		if catchUp && journal.Done[item.ID] {
+			summary.Resumed++
+			if firstErr == nil && item.UpdatedAt > updater.LastFromTimeStamp {
+				updater.LastFromTimeStamp = item.UpdatedAt
+				summary.NewWatermark = updater.LastFromTimeStamp
+			}
+			continue
+		}
+		item.ForceRedownload = item.ForceRedownload || forced.requested(item.ID)
+		var result ProcessResult
+		var err error
+		deferred := false
+		isQuarantined := quarantined[item.ID]
+		if depOrder && !isQuarantined {
+			deferred, err = deferIfOrphan(dbConnection, item)
+		}
+		if err == nil && !deferred && !isQuarantined {
+			result, err = processItemWithTimeout(ctx, item, dbConnection, apiClientInstance)
+		}
+		switch {
+		case isQuarantined:
+			// Set aside by an earlier cycle; the watermark moves past it.
+			log.Printf("Skipping quarantined item %d", item.ID)
+			summary.Skipped++
+		case deferred:
+			// Deferred items are picked up from the pending file, so the watermark moves past them.
+			summary.Deferred++
+		case result.Action == PROCESS_ACTION_QUARANTINE && err != nil:
+			// Quarantined items don't block the batch; the watermark moves past them.
+			summary.Failed++
+		case result.Action == PROCESS_ACTION_QUARANTINE:
+			// Set aside by the unknown type policy, which is not a failure.
+			summary.Skipped++
+		case errors.As(err, &dbUnavailable):
+			// Not the item's fault; pause the cycle and pick the batch up again later.
+			log.Printf("Database unavailable while processing item %d, pausing the cycle: %v", item.ID, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			break batch
+		case err != nil:
+			summary.Failed++
+			log.Printf("Failed to process item %d (request %s): %v", item.ID, ApiClient.RequestIDFromContext(ctx), err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			if failFast {
+				log.Printf("Stopping the batch at failed item %d (error policy %s)", item.ID, config.ERROR_POLICY_FAIL_FAST)
+				break batch
+			}
+			continue
+		case result.Action == PROCESS_ACTION_SKIP:
+			summary.Skipped++
+		default:
+			summary.Processed++
+			if result.Action == PROCESS_ACTION_SAVE {
+				savedAssets = append(savedAssets, result.AssetPaths...)
+				if result.Created {
+					summary.Created++
+				} else {
+					summary.Updated++
+				}
+			}
 		}
-		//TODO: handle error in processing item
-		if item.UpdatedAt > updater.LastFromTimeStamp {
+		if firstErr == nil && item.UpdatedAt > updater.LastFromTimeStamp {
 			updater.LastFromTimeStamp = item.UpdatedAt
+			summary.NewWatermark = updater.LastFromTimeStamp
+		}
+		if catchUp {
+			if err := markCatchUpDone(item.ID); err != nil {
+				log.Printf("Failed to journal catch-up progress for item %d: %v", item.ID, err)
+			}
+			log.Printf("Catch-up progress: %d/%d items of batch from %d", i+1, len(processedItems), params.From)
 		}
 	}
 
-	//TODO: uncomment
-	// ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Connection timeout
-	// defer cancel()
-	// err = dbConnection.Save(ctx, &updater)
-	// if err != nil {
-	// 	log.Printf("Error on Updating lastFromTimestamp: %v", err)
-	// 	return err
-	// }
+	if depOrder && !errors.As(firstErr, &dbUnavailable) {
+		resolved, abandoned, err := resolvePending(ctx, dbConnection, apiClientInstance, forced)
+		summary.Resolved = resolved
+		summary.Abandoned = abandoned
+		if err != nil {
+			log.Printf("Failed to resolve pending items, will retry next cycle: %v", err)
+		}
+	}
 
-	return nil
+	expired, err := deleteExpiredAssets(ctx, dbConnection, clock.Now())
+	if len(expired) > 0 {
+		log.Printf("Deleted %d asset(s) whose grace period ran out", len(expired))
+	}
+	if err != nil {
+		log.Printf("Failed to delete expired assets, will retry next cycle: %v", err)
+	}
+
+	var batchNewest int64
+	for _, item := range processedItems {
+		if item.UpdatedAt > batchNewest {
+			batchNewest = item.UpdatedAt
+		}
+	}
+	summary.WatermarkLag = contentWatermarkLag.record(batchNewest, updater.LastFromTimeStamp)
+	if threshold := settings.WatermarkLagWarning; threshold > 0 && summary.WatermarkLag > threshold {
+		summary.Lagging = true
+		log.Printf("WARNING: content watermark is %v behind the server (threshold %v), %d item(s) remaining",
+			summary.WatermarkLag, threshold, response.Count)
+	}
+
+	if sampleSize := settings.ProbeSampleSize; sampleSize > 0 {
+		summary.Unreadable = probeAssets(savedAssets, sampleSize)
+	}
+
+	if threshold := settings.DegradedFailureRatio; threshold > 0 && summary.FailureRatio() > threshold {
+		summary.Degraded = true
+		log.Printf("Content sync degraded: %d of %d items failed (threshold %.0f%%)",
+			summary.Failed, summary.Attempted(), threshold*100)
+	}
+
+	if firstErr != nil {
+		if sorted {
+			// Items ran out of server order, so only a completed batch may move the watermark.
+			updater.LastFromTimeStamp = params.From
+			summary.NewWatermark = params.From
+		}
+		if updater.LastFromTimeStamp > params.From {
+			if err := saveWatermark(dbConnection, updater); err != nil {
+				log.Printf("Failed to save watermark %d: %v", updater.LastFromTimeStamp, err)
+			}
+		}
+		return summary, firstErr
+	}
+
+	if forced.all && response.Count == 0 {
+		// Nothing more to sync, so every item of the pass has been downloaded again.
+		log.Printf("Forced re-download of all items finished")
+		if err := SetForceRedownloadAll(false); err != nil {
+			log.Printf("Failed to clear the forced re-download of all items: %v", err)
+		}
+	}
+
+	if updater.LastFromTimeStamp > params.From {
+		// The batch is complete, so a restart fetches the next one, even after a reboot.
+		if err := saveWatermark(dbConnection, updater); err != nil {
+			log.Printf("Failed to save watermark %d, will retry next cycle: %v", updater.LastFromTimeStamp, err)
+		}
+		if catchUp {
+			if err := startCatchUpBatch(updater.LastFromTimeStamp); err != nil {
+				log.Printf("Failed to journal catch-up watermark %d: %v", updater.LastFromTimeStamp, err)
+			}
+		}
+	}
+
+	return summary, nil
 
 }
-func ProcessContentItem(content SharedModels.ProcessedContentSchema,
-	dbConnection dbclient.DBClient, apiClient *ApiClient.APIClient) error {
-	log.Printf("Processing item ID: %d, Type: %s, Enabled: %t", content.ID, content.Type, content.Enable)
+
+// processItemWithTimeout runs processContentItemSafely under the configured
+// item_processing_timeout.
+// An item that runs out of time fails with a TimeoutError and is retried next cycle.
+func processItemWithTimeout(ctx context.Context, content SharedModels.ProcessedContentSchema,
+	dbConnection dbclient.DBClient, apiClient *ApiClient.APIClient) (ProcessResult, error) {
+	itemCtx := ctx
+	if timeout := settings.ItemProcessingTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		itemCtx, cancel = context.WithTimeout(itemCtx, timeout)
+		defer cancel()
+	}
+	result, err := processContentItemSafely(itemCtx, content, dbConnection, apiClient)
+	if err != nil && errors.Is(itemCtx.Err(), context.DeadlineExceeded) {
+		log.Printf("Item %d ran out of its processing time", content.ID)
+		var timeoutErr *cstmerr.TimeoutError
+		if !errors.As(err, &timeoutErr) {
+			err = cstmerr.NewTimeoutError(err)
+		}
+	}
+	return result, err
+}
+
+// processContentItemSafely runs ProcessContentItem, converting a panic into a
+// ProcessError and quarantining the item so the rest of the batch can continue.
+func processContentItemSafely(itemCtx context.Context, content SharedModels.ProcessedContentSchema,
+	dbConnection dbclient.DBClient, apiClient *ApiClient.APIClient) (result ProcessResult, err error) {
+	if settings.RecoverPanics {
+		defer func() {
+			if r := recover(); r != nil {
+				err = cstmerr.NewProcessError(fmt.Sprintf(cstmerr.PROCESS_PANIC, content.ID), fmt.Errorf("%v", r))
+				log.Printf("%v\n%s", err, debug.Stack())
+				result = ProcessResult{EntityID: content.ID, Action: PROCESS_ACTION_QUARANTINE}
+				contentTypeStats.record(content.Type, false, clock.Now())
+				if qErr := QuarantineContent(content.ID, err.Error()); qErr != nil {
+					log.Printf("Failed to quarantine item %d: %v", content.ID, qErr)
+				}
+			}
+		}()
+	}
+	return ProcessContentItem(itemCtx, content, dbConnection, apiClient)
+}
+
+func ProcessContentItem(itemCtx context.Context, content SharedModels.ProcessedContentSchema,
+	dbConnection dbclient.DBClient, apiClient *ApiClient.APIClient) (ProcessResult, error) {
+	log.Printf("Processing item ID: %d, Type: %s, Enabled: %t, Forced: %t",
+		content.ID, content.Type, content.Enable, content.ForceRedownload)
+
+	itemCtx = ApiClient.WithContentType(itemCtx, content.Type)
+	result, err := processContentDetails(itemCtx, content, dbConnection, apiClient)
+	contentTypeStats.record(content.Type, err == nil, clock.Now())
+	if err == nil {
+		runProcessHooks(content, result)
+	}
+	if err == nil && result.Action == PROCESS_ACTION_SAVE {
+		if cancelErr := cancelDeletions(result.AssetPaths); cancelErr != nil {
+			log.Printf("Failed to cancel scheduled deletions for item %d: %v", content.ID, cancelErr)
+		}
+	}
+	if err == nil && content.ForceRedownload && result.Action == PROCESS_ACTION_SAVE {
+		if clearErr := ClearForceRedownload(content.ID); clearErr != nil {
+			log.Printf("Failed to clear force re-download flag for item %d: %v", content.ID, clearErr)
+		}
+	}
+	return result, err
+}
+
+func processContentDetails(itemCtx context.Context, content SharedModels.ProcessedContentSchema,
+	dbConnection dbclient.DBClient, apiClient *ApiClient.APIClient) (ProcessResult, error) {
+	if content.UnknownType {
+		reason := fmt.Sprintf("unknown content type '%s'", content.Type)
+		log.Printf("Quarantining item %d: %s", content.ID, reason)
+		if err := QuarantineContent(content.ID, reason); err != nil {
+			return ProcessResult{EntityID: content.ID}, err
+		}
+		return ProcessResult{EntityID: content.ID, Action: PROCESS_ACTION_QUARANTINE}, nil
+	}
+	if content.Deleted {
+		// Tombstones have no Details to dispatch on, so route them by type.
+		content.Enable = false
+		switch content.Type {
+		case "local-advertisement":
+			return ProcessLocalAdvertisement(itemCtx, content, dbConnection, apiClient)
+		case "local-movie":
+			return ProcessLocalMovie(itemCtx, content, dbConnection, apiClient)
+		default:
+			log.Printf("Cannot perform deletion for type %s", content.Type)
+		}
+		return ProcessResult{EntityID: content.ID, Action: PROCESS_ACTION_SKIP}, nil
+	}
 
 	switch v := content.Details.(type) {
 	case SharedModels.LocalAdvertisementSchema:
-		return ProcessLocalAdvertisement(content, dbConnection, apiClient)
+		return ProcessLocalAdvertisement(itemCtx, content, dbConnection, apiClient)
 	// case SharedModels.LocalPageSchema:
-	// 	return ProcessLocalPage(content, dbConnection)
+	// 	return ProcessLocalPage(itemCtx, content, dbConnection)
 	// case SharedModels.LocalTabSchema:
-	// 	return ProcessLocalTab(content, dbConnection)
+	// 	return ProcessLocalTab(itemCtx, content, dbConnection)
 	// case SharedModels.LocalSliderSchema:
-	// 	return ProcessLocalSlider(content, dbConnection, apiClient)
+	// 	return ProcessLocalSlider(itemCtx, content, dbConnection, apiClient)
 	// case SharedModels.LocalMovieGenreSchema:
-	// 	return ProcessLocalMovieGenre(content, dbConnection, apiClient)
+	// 	return ProcessLocalMovieGenre(itemCtx, content, dbConnection, apiClient)
 	// case SharedModels.LocalSectionSchema:
-	// 	return ProcessLocalSection(content, dbConnection)
+	// 	return ProcessLocalSection(itemCtx, content, dbConnection)
 	// case SharedModels.LocalPollSchema:
-	// 	return ProcessLocalPoll(content, dbConnection)
+	// 	return ProcessLocalPoll(itemCtx, content, dbConnection)
 	case SharedModels.LocalMovieSchema:
-		return ProcessLocalMovie(content, dbConnection, apiClient)
+		return ProcessLocalMovie(itemCtx, content, dbConnection, apiClient)
 	default:
 		log.Printf("Cannot perform specific action for type %T", v)
 	}
 
-	return nil
+	return ProcessResult{EntityID: content.ID, Action: PROCESS_ACTION_SKIP}, nil
 }
 
-func ProcessLocalMovie(content SharedModels.ProcessedContentSchema,
-	dbConnection dbclient.DBClient, apiClient *ApiClient.APIClient) error {
+func ProcessLocalMovie(itemCtx context.Context, content SharedModels.ProcessedContentSchema,
+	dbConnection dbclient.DBClient, apiClient *ApiClient.APIClient) (ProcessResult, error) {
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Connection timeout
-	defer cancel()
+	result := ProcessResult{EntityID: content.ID}
 
 	localMovie := SharedModels.Movie{}
-	detail := content.Details.(SharedModels.LocalMovieSchema)
 	localMovie.ContentId = content.ID
 	if content.Enable {
+		detail, ok := content.Details.(SharedModels.LocalMovieSchema)
+		if !ok {
+			return result, cstmerr.NewProcessError(
+				fmt.Sprintf(cstmerr.PROCESS_DETAILS_TYPE, content.Details, "LocalMovieSchema"), nil)
+		}
 
-		movieDetail, err := apiClient.GetMovieDetail(int(detail.MovieID))
+		movieDetail, err := apiClient.GetMovieDetailContext(itemCtx, int(detail.MovieID))
 		if err != nil {
-			return cstmerr.NewProcessError(cstmerr.PROCESS_DOWNLOAD_ERROR, err)
+			return result, cstmerr.NewProcessError(cstmerr.PROCESS_DOWNLOAD_ERROR, err)
 		}
 
 		localMovie.Ages = &movieDetail.Ages
@@ -271,15 +729,33 @@ func ProcessLocalMovie(content SharedModels.ProcessedContentSchema,
 		localMovie.Genres = movieDetail.Genres
 		localMovie.ImdbCode = &movieDetail.IMDBCode
 		localMovie.ImdbRate = movieDetail.IMDBRate
-		//TODO: download the video if the extracted content does not exist on fs
-		extractedPath, podspaceHash, err := DownloadZippedVideo(apiClient, detail.FileLink, "")
-		if err != nil {
-			return err
+		var extractedPath string
+		found := false
+		if !content.ForceRedownload {
+			findCtx, cancel := dbContext(itemCtx)
+			extractedPath, found, err = findExtractedMovie(findCtx, dbConnection, content.ID)
+			cancel()
+			if err != nil {
+				return result, cstmerr.NewProcessError(cstmerr.PROCESS_FIND_ENTITY, err)
+			}
 		}
+		if found {
+			log.Printf("Movie %d already extracted at %s, skipping download", content.ID, extractedPath)
+		} else {
+			if isHLSPlaylistURL(detail.FileLink) {
+				extractedPath, err = downloadHLSMovie(itemCtx, apiClient, detail.FileLink, content.ForceRedownload)
+			} else {
+				extractedPath, _, err = downloadZippedVideo(itemCtx, dbConnection, apiClient, detail.FileLink, content.ForceRedownload, "")
+			}
+			if err != nil {
+				return result, err
+			}
+		}
+		result.AssetPaths = append(result.AssetPaths, extractedPath)
 
 		entries, err := os.ReadDir(extractedPath)
 		if err != nil {
-			return cstmerr.NewProcessError(fmt.Sprintf(cstmerr.PROCESS_FIND_DIRECTORY, extractedPath), err)
+			return result, cstmerr.NewProcessError(fmt.Sprintf(cstmerr.PROCESS_FIND_DIRECTORY, extractedPath), err)
 		}
 
 		var destinationFile string
@@ -290,19 +766,31 @@ func ProcessLocalMovie(content SharedModels.ProcessedContentSchema,
 			}
 		}
 
-		if len(destinationSub) == 0 {
-			return cstmerr.NewProcessError(cstmerr.PROCESS_CREATE_ERROR, nil)
+		masterName, found := discoverMasterPlaylist(filepath.Join(extractedPath, destinationSub))
+		if !found {
+			if len(destinationSub) == 0 {
+				return result, cstmerr.NewProcessError(cstmerr.PROCESS_CREATE_ERROR, nil)
+			}
+			masterName = masterPlaylistName(destinationSub)
 		}
 
-		masterFile := fmt.Sprintf("%s/master_%s.m3u8", destinationSub, destinationSub)
+		masterFile := filepath.Join(destinationSub, masterName)
 		destinationFile = filepath.Join(extractedPath, masterFile)
 
+		if settings.ValidateHLS {
+			// A partial archive can extract cleanly yet miss variants or segments; don't store it.
+			if err := validateHLSPackage(destinationFile); err != nil {
+				return result, err
+			}
+		}
+
 		hash, err := SharedModels.CalculateMD5(destinationFile, 1025)
 		if err != nil {
-			return cstmerr.NewProcessError(cstmerr.PROCESS_HASH_ERROR, err)
+			return result, cstmerr.NewProcessError(cstmerr.PROCESS_HASH_ERROR, err)
 		}
 		localMovie.Link.FileHash = hex.EncodeToString(hash)
-		localMovie.Link.PlayLink = filepath.Join(podspaceHash[0:len(podspaceHash)-4], masterFile)
+		result.Hash = localMovie.Link.FileHash
+		localMovie.Link.PlayLink = filepath.Join(filepath.Base(extractedPath), masterFile)
 		log.Printf("debug: playlink %s", localMovie.Link.PlayLink)
 
 		localMovie.NameEn = &movieDetail.NameEn
@@ -311,72 +799,221 @@ func ProcessLocalMovie(content SharedModels.ProcessedContentSchema,
 		localMovie.PostId = movieDetail.PostID
 		localMovie.YearsOfBroadcast = &movieDetail.YearsOFBroadcast
 
-		_, bannerUrlPodspaceHash, err := DownloadImage(apiClient, movieDetail.BannerURL, "")
+		bannerUrlPodspaceHash, _, err := downloadAndHashImage(itemCtx, dbConnection, apiClient, movieDetail.BannerURL, "", content.ForceRedownload)
 		if err != nil {
-			return cstmerr.NewProcessError(
-				fmt.Sprintf(cstmerr.PROCESS_DOWNLOAD_ERROR, movieDetail.BannerURL), err)
+			return result, err
 		}
+		result.AssetPaths = append(result.AssetPaths, filepath.Join(ContentBasePath(), "images", bannerUrlPodspaceHash))
 		localMovie.Image.BannerUrl = &bannerUrlPodspaceHash
 
-		_, imageUrlPodspaceHash, err := DownloadImage(apiClient, movieDetail.ImageURL, "")
+		imageUrlPodspaceHash, _, err := downloadAndHashImage(itemCtx, dbConnection, apiClient, movieDetail.ImageURL, "", content.ForceRedownload)
 		if err != nil {
-			return cstmerr.NewProcessError(
-				fmt.Sprintf(cstmerr.PROCESS_DOWNLOAD_ERROR, movieDetail.ImageURL), err)
+			return result, err
 		}
+		result.AssetPaths = append(result.AssetPaths, filepath.Join(ContentBasePath(), "images", imageUrlPodspaceHash))
 		localMovie.Image.ImageURL = imageUrlPodspaceHash
 
-		_, mobileBannerUrlPodspaceHash, err := DownloadImage(apiClient, movieDetail.MobileBannerURL, "")
+		mobileBannerUrlPodspaceHash, _, err := downloadAndHashImage(itemCtx, dbConnection, apiClient, movieDetail.MobileBannerURL, "", content.ForceRedownload)
 		if err != nil {
-			return cstmerr.NewProcessError(
-				fmt.Sprintf(cstmerr.PROCESS_DOWNLOAD_ERROR, movieDetail.MobileBannerURL), err)
+			return result, err
 		}
+		result.AssetPaths = append(result.AssetPaths, filepath.Join(ContentBasePath(), "images", mobileBannerUrlPodspaceHash))
 		localMovie.Image.MobileBannerUrl = &mobileBannerUrlPodspaceHash
 
-		err = dbConnection.Save(ctx, &localMovie)
+		ctx, cancel := dbContext(itemCtx)
+		defer cancel()
+		result.Created, err = dbConnection.SaveReturning(ctx, &localMovie)
 		if err != nil {
-			return cstmerr.NewProcessError("failed to create slider", err)
+			return result, cstmerr.NewProcessError("failed to create movie", err)
 		}
+		result.Action = PROCESS_ACTION_SAVE
 
 	} else {
+		ctx, cancel := dbContext(itemCtx)
+		defer cancel()
+		found, err := findContentRow(ctx, dbConnection, &localMovie, content.ID)
+		if err != nil {
+			return result, cstmerr.NewProcessError(cstmerr.PROCESS_FIND_ENTITY, err)
+		}
+		if !found {
+			result.Action = PROCESS_ACTION_SKIP
+			return result, nil
+		}
 
+		removed, err := deleteContentAssets(ctx, dbConnection, &localMovie, content.ID)
+		result.AssetPaths = append(result.AssetPaths, removed...)
+		if err != nil {
+			return result, cstmerr.NewProcessError(cstmerr.PROCESS_DELETE_FILE, err)
+		}
+
+		action, err := deleteContentRow(ctx, dbConnection, &localMovie, content.ID)
+		if err != nil {
+			return result, cstmerr.NewProcessError(cstmerr.PROCESS_DELETE_ENTITY, err)
+		}
+		result.Action = action
 	}
-	return nil
+	return result, nil
+}
+
+// saveWithAssociation saves model and, if count is not 0, associates it with
+// associates, in one transaction so a failed association doesn't leave the model
+// saved without it. A transaction interrupted by a lost connection is retried by the
+// reconnecting client. created tells whether the model was inserted rather than
+// updated, and associationFailed which step err comes from.
+func saveWithAssociation(ctx context.Context, dbConnection dbclient.DBClient, model interface{},
+	association string, associates interface{}, count int) (created bool, associationFailed bool, err error) {
+	if count == 0 {
+		created, err = dbConnection.SaveReturning(ctx, model)
+		return created, false, err
+	}
+	err = dbConnection.RunInTransaction(ctx, func(ctx context.Context, txClient dbclient.DBClient) error {
+		associationFailed = false
+		var err error
+		if created, err = txClient.SaveReturning(ctx, model); err != nil {
+			return err
+		}
+		associationFailed = true
+		return txClient.CreateAssosiate(ctx, model, association, associates)
+	})
+	return created, err != nil && associationFailed, err
+}
+
+// findContentRow loads the stored row of model for a disabled content item. A row that
+// was never synced is nothing to delete, so found is false and the item is skipped.
+func findContentRow(ctx context.Context, dbConnection dbclient.DBClient,
+	model interface{}, contentId int64) (found bool, err error) {
+	err = dbConnection.First(ctx, model)
+	var notFound *cstmerr.DBNotFoundError
+	if errors.As(err, &notFound) {
+		log.Printf("No stored row for item %d, nothing to delete", contentId)
+		return false, nil
+	}
+	return err == nil, err
 }
 
-func ProcessLocalPoll(content SharedModels.ProcessedContentSchema,
-	dbConnection dbclient.DBClient) error {
+// deleteContentRow deletes the row of model for the content item. A row that was
+// never synced is nothing to delete, so the item is skipped rather than failed.
+func deleteContentRow(ctx context.Context, dbConnection dbclient.DBClient,
+	model interface{}, contentId int64) (string, error) {
+	err := dbConnection.DeleteByContentId(ctx, model, contentId)
+	var notFound *cstmerr.DBNotFoundError
+	if errors.As(err, &notFound) {
+		log.Printf("No stored row for item %d, nothing to delete", contentId)
+		return PROCESS_ACTION_SKIP, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return PROCESS_ACTION_DELETE, nil
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Connection timeout
+// discoverMasterPlaylist scans hlsDir for an .m3u8 file that references variant
+// playlists (#EXT-X-STREAM-INF) and returns its name.
+func discoverMasterPlaylist(hlsDir string) (string, bool) {
+	entries, err := os.ReadDir(hlsDir)
+	if err != nil {
+		log.Printf("Error reading HLS directory %s: %v", hlsDir, err)
+		return "", false
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".m3u8") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(hlsDir, entry.Name()))
+		if err != nil {
+			log.Printf("Error reading playlist %s: %v", entry.Name(), err)
+			continue
+		}
+		if bytes.Contains(data, []byte("#EXT-X-STREAM-INF")) {
+			return entry.Name(), true
+		}
+	}
+	return "", false
+}
+
+// masterPlaylistName builds the master playlist name for an HLS directory from the
+// configured master_playlist_pattern, where {dir} is the directory name.
+func masterPlaylistName(dir string) string {
+	return strings.ReplaceAll(settings.MasterPlaylistPattern, "{dir}", dir)
+}
+
+// movieExtractedDir returns the extracted directory (relative to the videos
+// directory) of a movie play link of the form "<dir>/<sub>/master_<sub>.m3u8".
+func movieExtractedDir(playLink string) string {
+	return strings.SplitN(filepath.ToSlash(playLink), "/", 2)[0]
+}
+
+// findExtractedMovie looks up a previously synced movie and returns its extracted
+// directory if the directory is still in the asset store.
+func findExtractedMovie(ctx context.Context, dbConnection dbclient.DBClient,
+	contentId int64) (string, bool, error) {
+	exists, err := dbConnection.Exists(ctx, &SharedModels.Movie{ContentId: contentId})
+	if err != nil || !exists {
+		return "", false, err
+	}
+	existing := SharedModels.Movie{ContentId: contentId}
+	err = dbConnection.First(ctx, &existing)
+	if err != nil {
+		return "", false, err
+	}
+	extractedDir := movieExtractedDir(existing.Link.PlayLink)
+	if extractedDir == "" {
+		return "", false, nil
+	}
+
+	if present, err := assets().Exists(path.Join("videos", extractedDir)); err != nil || !present {
+		return "", false, nil
+	}
+	return filepath.Join(ContentBasePath(), "videos", extractedDir), true, nil
+}
+
+func ProcessLocalPoll(itemCtx context.Context, content SharedModels.ProcessedContentSchema,
+	dbConnection dbclient.DBClient) (ProcessResult, error) {
+
+	ctx, cancel := dbContext(itemCtx)
 	defer cancel()
+	result := ProcessResult{EntityID: content.ID}
 	localPoll := SharedModels.Poll{}
-	detail := content.Details.(SharedModels.LocalPollSchema)
+	detail, ok := content.Details.(SharedModels.LocalPollSchema)
+	if !ok {
+		return result, cstmerr.NewProcessError(
+			fmt.Sprintf(cstmerr.PROCESS_DETAILS_TYPE, content.Details, "LocalPollSchema"), nil)
+	}
 	localPoll.ContentId = content.ID
 	if content.Enable {
 		localPoll.Questions = detail.Questions
 		//TODO: add title
 
-		err := dbConnection.Save(ctx, &localPoll)
+		var err error
+		result.Created, err = dbConnection.SaveReturning(ctx, &localPoll)
 		if err != nil {
-			return cstmerr.NewProcessError(cstmerr.PROCESS_CREATE_ERROR, err)
+			return result, cstmerr.NewProcessError(cstmerr.PROCESS_CREATE_ERROR, err)
 		}
+		result.Action = PROCESS_ACTION_SAVE
 	} else {
-		err := dbConnection.Delete(ctx, &localPoll)
+		action, err := deleteContentRow(ctx, dbConnection, &localPoll, content.ID)
 		if err != nil {
-			return cstmerr.NewProcessError(cstmerr.PROCESS_DELETE_ENTITY, err)
+			return result, cstmerr.NewProcessError(cstmerr.PROCESS_DELETE_ENTITY, err)
 		}
+		result.Action = action
 	}
 
-	return nil
+	return result, nil
 }
 
-func ProcessLocalSection(content SharedModels.ProcessedContentSchema,
-	dbConnection dbclient.DBClient) error {
+func ProcessLocalSection(itemCtx context.Context, content SharedModels.ProcessedContentSchema,
+	dbConnection dbclient.DBClient) (ProcessResult, error) {
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Connection timeout
+	ctx, cancel := dbContext(itemCtx)
 	defer cancel()
+	result := ProcessResult{EntityID: content.ID}
 
 	localSection := SharedModels.Section{}
-	detail := content.Details.(SharedModels.LocalSectionSchema)
+	detail, ok := content.Details.(SharedModels.LocalSectionSchema)
+	if !ok {
+		return result, cstmerr.NewProcessError(
+			fmt.Sprintf(cstmerr.PROCESS_DETAILS_TYPE, content.Details, "LocalSectionSchema"), nil)
+	}
 	localSection.ContentId = content.ID
 
 	if content.Enable {
@@ -387,40 +1024,44 @@ func ProcessLocalSection(content SharedModels.ProcessedContentSchema,
 		localSection.Priority = &priority
 		//TODO: what is entity type
 
-		err := dbConnection.Save(ctx, &localSection)
-		if err != nil {
-			return cstmerr.NewProcessError("failed to create slider", err)
+		var tabs []*SharedModels.Tab
+		for _, value := range detail.LocalTabIDs {
+			tab := SharedModels.Tab{}
+			tab.ContentId = int64(value)
+			tabs = append(tabs, &tab)
 		}
-
-		if len(detail.LocalTabIDs) > 0 {
-			tabs := make([]*SharedModels.Tab, len(detail.LocalTabIDs))
-			for index, value := range detail.LocalTabIDs {
-				tab := SharedModels.Tab{}
-				tab.ContentId = int64(value)
-				tabs[index] = &tab
-			}
-
-			err = dbConnection.CreateAssosiate(ctx, &localSection, "Tabs", &tabs)
-			if err != nil {
-				return cstmerr.NewProcessError("failed to create assosiate tab page", err)
-			}
+		created, associationFailed, err := saveWithAssociation(ctx, dbConnection, &localSection, "Tabs", &tabs, len(tabs))
+		if associationFailed {
+			return result, cstmerr.NewProcessError("failed to create assosiate section tab", err)
+		}
+		if err != nil {
+			return result, cstmerr.NewProcessError("failed to create section", err)
 		}
+		result.Created = created
+		result.Action = PROCESS_ACTION_SAVE
 	} else {
-
+		action, err := deleteContentRow(ctx, dbConnection, &localSection, content.ID)
+		if err != nil {
+			return result, cstmerr.NewProcessError(cstmerr.PROCESS_DELETE_ENTITY, err)
+		}
+		result.Action = action
 	}
 
-	return nil
+	return result, nil
 }
 
-func ProcessLocalMovieGenre(content SharedModels.ProcessedContentSchema,
-	dbConnection dbclient.DBClient, apiclient *ApiClient.APIClient) error {
+func ProcessLocalMovieGenre(itemCtx context.Context, content SharedModels.ProcessedContentSchema,
+	dbConnection dbclient.DBClient, apiclient *ApiClient.APIClient) (ProcessResult, error) {
 
 	const GENRE = "genre"
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	result := ProcessResult{EntityID: content.ID}
 
 	localMovieGenre := SharedModels.Genre{}
-	detail := content.Details.(SharedModels.LocalMovieGenreSchema)
+	detail, ok := content.Details.(SharedModels.LocalMovieGenreSchema)
+	if !ok {
+		return result, cstmerr.NewProcessError(
+			fmt.Sprintf(cstmerr.PROCESS_DETAILS_TYPE, content.Details, "LocalMovieGenreSchema"), nil)
+	}
 	localMovieGenre.ContentId = content.ID
 	if content.Enable {
 
@@ -428,114 +1069,158 @@ func ProcessLocalMovieGenre(content SharedModels.ProcessedContentSchema,
 		localMovieGenre.Enable = content.Enable
 		//TODO: get name
 
-		_, imageUrlPodspaceHash, err := DownloadImage(apiclient, detail.ImageURL, GENRE)
+		imageRelPath, _, err := downloadAndHashImage(itemCtx, dbConnection, apiclient, detail.ImageURL, GENRE, content.ForceRedownload)
 		if err != nil {
-			return cstmerr.NewProcessError(
-				fmt.Sprintf(cstmerr.PROCESS_DOWNLOAD_ERROR, detail.ImageURL), err)
+			return result, err
 		}
-		trick := filepath.Join(GENRE, imageUrlPodspaceHash)
-		localMovieGenre.ImageURL = &trick
+		result.AssetPaths = append(result.AssetPaths, filepath.Join(ContentBasePath(), "images", imageRelPath))
+		localMovieGenre.ImageURL = &imageRelPath
 
-		err = dbConnection.Save(ctx, &localMovieGenre)
+		ctx, cancel := dbContext(itemCtx)
+		defer cancel()
+		result.Created, err = dbConnection.SaveReturning(ctx, &localMovieGenre)
 		if err != nil {
-			return cstmerr.NewProcessError("failed to create slider", err)
+			return result, cstmerr.NewProcessError("failed to create genre", err)
 		}
+		result.Action = PROCESS_ACTION_SAVE
 	} else {
-		//TODO: handle image deletion from filespace
-		err := dbConnection.Delete(ctx, &localMovieGenre)
+		ctx, cancel := dbContext(itemCtx)
+		defer cancel()
+		found, err := findContentRow(ctx, dbConnection, &localMovieGenre, content.ID)
+		if err != nil {
+			return result, cstmerr.NewProcessError(cstmerr.PROCESS_FIND_ENTITY, err)
+		}
+		if !found {
+			result.Action = PROCESS_ACTION_SKIP
+			return result, nil
+		}
+
+		removed, err := deleteContentAssets(ctx, dbConnection, &localMovieGenre, content.ID)
+		result.AssetPaths = append(result.AssetPaths, removed...)
 		if err != nil {
-			return cstmerr.NewProcessError(cstmerr.PROCESS_DELETE_ENTITY, err)
+			return result, cstmerr.NewProcessError(cstmerr.PROCESS_DELETE_FILE, err)
 		}
+
+		action, err := deleteContentRow(ctx, dbConnection, &localMovieGenre, content.ID)
+		if err != nil {
+			return result, cstmerr.NewProcessError(cstmerr.PROCESS_DELETE_ENTITY, err)
+		}
+		result.Action = action
 	}
 
-	return nil
+	return result, nil
 }
 
-func ProcessLocalSlider(content SharedModels.ProcessedContentSchema,
-	dbConnection dbclient.DBClient, apiclient *ApiClient.APIClient) error {
+func ProcessLocalSlider(itemCtx context.Context, content SharedModels.ProcessedContentSchema,
+	dbConnection dbclient.DBClient, apiclient *ApiClient.APIClient) (ProcessResult, error) {
 	const SLIDER = "slider"
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Connection timeout
-	defer cancel()
+	result := ProcessResult{EntityID: content.ID}
 
 	localSlider := SharedModels.Slider{}
-	detail := content.Details.(SharedModels.LocalSliderSchema)
+	detail, ok := content.Details.(SharedModels.LocalSliderSchema)
+	if !ok {
+		return result, cstmerr.NewProcessError(
+			fmt.Sprintf(cstmerr.PROCESS_DETAILS_TYPE, content.Details, "LocalSliderSchema"), nil)
+	}
 	localSlider.ContentId = content.ID
 
 	if content.Enable {
 
 		localSlider.ButtonTitle = detail.ButtonTitle
 
-		_, imageUrlPodspaceHash, err := DownloadImage(apiclient, detail.ImageURL, SLIDER)
+		imagesPath := filepath.Join(ContentBasePath(), "images")
+
+		imageRelPath, _, err := downloadAndHashImage(itemCtx, dbConnection, apiclient, detail.ImageURL, SLIDER, content.ForceRedownload)
 		if err != nil {
-			return cstmerr.NewProcessError(
-				fmt.Sprintf(cstmerr.PROCESS_DOWNLOAD_ERROR, detail.ImageURL), err)
+			return result, err
 		}
-		localSlider.Image.ImageURL = filepath.Join(SLIDER, imageUrlPodspaceHash)
+		result.AssetPaths = append(result.AssetPaths, filepath.Join(imagesPath, imageRelPath))
+		localSlider.Image.ImageURL = imageRelPath
 
 		if detail.LogoImageURL != nil {
-			_, logoImageUrlPodspaceHash, err := DownloadImage(apiclient, *detail.LogoImageURL, SLIDER)
+			logoImageRelPath, _, err := downloadAndHashImage(itemCtx, dbConnection, apiclient, *detail.LogoImageURL, SLIDER, content.ForceRedownload)
 			if err != nil {
-				return cstmerr.NewProcessError(
-					fmt.Sprintf(cstmerr.PROCESS_DOWNLOAD_ERROR, detail.ImageURL), err)
+				return result, err
 			}
-			trick := filepath.Join(SLIDER, logoImageUrlPodspaceHash)
-			localSlider.Image.LogoImageUrl = &trick
+			result.AssetPaths = append(result.AssetPaths, filepath.Join(imagesPath, logoImageRelPath))
+			localSlider.Image.LogoImageUrl = &logoImageRelPath
 		}
 
-		_, mediumImageUrlPodspaceHash, err := DownloadImage(apiclient, detail.MediumImageURL, SLIDER)
+		mediumImageRelPath, _, err := downloadAndHashImage(itemCtx, dbConnection, apiclient, detail.MediumImageURL, SLIDER, content.ForceRedownload)
 		if err != nil {
-			return cstmerr.NewProcessError(
-				fmt.Sprintf(cstmerr.PROCESS_DOWNLOAD_ERROR, detail.ImageURL), err)
+			return result, err
 		}
-		trick := filepath.Join(SLIDER, mediumImageUrlPodspaceHash)
-		localSlider.Image.MediumImageUrl = &trick
+		result.AssetPaths = append(result.AssetPaths, filepath.Join(imagesPath, mediumImageRelPath))
+		localSlider.Image.MediumImageUrl = &mediumImageRelPath
 
-		_, smallImageUrlPodspaceHash, err := DownloadImage(apiclient, detail.SmallImageURL, SLIDER)
+		smallImageRelPath, _, err := downloadAndHashImage(itemCtx, dbConnection, apiclient, detail.SmallImageURL, SLIDER, content.ForceRedownload)
 		if err != nil {
-			return cstmerr.NewProcessError(
-				fmt.Sprintf(cstmerr.PROCESS_DOWNLOAD_ERROR, detail.ImageURL), err)
+			return result, err
 		}
-		trick2 := filepath.Join(SLIDER, smallImageUrlPodspaceHash)
-		localSlider.Image.SmallImageUrl = &trick2
+		result.AssetPaths = append(result.AssetPaths, filepath.Join(imagesPath, smallImageRelPath))
+		localSlider.Image.SmallImageUrl = &smallImageRelPath
 
 		localSlider.Link = detail.Link
 
-		err = dbConnection.Save(ctx, &localSlider)
+		var tabs []*SharedModels.Tab
+		for _, value := range detail.LocalTabIDs {
+			tab := SharedModels.Tab{}
+			tab.ContentId = int64(value)
+			tabs = append(tabs, &tab)
+		}
+		ctx, cancel := dbContext(itemCtx)
+		defer cancel()
+		created, associationFailed, err := saveWithAssociation(ctx, dbConnection, &localSlider, "Tabs", &tabs, len(tabs))
+		if associationFailed {
+			return result, cstmerr.NewProcessError("failed to create assosiate slider tab", err)
+		}
 		if err != nil {
-			return cstmerr.NewProcessError("failed to create slider", err)
+			return result, cstmerr.NewProcessError("failed to create slider", err)
+		}
+		result.Created = created
+		result.Action = PROCESS_ACTION_SAVE
+	} else {
+		ctx, cancel := dbContext(itemCtx)
+		defer cancel()
+		found, err := findContentRow(ctx, dbConnection, &localSlider, content.ID)
+		if err != nil {
+			return result, cstmerr.NewProcessError(cstmerr.PROCESS_FIND_ENTITY, err)
+		}
+		if !found {
+			result.Action = PROCESS_ACTION_SKIP
+			return result, nil
 		}
-		if len(detail.LocalTabIDs) > 0 {
-			tabs := make([]*SharedModels.Tab, len(detail.LocalTabIDs))
-			for index, value := range detail.LocalTabIDs {
-				tab := SharedModels.Tab{}
-				tab.ContentId = int64(value)
-				tabs[index] = &tab
-			}
 
-			err = dbConnection.CreateAssosiate(ctx, &localSlider, "Tabs", &tabs)
-			if err != nil {
-				return cstmerr.NewProcessError("failed to create assosiate tab page", err)
-			}
+		removed, err := deleteContentAssets(ctx, dbConnection, &localSlider, content.ID)
+		result.AssetPaths = append(result.AssetPaths, removed...)
+		if err != nil {
+			return result, cstmerr.NewProcessError(cstmerr.PROCESS_DELETE_FILE, err)
 		}
-	} else {
+
 		//TODO: handle assosiation
-		err := dbConnection.Delete(ctx, &localSlider)
+		action, err := deleteContentRow(ctx, dbConnection, &localSlider, content.ID)
 		if err != nil {
-			return cstmerr.NewProcessError(cstmerr.PROCESS_DELETE_ENTITY, err)
+			return result, cstmerr.NewProcessError(cstmerr.PROCESS_DELETE_ENTITY, err)
 		}
+		result.Action = action
 	}
 
-	return nil
+	return result, nil
 }
 
-func ProcessLocalTab(content SharedModels.ProcessedContentSchema,
-	dbConnection dbclient.DBClient) error {
+func ProcessLocalTab(itemCtx context.Context, content SharedModels.ProcessedContentSchema,
+	dbConnection dbclient.DBClient) (ProcessResult, error) {
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Connection timeout
+	ctx, cancel := dbContext(itemCtx)
 	defer cancel()
+	result := ProcessResult{EntityID: content.ID}
 
 	localTab := SharedModels.Tab{}
-	detail := content.Details.(SharedModels.LocalTabSchema)
+	detail, ok := content.Details.(SharedModels.LocalTabSchema)
+	if !ok {
+		return result, cstmerr.NewProcessError(
+			fmt.Sprintf(cstmerr.PROCESS_DETAILS_TYPE, content.Details, "LocalTabSchema"), nil)
+	}
 	localTab.ContentId = content.ID
 
 	if content.Enable {
@@ -543,102 +1228,122 @@ func ProcessLocalTab(content SharedModels.ProcessedContentSchema,
 		localTab.Name = detail.Name
 		localTab.Type = detail.Type
 
-		err := dbConnection.Save(ctx, &localTab)
-		if err != nil {
-			return cstmerr.NewProcessError("failed to create tab", err)
+		var pages []*SharedModels.Page
+		for _, value := range detail.LocalPageIDs {
+			page := SharedModels.Page{}
+			page.ContentId = int64(value)
+			pages = append(pages, &page)
 		}
-		if len(detail.LocalPageIDs) > 0 {
-			pages := make([]*SharedModels.Page, len(detail.LocalPageIDs))
-
-			for index, value := range detail.LocalPageIDs {
-				page := SharedModels.Page{}
-				page.ContentId = int64(value)
-				pages[index] = &page
-			}
-			err := dbConnection.CreateAssosiate(ctx, &localTab, "Pages", &pages)
-			if err != nil {
-				return cstmerr.NewProcessError("failed to create assosiate tab page", err)
-			}
+		created, associationFailed, err := saveWithAssociation(ctx, dbConnection, &localTab, "Pages", &pages, len(pages))
+		if associationFailed {
+			return result, cstmerr.NewProcessError("failed to create assosiate tab page", err)
+		}
+		if err != nil {
+			return result, cstmerr.NewProcessError("failed to create tab", err)
 		}
+		result.Created = created
+		result.Action = PROCESS_ACTION_SAVE
 	} else {
 		//TODO: handle assosiation
-		err := dbConnection.Delete(ctx, &localTab)
+		action, err := deleteContentRow(ctx, dbConnection, &localTab, content.ID)
 		if err != nil {
-			return cstmerr.NewProcessError(cstmerr.PROCESS_DELETE_ENTITY, err)
+			return result, cstmerr.NewProcessError(cstmerr.PROCESS_DELETE_ENTITY, err)
 		}
+		result.Action = action
 	}
 
-	return nil
+	return result, nil
 }
-func ProcessLocalPage(content SharedModels.ProcessedContentSchema,
-	dbConnection dbclient.DBClient) error {
+func ProcessLocalPage(itemCtx context.Context, content SharedModels.ProcessedContentSchema,
+	dbConnection dbclient.DBClient) (ProcessResult, error) {
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Connection timeout
+	ctx, cancel := dbContext(itemCtx)
 	defer cancel()
+	result := ProcessResult{EntityID: content.ID}
 	localPage := SharedModels.Page{}
-	detail := content.Details.(SharedModels.LocalPageSchema)
+	detail, ok := content.Details.(SharedModels.LocalPageSchema)
+	if !ok {
+		return result, cstmerr.NewProcessError(
+			fmt.Sprintf(cstmerr.PROCESS_DETAILS_TYPE, content.Details, "LocalPageSchema"), nil)
+	}
 	localPage.ContentId = content.ID
 	if content.Enable {
 		localPage.Name = &detail.Name
 		localPage.Type = detail.Type
-		err := dbConnection.Save(ctx, &localPage)
+		var err error
+		result.Created, err = dbConnection.SaveReturning(ctx, &localPage)
 		if err != nil {
-			return cstmerr.NewProcessError("failed to save Local Page", err)
+			return result, cstmerr.NewProcessError("failed to save Local Page", err)
 		}
+		result.Action = PROCESS_ACTION_SAVE
 	} else {
-		err := dbConnection.Delete(ctx, &localPage)
+		action, err := deleteContentRow(ctx, dbConnection, &localPage, content.ID)
 		if err != nil {
-			return cstmerr.NewProcessError(cstmerr.PROCESS_DELETE_ENTITY, err)
+			return result, cstmerr.NewProcessError(cstmerr.PROCESS_DELETE_ENTITY, err)
 		}
+		result.Action = action
 	}
-	return nil
+	return result, nil
 }
 
-func ProcessLocalAdvertisement(
+func ProcessLocalAdvertisement(itemCtx context.Context,
 	content SharedModels.ProcessedContentSchema,
-	dbConnection dbclient.DBClient, apiclient *ApiClient.APIClient) error {
+	dbConnection dbclient.DBClient, apiclient *ApiClient.APIClient) (ProcessResult, error) {
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Connection timeout
-	defer cancel()
+	result := ProcessResult{EntityID: content.ID}
 	localAdvertisement := SharedModels.Advertisement{}
 	localAdvertisementLink := SharedModels.AdvertisementLink{}
 	localAdvertisement.ContentId = content.ID
 	if content.Enable {
-		detail := content.Details.(SharedModels.LocalAdvertisementSchema)
+		detail, ok := content.Details.(SharedModels.LocalAdvertisementSchema)
+		if !ok {
+			return result, cstmerr.NewProcessError(
+				fmt.Sprintf(cstmerr.PROCESS_DETAILS_TYPE, content.Details, "LocalAdvertisementSchema"), nil)
+		}
 		// Download filelink to destination
-		destinationFile, podspaceHash, err := DownloadVideo(apiclient, detail.FileLink, "ads")
+		playLink, hash, err := downloadAndHashMedia(itemCtx, dbConnection, apiclient, detail.FileLink, "ads", content.ForceRedownload)
 		if err != nil {
-			return err
+			return result, err
 		}
+		result.AssetPaths = append(result.AssetPaths, filepath.Join(ContentBasePath(), "videos", playLink))
 		localAdvertisement.SkipDuration = int32(detail.SkipDuration)
 		localAdvertisement.Synced = false
 		localAdvertisementLink.LinkType = "MP4"
-		hash, err := SharedModels.CalculateMD5(destinationFile, 1025)
-		if err != nil {
-			return cstmerr.NewProcessError(cstmerr.PROCESS_HASH_ERROR, err)
-		}
-		localAdvertisementLink.FileHash = hex.EncodeToString(hash)
-		localAdvertisementLink.PlayLink = filepath.Join("ads", podspaceHash)
+		localAdvertisementLink.FileHash = hash
+		result.Hash = localAdvertisementLink.FileHash
+		localAdvertisementLink.PlayLink = playLink
 		localAdvertisementLink.OriginalLink = detail.FileLink
 		localAdvertisement.Link = localAdvertisementLink
-		dbConnection.Save(ctx, &localAdvertisement)
+		ctx, cancel := dbContext(itemCtx)
+		defer cancel()
+		result.Created, err = dbConnection.SaveReturning(ctx, &localAdvertisement)
+		if err != nil {
+			return result, cstmerr.NewProcessError("failed to save advertisement", err)
+		}
+		result.Action = PROCESS_ACTION_SAVE
 	} else {
-		//TODO: handle file deletion from filespace
-		err := dbConnection.First(ctx, &localAdvertisement)
+		ctx, cancel := dbContext(itemCtx)
+		defer cancel()
+		found, err := findContentRow(ctx, dbConnection, &localAdvertisement, content.ID)
 		if err != nil {
-			return cstmerr.NewProcessError(cstmerr.PROCESS_DELETE_ENTITY, err)
+			return result, cstmerr.NewProcessError(cstmerr.PROCESS_FIND_ENTITY, err)
+		}
+		if !found {
+			result.Action = PROCESS_ACTION_SKIP
+			return result, nil
 		}
 
-		err = DeleteVideo(localAdvertisement.Link.PlayLink)
+		removed, err := deleteContentAssets(ctx, dbConnection, &localAdvertisement, content.ID)
+		result.AssetPaths = append(result.AssetPaths, removed...)
 		if err != nil {
-			return cstmerr.NewProcessError(cstmerr.PROCESS_DELETE_FILE, err)
+			return result, cstmerr.NewProcessError(cstmerr.PROCESS_DELETE_FILE, err)
 		}
 
-		err = dbConnection.Delete(ctx, &localAdvertisement)
+		action, err := deleteContentRow(ctx, dbConnection, &localAdvertisement, content.ID)
 		if err != nil {
-			return cstmerr.NewProcessError(cstmerr.PROCESS_DELETE_ENTITY, err)
+			return result, cstmerr.NewProcessError(cstmerr.PROCESS_DELETE_ENTITY, err)
 		}
-
+		result.Action = action
 	}
-	return nil
+	return result, nil
 }
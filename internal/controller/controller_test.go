@@ -0,0 +1,452 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"embedup-go/configs/config"
+	ApiClient "embedup-go/internal/apiclient"
+	"embedup-go/internal/clocktest"
+	"embedup-go/internal/cstmerr"
+	"embedup-go/internal/dbclient"
+	SharedModels "embedup-go/internal/shared"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// emptyDB is a DBClient with no stored rows. It fails any write, so a test notices
+// when a disabled item that was never synced still tries to delete something.
+type emptyDB struct {
+	dbclient.DBClient
+	deletes int
+	onFirst func(ctx context.Context) // Optional, called on every lookup
+}
+
+func (db *emptyDB) First(ctx context.Context, model interface{}, conditions ...interface{}) error {
+	if db.onFirst != nil {
+		db.onFirst(ctx)
+	}
+	return cstmerr.NewDBNotFoundError("record not found", nil)
+}
+
+func (db *emptyDB) DeleteByContentId(ctx context.Context, model interface{}, contentId int64) error {
+	db.deletes++
+	return errors.New("unexpected delete")
+}
+
+func TestDisabledItemWithoutStoredRowIsSkipped(t *testing.T) {
+	tests := []struct {
+		name    string
+		content SharedModels.ProcessedContentSchema
+		process func(SharedModels.ProcessedContentSchema, dbclient.DBClient) (ProcessResult, error)
+	}{
+		{"movie", SharedModels.ProcessedContentSchema{ID: 1},
+			func(c SharedModels.ProcessedContentSchema, db dbclient.DBClient) (ProcessResult, error) {
+				return ProcessLocalMovie(context.Background(), c, db, nil)
+			}},
+		{"movie tombstone", SharedModels.ProcessedContentSchema{ID: 2, Type: "local-movie", Deleted: true},
+			func(c SharedModels.ProcessedContentSchema, db dbclient.DBClient) (ProcessResult, error) {
+				return processContentDetails(context.Background(), c, db, nil)
+			}},
+		{"genre", SharedModels.ProcessedContentSchema{ID: 3, Details: SharedModels.LocalMovieGenreSchema{}},
+			func(c SharedModels.ProcessedContentSchema, db dbclient.DBClient) (ProcessResult, error) {
+				return ProcessLocalMovieGenre(context.Background(), c, db, nil)
+			}},
+		{"slider", SharedModels.ProcessedContentSchema{ID: 4, Details: SharedModels.LocalSliderSchema{}},
+			func(c SharedModels.ProcessedContentSchema, db dbclient.DBClient) (ProcessResult, error) {
+				return ProcessLocalSlider(context.Background(), c, db, nil)
+			}},
+		{"advertisement", SharedModels.ProcessedContentSchema{ID: 5},
+			func(c SharedModels.ProcessedContentSchema, db dbclient.DBClient) (ProcessResult, error) {
+				return ProcessLocalAdvertisement(context.Background(), c, db, nil)
+			}},
+		{"advertisement tombstone", SharedModels.ProcessedContentSchema{ID: 6, Type: "local-advertisement", Deleted: true},
+			func(c SharedModels.ProcessedContentSchema, db dbclient.DBClient) (ProcessResult, error) {
+				return processContentDetails(context.Background(), c, db, nil)
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &emptyDB{}
+			result, err := tt.process(tt.content, db)
+			if err != nil {
+				t.Fatalf("process: %v", err)
+			}
+			if result.Action != PROCESS_ACTION_SKIP {
+				t.Fatalf("action = %q, want %q", result.Action, PROCESS_ACTION_SKIP)
+			}
+			if db.deletes != 0 {
+				t.Fatalf("deletes = %d, want 0", db.deletes)
+			}
+		})
+	}
+}
+
+// deadlineDB records, for each save, how much time its context had left.
+type deadlineDB struct {
+	emptyDB
+	saveBudget time.Duration
+}
+
+func (db *deadlineDB) Save(ctx context.Context, model interface{}) error {
+	return nil
+}
+
+func (db *deadlineDB) SaveReturning(ctx context.Context, model interface{}) (bool, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		db.saveBudget = time.Until(deadline)
+	}
+	return true, nil
+}
+
+func TestSaveAfterSlowDownloadGetsFullDBTimeout(t *testing.T) {
+	const downloadTime = 200 * time.Millisecond
+	t.Setenv("PODBOX_UPDATE_CONTENT_BASE_PATH", t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			time.Sleep(downloadTime)
+		}
+		http.ServeContent(w, r, "ad.mp4", time.Time{}, strings.NewReader("advertisement video"))
+	}))
+	defer server.Close()
+	client := ApiClient.NewWithHTTPClient(&config.Config{}, "test-token",
+		ApiClient.NewRestyAdapter(ApiClient.DefaultTransportTimeouts(), ApiClient.DefaultRedirectSettings()))
+	db := &deadlineDB{}
+
+	content := SharedModels.ProcessedContentSchema{ID: 7, Enable: true,
+		Details: SharedModels.LocalAdvertisementSchema{FileLink: server.URL + "/ad.mp4"}}
+	if _, err := ProcessLocalAdvertisement(context.Background(), content, db, client); err != nil {
+		t.Fatalf("ProcessLocalAdvertisement: %v", err)
+	}
+	if db.saveBudget < dbCallTimeout-downloadTime/2 {
+		t.Fatalf("save had %v left, want close to the full %v after the download", db.saveBudget, dbCallTimeout)
+	}
+}
+
+func TestItemProcessingTimeoutFromConfig(t *testing.T) {
+	defer Configure(settings)
+	cfg := config.Default()
+	cfg.ItemProcessingTimeout = 50 * time.Millisecond
+	Configure(cfg)
+
+	var remaining time.Duration
+	db := &emptyDB{onFirst: func(ctx context.Context) {
+		if deadline, ok := ctx.Deadline(); ok {
+			remaining = time.Until(deadline)
+		}
+	}}
+	content := SharedModels.ProcessedContentSchema{ID: 8, Details: SharedModels.LocalMovieSchema{}}
+	if _, err := processItemWithTimeout(context.Background(), content, db, nil); err != nil {
+		t.Fatalf("processItemWithTimeout: %v", err)
+	}
+	if remaining <= 0 || remaining > cfg.ItemProcessingTimeout {
+		t.Fatalf("item had %v left, want at most the configured %v", remaining, cfg.ItemProcessingTimeout)
+	}
+}
+
+// savingDB reports every save as an insert or an update, as set by created.
+type savingDB struct {
+	emptyDB
+	created bool
+	plain   int // Saves that couldn't tell an insert from an update
+}
+
+func (db *savingDB) Save(ctx context.Context, model interface{}) error {
+	db.plain++
+	return nil
+}
+
+func (db *savingDB) SaveReturning(ctx context.Context, model interface{}) (bool, error) {
+	return db.created, nil
+}
+
+func (db *savingDB) RunInTransaction(ctx context.Context, fn func(ctx context.Context, txClient dbclient.DBClient) error) error {
+	return fn(ctx, db)
+}
+
+func (db *savingDB) CreateAssosiate(ctx context.Context, model interface{}, assosiation string, assosiate interface{}) error {
+	return nil
+}
+
+func TestProcessReportsCreatedOrUpdated(t *testing.T) {
+	tests := []struct {
+		name    string
+		content SharedModels.ProcessedContentSchema
+		process func(SharedModels.ProcessedContentSchema, dbclient.DBClient) (ProcessResult, error)
+	}{
+		{"poll", SharedModels.ProcessedContentSchema{ID: 1, Enable: true, Details: SharedModels.LocalPollSchema{}},
+			func(c SharedModels.ProcessedContentSchema, db dbclient.DBClient) (ProcessResult, error) {
+				return ProcessLocalPoll(context.Background(), c, db)
+			}},
+		{"page", SharedModels.ProcessedContentSchema{ID: 2, Enable: true, Details: SharedModels.LocalPageSchema{}},
+			func(c SharedModels.ProcessedContentSchema, db dbclient.DBClient) (ProcessResult, error) {
+				return ProcessLocalPage(context.Background(), c, db)
+			}},
+		{"section without tabs", SharedModels.ProcessedContentSchema{ID: 3, Enable: true, Details: SharedModels.LocalSectionSchema{}},
+			func(c SharedModels.ProcessedContentSchema, db dbclient.DBClient) (ProcessResult, error) {
+				return ProcessLocalSection(context.Background(), c, db)
+			}},
+		{"tab with pages", SharedModels.ProcessedContentSchema{ID: 4, Enable: true,
+			Details: SharedModels.LocalTabSchema{LocalPageIDs: []int{10, 11}}},
+			func(c SharedModels.ProcessedContentSchema, db dbclient.DBClient) (ProcessResult, error) {
+				return ProcessLocalTab(context.Background(), c, db)
+			}},
+	}
+	for _, tt := range tests {
+		for _, created := range []bool{true, false} {
+			t.Run(fmt.Sprintf("%s created=%t", tt.name, created), func(t *testing.T) {
+				db := &savingDB{created: created}
+				result, err := tt.process(tt.content, db)
+				if err != nil {
+					t.Fatalf("process: %v", err)
+				}
+				if result.Action != PROCESS_ACTION_SAVE || result.Created != created {
+					t.Fatalf("result = %s created=%t, want %s created=%t",
+						result.Action, result.Created, PROCESS_ACTION_SAVE, created)
+				}
+				if db.plain != 0 {
+					t.Fatalf("%d saves went through Save instead of SaveReturning", db.plain)
+				}
+			})
+		}
+	}
+}
+
+// resultDB deletes and saves rows as configured by deleteErr and saveErr.
+type resultDB struct {
+	emptyDB
+	deleteErr error
+	saveErr   error
+}
+
+func (db *resultDB) DeleteByContentId(ctx context.Context, model interface{}, contentId int64) error {
+	db.deletes++
+	return db.deleteErr
+}
+
+func (db *resultDB) SaveReturning(ctx context.Context, model interface{}) (bool, error) {
+	return db.saveErr == nil, db.saveErr
+}
+
+func TestProcessReportsDeletedSkippedAndFailed(t *testing.T) {
+	processes := []struct {
+		name    string
+		process func(SharedModels.ProcessedContentSchema, dbclient.DBClient) (ProcessResult, error)
+	}{
+		{"poll", func(c SharedModels.ProcessedContentSchema, db dbclient.DBClient) (ProcessResult, error) {
+			c.Details = SharedModels.LocalPollSchema{}
+			return ProcessLocalPoll(context.Background(), c, db)
+		}},
+		{"page", func(c SharedModels.ProcessedContentSchema, db dbclient.DBClient) (ProcessResult, error) {
+			c.Details = SharedModels.LocalPageSchema{}
+			return ProcessLocalPage(context.Background(), c, db)
+		}},
+		{"section", func(c SharedModels.ProcessedContentSchema, db dbclient.DBClient) (ProcessResult, error) {
+			c.Details = SharedModels.LocalSectionSchema{}
+			return ProcessLocalSection(context.Background(), c, db)
+		}},
+	}
+	tests := []struct {
+		name       string
+		enable     bool
+		db         resultDB
+		wantAction string
+		wantErr    bool
+	}{
+		{"deleted", false, resultDB{}, PROCESS_ACTION_DELETE, false},
+		{"skipped", false, resultDB{deleteErr: cstmerr.NewDBNotFoundError("record not found", nil)}, PROCESS_ACTION_SKIP, false},
+		{"delete failed", false, resultDB{deleteErr: errors.New("connection reset")}, "", true},
+		{"save failed", true, resultDB{saveErr: errors.New("connection reset")}, "", true},
+	}
+	for _, p := range processes {
+		for _, tt := range tests {
+			t.Run(p.name+" "+tt.name, func(t *testing.T) {
+				db := tt.db
+				result, err := p.process(SharedModels.ProcessedContentSchema{ID: 21, Enable: tt.enable}, &db)
+				var processErr *cstmerr.ProcessError
+				if tt.wantErr != errors.As(err, &processErr) {
+					t.Fatalf("error = %v, want a ProcessError: %t", err, tt.wantErr)
+				}
+				if result.EntityID != 21 || result.Action != tt.wantAction || result.Created {
+					t.Fatalf("result = %+v, want entity 21 with action %q", result, tt.wantAction)
+				}
+				wantDeletes := 1
+				if tt.enable {
+					wantDeletes = 0
+				}
+				if db.deletes != wantDeletes {
+					t.Fatalf("%d deletes, want %d", db.deletes, wantDeletes)
+				}
+			})
+		}
+	}
+}
+
+func TestDeleteExtractedDir(t *testing.T) {
+	base := t.TempDir()
+	t.Setenv("PODBOX_UPDATE_CONTENT_BASE_PATH", base)
+	if err := EnsureContentDirs(); err != nil {
+		t.Fatal(err)
+	}
+	movieDir := filepath.Join(base, "videos", "abc123", "hls")
+	writeFiles(t, movieDir, map[string]string{"master.m3u8": "#EXTM3U\n", "720p/seg0.ts": "segment"})
+	outside := filepath.Join(base, "images", "poster.jpg")
+	writeFiles(t, filepath.Dir(outside), map[string]string{"poster.jpg": "poster"})
+
+	for _, relPath := range []string{"../images", "../../x", "abc123/../../images", ".", "", outside, "/abc123"} {
+		err := DeleteExtractedDir(relPath)
+		var deleteErr *cstmerr.FileDeleteError
+		if !errors.As(err, &deleteErr) {
+			t.Errorf("DeleteExtractedDir(%q) = %v, want a FileDeleteError", relPath, err)
+		}
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Fatalf("file outside videos removed: %v", err)
+	}
+
+	if err := DeleteExtractedDir("abc123"); err != nil {
+		t.Fatalf("DeleteExtractedDir: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(base, "videos", "abc123")); !os.IsNotExist(err) {
+		t.Fatalf("extracted directory left behind: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(base, "videos")); err != nil {
+		t.Fatalf("videos directory removed: %v", err)
+	}
+
+	if err := DeleteExtractedDir("abc123"); err != nil {
+		t.Fatalf("DeleteExtractedDir of a missing directory: %v", err)
+	}
+}
+
+func TestEnsureContentDirs(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "sdcard", "assets")
+	t.Setenv("PODBOX_UPDATE_CONTENT_BASE_PATH", base)
+
+	// Running again over an existing tree is fine.
+	for range 2 {
+		if err := EnsureContentDirs(); err != nil {
+			t.Fatalf("EnsureContentDirs: %v", err)
+		}
+	}
+	for _, dir := range []string{"", "images", "images/genre", "images/slider", "videos", "videos/ads", "audios"} {
+		info, err := os.Stat(filepath.Join(base, filepath.FromSlash(dir)))
+		if err != nil || !info.IsDir() {
+			t.Errorf("content directory %q is missing: %v", dir, err)
+		}
+	}
+}
+
+func TestEnsureContentDirsFailsOnUnusableBase(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "assets")
+	if err := os.WriteFile(base, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PODBOX_UPDATE_CONTENT_BASE_PATH", base)
+
+	var fsErr *cstmerr.FileSystemError
+	if err := EnsureContentDirs(); !errors.As(err, &fsErr) {
+		t.Fatalf("EnsureContentDirs = %v, want a FileSystemError", err)
+	}
+}
+
+func TestDownloadAndHashHelpers(t *testing.T) {
+	short := []byte("a small poster")
+	long := bytes.Repeat([]byte("0123456789abcdef"), 128) // Only the first 1025 bytes are hashed
+	md5Hex := func(data []byte) string {
+		sum := md5.Sum(data)
+		return hex.EncodeToString(sum[:])
+	}
+	for _, tc := range []struct {
+		name     string
+		download func(*ApiClient.APIClient, string) (string, string, error)
+		content  []byte
+		dir      string
+		wantRel  string
+		wantHash string
+	}{
+		{
+			name: "image",
+			download: func(client *ApiClient.APIClient, url string) (string, string, error) {
+				return downloadAndHashImage(context.Background(), nil, client, url, "slider", false)
+			},
+			content: short, dir: "images", wantRel: filepath.Join("slider", md5Hex(short)+".jpg"), wantHash: md5Hex(short),
+		},
+		{
+			name: "media",
+			download: func(client *ApiClient.APIClient, url string) (string, string, error) {
+				return downloadAndHashMedia(context.Background(), nil, client, url, "ads", false)
+			},
+			content: long, dir: "videos", wantRel: filepath.Join("ads", md5Hex(long)+".mp4"), wantHash: md5Hex(long[:1025]),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			base := t.TempDir()
+			t.Setenv("PODBOX_UPDATE_CONTENT_BASE_PATH", base)
+			server := newStubHTTP()
+			const url = "https://cdn.test/asset"
+			server.setFile(url, tc.content)
+			client := ApiClient.NewWithHTTPClient(&config.Config{}, "test-token", server)
+			client.SetClock(clocktest.NewFakeClock(time.Unix(0, 0)))
+
+			rel, hash, err := tc.download(client, url)
+			if err != nil {
+				t.Fatalf("download: %v", err)
+			}
+			if rel != tc.wantRel || hash != tc.wantHash {
+				t.Fatalf("download = %q, %q; want %q, %q", rel, hash, tc.wantRel, tc.wantHash)
+			}
+			if got, err := os.ReadFile(filepath.Join(base, tc.dir, rel)); err != nil || !bytes.Equal(got, tc.content) {
+				t.Fatalf("downloaded file = %d bytes, %v; want the asset", len(got), err)
+			}
+
+			// A missing asset is a ProcessError naming the URL.
+			_, _, err = tc.download(client, "https://cdn.test/missing")
+			var processErr *cstmerr.ProcessError
+			if !errors.As(err, &processErr) || !strings.Contains(err.Error(), "https://cdn.test/missing") {
+				t.Fatalf("download of a missing asset = %v, want a ProcessError naming it", err)
+			}
+		})
+	}
+}
+
+// checkCounts fails the test if the item counts or watermark of got differ from want.
+func checkCounts(t *testing.T, name string, got SyncSummary, want SyncSummary) {
+	t.Helper()
+	if got.Fetched != want.Fetched || got.Processed != want.Processed || got.Created != want.Created ||
+		got.Updated != want.Updated || got.Skipped != want.Skipped || got.Failed != want.Failed ||
+		got.NewWatermark != want.NewWatermark {
+		t.Fatalf("%s summary = %+v, want %+v", name, got, want)
+	}
+}
+
+func TestSyncSummaryCountsMixedBatch(t *testing.T) {
+	p := newPipeline(t)
+	p.serveItems(t, []feedItem{p.adItem(1, true), p.adItem(2, false), p.adItem(3, true)})
+	p.db.failSave[3] = true
+
+	summary, err := p.app.RunCycle(context.Background())
+	if err == nil {
+		t.Fatal("RunCycle succeeded with a failing item")
+	}
+	checkCounts(t, "first cycle", summary, SyncSummary{Fetched: 3, Processed: 1, Created: 1, Skipped: 1, Failed: 1,
+		NewWatermark: 2000})
+
+	// The failed item is saved on the next cycle, along with a change to a synced one.
+	delete(p.db.failSave, 3)
+	changed := p.adItem(1, true)
+	changed.UpdatedAt = 4000
+	changed.Content["skipDuration"] = 10
+	p.serveItems(t, []feedItem{p.adItem(3, true), changed})
+	summary = p.runCycle(t)
+	checkCounts(t, "second cycle", summary, SyncSummary{Fetched: 2, Processed: 2, Created: 1, Updated: 1,
+		NewWatermark: 4000})
+}
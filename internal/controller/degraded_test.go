@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"context"
+	"embedup-go/configs/config"
+	"testing"
+)
+
+// serveAds makes the content update API return count enabled advertisements, with
+// IDs from 1, each with a file of its own.
+func (p *pipeline) serveAds(t *testing.T, count int) {
+	t.Helper()
+	var items []feedItem
+	for id := int64(1); id <= int64(count); id++ {
+		items = append(items, p.adItem(id, true))
+	}
+	p.serveItems(t, items)
+}
+
+func TestDegradedFailureRatio(t *testing.T) {
+	tests := []struct {
+		name     string
+		failing  []int64
+		degraded bool
+	}{
+		{"below threshold", []int64{2}, false},
+		{"at threshold", []int64{2, 3}, false},
+		{"above threshold", []int64{1, 2, 3}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPipeline(t)
+			p.serveAds(t, 4)
+			for _, id := range tt.failing {
+				p.db.failSave[id] = true
+			}
+
+			summary, err := p.app.RunCycle(context.Background())
+			if err == nil {
+				t.Fatal("RunCycle succeeded with failing items")
+			}
+			if summary.Failed != len(tt.failing) || summary.Attempted() != 4 {
+				t.Fatalf("summary = %+v, want %d of 4 failed", summary, len(tt.failing))
+			}
+			if summary.Degraded != tt.degraded {
+				t.Fatalf("degraded = %t at failure ratio %.2f, want %t", summary.Degraded, summary.FailureRatio(), tt.degraded)
+			}
+		})
+	}
+}
+
+func TestErrorPolicy(t *testing.T) {
+	tests := []struct {
+		policy    string
+		attempted int
+		processed int
+	}{
+		{config.ERROR_POLICY_BEST_EFFORT, 4, 3},
+		{config.ERROR_POLICY_FAIL_FAST, 2, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			withSettings(t, func(cfg *config.Config) { cfg.ErrorPolicy = tt.policy })
+			p := newPipeline(t)
+			p.serveAds(t, 4)
+			p.db.failSave[2] = true
+
+			summary, err := p.app.RunCycle(context.Background())
+			if err == nil {
+				t.Fatal("RunCycle succeeded with a failing item")
+			}
+			if summary.Attempted() != tt.attempted || summary.Processed != tt.processed || summary.Failed != 1 {
+				t.Fatalf("summary = %+v, want %d attempted and %d processed", summary, tt.attempted, tt.processed)
+			}
+			if summary.NewWatermark != 1000 {
+				t.Fatalf("watermark = %d, want it before the failed item", summary.NewWatermark)
+			}
+		})
+	}
+}
+
+func TestDegradedFailureRatioZeroDisablesIt(t *testing.T) {
+	withSettings(t, func(cfg *config.Config) { cfg.DegradedFailureRatio = 0 })
+	p := newPipeline(t)
+	p.serveAds(t, 2)
+	p.db.failSave[1] = true
+	p.db.failSave[2] = true
+
+	summary, _ := p.app.RunCycle(context.Background())
+	if summary.Failed != 2 || summary.Degraded {
+		t.Fatalf("summary = %+v, want every item failed without the batch counting as degraded", summary)
+	}
+}
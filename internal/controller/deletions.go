@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"embedup-go/internal/cstmerr"
+	"embedup-go/internal/dbclient"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var pendingDeletionsMu sync.Mutex
+
+// pendingDeletionsFile returns the file in the state directory listing assets waiting
+// out the deletion grace period. Each line holds the Unix time the asset is due for
+// deletion, its kind and its path relative to the kind's directory.
+func pendingDeletionsFile() string {
+	return statePath("pending_deletions")
+}
+
+// scheduleDeletion records the asset for deletion at due. An asset already scheduled
+// keeps its earlier due time.
+func scheduleDeletion(asset contentAsset, due time.Time) error {
+	return updatePendingDeletions(func(pending map[contentAsset]time.Time) {
+		if existing, ok := pending[asset]; ok && existing.Before(due) {
+			return
+		}
+		log.Printf("Deleting %s/%s after %s unless it is used again", asset.kind, asset.relPath, due.Format(time.RFC3339))
+		pending[asset] = due
+	})
+}
+
+// cancelDeletions drops the scheduled deletions of any of paths, which are in use again.
+func cancelDeletions(paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	inUse := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		inUse[path] = true
+	}
+	return updatePendingDeletions(func(pending map[contentAsset]time.Time) {
+		for asset := range pending {
+			if inUse[assetPath(asset)] {
+				log.Printf("Keeping %s/%s, used again before its deletion", asset.kind, asset.relPath)
+				delete(pending, asset)
+			}
+		}
+	})
+}
+
+// deleteExpiredAssets deletes the scheduled assets due by now, along with their recorded
+// sizes, and returns their paths. Assets that fail to delete stay scheduled and are
+// retried next cycle.
+func deleteExpiredAssets(ctx context.Context, dbConnection dbclient.DBClient, now time.Time) ([]string, error) {
+	var removed []string
+	var firstErr error
+	err := updatePendingDeletions(func(pending map[contentAsset]time.Time) {
+		for asset, due := range pending {
+			if now.Before(due) {
+				continue
+			}
+			path, err := deleteAsset(asset)
+			if err != nil {
+				log.Printf("Failed to delete %s/%s after its grace period: %v", asset.kind, asset.relPath, err)
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			metaCtx, cancel := dbContext(ctx)
+			err = forgetAssetMeta(metaCtx, dbConnection, asset)
+			cancel()
+			if err != nil {
+				log.Printf("Failed to forget %s/%s after deleting it: %v", asset.kind, asset.relPath, err)
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			removed = append(removed, path)
+			delete(pending, asset)
+		}
+	})
+	if err != nil {
+		return removed, err
+	}
+	sort.Strings(removed)
+	return removed, firstErr
+}
+
+// updatePendingDeletions applies change to the scheduled deletions and writes them back.
+func updatePendingDeletions(change func(pending map[contentAsset]time.Time)) error {
+	pendingDeletionsMu.Lock()
+	defer pendingDeletionsMu.Unlock()
+	pending, err := readPendingDeletions()
+	if err != nil {
+		return err
+	}
+	before := len(pending)
+	change(pending)
+	if before == 0 && len(pending) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	for asset, due := range pending {
+		fmt.Fprintf(&buf, "%d %s %s\n", due.Unix(), asset.kind, asset.relPath)
+	}
+	return writeStateFile(pendingDeletionsFile(), buf.Bytes())
+}
+
+// readPendingDeletions returns the scheduled deletions. A missing file means none.
+func readPendingDeletions() (map[contentAsset]time.Time, error) {
+	pending := make(map[contentAsset]time.Time)
+	data, err := os.ReadFile(pendingDeletionsFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return pending, nil
+		}
+		return nil, cstmerr.NewFileIOError(fmt.Sprintf("failed to read %s", pendingDeletionsFile()), err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) != 3 {
+			log.Printf("Ignoring malformed pending deletion %q", line)
+			continue
+		}
+		due, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			log.Printf("Ignoring malformed pending deletion %q", line)
+			continue
+		}
+		pending[contentAsset{kind: fields[1], relPath: fields[2]}] = time.Unix(due, 0)
+	}
+	return pending, scanner.Err()
+}
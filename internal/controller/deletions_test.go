@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"context"
+	"crypto/md5"
+	"embedup-go/configs/config"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGracePeriodDefersAssetDeletion(t *testing.T) {
+	withSettings(t, func(cfg *config.Config) { cfg.DeletionGracePeriod = 24 * time.Hour })
+	p := newPipeline(t)
+	p.server.setFile(adURL, adContent)
+	sum := md5.Sum(adContent)
+	adPath := filepath.Join(ContentBasePath(), "videos", "ads", hex.EncodeToString(sum[:])+".mp4")
+
+	p.serveFeed(t, "feed_advertisement_enabled.json")
+	p.runCycle(t)
+	p.serveFeed(t, "feed_advertisement_disabled.json")
+	p.runCycle(t)
+
+	if _, err := os.Stat(adPath); err != nil {
+		t.Fatalf("advertisement deleted within its grace period: %v", err)
+	}
+	if filepath.Dir(pendingDeletionsFile()) != settings.StateDir {
+		t.Fatalf("pending deletions file %s is outside the state dir", pendingDeletionsFile())
+	}
+	pending, err := readPendingDeletions()
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("pending deletions = %v, %v; want the advertisement", pending, err)
+	}
+
+	removed, err := deleteExpiredAssets(context.Background(), p.db, time.Now().Add(25*time.Hour))
+	if err != nil || len(removed) != 1 {
+		t.Fatalf("deleteExpiredAssets = %v, %v", removed, err)
+	}
+	if _, err := os.Stat(adPath); !os.IsNotExist(err) {
+		t.Fatalf("advertisement kept after its grace period: %v", err)
+	}
+}
+
+func TestReenabledContentCancelsDeletion(t *testing.T) {
+	withSettings(t, func(cfg *config.Config) { cfg.DeletionGracePeriod = 24 * time.Hour })
+	p := newPipeline(t)
+	p.server.setFile(adURL, adContent)
+
+	p.serveFeed(t, "feed_advertisement_enabled.json")
+	p.runCycle(t)
+	p.serveFeed(t, "feed_advertisement_disabled.json")
+	p.runCycle(t)
+	p.serveFeed(t, "feed_advertisement_enabled.json")
+	p.runCycle(t)
+
+	pending, err := readPendingDeletions()
+	if err != nil || len(pending) != 0 {
+		t.Fatalf("pending deletions = %v, %v; want none once the advertisement is used again", pending, err)
+	}
+}
+
+func TestGracePeriodExpiresThroughTheCycle(t *testing.T) {
+	withSettings(t, func(cfg *config.Config) { cfg.DeletionGracePeriod = 24 * time.Hour })
+	fake := withClock(t)
+	p := newPipeline(t)
+	p.server.setFile(adURL, adContent)
+	sum := md5.Sum(adContent)
+	adPath := filepath.Join(ContentBasePath(), "videos", "ads", hex.EncodeToString(sum[:])+".mp4")
+
+	p.serveFeed(t, "feed_advertisement_enabled.json")
+	p.runCycle(t)
+	p.serveFeed(t, "feed_advertisement_disabled.json")
+	p.runCycle(t)
+
+	fake.Advance(23 * time.Hour)
+	p.runCycle(t)
+	if _, err := os.Stat(adPath); err != nil {
+		t.Fatalf("advertisement deleted within its grace period: %v", err)
+	}
+
+	fake.Advance(2 * time.Hour)
+	p.runCycle(t)
+	if _, err := os.Stat(adPath); !os.IsNotExist(err) {
+		t.Fatalf("advertisement kept after its grace period: %v", err)
+	}
+	if pending, err := readPendingDeletions(); err != nil || len(pending) != 0 {
+		t.Fatalf("pending deletions = %v, %v; want none", pending, err)
+	}
+}
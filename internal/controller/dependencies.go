@@ -0,0 +1,226 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	ApiClient "embedup-go/internal/apiclient"
+	"embedup-go/internal/cstmerr"
+	"embedup-go/internal/dbclient"
+	SharedModels "embedup-go/internal/shared"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var pendingMu sync.Mutex
+
+// pendingFile returns the file in the state directory listing items waiting for their
+// parent. Each line holds a content ID, the type and content ID of the parent it waits
+// for and the Unix time it was deferred at.
+func pendingFile() string {
+	return statePath("pending")
+}
+
+// parentRef identifies the content item another one depends on.
+type parentRef struct {
+	Type string
+	ID   int64
+}
+
+// pendingItem is an item waiting for its parent since Since.
+type pendingItem struct {
+	Parent parentRef
+	Since  time.Time
+}
+
+// parentModels returns, per parent content type, the local row that must exist
+// before its children can be stored.
+var parentModels = map[string]func(id int64) interface{}{
+	"local-series":          func(id int64) interface{} { return &SharedModels.Series{ContentId: id} },
+	"local-series-season":   func(id int64) interface{} { return &SharedModels.SeriesSeason{ContentId: id} },
+	"local-section":         func(id int64) interface{} { return &SharedModels.Section{ContentId: id} },
+	"local-podcastparent":   func(id int64) interface{} { return &SharedModels.PodcastAlbum{ContentId: id} },
+	"local-audiobookparent": func(id int64) interface{} { return &SharedModels.AudiobookAlbum{ContentId: id} },
+}
+
+// parentOf returns the parent an item must wait for. Disabled items only clean up
+// and never wait.
+func parentOf(content SharedModels.ProcessedContentSchema) (parentRef, bool) {
+	if !content.Enable || content.Deleted {
+		return parentRef{}, false
+	}
+	switch v := content.Details.(type) {
+	case SharedModels.LocalSeriesSeasonSchema:
+		return parentRef{Type: "local-series", ID: int64(v.LocalSeriesID)}, true
+	case SharedModels.LocalSeriesEpisodeSchema:
+		return parentRef{Type: "local-series-season", ID: int64(v.LocalSeasonID)}, true
+	case SharedModels.LocalSectionContentSchema:
+		return parentRef{Type: "local-section", ID: int64(v.LocalSectionID)}, true
+	case SharedModels.LocalPodcastSchema:
+		return parentRef{Type: "local-podcastparent", ID: int64(v.LocalPodcastParentID)}, true
+	case SharedModels.LocalAudiobookSchema:
+		return parentRef{Type: "local-audiobookparent", ID: int64(v.LocalAudiobookParentID)}, true
+	}
+	return parentRef{}, false
+}
+
+// parentPresent reports whether the parent's row is stored locally.
+func parentPresent(ctx context.Context, dbConnection dbclient.DBClient, parent parentRef) (bool, error) {
+	newModel, ok := parentModels[parent.Type]
+	if !ok {
+		return true, nil
+	}
+	err := dbConnection.First(ctx, newModel(parent.ID))
+	var notFound *cstmerr.DBNotFoundError
+	if errors.As(err, &notFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// deferIfOrphan sets content aside in the pending file if its parent isn't stored yet,
+// reporting whether it did.
+func deferIfOrphan(dbConnection dbclient.DBClient, content SharedModels.ProcessedContentSchema) (bool, error) {
+	parent, ok := parentOf(content)
+	if !ok {
+		return false, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Connection timeout
+	defer cancel()
+	present, err := parentPresent(ctx, dbConnection, parent)
+	if err != nil || present {
+		return false, err
+	}
+	log.Printf("Deferring item %d until its parent %s %d is synced", content.ID, parent.Type, parent.ID)
+	return true, updatePending(func(pending map[int64]pendingItem) {
+		// An item deferred again keeps waiting since it was first deferred.
+		since := clock.Now()
+		if existing, ok := pending[content.ID]; ok {
+			since = existing.Since
+		}
+		pending[content.ID] = pendingItem{Parent: parent, Since: since}
+	})
+}
+
+// resolvePending processes the pending items whose parent is now stored, fetching
+// each again so the latest version is used, and drops the items that waited longer
+// than the pending max age. It returns how many were processed and how many dropped.
+func resolvePending(cycleCtx context.Context, dbConnection dbclient.DBClient,
+	apiClient *ApiClient.APIClient, forced forceRedownloads) (resolved int, abandoned int, err error) {
+	pending, err := readPending()
+	if err != nil || len(pending) == 0 {
+		return 0, 0, err
+	}
+	ids := make([]int64, 0, len(pending))
+	for id := range pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var firstErr error
+	for _, id := range ids {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Connection timeout
+		present, err := parentPresent(ctx, dbConnection, pending[id].Parent)
+		cancel()
+		if err != nil {
+			return resolved, abandoned, err
+		}
+		if !present {
+			maxAge := settings.PendingMaxAge
+			if maxAge <= 0 || clock.Now().Sub(pending[id].Since) <= maxAge {
+				continue
+			}
+			log.Printf("Dropping item %d, its parent %s %d wasn't synced within %v",
+				id, pending[id].Parent.Type, pending[id].Parent.ID, maxAge)
+			abandoned++
+		} else {
+			item, err := apiClient.GetContentItemContext(cycleCtx, id)
+			var notFound *cstmerr.ContentNotFoundError
+			switch {
+			case errors.As(err, &notFound):
+				log.Printf("Pending item %d no longer exists on the server, dropping it", id)
+			case err != nil:
+				log.Printf("Failed to fetch pending item %d: %v", id, err)
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			case item != nil:
+				item.ForceRedownload = item.ForceRedownload || forced.requested(id)
+				if _, err := processItemWithTimeout(cycleCtx, *item, dbConnection, apiClient); err != nil {
+					log.Printf("Failed to process pending item %d: %v", id, err)
+					if firstErr == nil {
+						firstErr = err
+					}
+					continue
+				}
+				resolved++
+			}
+		}
+		if err := updatePending(func(pending map[int64]pendingItem) { delete(pending, id) }); err != nil {
+			return resolved, abandoned, err
+		}
+	}
+	return resolved, abandoned, firstErr
+}
+
+// updatePending applies change to the pending items and writes them back.
+func updatePending(change func(pending map[int64]pendingItem)) error {
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	pending, err := readPendingLocked()
+	if err != nil {
+		return err
+	}
+	change(pending)
+	var buf bytes.Buffer
+	for id, item := range pending {
+		fmt.Fprintf(&buf, "%d %s %d %d\n", id, item.Parent.Type, item.Parent.ID, item.Since.Unix())
+	}
+	return writeStateFile(pendingFile(), buf.Bytes())
+}
+
+// readPending returns the pending items by content ID. A missing file means none.
+func readPending() (map[int64]pendingItem, error) {
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	return readPendingLocked()
+}
+
+func readPendingLocked() (map[int64]pendingItem, error) {
+	pending := make(map[int64]pendingItem)
+	data, err := os.ReadFile(pendingFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return pending, nil
+		}
+		return nil, cstmerr.NewFileIOError(fmt.Sprintf("failed to read %s", pendingFile()), err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 4 {
+			log.Printf("Ignoring malformed pending entry %q", scanner.Text())
+			continue
+		}
+		id, err := strconv.ParseInt(fields[0], 10, 64)
+		parentID, parentErr := strconv.ParseInt(fields[2], 10, 64)
+		since, sinceErr := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil || parentErr != nil || sinceErr != nil {
+			log.Printf("Ignoring malformed pending entry %q", scanner.Text())
+			continue
+		}
+		pending[id] = pendingItem{Parent: parentRef{Type: fields[1], ID: parentID}, Since: time.Unix(since, 0)}
+	}
+	return pending, scanner.Err()
+}
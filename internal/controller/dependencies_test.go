@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"context"
+	"embedup-go/configs/config"
+	SharedModels "embedup-go/internal/shared"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// orphanEpisode is an episode whose season is not stored.
+var orphanEpisode = SharedModels.ProcessedContentSchema{
+	ID: 42, Type: "local-series-episode", Enable: true,
+	Details: SharedModels.LocalSeriesEpisodeSchema{LocalSeasonID: 7},
+}
+
+func TestDeferredItemIsKeptInStateDir(t *testing.T) {
+	withSettings(t, func(cfg *config.Config) { cfg.DependencyOrder = true })
+
+	deferred, err := deferIfOrphan(&emptyDB{}, orphanEpisode)
+	if err != nil || !deferred {
+		t.Fatalf("deferIfOrphan = %t, %v; want the episode deferred", deferred, err)
+	}
+	pending, err := readPending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, ok := pending[orphanEpisode.ID]
+	if !ok || item.Parent != (parentRef{Type: "local-series-season", ID: 7}) || time.Since(item.Since) > time.Minute {
+		t.Fatalf("pending = %+v", pending)
+	}
+	if !strings.HasPrefix(pendingFile(), settings.StateDir) {
+		t.Fatalf("pending file %s is outside the state dir", pendingFile())
+	}
+}
+
+func TestDeferredAgainKeepsWaitingSince(t *testing.T) {
+	withSettings(t, nil)
+	since := time.Now().Add(-time.Hour).Truncate(time.Second)
+	line := fmt.Sprintf("%d local-series-season 7 %d\n", orphanEpisode.ID, since.Unix())
+	if err := writeStateFile(pendingFile(), []byte(line)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := deferIfOrphan(&emptyDB{}, orphanEpisode); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := readPending()
+	if err != nil || !pending[orphanEpisode.ID].Since.Equal(since) {
+		t.Fatalf("pending = %+v, %v; want it waiting since %v", pending, err, since)
+	}
+}
+
+func TestStalePendingItemIsDropped(t *testing.T) {
+	withSettings(t, func(cfg *config.Config) { cfg.PendingMaxAge = 24 * time.Hour })
+	stale := time.Now().Add(-48 * time.Hour).Unix()
+	fresh := time.Now().Unix()
+	data := fmt.Sprintf("1 local-series-season 7 %d\n2 local-series-season 7 %d\n", stale, fresh)
+	if err := writeStateFile(pendingFile(), []byte(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	resolved, abandoned, err := resolvePending(context.Background(), &emptyDB{}, nil, forceRedownloads{})
+	if err != nil || resolved != 0 || abandoned != 1 {
+		t.Fatalf("resolvePending = %d, %d, %v; want 1 abandoned", resolved, abandoned, err)
+	}
+	pending, err := readPending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pending[1]; ok {
+		t.Fatal("stale item still pending")
+	}
+	if _, ok := pending[2]; !ok {
+		t.Fatal("fresh item dropped")
+	}
+	if _, err := os.Stat(pendingFile()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPendingMaxAgeThroughTheCycle(t *testing.T) {
+	withSettings(t, func(cfg *config.Config) {
+		cfg.DependencyOrder = true
+		cfg.PendingMaxAge = 24 * time.Hour
+	})
+	fake := withClock(t)
+	p := newPipeline(t)
+	p.serveItems(t, []feedItem{{ID: 42, Type: "local-series-episode", UpdatedAt: 1000, Enable: true,
+		Content: map[string]any{"fileLink": "https://cdn.test/episodes/42.mp4", "localSeasonId": 7, "episodeId": 1}}})
+
+	if summary := p.runCycle(t); summary.Deferred != 1 {
+		t.Fatalf("summary = %+v, want the episode deferred", summary)
+	}
+	p.serveItems(t, nil)
+	fake.Advance(23 * time.Hour)
+	if summary := p.runCycle(t); summary.Abandoned != 0 {
+		t.Fatalf("summary = %+v, want the episode still waiting", summary)
+	}
+	fake.Advance(2 * time.Hour)
+	if summary := p.runCycle(t); summary.Abandoned != 1 {
+		t.Fatalf("summary = %+v, want the episode dropped past the pending max age", summary)
+	}
+}
@@ -0,0 +1,217 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	ApiClient "embedup-go/internal/apiclient"
+	"embedup-go/internal/cstmerr"
+	SharedModels "embedup-go/internal/shared"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// hlsURIAttribute matches the URI="..." attribute of tags such as #EXT-X-MEDIA,
+// #EXT-X-MAP and #EXT-X-KEY.
+var hlsURIAttribute = regexp.MustCompile(`URI="([^"]*)"`)
+
+// validateHLSPackage checks that every variant playlist, rendition and segment
+// referenced from the master playlist, directly or through a variant playlist,
+// exists on disk. Remote (absolute URL) references are not checked.
+func validateHLSPackage(masterPath string) error {
+	visited := make(map[string]bool)
+	var check func(playlistPath string) error
+	check = func(playlistPath string) error {
+		if visited[playlistPath] {
+			return nil
+		}
+		visited[playlistPath] = true
+
+		data, err := os.ReadFile(playlistPath)
+		if err != nil {
+			return cstmerr.NewProcessError(fmt.Sprintf(cstmerr.PROCESS_INCOMPLETE_HLS, playlistPath), err)
+		}
+		for _, ref := range hlsReferences(data) {
+			if strings.Contains(ref, "://") {
+				continue
+			}
+			refPath := filepath.Join(filepath.Dir(playlistPath), filepath.FromSlash(ref))
+			if _, err := os.Stat(refPath); err != nil {
+				return cstmerr.NewProcessError(fmt.Sprintf(cstmerr.PROCESS_INCOMPLETE_HLS, refPath), err)
+			}
+			if strings.EqualFold(filepath.Ext(refPath), ".m3u8") {
+				if err := check(refPath); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return check(masterPath)
+}
+
+// hlsReferences returns the URIs a playlist refers to: its URI lines and the URI
+// attributes of its tags. Query strings are dropped.
+func hlsReferences(data []byte) []string {
+	refs := hlsURIs(data)
+	for i, ref := range refs {
+		refs[i], _, _ = strings.Cut(ref, "?")
+	}
+	return refs
+}
+
+// hlsURIs returns the URIs a playlist refers to as written, query strings included.
+func hlsURIs(data []byte) []string {
+	var refs []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#"):
+			for _, match := range hlsURIAttribute.FindAllStringSubmatch(line, -1) {
+				refs = append(refs, match[1])
+			}
+		default:
+			refs = append(refs, line)
+		}
+	}
+	return refs
+}
+
+// hlsDownloadWorkers is how many files of an HLS directory are downloaded at once,
+// within the limit of the API client's download limiter.
+const hlsDownloadWorkers = 4
+
+// isHLSPlaylistURL reports whether a file link points at an HLS master playlist served
+// as a directory of files, rather than at a zipped package.
+func isHLSPlaylistURL(fileLink string) bool {
+	u, err := url.Parse(fileLink)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(path.Ext(u.Path), ".m3u8")
+}
+
+// DownloadHLSDirectory downloads the HLS package served under masterURL into destDir,
+// saving the master playlist as masterName and every playlist, rendition and segment
+// it refers to at the same relative path. Files already downloaded are kept.
+func DownloadHLSDirectory(apiClient *ApiClient.APIClient, masterURL string, destDir string, masterName string) error {
+	return downloadHLSDirectory(context.Background(), apiClient, masterURL, destDir, masterName)
+}
+
+func downloadHLSDirectory(ctx context.Context, apiClient *ApiClient.APIClient, masterURL string, destDir string, masterName string) error {
+	root, err := url.Parse(masterURL)
+	if err != nil {
+		return cstmerr.NewProcessError(fmt.Sprintf(cstmerr.PROCESS_DOWNLOAD_ERROR, masterURL), err)
+	}
+	rootPrefix := strings.TrimSuffix(path.Dir(root.Path), "/") + "/"
+	visited := make(map[string]bool)
+
+	// download fetches the playlist at playlistURL to localPath, then everything it
+	// refers to, recursing into variant playlists.
+	var download func(playlistURL *url.URL, localPath string) error
+	download = func(playlistURL *url.URL, localPath string) error {
+		if visited[localPath] {
+			return nil
+		}
+		visited[localPath] = true
+		if err := apiClient.DownloadFileWithRetryMD5Context(ctx, playlistURL.String(), localPath, ""); err != nil {
+			return cstmerr.NewProcessError(fmt.Sprintf(cstmerr.PROCESS_DOWNLOAD_ERROR, playlistURL), err)
+		}
+		data, err := os.ReadFile(localPath)
+		if err != nil {
+			return cstmerr.NewFileIOError(fmt.Sprintf("failed to read playlist %s", localPath), err)
+		}
+
+		var playlists []*url.URL
+		var playlistPaths []string
+		segments := make(map[string]string) // Local path to URL
+		for _, ref := range hlsURIs(data) {
+			if strings.Contains(ref, "://") {
+				continue // Left remote, as validateHLSPackage does
+			}
+			refURL, err := playlistURL.Parse(ref)
+			if err != nil {
+				return cstmerr.NewProcessError(fmt.Sprintf(cstmerr.PROCESS_DOWNLOAD_ERROR, ref), err)
+			}
+			// Lay files out relative to the master's directory, never outside destDir.
+			rel, ok := strings.CutPrefix(refURL.Path, rootPrefix)
+			if !ok {
+				return cstmerr.NewProcessError(fmt.Sprintf(cstmerr.PROCESS_INCOMPLETE_HLS, ref), nil)
+			}
+			refPath, err := SharedModels.ArchiveEntryPath(destDir, rel)
+			if err != nil {
+				return err
+			}
+			if strings.EqualFold(path.Ext(refURL.Path), ".m3u8") {
+				playlists = append(playlists, refURL)
+				playlistPaths = append(playlistPaths, refPath)
+			} else {
+				segments[refPath] = refURL.String()
+			}
+		}
+
+		if err := downloadHLSFiles(ctx, apiClient, segments); err != nil {
+			return err
+		}
+		for i, playlist := range playlists {
+			if err := download(playlist, playlistPaths[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return download(root, filepath.Join(destDir, masterName))
+}
+
+// downloadHLSFiles downloads every URL in files to its local path, hlsDownloadWorkers
+// at a time, and returns the first error.
+func downloadHLSFiles(ctx context.Context, apiClient *ApiClient.APIClient, files map[string]string) error {
+	type job struct{ localPath, url string }
+	jobs := make(chan job)
+	var (
+		wg       sync.WaitGroup
+		errMu    sync.Mutex
+		firstErr error
+	)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for i := 0; i < hlsDownloadWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if err := apiClient.DownloadFileWithRetryMD5Context(ctx, j.url, j.localPath, ""); err != nil {
+					errMu.Lock()
+					if firstErr == nil {
+						firstErr = cstmerr.NewProcessError(fmt.Sprintf(cstmerr.PROCESS_DOWNLOAD_ERROR, j.url), err)
+						cancel()
+					}
+					errMu.Unlock()
+				}
+			}
+		}()
+	}
+feed:
+	for localPath, fileURL := range files {
+		select {
+		case jobs <- job{localPath: localPath, url: fileURL}:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"context"
+	"embedup-go/configs/config"
+	ApiClient "embedup-go/internal/apiclient"
+	SharedModels "embedup-go/internal/shared"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// hlsTree is an HLS package with a master playlist, two variants, an audio rendition
+// and their segments, keyed by path relative to the master playlist.
+var hlsTree = map[string]string{
+	"master.m3u8": "#EXTM3U\n" +
+		"#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"aac\",NAME=\"en\",URI=\"audio/en.m3u8\"\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=2000000,AUDIO=\"aac\"\n720p/index.m3u8\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=800000,AUDIO=\"aac\"\n480p/index.m3u8\n",
+	"720p/index.m3u8": "#EXTM3U\n#EXTINF:4,\nseg/0.ts\n#EXTINF:4,\nseg/1.ts\n#EXT-X-ENDLIST\n",
+	"720p/seg/0.ts":   "720p segment 0",
+	"720p/seg/1.ts":   "720p segment 1",
+	"480p/index.m3u8": "#EXTM3U\n#EXTINF:4,\n0.ts\n#EXTINF:4,\nhttps://ads.test/remote.ts\n#EXT-X-ENDLIST\n",
+	"480p/0.ts":       "480p segment 0",
+	"audio/en.m3u8":   "#EXTM3U\n#EXT-X-MAP:URI=\"init.mp4\"\n#EXTINF:4,\nen0.aac\n#EXT-X-ENDLIST\n",
+	"audio/init.mp4":  "audio init",
+	"audio/en0.aac":   "audio segment 0",
+	"unreferenced.ts": "never listed by a playlist",
+	"broken.m3u8":     "#EXTM3U\n#EXTINF:4,\nmissing.ts\n#EXT-X-ENDLIST\n",
+}
+
+// serveHLSTree serves hlsTree under /movies/night/ and records the paths requested
+// with GET.
+func serveHLSTree(t *testing.T) (*httptest.Server, func() []string) {
+	t.Helper()
+	src := t.TempDir()
+	files := make(map[string]string)
+	for name, content := range hlsTree {
+		files["movies/night/"+name] = content
+	}
+	writeFiles(t, src, files)
+	var mu sync.Mutex
+	var fetched []string
+	fileServer := http.FileServer(http.Dir(src))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			mu.Lock()
+			fetched = append(fetched, r.URL.Path)
+			mu.Unlock()
+		}
+		fileServer.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), fetched...)
+	}
+}
+
+func TestDownloadHLSDirectoryMirrorsTree(t *testing.T) {
+	server, fetched := serveHLSTree(t)
+	client := ApiClient.NewWithHTTPClient(&config.Config{}, "test-token",
+		ApiClient.NewRestyAdapter(ApiClient.DefaultTransportTimeouts(), ApiClient.DefaultRedirectSettings()))
+	dest := filepath.Join(t.TempDir(), "hls")
+
+	if err := DownloadHLSDirectory(client, server.URL+"/movies/night/master.m3u8", dest, "master_hls.m3u8"); err != nil {
+		t.Fatalf("DownloadHLSDirectory: %v", err)
+	}
+
+	want := map[string]string{"master_hls.m3u8": hlsTree["master.m3u8"]}
+	for _, name := range []string{"720p/index.m3u8", "720p/seg/0.ts", "720p/seg/1.ts", "480p/index.m3u8",
+		"480p/0.ts", "audio/en.m3u8", "audio/init.mp4", "audio/en0.aac"} {
+		want[name] = hlsTree[name]
+	}
+	got := make(map[string]string)
+	err := filepath.WalkDir(dest, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dest, path)
+		content, err := os.ReadFile(path)
+		got[filepath.ToSlash(rel)] = string(content)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("downloaded %d files, want %d: %v", len(got), len(want), got)
+	}
+	for name, content := range want {
+		if got[name] != content {
+			t.Errorf("%s = %q, want %q", name, got[name], content)
+		}
+	}
+	for _, path := range fetched() {
+		if path == "/movies/night/unreferenced.ts" {
+			t.Errorf("fetched %s, which no playlist refers to", path)
+		}
+	}
+}
+
+func TestDownloadHLSDirectoryFailsOnMissingSegment(t *testing.T) {
+	server, _ := serveHLSTree(t)
+	client := ApiClient.NewWithHTTPClient(&config.Config{}, "test-token",
+		ApiClient.NewRestyAdapter(ApiClient.DefaultTransportTimeouts(), ApiClient.DefaultRedirectSettings()))
+	dest := filepath.Join(t.TempDir(), "hls")
+
+	if err := DownloadHLSDirectory(client, server.URL+"/movies/night/broken.m3u8", dest, "master_hls.m3u8"); err == nil {
+		t.Fatal("DownloadHLSDirectory of a playlist naming a missing segment succeeded")
+	}
+}
+
+// serveHLSMovie makes the APIs serve the movie of movie_detail.json as a local-movie
+// item whose file link is fileLink, with hlsTree served under cdn.test/movies/night/.
+func (p *pipeline) serveHLSMovie(t *testing.T, fileLink string) {
+	t.Helper()
+	for name, content := range hlsTree {
+		p.server.setFile("https://cdn.test/movies/night/"+name, []byte(content))
+	}
+	for url, content := range movieImages {
+		p.server.setFile(url, content)
+	}
+	p.server.setJSON(testDetailURL+"/77", readFixture(t, "movie_detail.json"))
+	p.serveItems(t, []feedItem{{ID: 201, Type: "local-movie", UpdatedAt: 1700000200000, Enable: true,
+		Content: map[string]any{"fileLink": fileLink, "movieId": 77}}})
+}
+
+func TestPipelineRoutesMovieLinks(t *testing.T) {
+	const hlsURL = "https://cdn.test/movies/night/master.m3u8"
+	const zipURL = "https://cdn.test/movies/night-train.zip"
+
+	t.Run("m3u8 is downloaded as an HLS directory", func(t *testing.T) {
+		p := newPipeline(t)
+		p.serveHLSMovie(t, hlsURL)
+		p.server.setFile(zipURL, []byte("never downloaded"))
+
+		if summary := p.runCycle(t); summary.Created != 1 || summary.Failed != 0 {
+			t.Fatalf("summary = %+v", summary)
+		}
+		movie := SharedModels.Movie{ContentId: 201}
+		if err := p.db.First(context.Background(), &movie); err != nil {
+			t.Fatalf("movie not saved: %v", err)
+		}
+		dir := SharedModels.CalculateStringMD5(hlsURL)
+		if want := filepath.Join(dir, HLS_DIRECTORY_SUBDIR, "master_hls.m3u8"); movie.Link.PlayLink != want {
+			t.Fatalf("play link = %s, want %s", movie.Link.PlayLink, want)
+		}
+		segment := filepath.Join(ContentBasePath(), "videos", dir, HLS_DIRECTORY_SUBDIR, "720p", "seg", "1.ts")
+		if got, err := os.ReadFile(segment); err != nil || string(got) != hlsTree["720p/seg/1.ts"] {
+			t.Fatalf("segment = %q, %v", got, err)
+		}
+		if p.server.downloadCount(hlsURL) != 1 || p.server.downloadCount(zipURL) != 0 {
+			t.Fatalf("downloads: master %d, zip %d; want 1 and 0",
+				p.server.downloadCount(hlsURL), p.server.downloadCount(zipURL))
+		}
+	})
+
+	t.Run("zip is downloaded and extracted", func(t *testing.T) {
+		p := newPipeline(t)
+		p.serveMovie(t, movieFiles)
+
+		if summary := p.runCycle(t); summary.Created != 1 || summary.Failed != 0 {
+			t.Fatalf("summary = %+v", summary)
+		}
+		if p.server.downloadCount(zipURL) != 1 || p.server.downloadCount(hlsURL) != 0 {
+			t.Fatalf("downloads: zip %d, master %d; want 1 and 0",
+				p.server.downloadCount(zipURL), p.server.downloadCount(hlsURL))
+		}
+		if _, err := os.Stat(filepath.Join(ContentBasePath(), "videos", SharedModels.CalculateStringMD5(hlsURL))); !os.IsNotExist(err) {
+			t.Fatalf("an HLS directory was created for a zipped movie: %v", err)
+		}
+	})
+}
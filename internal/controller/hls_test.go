@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"context"
+	"embedup-go/configs/config"
+	"embedup-go/internal/cstmerr"
+	SharedModels "embedup-go/internal/shared"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles writes files, keyed by slash-separated path, under dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDiscoverMasterPlaylist(t *testing.T) {
+	const master = "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\n720p/index.m3u8\n"
+	const media = "#EXTM3U\n#EXTINF:4,\nsegment0.ts\n"
+	tests := []struct {
+		name   string
+		files  map[string]string
+		want   string
+		wantOK bool
+	}{
+		{"pattern named master", map[string]string{
+			"master_hls.m3u8": master, "variant.m3u8": media, "segment0.ts": "ts"}, "master_hls.m3u8", true},
+		{"variants in subdirectories", map[string]string{
+			"index.m3u8": master, "720p/index.m3u8": media, "720p/segment0.ts": "ts"}, "index.m3u8", true},
+		{"upper-case extension", map[string]string{
+			"Playlist.M3U8": master, "variant.m3u8": media}, "Playlist.M3U8", true},
+		{"media playlists only", map[string]string{"variant.m3u8": media}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+			if got, ok := discoverMasterPlaylist(dir); got != tt.want || ok != tt.wantOK {
+				t.Fatalf("discoverMasterPlaylist = %q, %t; want %q, %t", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestMasterPlaylistName(t *testing.T) {
+	withSettings(t, nil)
+	if got := masterPlaylistName("hls"); got != "master_hls.m3u8" {
+		t.Fatalf("default name = %q, want master_hls.m3u8", got)
+	}
+	withSettings(t, func(cfg *config.Config) { cfg.MasterPlaylistPattern = "{dir}/playlist.m3u8" })
+	if got := masterPlaylistName("hls"); got != "hls/playlist.m3u8" {
+		t.Fatalf("configured name = %q, want hls/playlist.m3u8", got)
+	}
+}
+
+func TestValidateHLSPackage(t *testing.T) {
+	tests := []struct {
+		name    string
+		missing string
+	}{
+		{"complete", ""},
+		{"missing variant", "hls/variant.m3u8"},
+		{"missing segment", "hls/segment0.ts"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, movieFiles)
+			if tt.missing != "" {
+				if err := os.Remove(filepath.Join(dir, filepath.FromSlash(tt.missing))); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := validateHLSPackage(filepath.Join(dir, "hls", "master_hls.m3u8"))
+			var processErr *cstmerr.ProcessError
+			if tt.missing == "" && err != nil {
+				t.Fatalf("validateHLSPackage: %v", err)
+			}
+			if tt.missing != "" && !errors.As(err, &processErr) {
+				t.Fatalf("validateHLSPackage error = %v, want a ProcessError", err)
+			}
+		})
+	}
+}
+
+func TestPipelineValidatesHLSPackage(t *testing.T) {
+	partial := map[string]string{
+		"hls/master_hls.m3u8": movieFiles["hls/master_hls.m3u8"],
+		"hls/variant.m3u8":    movieFiles["hls/variant.m3u8"],
+	}
+	tests := []struct {
+		name     string
+		validate bool
+		saved    bool
+	}{
+		{"validated", true, false},
+		{"not validated", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withSettings(t, func(cfg *config.Config) { cfg.ValidateHLS = tt.validate })
+			p := newPipeline(t)
+			p.serveMovie(t, partial)
+
+			summary, _ := p.app.RunCycle(context.Background())
+			if saved := p.db.rows(&SharedModels.Movie{}) == 1; saved != tt.saved {
+				t.Fatalf("movie saved = %t, want %t (summary %+v)", saved, tt.saved, summary)
+			}
+			if !tt.saved && (summary.Failed != 1 || summary.NewWatermark != 0) {
+				t.Fatalf("summary = %+v, want the partial movie failed and fetched again", summary)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package controller
+
+import (
+	SharedModels "embedup-go/internal/shared"
+	"log"
+	"sync"
+)
+
+// ProcessHook is notified of every content item processed successfully, for side
+// effects such as telling a local service that a new movie landed. OnProcessed runs
+// on the processing goroutine, so it should return quickly.
+type ProcessHook interface {
+	OnProcessed(item SharedModels.ProcessedContentSchema, result ProcessResult)
+}
+
+// ProcessHookFunc adapts a function to a ProcessHook.
+type ProcessHookFunc func(item SharedModels.ProcessedContentSchema, result ProcessResult)
+
+func (f ProcessHookFunc) OnProcessed(item SharedModels.ProcessedContentSchema, result ProcessResult) {
+	f(item, result)
+}
+
+var (
+	processHooksMu sync.RWMutex
+	processHooks   []ProcessHook
+)
+
+// RegisterProcessHook adds hook to those called after each successfully processed item.
+func RegisterProcessHook(hook ProcessHook) {
+	processHooksMu.Lock()
+	defer processHooksMu.Unlock()
+	processHooks = append(processHooks, hook)
+}
+
+// runProcessHooks calls the registered hooks in registration order. A hook that
+// panics is logged and skipped; it doesn't fail the item.
+func runProcessHooks(item SharedModels.ProcessedContentSchema, result ProcessResult) {
+	processHooksMu.RLock()
+	hooks := processHooks
+	processHooksMu.RUnlock()
+	for _, hook := range hooks {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("Process hook %T panicked on item %d: %v", hook, item.ID, r)
+				}
+			}()
+			hook.OnProcessed(item, result)
+		}()
+	}
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"context"
+	SharedModels "embedup-go/internal/shared"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// recordingHook keeps every item and result it is notified of.
+type recordingHook struct {
+	mu      sync.Mutex
+	items   []SharedModels.ProcessedContentSchema
+	results []ProcessResult
+}
+
+func (h *recordingHook) OnProcessed(item SharedModels.ProcessedContentSchema, result ProcessResult) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.items = append(h.items, item)
+	h.results = append(h.results, result)
+}
+
+// withProcessHooks registers only hooks for the duration of the test.
+func withProcessHooks(t *testing.T, hooks ...ProcessHook) {
+	t.Helper()
+	processHooksMu.Lock()
+	saved := processHooks
+	processHooks = nil
+	processHooksMu.Unlock()
+	for _, hook := range hooks {
+		RegisterProcessHook(hook)
+	}
+	t.Cleanup(func() {
+		processHooksMu.Lock()
+		defer processHooksMu.Unlock()
+		processHooks = saved
+	})
+}
+
+func TestProcessHookReceivesItemAndResult(t *testing.T) {
+	hook := &recordingHook{}
+	withProcessHooks(t, hook)
+	p := newPipeline(t)
+	p.adItem(5, true)
+	content := SharedModels.ProcessedContentSchema{ID: 5, Type: "local-advertisement", UpdatedAt: 5000, Enable: true,
+		Details: SharedModels.LocalAdvertisementSchema{FileLink: "https://cdn.test/ads/5.mp4", SkipDuration: 5}}
+
+	result, err := ProcessContentItem(context.Background(), content, p.db, p.app.API)
+	if err != nil {
+		t.Fatalf("ProcessContentItem: %v", err)
+	}
+	if len(hook.items) != 1 {
+		t.Fatalf("hook called %d times, want once", len(hook.items))
+	}
+	if !reflect.DeepEqual(hook.items[0], content) {
+		t.Fatalf("hook item = %+v, want %+v", hook.items[0], content)
+	}
+	if !reflect.DeepEqual(hook.results[0], result) {
+		t.Fatalf("hook result = %+v, want %+v", hook.results[0], result)
+	}
+	if result.EntityID != 5 || result.Action != PROCESS_ACTION_SAVE || !result.Created {
+		t.Fatalf("result = %+v", result)
+	}
+}
+
+func TestProcessHookSkipsFailedItems(t *testing.T) {
+	hook := &recordingHook{}
+	withProcessHooks(t, hook)
+	p := newPipeline(t)
+	p.db.failSave[2] = true
+	p.serveItems(t, []feedItem{p.adItem(1, true), p.adItem(2, true)})
+
+	p.app.RunCycle(context.Background())
+	if len(hook.items) != 1 || hook.items[0].ID != 1 {
+		t.Fatalf("hook notified of %+v, want only item 1", hook.items)
+	}
+}
+
+func TestPanickingProcessHookDoesNotAbortItem(t *testing.T) {
+	after := &recordingHook{}
+	withProcessHooks(t,
+		ProcessHookFunc(func(SharedModels.ProcessedContentSchema, ProcessResult) { panic("hook bug") }),
+		after)
+	p := newPipeline(t)
+	p.serveItems(t, []feedItem{p.adItem(1, true), p.adItem(2, true)})
+
+	summary := p.runCycle(t)
+	if summary.Processed != 2 || summary.Failed != 0 {
+		t.Fatalf("summary = %+v, want both items processed", summary)
+	}
+	if p.db.rows(&SharedModels.Advertisement{}) != 2 {
+		t.Fatal("advertisements not saved")
+	}
+	if len(after.items) != 2 {
+		t.Fatalf("hook after the panicking one called %d times, want 2", len(after.items))
+	}
+}
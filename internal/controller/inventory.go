@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"context"
+	"embedup-go/internal/cstmerr"
+	"embedup-go/internal/dbclient"
+	SharedModels "embedup-go/internal/shared"
+	"path/filepath"
+	"reflect"
+	"time"
+)
+
+// InventoryEntry describes one content row stored on the device.
+type InventoryEntry struct {
+	ContentId int64            `json:"contentId"`
+	Type      string           `json:"type"`
+	Hash      string           `json:"hash,omitempty"` // Hash of the primary asset, if any
+	Assets    []InventoryAsset `json:"assets,omitempty"`
+}
+
+// InventoryAsset is an asset of an InventoryEntry on disk.
+type InventoryAsset struct {
+	Kind string `json:"kind"`           // One of the ASSET_KIND_* values
+	Path string `json:"path"`           // Under the content base path
+	Size int64  `json:"size,omitempty"` // As recorded when downloaded; 0 if unknown
+}
+
+// inventoryModels maps each synced content type to a pointer to an empty slice of its model.
+var inventoryModels = []struct {
+	contentType string
+	newSlice    func() interface{}
+}{
+	{"local-advertisement", func() interface{} { return &[]SharedModels.Advertisement{} }},
+	{"local-movie", func() interface{} { return &[]SharedModels.Movie{} }},
+	{"local-movie-genre", func() interface{} { return &[]SharedModels.Genre{} }},
+	{"local-slider", func() interface{} { return &[]SharedModels.Slider{} }},
+	{"local-section", func() interface{} { return &[]SharedModels.Section{} }},
+	{"local-tab", func() interface{} { return &[]SharedModels.Tab{} }},
+	{"local-page", func() interface{} { return &[]SharedModels.Page{} }},
+	{"local-poll", func() interface{} { return &[]SharedModels.Poll{} }},
+}
+
+// ExportInventory lists every stored content row with its type, primary asset hash and
+// asset paths and sizes, so it can be diffed against the server's expected state to find drift.
+func ExportInventory(dbConnection dbclient.DBClient) ([]InventoryEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var metas []SharedModels.AssetMeta
+	if err := dbConnection.Find(ctx, &metas); err != nil {
+		return nil, cstmerr.NewDBQueryError("failed to list asset sizes", err)
+	}
+	sizes := make(map[string]int64, len(metas))
+	for _, meta := range metas {
+		sizes[meta.Path] = meta.Size
+	}
+
+	var inventory []InventoryEntry
+	for _, m := range inventoryModels {
+		rows := m.newSlice()
+		if err := dbConnection.Find(ctx, rows); err != nil {
+			return inventory, cstmerr.NewDBQueryError("failed to list "+m.contentType, err)
+		}
+		slice := reflect.ValueOf(rows).Elem()
+		for i := 0; i < slice.Len(); i++ {
+			model := slice.Index(i).Addr().Interface()
+			entry := InventoryEntry{
+				ContentId: slice.Index(i).FieldByName("ContentId").Int(),
+				Type:      m.contentType,
+				Hash:      inventoryHash(model),
+			}
+			for _, asset := range contentAssets(model) {
+				dir := "images"
+				if asset.kind != ASSET_KIND_IMAGE {
+					dir = "videos"
+				}
+				path := filepath.Join(dir, asset.relPath)
+				entry.Assets = append(entry.Assets, InventoryAsset{
+					Kind: asset.kind,
+					Path: path,
+					Size: sizes[path],
+				})
+			}
+			inventory = append(inventory, entry)
+		}
+	}
+	return inventory, nil
+}
+
+// inventoryHash returns the hash stored for a model's primary asset, if it has one.
+func inventoryHash(model interface{}) string {
+	switch m := model.(type) {
+	case *SharedModels.Advertisement:
+		return m.Link.FileHash
+	case *SharedModels.Movie:
+		return m.Link.FileHash
+	}
+	return ""
+}
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"cmp"
+	"context"
+	"crypto/md5"
+	"embedup-go/internal/cstmerr"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestExportInventoryListsSyncedContent(t *testing.T) {
+	p := newPipeline(t)
+	p.serveItems(t, []feedItem{p.adItem(1, true), p.adItem(2, true)})
+	p.runCycle(t)
+
+	inventory, err := ExportInventory(p.db)
+	if err != nil {
+		t.Fatalf("ExportInventory: %v", err)
+	}
+	var want []InventoryEntry
+	for id := int64(1); id <= 2; id++ {
+		content, _ := p.server.file(p.adItem(id, true).Content["fileLink"].(string))
+		sum := md5.Sum(content)
+		hash := hex.EncodeToString(sum[:])
+		want = append(want, InventoryEntry{ContentId: id, Type: "local-advertisement", Hash: hash,
+			Assets: []InventoryAsset{{Kind: ASSET_KIND_VIDEO, Path: filepath.Join("videos", "ads", hash+".mp4"),
+				Size: int64(len(content))}}})
+	}
+	if !reflect.DeepEqual(sortedInventory(inventory), want) {
+		t.Fatalf("inventory = %+v, want %+v", inventory, want)
+	}
+}
+
+func TestExportInventoryQueryError(t *testing.T) {
+	_, err := ExportInventory(&failingFindDB{})
+	var queryErr *cstmerr.DBQueryError
+	if !errors.As(err, &queryErr) {
+		t.Fatalf("ExportInventory = %v, want a DBQueryError", err)
+	}
+}
+
+// failingFindDB fails every Find.
+type failingFindDB struct{ emptyDB }
+
+func (*failingFindDB) Find(ctx context.Context, dest interface{}, conditions ...interface{}) error {
+	return errors.New("connection reset")
+}
+
+// sortedInventory orders entries by content ID, since memDB lists rows in no order.
+func sortedInventory(inventory []InventoryEntry) []InventoryEntry {
+	sorted := append([]InventoryEntry(nil), inventory...)
+	slices.SortFunc(sorted, func(a, b InventoryEntry) int { return cmp.Compare(a.ContentId, b.ContentId) })
+	return sorted
+}
@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"sync"
+	"time"
+)
+
+// watermarkLag tracks how far the local watermark is behind the newest content
+// timestamp seen from the server; it is safe for concurrent use.
+type watermarkLag struct {
+	mu     sync.Mutex
+	newest int64 // Newest content timestamp seen, in Unix milliseconds
+	lag    time.Duration
+}
+
+var contentWatermarkLag = &watermarkLag{}
+
+// record notes the newest timestamp of a fetched batch and returns the lag of watermark
+// behind the newest timestamp seen so far. A batch only shows the server's content up
+// to its size, so the lag is a lower bound while a backlog remains.
+func (wl *watermarkLag) record(batchNewest int64, watermark int64) time.Duration {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+	if batchNewest > wl.newest {
+		wl.newest = batchNewest
+	}
+	wl.lag = 0
+	if wl.newest > watermark {
+		wl.lag = time.Duration(wl.newest-watermark) * time.Millisecond
+	}
+	return wl.lag
+}
+
+// WatermarkLag returns how far the local watermark was behind the newest content on
+// the server after the last sync cycle, as served by MetricsHandler.
+func WatermarkLag() time.Duration {
+	contentWatermarkLag.mu.Lock()
+	defer contentWatermarkLag.mu.Unlock()
+	return contentWatermarkLag.lag
+}
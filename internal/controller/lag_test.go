@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"context"
+	"embedup-go/configs/config"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withFreshLag gives the test a watermark lag tracker of its own.
+func withFreshLag(t *testing.T) {
+	t.Helper()
+	previous := contentWatermarkLag
+	contentWatermarkLag = &watermarkLag{}
+	t.Cleanup(func() { contentWatermarkLag = previous })
+}
+
+func TestWatermarkLagRecordsMilliseconds(t *testing.T) {
+	wl := &watermarkLag{}
+	if lag := wl.record(1700003600000, 1700000000000); lag != time.Hour {
+		t.Fatalf("lag = %v, want 1h between timestamps an hour apart in milliseconds", lag)
+	}
+	// An older batch doesn't lower the newest timestamp seen.
+	if lag := wl.record(1700000000000, 1700001800000); lag != 30*time.Minute {
+		t.Fatalf("lag = %v, want 30m", lag)
+	}
+	if lag := wl.record(0, 1700003600000); lag != 0 {
+		t.Fatalf("lag = %v, want 0 once the watermark caught up", lag)
+	}
+}
+
+func TestPipelineWatermarkLag(t *testing.T) {
+	tests := []struct {
+		name    string
+		failing bool
+		lagging bool
+		metric  string
+	}{
+		{"caught up", false, false, "podbox_content_watermark_lag_seconds 0\n"},
+		{"behind", true, true, "podbox_content_watermark_lag_seconds 3\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withFreshLag(t)
+			withSettings(t, func(cfg *config.Config) { cfg.WatermarkLagWarning = time.Second })
+			p := newPipeline(t)
+			p.serveAds(t, 4)
+			// A failed first item keeps the watermark at 1s, 3s behind the newest item.
+			p.app.Updater.LastFromTimeStamp = 1000
+			p.db.failSave[1] = tt.failing
+
+			summary, _ := p.app.RunCycle(context.Background())
+			if summary.Lagging != tt.lagging {
+				t.Fatalf("summary = %+v, want lagging %t", summary, tt.lagging)
+			}
+
+			recorder := httptest.NewRecorder()
+			MetricsHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+			if body := recorder.Body.String(); !strings.Contains(body, tt.metric) {
+				t.Fatalf("metrics = %q, want %q", body, tt.metric)
+			}
+		})
+	}
+}
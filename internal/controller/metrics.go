@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+)
+
+// MetricsHandler serves the content sync metrics in the Prometheus text format: the
+// watermark lag and the per-content-type processing outcomes.
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeMetrics(w)
+	})
+}
+
+func writeMetrics(w io.Writer) {
+	fmt.Fprintln(w, "# HELP podbox_content_watermark_lag_seconds How far the content watermark is behind the newest content seen on the server.")
+	fmt.Fprintln(w, "# TYPE podbox_content_watermark_lag_seconds gauge")
+	fmt.Fprintf(w, "podbox_content_watermark_lag_seconds %g\n", WatermarkLag().Seconds())
+
+	stats := TypeStats()
+	types := make([]string, 0, len(stats))
+	for contentType := range stats {
+		types = append(types, contentType)
+	}
+	sort.Strings(types)
+	fmt.Fprintln(w, "# HELP podbox_content_items_total Content items processed, by type and outcome.")
+	fmt.Fprintln(w, "# TYPE podbox_content_items_total counter")
+	for _, contentType := range types {
+		fmt.Fprintf(w, "podbox_content_items_total{type=%q,outcome=\"succeeded\"} %d\n", contentType, stats[contentType].Succeeded)
+		fmt.Fprintf(w, "podbox_content_items_total{type=%q,outcome=\"failed\"} %d\n", contentType, stats[contentType].Failed)
+	}
+	fmt.Fprintln(w, "# HELP podbox_content_last_success_timestamp_seconds When an item of the type was last processed successfully.")
+	fmt.Fprintln(w, "# TYPE podbox_content_last_success_timestamp_seconds gauge")
+	for _, contentType := range types {
+		if last := stats[contentType].LastSuccess; !last.IsZero() {
+			fmt.Fprintf(w, "podbox_content_last_success_timestamp_seconds{type=%q} %d\n", contentType, last.Unix())
+		}
+	}
+}
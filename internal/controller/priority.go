@@ -0,0 +1,72 @@
+package controller
+
+import (
+	SharedModels "embedup-go/internal/shared"
+	"sort"
+)
+
+// defaultPriorityOrder lists content types from the first to be synced to the last:
+// device updates, then the media the device serves, then the UI that links to it.
+var defaultPriorityOrder = []string{
+	"local-device-update",
+	"local-movie",
+	"local-series",
+	"local-series-season",
+	"local-series-episode",
+	"local-music",
+	"local-album",
+	"local-podcastparent",
+	"local-podcast",
+	"local-audiobookparent",
+	"local-audiobook",
+	"local-advertisement",
+	"local-movie-genre",
+	"local-page",
+	"local-tab",
+	"local-section",
+	"local-section-content",
+	"local-slider",
+	"local-poll",
+	"local-terms-conditions",
+}
+
+// priorityOrder returns the content types in sync order: the configured
+// priority_order, or defaultPriorityOrder if it is empty.
+func priorityOrder() []string {
+	if len(settings.PriorityOrder) > 0 {
+		return settings.PriorityOrder
+	}
+	return defaultPriorityOrder
+}
+
+// sortByPriority orders items so the device becomes usable as early as possible in a
+// large sync: enabled items by their type's position in priorityOrder, types not
+// listed after those, and disabled items, which only clean up, last. The server order
+// is kept within a rank.
+func sortByPriority(items []SharedModels.ProcessedContentSchema) {
+	order := priorityOrder()
+	rank := make(map[string]int, len(order))
+	for i, contentType := range order {
+		rank[contentType] = i
+	}
+	itemRank := func(item SharedModels.ProcessedContentSchema) int {
+		if !item.Enable {
+			return len(order) + 1
+		}
+		if r, ok := rank[item.Type]; ok {
+			return r
+		}
+		return len(order)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return itemRank(items[i]) < itemRank(items[j])
+	})
+}
+
+// sortDeletesFirst moves disabled items ahead of enabled ones, keeping the order
+// within each.
+func sortDeletesFirst(items []SharedModels.ProcessedContentSchema) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return !items[i].Enable && items[j].Enable
+	})
+}
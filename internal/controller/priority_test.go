@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"embedup-go/configs/config"
+	SharedModels "embedup-go/internal/shared"
+	"reflect"
+	"testing"
+)
+
+func TestBatchOrdering(t *testing.T) {
+	tests := []struct {
+		name         string
+		prioritySort bool
+		deletesFirst bool
+		want         []int64
+	}{
+		{"server order", false, false, []int64{2, 1, 3}},
+		{"priority sort", true, false, []int64{2, 3, 1}},
+		{"deletes first", false, true, []int64{1, 2, 3}},
+		{"deletes first over priority sort", true, true, []int64{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withSettings(t, func(cfg *config.Config) {
+				cfg.PrioritySort = tt.prioritySort
+				cfg.DeletesFirst = tt.deletesFirst
+			})
+			p := newPipeline(t)
+			p.serveItems(t, []feedItem{p.adItem(1, true)})
+			p.runCycle(t)
+
+			p.db.written = nil
+			p.serveItems(t, []feedItem{p.adItem(2, true), p.adItem(1, false), p.adItem(3, true)})
+			p.runCycle(t)
+			if !reflect.DeepEqual(p.db.written, tt.want) {
+				t.Fatalf("items written in order %v, want %v", p.db.written, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortByPriority(t *testing.T) {
+	batch := func() []SharedModels.ProcessedContentSchema {
+		return []SharedModels.ProcessedContentSchema{
+			{ID: 1, Type: "local-slider", Enable: true},
+			{ID: 2, Type: "local-movie", Enable: false},
+			{ID: 3, Type: "local-news", Enable: true},
+			{ID: 4, Type: "local-tab", Enable: true},
+			{ID: 5, Type: "local-movie", Enable: true},
+			{ID: 6, Type: "local-device-update", Enable: true},
+		}
+	}
+	ids := func(items []SharedModels.ProcessedContentSchema) []int64 {
+		var ids []int64
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name  string
+		order []string
+		want  []int64
+	}{
+		// Device update, media, UI, types not listed, then disabled items.
+		{"default order", nil, []int64{6, 5, 4, 1, 3, 2}},
+		{"configured order", []string{"local-slider", "local-tab"}, []int64{1, 4, 3, 5, 6, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withSettings(t, func(cfg *config.Config) { cfg.PriorityOrder = tt.order })
+			items := batch()
+			sortByPriority(items)
+			if got := ids(items); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"embedup-go/internal/cstmerr"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+)
+
+// probeHeadBytes is how much of an asset is read to check that it can be opened.
+const probeHeadBytes = 512
+
+// probeAssets reads back the start of a random sample of up to sampleSize of paths and
+// returns the ones that could not be read.
+func probeAssets(paths []string, sampleSize int) []string {
+	var unreadable []string
+	for _, i := range rand.Perm(len(paths))[:min(sampleSize, len(paths))] {
+		if err := probeAsset(paths[i]); err != nil {
+			log.Printf("Asset probe failed for %s: %v", paths[i], err)
+			unreadable = append(unreadable, paths[i])
+		}
+	}
+	return unreadable
+}
+
+// probeAsset checks that the first bytes of an asset can be read. An extracted HLS
+// movie directory is checked through its master playlist.
+func probeAsset(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return cstmerr.NewFileIOError(fmt.Sprintf("failed to stat asset %s", path), err)
+	}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return cstmerr.NewFileIOError(fmt.Sprintf("failed to read asset directory %s", path), err)
+		}
+		var hlsDir string
+		for _, entry := range entries {
+			if entry.IsDir() {
+				hlsDir = filepath.Join(path, entry.Name())
+			}
+		}
+		masterName, found := discoverMasterPlaylist(hlsDir)
+		if hlsDir == "" || !found {
+			return cstmerr.NewFileIOError(fmt.Sprintf("no master playlist under %s", path), nil)
+		}
+		path = filepath.Join(hlsDir, masterName)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return cstmerr.NewFileIOError(fmt.Sprintf("failed to open asset %s", path), err)
+	}
+	defer file.Close()
+	n, err := io.ReadFull(file, make([]byte, probeHeadBytes))
+	if n == 0 {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return cstmerr.NewFileIOError(fmt.Sprintf("failed to read asset %s", path), err)
+	}
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return cstmerr.NewFileIOError(fmt.Sprintf("failed to read asset %s", path), err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"embedup-go/configs/config"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestProbeAssets(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	readable := write("readable.mp4", "a readable video")
+	truncated := write("truncated.mp4", "")
+	write("movie/hls/master_hls.m3u8", "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\n720p.m3u8\n")
+	write("broken/hls/720p.m3u8", "#EXTM3U\n")
+	movie := filepath.Join(dir, "movie")
+	broken := filepath.Join(dir, "broken")
+	missing := filepath.Join(dir, "missing.jpg")
+
+	paths := []string{readable, truncated, movie, broken, missing}
+	got := probeAssets(paths, len(paths))
+	want := []string{truncated, broken, missing}
+	sort.Strings(got)
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unreadable = %v, want %v", got, want)
+	}
+	if got := probeAssets(paths, 1); len(got) > 1 {
+		t.Fatalf("probed %d unreadable assets with a sample of 1", len(got))
+	}
+}
+
+func TestPipelineProbesSavedAssets(t *testing.T) {
+	withSettings(t, func(cfg *config.Config) { cfg.ProbeSampleSize = 10 })
+	p := newPipeline(t)
+	p.server.setFile(adURL, adContent)
+	p.serveFeed(t, "feed_advertisement_enabled.json")
+
+	if summary := p.runCycle(t); summary.Processed != 1 || len(summary.Unreadable) != 0 {
+		t.Fatalf("summary = %+v, want the saved advertisement probed as readable", summary)
+	}
+}
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"context"
+	"embedup-go/internal/cstmerr"
+	"embedup-go/internal/dbclient"
+	SharedModels "embedup-go/internal/shared"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// PURGE_ALL_CONFIRMATION must be passed to PurgeAll, so a wipe is never triggered by accident.
+const PURGE_ALL_CONFIRMATION = "delete-all-local-content"
+
+// PurgeAll wipes all synced content for a factory reset: it empties every content table
+// and the recorded asset sizes, removes the images, videos and audios directories and
+// the catch-up journal, and resets updater's watermark to 0 so the next cycle syncs
+// everything again. Unlike
+// reconciling, nothing is repaired. It refuses to run unless confirmation is
+// PURGE_ALL_CONFIRMATION.
+func PurgeAll(dbConnection dbclient.DBClient, updater *SharedModels.Updater, confirmation string) error {
+	if confirmation != PURGE_ALL_CONFIRMATION {
+		return cstmerr.NewConfigError("refusing to purge all content without confirmation", nil)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	log.Println("Purging all local content...")
+	err := dbConnection.RunInTransaction(ctx, func(ctx context.Context, txClient dbclient.DBClient) error {
+		tables := append([]any{&SharedModels.AssetMeta{}}, SharedModels.AutoMigrateList...)
+		if err := txClient.Truncate(ctx, tables...); err != nil {
+			return err
+		}
+		reset := *updater
+		reset.LastFromTimeStamp = 0
+		return txClient.Save(ctx, &reset)
+	})
+	if err != nil {
+		return cstmerr.NewDBTransactionError("failed to purge content tables", err)
+	}
+	updater.LastFromTimeStamp = 0
+
+	for _, dir := range []string{"images", "videos", "audios"} {
+		if err := assets().Delete(dir); err != nil && !os.IsNotExist(err) {
+			path := filepath.Join(ContentBasePath(), dir)
+			return cstmerr.NewFileDeleteError(fmt.Sprintf("failed to delete directory: %s", path), err)
+		}
+	}
+	catchUpMu.Lock()
+	defer catchUpMu.Unlock()
+	if err := os.Remove(catchUpFile()); err != nil && !os.IsNotExist(err) {
+		return cstmerr.NewFileDeleteError(fmt.Sprintf("failed to delete file: %s", catchUpFile()), err)
+	}
+	log.Println("All local content purged.")
+	return nil
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"context"
+	"embedup-go/internal/cstmerr"
+	"embedup-go/internal/dbclient"
+	SharedModels "embedup-go/internal/shared"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// truncatingDB records the tables truncated and the updater saved in a transaction.
+type truncatingDB struct {
+	emptyDB
+	truncated []interface{}
+	saved     *SharedModels.Updater
+}
+
+func (db *truncatingDB) RunInTransaction(ctx context.Context, fn func(ctx context.Context, txClient dbclient.DBClient) error) error {
+	return fn(ctx, db)
+}
+
+func (db *truncatingDB) Truncate(ctx context.Context, models ...interface{}) error {
+	db.truncated = append(db.truncated, models...)
+	return nil
+}
+
+func (db *truncatingDB) Save(ctx context.Context, model interface{}) error {
+	db.saved = model.(*SharedModels.Updater)
+	return nil
+}
+
+func TestPurgeAll(t *testing.T) {
+	base := t.TempDir()
+	t.Setenv("PODBOX_UPDATE_CONTENT_BASE_PATH", base)
+	withSettings(t, nil)
+	if err := EnsureContentDirs(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "images", "a.jpg"), []byte("image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	db := &truncatingDB{}
+	updater := &SharedModels.Updater{LastFromTimeStamp: 5000}
+
+	if err := PurgeAll(db, updater, PURGE_ALL_CONFIRMATION); err != nil {
+		t.Fatalf("PurgeAll: %v", err)
+	}
+	if len(db.truncated) != len(SharedModels.AutoMigrateList)+1 {
+		t.Fatalf("truncated %d tables, want %d", len(db.truncated), len(SharedModels.AutoMigrateList)+1)
+	}
+	if _, ok := db.truncated[0].(*SharedModels.AssetMeta); !ok {
+		t.Fatalf("asset_meta not truncated: %v", db.truncated)
+	}
+	if db.saved == nil || db.saved.LastFromTimeStamp != 0 || updater.LastFromTimeStamp != 0 {
+		t.Fatalf("watermark not reset: saved %+v, in memory %d", db.saved, updater.LastFromTimeStamp)
+	}
+	for _, dir := range []string{"images", "videos", "audios"} {
+		if _, err := os.Stat(filepath.Join(base, dir)); !os.IsNotExist(err) {
+			t.Fatalf("%s still exists: %v", dir, err)
+		}
+	}
+}
+
+func TestPurgeAllRequiresConfirmation(t *testing.T) {
+	db := &truncatingDB{}
+	updater := &SharedModels.Updater{LastFromTimeStamp: 5000}
+
+	err := PurgeAll(db, updater, "yes")
+	var configErr *cstmerr.ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("PurgeAll error = %v, want a ConfigError", err)
+	}
+	if len(db.truncated) != 0 || updater.LastFromTimeStamp != 5000 {
+		t.Fatal("nothing may be purged without confirmation")
+	}
+}
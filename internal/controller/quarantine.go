@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"embedup-go/internal/cstmerr"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var quarantineMu sync.Mutex
+
+// quarantineFile returns the file in the state directory listing content items that
+// could not be processed and were set aside. Each line holds a content ID followed by
+// the reason.
+func quarantineFile() string {
+	return statePath("quarantine")
+}
+
+// QuarantineContent records the content ID and why it was set aside.
+func QuarantineContent(id int64, reason string) error {
+	quarantineMu.Lock()
+	defer quarantineMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(quarantineFile()), 0755); err != nil {
+		return cstmerr.NewFileIOError(fmt.Sprintf("failed to create %s", filepath.Dir(quarantineFile())), err)
+	}
+	file, err := os.OpenFile(quarantineFile(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return cstmerr.NewFileIOError(fmt.Sprintf("failed to open %s", quarantineFile()), err)
+	}
+	defer file.Close()
+	reason = strings.Join(strings.Fields(reason), " ")
+	if _, err := fmt.Fprintf(file, "%d %s\n", id, reason); err != nil {
+		return cstmerr.NewFileIOError(fmt.Sprintf("failed to write %s", quarantineFile()), err)
+	}
+	return nil
+}
+
+// QuarantinedContentIDs returns the IDs of all quarantined content items.
+// A missing file means nothing is quarantined.
+func QuarantinedContentIDs() ([]int64, error) {
+	quarantineMu.Lock()
+	defer quarantineMu.Unlock()
+	data, err := os.ReadFile(quarantineFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, cstmerr.NewFileIOError(fmt.Sprintf("failed to read %s", quarantineFile()), err)
+	}
+	var ids []int64
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		id, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			log.Printf("Ignoring malformed quarantine entry %q", scanner.Text())
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, scanner.Err()
+}
+
+// quarantinedContent returns the quarantined content IDs as a set.
+func quarantinedContent() (map[int64]bool, error) {
+	ids, err := QuarantinedContentIDs()
+	set := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set, err
+}
@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"context"
+	"embedup-go/configs/config"
+	ApiClient "embedup-go/internal/apiclient"
+	SharedModels "embedup-go/internal/shared"
+	"testing"
+)
+
+func TestPanickingItemIsQuarantinedAndSkippedNextCycle(t *testing.T) {
+	withSettings(t, nil)
+	p := newPipeline(t)
+	p.serveAds(t, 3)
+	p.db.panicSave[2] = true
+
+	summary := p.runCycle(t)
+	if summary.Processed != 2 || summary.Failed != 1 || summary.NewWatermark != 3000 {
+		t.Fatalf("summary = %+v, want the panicking item quarantined and the rest processed", summary)
+	}
+	ids, err := QuarantinedContentIDs()
+	if err != nil || len(ids) != 1 || ids[0] != 2 {
+		t.Fatalf("quarantined = %v, %v; want [2]", ids, err)
+	}
+
+	delete(p.db.panicSave, 2)
+	summary = p.runCycle(t)
+	if summary.Processed != 2 || summary.Skipped != 1 || summary.Failed != 0 {
+		t.Fatalf("summary = %+v, want the quarantined item skipped", summary)
+	}
+	if err := p.db.First(context.Background(), &SharedModels.Advertisement{ContentId: 2}); err == nil {
+		t.Fatal("quarantined advertisement was saved")
+	}
+}
+
+func TestWrongDetailsTypeDoesNotStopTheLoop(t *testing.T) {
+	withSettings(t, nil)
+	p := newPipeline(t)
+	item := SharedModels.ProcessedContentSchema{ID: 7, Type: "local-movie", Enable: true,
+		Details: SharedModels.LocalAdvertisementSchema{}}
+
+	// The advertisement handler gets a local-movie item; without an API client to
+	// fetch the file it panics, and the panic is turned into a quarantine.
+	result, err := processContentItemSafely(context.Background(), item, p.db, nil)
+	if err == nil || result.Action != PROCESS_ACTION_QUARANTINE {
+		t.Fatalf("result = %+v, %v; want the item quarantined with an error", result, err)
+	}
+	if ids, _ := QuarantinedContentIDs(); len(ids) != 1 || ids[0] != 7 {
+		t.Fatalf("quarantined = %v, want [7]", ids)
+	}
+}
+
+func TestPanicsPropagateWhenRecoveryIsDisabled(t *testing.T) {
+	withSettings(t, func(cfg *config.Config) { cfg.RecoverPanics = false })
+	p := newPipeline(t)
+	p.serveAds(t, 1)
+	p.db.panicSave[1] = true
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("panic was recovered with recover_panics disabled")
+		}
+	}()
+	p.app.RunCycle(context.Background())
+}
+
+func TestUnknownTypeQuarantineIsNotAFailure(t *testing.T) {
+	withSettings(t, func(cfg *config.Config) { cfg.DegradedFailureRatio = 0.1 })
+	p := newPipeline(t)
+	cfg := &config.Config{ContentUpdateAPIURL: testUpdatesURL, ContentDetailAPIURL: testDetailURL,
+		UnknownTypePolicy: config.UNKNOWN_TYPE_POLICY_QUARANTINE}
+	p.app.API = ApiClient.NewWithHTTPClient(cfg, "test-token", p.server)
+	p.serveItems(t, []feedItem{p.adItem(1, true),
+		{ID: 2, Type: "local-hologram", UpdatedAt: 2000, Enable: true, Content: map[string]any{"title": "new"}}})
+
+	summary := p.runCycle(t)
+	if summary.Processed != 1 || summary.Skipped != 1 || summary.Failed != 0 || summary.Degraded ||
+		summary.NewWatermark != 2000 {
+		t.Fatalf("summary = %+v, want the unknown type skipped past without failing", summary)
+	}
+	if ids, _ := QuarantinedContentIDs(); len(ids) != 1 || ids[0] != 2 {
+		t.Fatalf("quarantined = %v, want [2]", ids)
+	}
+}
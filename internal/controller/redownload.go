@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"embedup-go/internal/cstmerr"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FORCE_REDOWNLOAD_ALL in the control file forces every item to be re-downloaded.
+const FORCE_REDOWNLOAD_ALL = "*"
+
+var forceRedownloadMu sync.Mutex
+
+// forceRedownloadFile returns the control file listing content IDs (one per line)
+// whose assets must be re-downloaded.
+func forceRedownloadFile() string {
+	return settings.ForceRedownloadFile
+}
+
+// readForceRedownloadEntries returns the non-empty, trimmed lines of the control file.
+// A missing file means nothing is forced.
+func readForceRedownloadEntries() ([]string, error) {
+	data, err := os.ReadFile(forceRedownloadFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entries []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			entries = append(entries, line)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// forceRedownloads is the content of the control file, read once per cycle.
+type forceRedownloads struct {
+	all bool // FORCE_REDOWNLOAD_ALL is listed
+	ids map[int64]bool
+}
+
+// requested reports whether the item's assets must be re-downloaded.
+func (f forceRedownloads) requested(id int64) bool {
+	return f.all || f.ids[id]
+}
+
+// readForceRedownloads reads the control file. Malformed lines are logged and ignored.
+func readForceRedownloads() (forceRedownloads, error) {
+	forceRedownloadMu.Lock()
+	defer forceRedownloadMu.Unlock()
+	forced := forceRedownloads{ids: make(map[int64]bool)}
+	entries, err := readForceRedownloadEntries()
+	if err != nil {
+		return forced, cstmerr.NewFileIOError(fmt.Sprintf("failed to read %s", forceRedownloadFile()), err)
+	}
+	for _, entry := range entries {
+		if entry == FORCE_REDOWNLOAD_ALL {
+			forced.all = true
+			continue
+		}
+		id, err := strconv.ParseInt(entry, 10, 64)
+		if err != nil {
+			log.Printf("Ignoring malformed force re-download entry %q", entry)
+			continue
+		}
+		forced.ids[id] = true
+	}
+	return forced, nil
+}
+
+// ClearForceRedownload removes the content ID from the control file. A global
+// FORCE_REDOWNLOAD_ALL entry is left until a cycle finds nothing more to sync.
+func ClearForceRedownload(id int64) error {
+	forceRedownloadMu.Lock()
+	defer forceRedownloadMu.Unlock()
+	entries, err := readForceRedownloadEntries()
+	if err != nil {
+		return cstmerr.NewFileIOError(fmt.Sprintf("failed to read %s", forceRedownloadFile()), err)
+	}
+	idStr := strconv.FormatInt(id, 10)
+	kept := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry != idStr {
+			kept = append(kept, entry)
+		}
+	}
+	if len(kept) == len(entries) {
+		return nil
+	}
+	content := strings.Join(kept, "\n")
+	if len(kept) > 0 {
+		content += "\n"
+	}
+	if err := os.WriteFile(forceRedownloadFile(), []byte(content), 0644); err != nil {
+		return cstmerr.NewFileIOError(fmt.Sprintf("failed to write %s", forceRedownloadFile()), err)
+	}
+	return nil
+}
+
+// SetForceRedownloadAll adds or removes the FORCE_REDOWNLOAD_ALL entry. Set it along
+// with a re-sync window (resync_from) for the pass to cover content already synced;
+// it is removed once a cycle finds nothing more to sync.
+func SetForceRedownloadAll(enabled bool) error {
+	forceRedownloadMu.Lock()
+	defer forceRedownloadMu.Unlock()
+	entries, err := readForceRedownloadEntries()
+	if err != nil {
+		return cstmerr.NewFileIOError(fmt.Sprintf("failed to read %s", forceRedownloadFile()), err)
+	}
+	kept := make([]string, 0, len(entries)+1)
+	for _, entry := range entries {
+		if entry != FORCE_REDOWNLOAD_ALL {
+			kept = append(kept, entry)
+		}
+	}
+	if enabled {
+		kept = append(kept, FORCE_REDOWNLOAD_ALL)
+	}
+	content := strings.Join(kept, "\n")
+	if len(kept) > 0 {
+		content += "\n"
+	}
+	if err := os.WriteFile(forceRedownloadFile(), []byte(content), 0644); err != nil {
+		return cstmerr.NewFileIOError(fmt.Sprintf("failed to write %s", forceRedownloadFile()), err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"embedup-go/configs/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withForceRedownloadFile points the control file at a temporary file holding content.
+func withForceRedownloadFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "force_redownload")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	withSettings(t, func(cfg *config.Config) { cfg.ForceRedownloadFile = path })
+	return path
+}
+
+func TestReadForceRedownloads(t *testing.T) {
+	withForceRedownloadFile(t, "12\n bogus \n\n34\n")
+	forced, err := readForceRedownloads()
+	if err != nil {
+		t.Fatalf("readForceRedownloads: %v", err)
+	}
+	if forced.all || !forced.requested(12) || !forced.requested(34) || forced.requested(56) {
+		t.Fatalf("forced = %+v, want only 12 and 34", forced)
+	}
+
+	withSettings(t, func(cfg *config.Config) { cfg.ForceRedownloadFile = filepath.Join(t.TempDir(), "missing") })
+	if forced, err := readForceRedownloads(); err != nil || forced.requested(12) {
+		t.Fatalf("missing file = %+v, %v; want nothing forced", forced, err)
+	}
+}
+
+func TestPipelineForcedRedownload(t *testing.T) {
+	tests := []struct {
+		name      string
+		control   string
+		downloads int
+		left      string
+	}{
+		{"normal", "", 1, ""},
+		{"forced", "101\n7\n", 2, "7\n"},
+		{"all forced", "*\n", 2, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := withForceRedownloadFile(t, "")
+			p := newPipeline(t)
+			p.server.setFile(adURL, adContent)
+			p.serveFeed(t, "feed_advertisement_enabled.json")
+			p.runCycle(t)
+
+			if err := os.WriteFile(path, []byte(tt.control), 0644); err != nil {
+				t.Fatal(err)
+			}
+			p.runCycle(t)
+			if got := p.server.downloadCount(adURL); got != tt.downloads {
+				t.Fatalf("advertisement downloaded %d time(s), want %d", got, tt.downloads)
+			}
+			if got, err := os.ReadFile(path); err != nil || string(got) != tt.left {
+				t.Fatalf("control file = %q, %v; want %q", got, err, tt.left)
+			}
+		})
+	}
+}
+
+func TestForceRedownloadAllKeptWhileItemsRemain(t *testing.T) {
+	path := withForceRedownloadFile(t, "*\n")
+	p := newPipeline(t)
+	p.server.setFile(adURL, adContent)
+	p.server.setJSON(testUpdatesURL, []byte(strings.Replace(
+		string(readFixture(t, "feed_advertisement_enabled.json")), `"count": 0`, `"count": 5`, 1)))
+
+	p.runCycle(t)
+	if got, err := os.ReadFile(path); err != nil || string(got) != "*\n" {
+		t.Fatalf("control file = %q, %v; want the forced re-download of all items kept", got, err)
+	}
+}
@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"context"
+	"embedup-go/internal/cstmerr"
+	"embedup-go/internal/dbclient"
+	SharedModels "embedup-go/internal/shared"
+	"log"
+)
+
+// ApplyResyncFrom moves updater's in-memory watermark back to from, so the next fetch
+// re-pulls everything updated since and the watermark advances from there as usual.
+// An override is applied once: it is recorded in the updater row first, so a restart
+// with the same configuration doesn't re-pull the window again. One that isn't earlier
+// than the watermark has nothing to re-pull and is ignored. The stored watermark is
+// never changed. It reports whether the watermark was moved.
+func ApplyResyncFrom(ctx context.Context, dbConnection dbclient.DBClient,
+	updater *SharedModels.Updater, from int64) (bool, error) {
+	if from <= 0 || from == updater.ResyncFrom {
+		return false, nil
+	}
+	if from >= updater.LastFromTimeStamp {
+		log.Printf("Ignoring resync_from %d, not earlier than the stored watermark %d",
+			from, updater.LastFromTimeStamp)
+		return false, nil
+	}
+	err := dbConnection.Updates(ctx, &SharedModels.Updater{ContentId: updater.ContentId},
+		map[string]interface{}{"resyncFrom": from})
+	if err != nil {
+		return false, cstmerr.NewDBError("failed to record the applied resync_from", err)
+	}
+	updater.ResyncFrom = from
+
+	log.Printf("Re-syncing content updated since %d (stored watermark %d)", from, updater.LastFromTimeStamp)
+	updater.LastFromTimeStamp = from
+	return true, nil
+}
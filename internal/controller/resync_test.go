@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"context"
+	SharedModels "embedup-go/internal/shared"
+	"errors"
+	"testing"
+)
+
+// updatesDB records the column updates made through Updates.
+type updatesDB struct {
+	emptyDB
+	updates []map[string]interface{}
+	err     error
+}
+
+func (db *updatesDB) Updates(ctx context.Context, modelWithPK interface{}, data interface{}) error {
+	if db.err != nil {
+		return db.err
+	}
+	db.updates = append(db.updates, data.(map[string]interface{}))
+	return nil
+}
+
+func TestApplyResyncFromOnce(t *testing.T) {
+	db := &updatesDB{}
+	updater := &SharedModels.Updater{LastFromTimeStamp: 5000}
+
+	applied, err := ApplyResyncFrom(context.Background(), db, updater, 1000)
+	if err != nil || !applied {
+		t.Fatalf("ApplyResyncFrom = %t, %v; want applied", applied, err)
+	}
+	if updater.LastFromTimeStamp != 1000 {
+		t.Fatalf("watermark = %d, want 1000", updater.LastFromTimeStamp)
+	}
+	if len(db.updates) != 1 || db.updates[0]["resyncFrom"] != int64(1000) {
+		t.Fatalf("updates = %v, want resyncFrom recorded once", db.updates)
+	}
+	if _, ok := db.updates[0]["lastFromTimeStamp"]; ok {
+		t.Fatal("the stored watermark must not be overwritten")
+	}
+
+	// The cycle advances the watermark; the next start with the same config keeps it.
+	updater.LastFromTimeStamp = 6000
+	applied, err = ApplyResyncFrom(context.Background(), db, updater, 1000)
+	if err != nil || applied {
+		t.Fatalf("second ApplyResyncFrom = %t, %v; want not applied", applied, err)
+	}
+	if updater.LastFromTimeStamp != 6000 {
+		t.Fatalf("watermark = %d, want it to progress from the re-sync", updater.LastFromTimeStamp)
+	}
+}
+
+func TestApplyResyncFromIgnoresLaterOverride(t *testing.T) {
+	db := &updatesDB{}
+	updater := &SharedModels.Updater{LastFromTimeStamp: 5000}
+
+	for _, from := range []int64{0, 5000, 9000} {
+		applied, err := ApplyResyncFrom(context.Background(), db, updater, from)
+		if err != nil || applied {
+			t.Fatalf("ApplyResyncFrom(%d) = %t, %v; want ignored", from, applied, err)
+		}
+	}
+	if updater.LastFromTimeStamp != 5000 || len(db.updates) != 0 {
+		t.Fatalf("watermark = %d, updates = %v; want both untouched", updater.LastFromTimeStamp, db.updates)
+	}
+}
+
+func TestApplyResyncFromNotRecorded(t *testing.T) {
+	db := &updatesDB{err: errors.New("connection refused")}
+	updater := &SharedModels.Updater{LastFromTimeStamp: 5000}
+
+	if applied, err := ApplyResyncFrom(context.Background(), db, updater, 1000); err == nil || applied {
+		t.Fatalf("ApplyResyncFrom = %t, %v; want an error", applied, err)
+	}
+	if updater.LastFromTimeStamp != 5000 {
+		t.Fatalf("watermark = %d, want it untouched when the override can't be recorded", updater.LastFromTimeStamp)
+	}
+}
+
+func TestResyncKeepsSavedWatermark(t *testing.T) {
+	p := newPipeline(t)
+	p.serveItems(t, []feedItem{p.adItem(5, true)})
+	p.runCycle(t)
+
+	applied, err := ApplyResyncFrom(context.Background(), p.db, p.app.Updater, 1000)
+	if err != nil || !applied {
+		t.Fatalf("ApplyResyncFrom = %t, %v; want applied", applied, err)
+	}
+	p.serveItems(t, []feedItem{p.adItem(2, true), p.adItem(3, true)})
+	if summary := p.runCycle(t); summary.Processed != 2 || summary.NewWatermark != 3000 {
+		t.Fatalf("re-sync summary = %+v, want both items processed", summary)
+	}
+	if got := p.storedWatermark(t); got != 5000 {
+		t.Fatalf("stored watermark = %d after the re-sync, want 5000 kept", got)
+	}
+
+	// Past the stored watermark, the re-synced watermark is saved as usual.
+	p.serveItems(t, []feedItem{p.adItem(6, true)})
+	p.runCycle(t)
+	if got := p.storedWatermark(t); got != 6000 {
+		t.Fatalf("stored watermark = %d, want 6000", got)
+	}
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"context"
+	"embedup-go/configs/config"
+	"embedup-go/internal/cstmerr"
+	SharedModels "embedup-go/internal/shared"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// settings is the configuration content processing runs with. It holds the defaults
+// until Configure is called.
+var settings = config.Default()
+
+// Configure sets the configuration content processing runs with. Call it once at
+// startup, before the first cycle.
+func Configure(cfg *config.Config) {
+	settings = cfg
+}
+
+// clock is the time content processing runs on, e.g. for deletion grace periods and
+// the pending max age. It is the real clock until SetClock is called.
+var clock SharedModels.Clock = SharedModels.RealClock{}
+
+// SetClock sets the clock content processing runs on. Call it once at startup, before
+// the first cycle.
+func SetClock(c SharedModels.Clock) {
+	clock = c
+}
+
+// dbCallTimeout bounds one database step of processing an item.
+const dbCallTimeout = 10 * time.Second // Connection timeout
+
+// dbContext returns the context for one database step of processing an item. Create
+// it right before the step, so time spent downloading doesn't count against it.
+func dbContext(itemCtx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(itemCtx, dbCallTimeout)
+}
+
+// statePath returns the path of the named file in the state directory.
+func statePath(name string) string {
+	return filepath.Join(settings.StateDir, name)
+}
+
+// writeStateFile replaces the file at path with data through a temporary file in the
+// same directory, so a crash mid-write never leaves it truncated. The directory is
+// created if needed.
+func writeStateFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return cstmerr.NewFileIOError(fmt.Sprintf("failed to create %s", filepath.Dir(path)), err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return cstmerr.NewFileIOError(fmt.Sprintf("failed to write %s", path), err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return cstmerr.NewFileIOError(fmt.Sprintf("failed to write %s", path), err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"embedup-go/configs/config"
+	"embedup-go/internal/clocktest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// withSettings runs the test with the default configuration changed by change and a
+// state directory of its own, restoring the previous configuration afterwards.
+func withSettings(t *testing.T, change func(cfg *config.Config)) {
+	t.Helper()
+	previous := settings
+	t.Cleanup(func() { Configure(previous) })
+	cfg := config.Default()
+	cfg.StateDir = t.TempDir()
+	if change != nil {
+		change(cfg)
+	}
+	Configure(cfg)
+}
+
+func TestWriteStateFileReplacesAtomically(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "pending")
+	if err := writeStateFile(path, []byte("first\n")); err != nil {
+		t.Fatalf("writeStateFile: %v", err)
+	}
+	if err := writeStateFile(path, []byte("second\n")); err != nil {
+		t.Fatalf("writeStateFile: %v", err)
+	}
+	if got, err := os.ReadFile(path); err != nil || string(got) != "second\n" {
+		t.Fatalf("state file = %q, %v", got, err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("state dir = %v, %v; want only the state file", entries, err)
+	}
+}
+
+// withClock runs content processing on a fake clock for the rest of the test.
+func withClock(t *testing.T) *clocktest.FakeClock {
+	t.Helper()
+	previous := clock
+	t.Cleanup(func() { SetClock(previous) })
+	fake := clocktest.NewFakeClock(time.Unix(1700000000, 0))
+	SetClock(fake)
+	return fake
+}
@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"sync"
+	"time"
+)
+
+// ContentTypeStats is a snapshot of the processing outcomes of one content type.
+type ContentTypeStats struct {
+	Succeeded   int64
+	Failed      int64
+	LastSuccess time.Time // Zero if no item of the type was processed successfully yet
+}
+
+// typeStats accumulates per-content-type processing outcomes; it is safe for concurrent use.
+type typeStats struct {
+	mu     sync.Mutex
+	byType map[string]ContentTypeStats
+}
+
+var contentTypeStats = &typeStats{byType: make(map[string]ContentTypeStats)}
+
+// record counts one processed item of contentType, finished at now.
+func (ts *typeStats) record(contentType string, succeeded bool, now time.Time) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	stats := ts.byType[contentType]
+	if succeeded {
+		stats.Succeeded++
+		stats.LastSuccess = now
+	} else {
+		stats.Failed++
+	}
+	ts.byType[contentType] = stats
+}
+
+func (ts *typeStats) snapshot() map[string]ContentTypeStats {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	snapshot := make(map[string]ContentTypeStats, len(ts.byType))
+	for k, v := range ts.byType {
+		snapshot[k] = v
+	}
+	return snapshot
+}
+
+// TypeStats returns the processing outcomes recorded so far, keyed by content type,
+// e.g. to tell when movies last synced successfully.
+func TypeStats() map[string]ContentTypeStats {
+	return contentTypeStats.snapshot()
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"context"
+	"embedup-go/internal/clocktest"
+	"testing"
+	"time"
+)
+
+// withFreshTypeStats runs the test against empty per-type stats.
+func withFreshTypeStats(t *testing.T) {
+	t.Helper()
+	saved := contentTypeStats
+	contentTypeStats = &typeStats{byType: make(map[string]ContentTypeStats)}
+	t.Cleanup(func() { contentTypeStats = saved })
+}
+
+func TestTypeStatsCountsOutcomesPerType(t *testing.T) {
+	withFreshTypeStats(t)
+	p := newPipeline(t)
+	p.app.API.SetClock(clocktest.NewFakeClock(time.Unix(0, 0)))
+	p.serveMovie(t, movieFiles)
+	p.db.failSave[3] = true
+	p.serveItems(t, []feedItem{
+		p.adItem(1, true),
+		p.adItem(2, true),
+		p.adItem(3, true),
+		{ID: 201, Type: "local-movie", UpdatedAt: 4000, Enable: true,
+			Content: map[string]any{"fileLink": "https://cdn.test/movies/night-train.zip", "movieId": 77}},
+		{ID: 202, Type: "local-movie", UpdatedAt: 5000, Enable: true,
+			Content: map[string]any{"fileLink": "https://cdn.test/movies/missing.zip", "movieId": 77}},
+	})
+
+	before := time.Now()
+	summary, _ := p.app.RunCycle(context.Background())
+	after := time.Now()
+	if summary.Processed != 3 || summary.Failed != 2 {
+		t.Fatalf("summary = %+v, want 3 processed and 2 failed", summary)
+	}
+
+	stats := TypeStats()
+	want := map[string][2]int64{
+		"local-advertisement": {2, 1},
+		"local-movie":         {1, 1},
+	}
+	if len(stats) != len(want) {
+		t.Fatalf("stats = %+v, want types %v", stats, want)
+	}
+	for contentType, counts := range want {
+		got := stats[contentType]
+		if got.Succeeded != counts[0] || got.Failed != counts[1] {
+			t.Errorf("%s: succeeded %d, failed %d; want %d and %d",
+				contentType, got.Succeeded, got.Failed, counts[0], counts[1])
+		}
+		if got.LastSuccess.Before(before) || got.LastSuccess.After(after) {
+			t.Errorf("%s: last success %v, want during the cycle", contentType, got.LastSuccess)
+		}
+	}
+}
+
+func TestTypeStatsLastSuccessZeroWithoutSuccess(t *testing.T) {
+	withFreshTypeStats(t)
+	p := newPipeline(t)
+	p.db.failSave[1] = true
+	p.serveItems(t, []feedItem{p.adItem(1, true)})
+
+	p.app.RunCycle(context.Background())
+	stats := TypeStats()["local-advertisement"]
+	if stats.Succeeded != 0 || stats.Failed != 1 || !stats.LastSuccess.IsZero() {
+		t.Fatalf("stats = %+v, want one failure and no last success", stats)
+	}
+}
+
+func TestTypeStatsSnapshotIsACopy(t *testing.T) {
+	withFreshTypeStats(t)
+	contentTypeStats.record("local-movie", true, time.Unix(100, 0))
+
+	snapshot := TypeStats()
+	contentTypeStats.record("local-movie", false, time.Unix(200, 0))
+	if got := snapshot["local-movie"]; got.Succeeded != 1 || got.Failed != 0 || !got.LastSuccess.Equal(time.Unix(100, 0)) {
+		t.Fatalf("snapshot changed after a later record: %+v", got)
+	}
+	if got := TypeStats()["local-movie"]; got.Failed != 1 || !got.LastSuccess.Equal(time.Unix(100, 0)) {
+		t.Fatalf("a failure moved the last success: %+v", got)
+	}
+}
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"context"
+	"embedup-go/internal/dbclient"
+)
+
+// throttledDBClient wraps a DBClient so that at most a fixed number of writes run
+// at once, keeping parallel content processing from exhausting the small embedded
+// Postgres connection pool. Reads are passed through unthrottled.
+type throttledDBClient struct {
+	dbclient.DBClient
+	writes chan struct{}
+}
+
+// NewThrottledDBClient limits concurrent writes on db to maxWrites.
+// A maxWrites of 0 or less returns db unchanged.
+func NewThrottledDBClient(db dbclient.DBClient, maxWrites int) dbclient.DBClient {
+	if maxWrites <= 0 {
+		return db
+	}
+	return &throttledDBClient{DBClient: db, writes: make(chan struct{}, maxWrites)}
+}
+
+// acquire blocks until a write slot is free or ctx is done.
+func (t *throttledDBClient) acquire(ctx context.Context) error {
+	select {
+	case t.writes <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *throttledDBClient) release() {
+	<-t.writes
+}
+
+func (t *throttledDBClient) Create(ctx context.Context, model interface{}) error {
+	if err := t.acquire(ctx); err != nil {
+		return err
+	}
+	defer t.release()
+	return t.DBClient.Create(ctx, model)
+}
+
+func (t *throttledDBClient) Save(ctx context.Context, model interface{}) error {
+	if err := t.acquire(ctx); err != nil {
+		return err
+	}
+	defer t.release()
+	return t.DBClient.Save(ctx, model)
+}
+
+func (t *throttledDBClient) SaveReturning(ctx context.Context, model interface{}) (bool, error) {
+	if err := t.acquire(ctx); err != nil {
+		return false, err
+	}
+	defer t.release()
+	return t.DBClient.SaveReturning(ctx, model)
+}
+
+func (t *throttledDBClient) Updates(ctx context.Context, modelWithPK interface{}, data interface{}) error {
+	if err := t.acquire(ctx); err != nil {
+		return err
+	}
+	defer t.release()
+	return t.DBClient.Updates(ctx, modelWithPK, data)
+}
+
+func (t *throttledDBClient) Delete(ctx context.Context, model interface{}, conditions ...interface{}) error {
+	if err := t.acquire(ctx); err != nil {
+		return err
+	}
+	defer t.release()
+	return t.DBClient.Delete(ctx, model, conditions...)
+}
+
+func (t *throttledDBClient) DeleteByContentId(ctx context.Context, model interface{}, contentId int64) error {
+	if err := t.acquire(ctx); err != nil {
+		return err
+	}
+	defer t.release()
+	return t.DBClient.DeleteByContentId(ctx, model, contentId)
+}
+
+func (t *throttledDBClient) Truncate(ctx context.Context, models ...interface{}) error {
+	if err := t.acquire(ctx); err != nil {
+		return err
+	}
+	defer t.release()
+	return t.DBClient.Truncate(ctx, models...)
+}
+
+// RunInTransaction takes a single write slot for the whole transaction, which holds
+// one connection however many writes it makes.
+func (t *throttledDBClient) RunInTransaction(ctx context.Context,
+	fn func(ctx context.Context, txClient dbclient.DBClient) error) error {
+	if err := t.acquire(ctx); err != nil {
+		return err
+	}
+	defer t.release()
+	return t.DBClient.RunInTransaction(ctx, fn)
+}
+
+func (t *throttledDBClient) CreateAssosiate(ctx context.Context, model interface{},
+	assosiation string, assosiate interface{}) error {
+	if err := t.acquire(ctx); err != nil {
+		return err
+	}
+	defer t.release()
+	return t.DBClient.CreateAssosiate(ctx, model, assosiation, assosiate)
+}
+
+func (t *throttledDBClient) DeleteAssosiate(ctx context.Context, model interface{},
+	assosiation string, assosiate interface{}) error {
+	if err := t.acquire(ctx); err != nil {
+		return err
+	}
+	defer t.release()
+	return t.DBClient.DeleteAssosiate(ctx, model, assosiation, assosiate)
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"context"
+	"embedup-go/internal/dbclient"
+	SharedModels "embedup-go/internal/shared"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingDB holds every write until release is closed, tracking how many run at once.
+type blockingDB struct {
+	dbclient.DBClient
+	release   chan struct{}
+	started   chan struct{}
+	mu        sync.Mutex
+	active    int
+	maxActive int
+}
+
+func newBlockingDB() *blockingDB {
+	return &blockingDB{release: make(chan struct{}), started: make(chan struct{}, 100)}
+}
+
+func (db *blockingDB) Save(ctx context.Context, model interface{}) error {
+	db.mu.Lock()
+	db.active++
+	db.maxActive = max(db.maxActive, db.active)
+	db.mu.Unlock()
+	db.started <- struct{}{}
+	<-db.release
+	db.mu.Lock()
+	db.active--
+	db.mu.Unlock()
+	return nil
+}
+
+func (db *blockingDB) First(ctx context.Context, model interface{}, conditions ...interface{}) error {
+	return nil
+}
+
+func TestThrottledDBClientLimitsConcurrentWrites(t *testing.T) {
+	const maxWrites = 2
+	inner := newBlockingDB()
+	db := NewThrottledDBClient(inner, maxWrites)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			db.Save(context.Background(), &SharedModels.Advertisement{})
+		}()
+	}
+	for i := 0; i < maxWrites; i++ {
+		<-inner.started
+	}
+	select {
+	case <-inner.started:
+		t.Fatalf("a write beyond the limit of %d started", maxWrites)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Reads are not throttled.
+	if err := db.First(context.Background(), &SharedModels.Advertisement{}); err != nil {
+		t.Fatalf("First while writes are blocked: %v", err)
+	}
+
+	close(inner.release)
+	wg.Wait()
+	if inner.maxActive != maxWrites {
+		t.Fatalf("%d writes ran at once, want %d", inner.maxActive, maxWrites)
+	}
+}
+
+func TestThrottledDBClientWaiterGivesUpOnCancel(t *testing.T) {
+	inner := newBlockingDB()
+	db := NewThrottledDBClient(inner, 1)
+	go db.Save(context.Background(), &SharedModels.Advertisement{})
+	<-inner.started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- db.Save(ctx, &SharedModels.Advertisement{}) }()
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Save = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("canceled write still waiting for a slot")
+	}
+	close(inner.release)
+
+	// The canceled waiter took no slot, so the next write runs.
+	if err := db.Save(context.Background(), &SharedModels.Advertisement{}); err != nil {
+		t.Fatalf("Save after the slot was freed: %v", err)
+	}
+}
+
+func TestNewThrottledDBClientUnlimited(t *testing.T) {
+	inner := newBlockingDB()
+	if db := NewThrottledDBClient(inner, 0); db != dbclient.DBClient(inner) {
+		t.Fatal("a limit of 0 wrapped the client")
+	}
+}
@@ -0,0 +1,55 @@
+package controller
+
+import (
+	SharedModels "embedup-go/internal/shared"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPipelineTombstoneDeletesSyncedItem(t *testing.T) {
+	p := newPipeline(t)
+	ad := p.adItem(1, true)
+	p.serveItems(t, []feedItem{ad})
+	if summary := p.runCycle(t); summary.Created != 1 {
+		t.Fatalf("sync summary = %+v", summary)
+	}
+	var files []string
+	filepath.WalkDir(filepath.Join(ContentBasePath(), "videos", "ads"), func(path string, entry os.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if len(files) != 1 {
+		t.Fatalf("advertisement files = %v, want one", files)
+	}
+
+	// A tombstone carries no content and is processed even though it is not enabled.
+	p.serveItems(t, []feedItem{{ID: 1, Type: "local-advertisement", UpdatedAt: 2000, Deleted: true}})
+	summary := p.runCycle(t)
+	if summary.Processed != 1 || summary.Failed != 0 || summary.NewWatermark != 2000 {
+		t.Fatalf("tombstone summary = %+v", summary)
+	}
+	if p.db.rows(&SharedModels.Advertisement{}) != 0 {
+		t.Fatal("advertisement row left behind")
+	}
+	if _, err := os.Stat(files[0]); !os.IsNotExist(err) {
+		t.Fatalf("advertisement file left behind: %v", err)
+	}
+}
+
+func TestPipelineTombstoneOfUnsyncedOrUndeletableItemIsSkipped(t *testing.T) {
+	p := newPipeline(t)
+	p.serveItems(t, []feedItem{
+		{ID: 5, Type: "local-advertisement", UpdatedAt: 1000, Deleted: true},
+		{ID: 6, Type: "local-page", UpdatedAt: 2000, Deleted: true},
+	})
+	summary := p.runCycle(t)
+	if summary.Skipped != 2 || summary.Failed != 0 || summary.NewWatermark != 2000 {
+		t.Fatalf("summary = %+v, want both tombstones skipped", summary)
+	}
+	if len(p.db.written) != 0 {
+		t.Fatalf("wrote %v for tombstones of nothing stored", p.db.written)
+	}
+}
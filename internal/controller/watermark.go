@@ -0,0 +1,31 @@
+package controller
+
+import (
+	"context"
+	"embedup-go/internal/cstmerr"
+	"embedup-go/internal/dbclient"
+	SharedModels "embedup-go/internal/shared"
+	"time"
+)
+
+// saveWatermark stores updater's watermark in its row, so a restart fetches from where
+// the last cycle stopped. The stored watermark only moves forward: while a resync_from
+// window is re-pulled the in-memory watermark is behind it, and the row keeps its value.
+func saveWatermark(dbConnection dbclient.DBClient, updater *SharedModels.Updater) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Connection timeout
+	defer cancel()
+
+	stored := SharedModels.Updater{ContentId: updater.ContentId}
+	if err := dbConnection.First(ctx, &stored); err != nil {
+		return cstmerr.NewDBError("failed to load the stored watermark", err)
+	}
+	if stored.LastFromTimeStamp >= updater.LastFromTimeStamp {
+		return nil
+	}
+	err := dbConnection.Updates(ctx, &SharedModels.Updater{ContentId: updater.ContentId},
+		map[string]interface{}{"lastFromTimeStamp": updater.LastFromTimeStamp})
+	if err != nil {
+		return cstmerr.NewDBError("failed to save the watermark", err)
+	}
+	return nil
+}
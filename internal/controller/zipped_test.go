@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"embedup-go/configs/config"
+	ApiClient "embedup-go/internal/apiclient"
+	"embedup-go/internal/cstmerr"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeZip writes an archive of files, by entry name, to path.
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	w := zip.NewWriter(out)
+	for name, content := range files {
+		entry, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := entry.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// localClient returns an APIClient for file:// URLs, which are copied without HTTP.
+func localClient() *ApiClient.APIClient {
+	return ApiClient.NewWithHTTPClient(&config.Config{}, "test-token",
+		ApiClient.NewRestyAdapter(ApiClient.DefaultTransportTimeouts(), ApiClient.DefaultRedirectSettings()))
+}
+
+var movieFiles = map[string]string{
+	"hls/master_hls.m3u8": "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1\nvariant.m3u8\n",
+	"hls/variant.m3u8":    "#EXTM3U\n#EXTINF:1,\nsegment0.ts\n",
+	"hls/segment0.ts":     "segment zero",
+}
+
+func TestDownloadZippedVideoExtracts(t *testing.T) {
+	t.Setenv("PODBOX_UPDATE_CONTENT_BASE_PATH", t.TempDir())
+	archive := filepath.Join(t.TempDir(), "movie.zip")
+	writeZip(t, archive, movieFiles)
+
+	extracted, _, err := downloadZippedVideo(context.Background(), nil, localClient(), "file://"+archive, false)
+	if err != nil {
+		t.Fatalf("downloadZippedVideo: %v", err)
+	}
+	for name, want := range movieFiles {
+		got, err := os.ReadFile(filepath.Join(extracted, filepath.FromSlash(name)))
+		if err != nil || string(got) != want {
+			t.Fatalf("%s = %q, %v; want %q", name, got, err, want)
+		}
+	}
+}
+
+func TestDownloadZippedVideoResumesExtraction(t *testing.T) {
+	defer Configure(settings)
+	cfg := config.Default()
+	cfg.ExtractResume = true
+	Configure(cfg)
+	t.Setenv("PODBOX_UPDATE_CONTENT_BASE_PATH", t.TempDir())
+	archive := filepath.Join(t.TempDir(), "movie.zip")
+	writeZip(t, archive, movieFiles)
+	client := localClient()
+
+	extracted, _, err := downloadZippedVideo(context.Background(), nil, client, "file://"+archive, false)
+	if err != nil {
+		t.Fatalf("downloadZippedVideo: %v", err)
+	}
+	// Simulate an interrupted extraction: one file kept in full, one cut short.
+	kept := filepath.Join(extracted, "hls", "segment0.ts")
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(kept, old, old); err != nil {
+		t.Fatal(err)
+	}
+	cut := filepath.Join(extracted, "hls", "variant.m3u8")
+	if err := os.WriteFile(cut, []byte("#EXT"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := downloadZippedVideo(context.Background(), nil, client, "file://"+archive, false); err != nil {
+		t.Fatalf("resumed downloadZippedVideo: %v", err)
+	}
+	if got, _ := os.ReadFile(cut); string(got) != movieFiles["hls/variant.m3u8"] {
+		t.Fatalf("cut file = %q, want it extracted again", got)
+	}
+	if info, err := os.Stat(kept); err != nil || !info.ModTime().Equal(old) {
+		t.Fatalf("complete file was written again (%v)", err)
+	}
+}
+
+func TestDownloadZippedVideoChecksExtractionSpace(t *testing.T) {
+	defer Configure(settings)
+	cfg := config.Default()
+	cfg.ExtractFreeSpaceCheck = true
+	cfg.ExtractFreeSpaceMargin = math.MaxInt64 / 2
+	Configure(cfg)
+	t.Setenv("PODBOX_UPDATE_CONTENT_BASE_PATH", t.TempDir())
+	archive := filepath.Join(t.TempDir(), "movie.zip")
+	writeZip(t, archive, movieFiles)
+
+	_, _, err := downloadZippedVideo(context.Background(), nil, localClient(), "file://"+archive, false)
+	var spaceErr *cstmerr.DiskSpaceError
+	if !errors.As(err, &spaceErr) {
+		t.Fatalf("err = %v, want a DiskSpaceError", err)
+	}
+	entries, err := os.ReadDir(filepath.Join(ContentBasePath(), "videos"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].IsDir() {
+		t.Fatalf("videos dir = %v, want only the kept archive", entries)
+	}
+}
+
+// corruptZip writes an archive of files stored uncompressed to path, with a byte of
+// the first entry's data flipped so that its CRC-32 no longer matches.
+func corruptZip(t *testing.T, path string, name, content string) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	entry, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry.Write([]byte(content))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	data[bytes.Index(data, []byte(content))] ^= 0xff
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDownloadZippedVideoDropsCorruptArchive(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		corrupt func(t *testing.T, path string)
+	}{
+		{"bad CRC", func(t *testing.T, path string) { corruptZip(t, path, "hls/segment0.ts", "segment zero") }},
+		{"truncated", func(t *testing.T, path string) {
+			writeZip(t, path, movieFiles)
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Truncate(path, info.Size()/2); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PODBOX_UPDATE_CONTENT_BASE_PATH", t.TempDir())
+			archive := filepath.Join(t.TempDir(), "movie.zip")
+			tt.corrupt(t, archive)
+
+			_, _, err := downloadZippedVideo(context.Background(), nil, localClient(), "file://"+archive, false)
+			var archiveErr *cstmerr.ArchiveError
+			if !errors.As(err, &archiveErr) {
+				t.Fatalf("err = %v, want an ArchiveError", err)
+			}
+			zips, err := filepath.Glob(filepath.Join(ContentBasePath(), "videos", "*.zip"))
+			if err != nil || len(zips) != 0 {
+				t.Fatalf("corrupt archive kept: %v", zips)
+			}
+
+			// The next attempt downloads the archive again instead of reusing the corrupt copy.
+			writeZip(t, archive, movieFiles)
+			extracted, _, err := downloadZippedVideo(context.Background(), nil, localClient(), "file://"+archive, false)
+			if err != nil {
+				t.Fatalf("downloadZippedVideo after the archive was fixed: %v", err)
+			}
+			if got, err := os.ReadFile(filepath.Join(extracted, "hls", "segment0.ts")); err != nil || string(got) != "segment zero" {
+				t.Fatalf("segment0.ts = %q, %v", got, err)
+			}
+		})
+	}
+}
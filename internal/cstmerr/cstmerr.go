@@ -49,6 +49,13 @@ func NewVersionFormatError(msg string, underlyingErr error) *VersionFormatError
 	return &VersionFormatError{BaseError{Msg: msg, Err: underlyingErr}}
 }
 
+// VersionMismatchError indicates the installed version differs from the one an update should have produced.
+type VersionMismatchError struct{ BaseError }
+
+func NewVersionMismatchError(expected, actual int) *VersionMismatchError {
+	return &VersionMismatchError{BaseError{Msg: fmt.Sprintf("expected version %d after update, found %d", expected, actual)}}
+}
+
 // APIClientError indicates a general problem with the HTTP client or request creation.
 type APIClientError struct{ BaseError }
 
@@ -74,6 +81,31 @@ func (e *APIRequestFailedError) Error() string {
 	return fmt.Sprintf("%s - %s", e.BaseError.Msg, e.Message)
 }
 
+// ContentNotFoundError indicates the server has no content item with the requested ID.
+type ContentNotFoundError struct {
+	BaseError
+	ID int64
+}
+
+func NewContentNotFoundError(id int64) *ContentNotFoundError {
+	return &ContentNotFoundError{BaseError: BaseError{Msg: fmt.Sprintf("content item %d not found", id)}, ID: id}
+}
+
+// SchemaVersionError indicates the server sent content in a schema version the client can't parse.
+type SchemaVersionError struct {
+	BaseError
+	Version   int
+	Supported int
+}
+
+func NewSchemaVersionError(version int, supported int) *SchemaVersionError {
+	return &SchemaVersionError{
+		BaseError: BaseError{Msg: fmt.Sprintf("unsupported content schema version %d (client supports up to %d)", version, supported)},
+		Version:   version,
+		Supported: supported,
+	}
+}
+
 // NoUpdateAvailable is used when the service is already up-to-date.
 // This might be better handled by returning (nil, nil) from CheckForUpdates if no update.
 type NoUpdateAvailableError struct{ BaseError }
@@ -82,11 +114,37 @@ func NewNoUpdateAvailableError() *NoUpdateAvailableError {
 	return &NoUpdateAvailableError{BaseError{Msg: "No update available or service up-to-date"}}
 }
 
-// DownloadError indicates a problem during file download.
-type DownloadError struct{ BaseError }
+// DownloadError indicates a problem during file download. StatusCode is set when the
+// server answered with an unexpected HTTP status.
+type DownloadError struct {
+	BaseError
+	StatusCode int
+}
 
 func NewDownloadError(msg string) *DownloadError {
-	return &DownloadError{BaseError{Msg: "Download error: " + msg}}
+	return &DownloadError{BaseError: BaseError{Msg: "Download error: " + msg}}
+}
+
+func NewDownloadStatusError(statusCode int) *DownloadError {
+	return &DownloadError{
+		BaseError:  BaseError{Msg: fmt.Sprintf("Download error: download request failed with status: %d", statusCode)},
+		StatusCode: statusCode,
+	}
+}
+
+// ChecksumError indicates a downloaded file does not hash to the expected value.
+type ChecksumError struct {
+	BaseError
+	Expected string
+	Actual   string
+}
+
+func NewChecksumError(path string, expected string, actual string) *ChecksumError {
+	return &ChecksumError{
+		BaseError: BaseError{Msg: fmt.Sprintf("Checksum error: %s hashes to %s, expected %s", path, actual, expected)},
+		Expected:  expected,
+		Actual:    actual,
+	}
 }
 
 // TimeoutError indicates a timeout during an operation.
@@ -96,11 +154,22 @@ func NewTimeoutError(underlyingErr error) *TimeoutError {
 	return &TimeoutError{BaseError{Msg: "Timeout error", Err: underlyingErr}}
 }
 
-// HeadError indicates a problem with the HEAD request.
-type HeadError struct{ BaseError }
+// HeadError indicates a problem with the HEAD request. StatusCode is set when the
+// server answered with an unexpected HTTP status.
+type HeadError struct {
+	BaseError
+	StatusCode int
+}
 
 func NewHeadError(msg string) *HeadError {
-	return &HeadError{BaseError{Msg: "Head error: " + msg}}
+	return &HeadError{BaseError: BaseError{Msg: "Head error: " + msg}}
+}
+
+func NewHeadStatusError(statusCode int) *HeadError {
+	return &HeadError{
+		BaseError:  BaseError{Msg: fmt.Sprintf("Head error: HEAD request failed with status: %d", statusCode)},
+		StatusCode: statusCode,
+	}
 }
 
 // DecryptionError (if used)
@@ -114,6 +183,13 @@ func NewArchiveError(msg string, underlyingErr error) *ArchiveError {
 	return &ArchiveError{BaseError{Msg: "Archive extraction error", Err: underlyingErr}}
 }
 
+// PatchError indicates a delta patch could not be applied.
+type PatchError struct{ BaseError }
+
+func NewPatchError(msg string, underlyingErr error) *PatchError {
+	return &PatchError{BaseError{Msg: "Patch error: " + msg, Err: underlyingErr}}
+}
+
 // ScriptError indicates a problem executing an update script.
 type ScriptError struct{ BaseError }
 
@@ -128,6 +204,23 @@ func NewFileSystemError(msg string) *FileSystemError {
 	return &FileSystemError{BaseError{Msg: "Filesystem error: " + msg}}
 }
 
+// DiskSpaceError indicates there is not enough free space on the filesystem of Path.
+type DiskSpaceError struct {
+	BaseError
+	Path      string
+	Required  int64
+	Available int64
+}
+
+func NewDiskSpaceError(path string, required int64, available int64) *DiskSpaceError {
+	return &DiskSpaceError{
+		BaseError: BaseError{Msg: fmt.Sprintf("Disk space error: %s needs %d bytes, %d available", path, required, available)},
+		Path:      path,
+		Required:  required,
+		Available: available,
+	}
+}
+
 // HexError (if used for decryption key)
 // type HexError struct{ BaseError }
 // func NewHexError(msg string, underlyingErr error) *HexError { ... }
@@ -216,4 +309,7 @@ const (
 	PROCESS_FIND_DIRECTORY     = "unable to find directories inside of %s"
 	PROCESS_FIND_SUB_DIRECTORY = "unable to find subdirectory inside"
 	PROCESS_HASH_FIND          = "unable to get hash of file from server"
+	PROCESS_PANIC              = "panic while processing content item %d"
+	PROCESS_DETAILS_TYPE       = "unexpected details type %T, want %s"
+	PROCESS_INCOMPLETE_HLS     = "incomplete HLS package, missing %s"
 )
@@ -4,8 +4,14 @@ import (
 	"context"
 	"embedup-go/configs/config"
 	"embedup-go/internal/cstmerr"
+	"embedup-go/internal/shared"
+	"errors"
 	"fmt"
+	"log"
+	"strings"
 	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 // QueryOptions can be used to pass common query modifiers like limit, offset, order.
@@ -25,6 +31,10 @@ type DBClient interface {
 	Close() error
 	Ping(ctx context.Context) error
 
+	// Migrate creates or updates the tables of the given models. Join tables of their
+	// many2many relations are created along with them. Connect does not migrate.
+	Migrate(ctx context.Context, models ...interface{}) error
+
 	// Create inserts a new record into the database.
 	// 'model' is a pointer to the struct to be created.
 	Create(ctx context.Context, model interface{}) error
@@ -34,6 +44,10 @@ type DBClient interface {
 	// 'model' is a pointer to the struct to be saved.
 	Save(ctx context.Context, model interface{}) error
 
+	// SaveReturning saves like Save and reports whether the record was created
+	// rather than updated.
+	SaveReturning(ctx context.Context, model interface{}) (created bool, err error)
+
 	// Updates updates attributes for a record.
 	// 'model' is a pointer to the struct (can be a partial struct or map for updates).
 	// 'conditionModel' is optional, a pointer to a struct with PK or unique fields to identify the record to update.
@@ -45,24 +59,46 @@ type DBClient interface {
 	// 'model' is a pointer to the struct with its primary key set, or a struct defining conditions.
 	Delete(ctx context.Context, model interface{}, conditions ...interface{}) error // conditions can be id, or query + args
 
+	// DeleteByContentId deletes the record of model's table whose contentId is contentId.
+	// It returns a DBNotFoundError if there is no such record.
+	DeleteByContentId(ctx context.Context, model interface{}, contentId int64) error
+
+	// Truncate removes every record of the tables of the given models, along with the
+	// records of any table referencing them, such as many2many join tables.
+	Truncate(ctx context.Context, models ...interface{}) error
+
 	// First retrieves the first record matching the given conditions.
 	// 'model' is a pointer to the struct to scan data into.
 	// 'conditions' can be a primary key, a struct to build WHERE conditions, or query string + args.
 	// The interpretation of 'conditions' will be up to the adapter.
 	First(ctx context.Context, model interface{}, conditions ...interface{}) error
 
+	// Exists reports whether at least one record matches.
+	// 'model' is a pointer to the struct identifying the table; without 'conditions',
+	// its non-zero fields (typically the primary key) are used as the WHERE conditions.
+	// 'conditions' can be a struct or query string + args, as for Find.
+	Exists(ctx context.Context, model interface{}, conditions ...interface{}) (bool, error)
+
 	// Find retrieves a collection of models matching the given conditions.
 	// 'collection' is a pointer to a slice of structs.
 	// 'conditions' can be a struct to build WHERE conditions, or query string + args.
 	Find(ctx context.Context, collection interface{}, conditions ...interface{}) error
 
+	// FindWith retrieves a collection using typed query options (WhereGT, WhereIn, OrderBy, Limit, ...).
+	// 'collection' is a pointer to a slice of structs.
+	FindWith(ctx context.Context, collection interface{}, opts ...FindOption) error
+
 	// ExecRaw executes a raw SQL query that doesn't necessarily map directly to a model.
 	// Kept for flexibility (e.g., complex joins, DDL, functions not covered by ORM methods).
 	ExecRaw(ctx context.Context, query string, args ...interface{}) (QueryResult, error)
 
-	// SelectRaw executes a raw SQL query and scans results into models.
-	// Kept for flexibility.
-	SelectRaw(ctx context.Context, collectionOrModel interface{}, query string, args ...interface{}) error
+	// SelectRaw executes a raw SQL query and scans its first row into 'model', a pointer
+	// to a struct. It returns a DBNotFoundError if the query yields no row.
+	SelectRaw(ctx context.Context, model interface{}, query string, args ...interface{}) error
+
+	// SelectRawRows executes a raw SQL query and scans every row into 'dest', a pointer
+	// to a slice. No rows leaves the slice empty and is not an error.
+	SelectRawRows(ctx context.Context, dest interface{}, query string, args ...interface{}) error
 
 	// RunInTransaction executes a function within a database transaction.
 	RunInTransaction(ctx context.Context, fn func(ctx context.Context, txClient DBClient) error) error
@@ -74,13 +110,89 @@ type DBClient interface {
 		assosiation string, assosiate interface{}) error
 }
 
+// FindCondition is a single "column <operator> value" condition of a FindWith query.
+type FindCondition struct {
+	Column   string
+	Operator string // One of the FIND_OP_* values
+	Value    interface{}
+}
+
+const (
+	FIND_OP_GT = ">"
+	FIND_OP_LT = "<"
+	FIND_OP_IN = "IN"
+)
+
+// FindQuery collects what the FindOptions passed to FindWith describe.
+type FindQuery struct {
+	Conditions []FindCondition
+	OrderBy    []FindOrder
+	Limit      int
+}
+
+// FindOrder orders FindWith results by a column, Desc selecting descending order.
+type FindOrder struct {
+	Column string
+	Desc   bool
+}
+
+// FindOption configures a FindWith query.
+type FindOption func(*FindQuery)
+
+// WhereGT matches rows where col > val.
+func WhereGT(col string, val interface{}) FindOption {
+	return func(q *FindQuery) {
+		q.Conditions = append(q.Conditions, FindCondition{Column: col, Operator: FIND_OP_GT, Value: val})
+	}
+}
+
+// WhereLT matches rows where col < val.
+func WhereLT(col string, val interface{}) FindOption {
+	return func(q *FindQuery) {
+		q.Conditions = append(q.Conditions, FindCondition{Column: col, Operator: FIND_OP_LT, Value: val})
+	}
+}
+
+// WhereIn matches rows where col is one of vals (a slice).
+func WhereIn(col string, vals interface{}) FindOption {
+	return func(q *FindQuery) {
+		q.Conditions = append(q.Conditions, FindCondition{Column: col, Operator: FIND_OP_IN, Value: vals})
+	}
+}
+
+// OrderBy orders the results by col; dir is "asc" or "desc".
+func OrderBy(col string, dir string) FindOption {
+	return func(q *FindQuery) {
+		q.OrderBy = append(q.OrderBy, FindOrder{Column: col, Desc: strings.EqualFold(dir, "desc")})
+	}
+}
+
+// Limit caps the number of returned rows.
+func Limit(n int) FindOption {
+	return func(q *FindQuery) {
+		q.Limit = n
+	}
+}
+
+// BuildFindQuery applies opts to an empty FindQuery.
+func BuildFindQuery(opts ...FindOption) FindQuery {
+	var q FindQuery
+	for _, opt := range opts {
+		opt(&q)
+	}
+	return q
+}
+
 // QueryResult (can remain the same for ExecRaw)
 type QueryResult interface {
 	RowsAffected() int
 }
 
 // NewDBClient is a factory function that will return a specific DBClient implementation.
-func NewDBClient(dbConfig *config.DatabaseConfig, dbType string) (DBClient, error) { // Added dbType
+// Transient connection failures are retried on the given clock as configured by
+// DBConnectMaxRetries and DBConnectBackoff, and operations that lose the connection
+// later are retried as configured by DBReconnectMaxRetries.
+func NewDBClient(dbConfig *config.DatabaseConfig, dbType string, clock shared.Clock) (DBClient, error) { // Added dbType
 	if dbConfig == nil {
 		return nil, fmt.Errorf("database configuration is nil")
 	}
@@ -102,12 +214,54 @@ func NewDBClient(dbConfig *config.DatabaseConfig, dbType string) (DBClient, erro
 		return nil, cstmerr.NewDBConnectionError(fmt.Sprintf("failed to find db type %s", dbType), err)
 	}
 
+	if err := connectWithRetry(adapter, dbConfig, clock); err != nil {
+		// The specific adapter's Connect method will wrap errors appropriately.
+		return nil, cstmerr.NewDBConnectionError("failed to connect with pg_adapter", err)
+	}
+	client := NewReconnectingClient(adapter, clock, dbConfig.DBReconnectMaxRetries, dbConfig.DBConnectBackoff)
+	return NewHealthWatchdog(client, adapter, clock, dbConfig.DBHealthCheckInterval), nil
+}
+
+// connectWithRetry connects adapter, retrying transient failures on clock as configured
+// by DBConnectMaxRetries and DBConnectBackoff.
+func connectWithRetry(adapter DBClient, dbConfig *config.DatabaseConfig, clock shared.Clock) error {
+	return shared.RetryWithBackoff(context.Background(), clock,
+		dbConfig.DBConnectMaxRetries, dbConfig.DBConnectBackoff, isRetryableConnectError,
+		func(attempt int) error {
+			err := connectOnce(adapter)
+			if err != nil {
+				log.Printf("Database connection attempt %d/%d failed: %v",
+					attempt+1, dbConfig.DBConnectMaxRetries+1, err)
+			}
+			return err
+		})
+}
+
+// connectOnce performs a single connection attempt bounded by the connection timeout.
+func connectOnce(adapter DBClient) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Connection timeout
 	defer cancel()
+	return adapter.Connect(ctx)
+}
 
-	if err = adapter.Connect(ctx); err != nil {
-		// The specific adapter's Connect method will wrap errors appropriately.
-		return nil, cstmerr.NewDBConnectionError("failed to connect with pg_adapter", err)
+// isRetryableConnectError reports whether a failed connection attempt is worth retrying.
+// Errors reported by the server for bad credentials or an unknown database, and
+// malformed connection strings, will not fix themselves and are returned immediately.
+// Anything else (connection refused, timeouts, "the database system is starting up")
+// is treated as transient.
+func isRetryableConnectError(err error) bool {
+	var parseErr *pgconn.ParseConfigError
+	if errors.As(err, &parseErr) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case strings.HasPrefix(pgErr.Code, "28"): // invalid_authorization_specification, invalid_password
+			return false
+		case pgErr.Code == "3D000": // invalid_catalog_name
+			return false
+		}
 	}
-	return adapter, nil
+	return true
 }
@@ -0,0 +1,129 @@
+package dbclient
+
+import (
+	"context"
+	"embedup-go/configs/config"
+	"embedup-go/internal/clocktest"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// dialClient fails Connect with the queued errors, then connects.
+type dialClient struct {
+	DBClient
+	errs     []error
+	connects int
+}
+
+func (d *dialClient) Connect(ctx context.Context) error {
+	d.connects++
+	if len(d.errs) > 0 {
+		err := d.errs[0]
+		d.errs = d.errs[1:]
+		return err
+	}
+	return nil
+}
+
+var (
+	errRefused = &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	errAuth    = &pgconn.PgError{Severity: "FATAL", Code: "28P01", Message: "password authentication failed"}
+)
+
+func TestConnectWithRetryRetriesRefusedConnections(t *testing.T) {
+	adapter := &dialClient{errs: []error{errRefused, errRefused}}
+	clock := clocktest.NewFakeClock(time.Unix(0, 0))
+	cfg := &config.DatabaseConfig{DBConnectMaxRetries: 5, DBConnectBackoff: time.Second}
+
+	if err := connectWithRetry(adapter, cfg, clock); err != nil {
+		t.Fatalf("connectWithRetry: %v", err)
+	}
+	if adapter.connects != 3 {
+		t.Fatalf("connects = %d, want 3", adapter.connects)
+	}
+	if want := []time.Duration{time.Second, 2 * time.Second}; fmt.Sprint(clock.Sleeps) != fmt.Sprint(want) {
+		t.Fatalf("sleeps = %v, want %v", clock.Sleeps, want)
+	}
+}
+
+func TestConnectWithRetryGivesUp(t *testing.T) {
+	adapter := &dialClient{errs: []error{errRefused, errRefused, errRefused}}
+	cfg := &config.DatabaseConfig{DBConnectMaxRetries: 2, DBConnectBackoff: time.Second}
+
+	if err := connectWithRetry(adapter, cfg, clocktest.NewFakeClock(time.Unix(0, 0))); !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Fatalf("connectWithRetry error = %v, want connection refused", err)
+	}
+	if adapter.connects != 3 {
+		t.Fatalf("connects = %d, want 3", adapter.connects)
+	}
+}
+
+func TestConnectWithRetryDoesNotRetryAuthFailure(t *testing.T) {
+	adapter := &dialClient{errs: []error{fmt.Errorf("connect: %w", errAuth)}}
+	clock := clocktest.NewFakeClock(time.Unix(0, 0))
+	cfg := &config.DatabaseConfig{DBConnectMaxRetries: 5, DBConnectBackoff: time.Second}
+
+	var pgErr *pgconn.PgError
+	if err := connectWithRetry(adapter, cfg, clock); !errors.As(err, &pgErr) || pgErr.Code != "28P01" {
+		t.Fatalf("connectWithRetry error = %v, want the auth failure", err)
+	}
+	if adapter.connects != 1 || len(clock.Sleeps) != 0 {
+		t.Fatalf("connects = %d, sleeps = %v, want a single attempt", adapter.connects, clock.Sleeps)
+	}
+}
+
+func TestIsRetryableConnectError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"connection refused", errRefused, true},
+		{"timeout", context.DeadlineExceeded, true},
+		{"starting up", &pgconn.PgError{Code: "57P03"}, true},
+		{"invalid password", errAuth, false},
+		{"invalid authorization", &pgconn.PgError{Code: "28000"}, false},
+		{"unknown database", fmt.Errorf("connect: %w", &pgconn.PgError{Code: "3D000"}), false},
+		{"malformed dsn", &pgconn.ParseConfigError{ConnString: "port=x"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryableConnectError(tt.err); got != tt.want {
+				t.Fatalf("isRetryableConnectError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConnectClosesStalePool(t *testing.T) {
+	ga, fake := fakeAdapter(t)
+	ga.config = &config.DatabaseConfig{Host: "127.0.0.1", Port: 1, User: "embedup", Password: "secret", DBName: "embedup", SSLMode: "disable"}
+	old, err := ga.db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A healthy pool is kept as it is.
+	if err := ga.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect with a healthy pool: %v", err)
+	}
+	if current, _ := ga.db.DB(); current != old {
+		t.Fatal("Connect replaced a healthy pool")
+	}
+
+	// A pool that fails its ping is closed before it is replaced, even if the new
+	// connection fails too.
+	fake.pingErr = errRefused
+	if err := ga.Connect(context.Background()); err == nil {
+		t.Fatal("Connect to an unreachable server succeeded")
+	}
+	if err := old.Ping(); err == nil || err.Error() != "sql: database is closed" {
+		t.Fatalf("old pool after reconnecting = %v, want it closed", err)
+	}
+}
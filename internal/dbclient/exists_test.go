@@ -0,0 +1,173 @@
+package dbclient
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+
+	"embedup-go/internal/cstmerr"
+	"embedup-go/internal/shared"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// fakeQuery is a statement fakeDB received.
+type fakeQuery struct {
+	sql  string
+	args []interface{}
+}
+
+// fakeDB is a database/sql driver that answers every query with answer, so adapter
+// methods can be tested against result rows without a database.
+type fakeDB struct {
+	mu      sync.Mutex
+	queries []fakeQuery
+	answer  func(sql string) (columns []string, rows [][]driver.Value)
+	pingErr error
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return nil }
+
+// sent returns the statements received so far.
+func (f *fakeDB) sent() []fakeQuery {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeQuery(nil), f.queries...)
+}
+
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return nil, errors.New("fakedb: no transactions") }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fakedb: no prepared statements")
+}
+
+func (c *fakeConn) Ping(context.Context) error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	return c.db.pingErr
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.mu.Lock()
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	c.db.queries = append(c.db.queries, fakeQuery{sql: query, args: values})
+	answer := c.db.answer
+	c.db.mu.Unlock()
+	if answer == nil {
+		return &fakeRows{}, nil
+	}
+	columns, rows := answer(query)
+	return &fakeRows{columns: columns, rows: rows}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// fakeAdapter returns an adapter whose queries go to a fakeDB.
+func fakeAdapter(t *testing.T) (*GORMAdapter, *fakeDB) {
+	t.Helper()
+	fake := &fakeDB{}
+	sqlDB := sql.OpenDB(fake)
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger:         logger.Discard,
+		NamingStrategy: CustomNamingStrategy{schema.NamingStrategy{SingularTable: true}},
+	})
+	if err != nil {
+		t.Fatalf("gorm.Open: %v", err)
+	}
+	return &GORMAdapter{db: db}, fake
+}
+
+// countAnswer answers every query with a single count.
+func countAnswer(count int64) func(string) ([]string, [][]driver.Value) {
+	return func(string) ([]string, [][]driver.Value) {
+		return []string{"count"}, [][]driver.Value{{count}}
+	}
+}
+
+func TestExists(t *testing.T) {
+	for _, count := range []int64{0, 1, 3} {
+		ga, fake := fakeAdapter(t)
+		fake.answer = countAnswer(count)
+
+		exists, err := ga.Exists(context.Background(), &shared.Advertisement{}, `"synced" = ?`, true)
+		if err != nil || exists != (count > 0) {
+			t.Fatalf("Exists with %d matching rows = %t, %v", count, exists, err)
+		}
+		// Without conditions, the model itself is the condition.
+		exists, err = ga.Exists(context.Background(), &shared.Movie{ContentId: 42})
+		if err != nil || exists != (count > 0) {
+			t.Fatalf("Exists(movie 42) with %d matching rows = %t, %v", count, exists, err)
+		}
+
+		want := []fakeQuery{
+			{`SELECT count(*) FROM "advertisement" WHERE "synced" = $1`, []interface{}{true}},
+			{`SELECT count(*) FROM "movie" WHERE "movie"."contentId" = $1`, []interface{}{int64(42)}},
+		}
+		if got := fake.sent(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("queries = %+v, want %+v", got, want)
+		}
+	}
+
+	var dbErr *cstmerr.DBError
+	if _, err := (&GORMAdapter{}).Exists(context.Background(), &shared.Movie{}); !errors.As(err, &dbErr) {
+		t.Fatalf("Exists without a connection = %v, want a DBError", err)
+	}
+}
+
+func TestRecordExists(t *testing.T) {
+	for _, count := range []int64{0, 1} {
+		ga, fake := fakeAdapter(t)
+		fake.answer = countAnswer(count)
+		exists, err := recordExists(ga.db, &shared.Advertisement{ContentId: 7, Synced: true})
+		if err != nil || exists != (count > 0) {
+			t.Fatalf("recordExists with %d matching rows = %t, %v", count, exists, err)
+		}
+		// Only the primary key is matched.
+		sent := fake.sent()
+		if len(sent) != 1 || sent[0].sql != `SELECT count(*) FROM "advertisement" WHERE "advertisement"."contentId" = $1` ||
+			!reflect.DeepEqual(sent[0].args, []interface{}{int64(7)}) {
+			t.Fatalf("queries = %+v", sent)
+		}
+	}
+
+	// A model without a primary key value is new, without asking the database.
+	ga, fake := fakeAdapter(t)
+	fake.answer = countAnswer(1)
+	if exists, err := recordExists(ga.db, &shared.Advertisement{}); err != nil || exists {
+		t.Fatalf("recordExists(zero key) = %t, %v", exists, err)
+	}
+	if sent := fake.sent(); len(sent) != 0 {
+		t.Fatalf("zero key was looked up: %+v", sent)
+	}
+}
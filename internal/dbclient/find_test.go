@@ -0,0 +1,58 @@
+package dbclient
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"embedup-go/internal/shared"
+)
+
+func TestApplyFindQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []FindOption
+		wantSQL  string
+		wantVars []interface{}
+	}{
+		{"no options", nil, `SELECT * FROM "advertisement"`, []interface{}{}},
+		{"greater than", []FindOption{WhereGT("updatedAt", int64(1000))},
+			`SELECT * FROM "advertisement" WHERE "updatedAt" > $1`, []interface{}{int64(1000)}},
+		{"less than", []FindOption{WhereLT("skipDuration", 5)},
+			`SELECT * FROM "advertisement" WHERE "skipDuration" < $1`, []interface{}{5}},
+		{"in", []FindOption{WhereIn("contentId", []int64{1, 2, 3})},
+			`SELECT * FROM "advertisement" WHERE "contentId" IN ($1,$2,$3)`, []interface{}{int64(1), int64(2), int64(3)}},
+		{"order and limit", []FindOption{OrderBy("updatedAt", "DESC"), OrderBy("contentId", "asc"), Limit(10)},
+			`SELECT * FROM "advertisement" ORDER BY "updatedAt" DESC,"contentId" LIMIT $1`, []interface{}{10}},
+		{"combined", []FindOption{WhereGT("updatedAt", int64(1000)), WhereIn("contentId", []int64{4, 5}),
+			OrderBy("updatedAt", "asc"), Limit(2)},
+			`SELECT * FROM "advertisement" WHERE "updatedAt" > $1 AND "contentId" IN ($2,$3) ORDER BY "updatedAt" LIMIT $4`,
+			[]interface{}{int64(1000), int64(4), int64(5), 2}},
+		{"zero limit is unlimited", []FindOption{Limit(0)}, `SELECT * FROM "advertisement"`, []interface{}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ga, _ := dryRunAdapter(t)
+			var ads []shared.Advertisement
+			stmt := applyFindQuery(ga.db, BuildFindQuery(tt.opts...)).Find(&ads).Statement
+			if got := stmt.SQL.String(); got != tt.wantSQL {
+				t.Errorf("SQL = %s\nwant  %s", got, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(stmt.Vars, tt.wantVars) {
+				t.Errorf("vars = %#v, want %#v", stmt.Vars, tt.wantVars)
+			}
+		})
+	}
+}
+
+func TestFindWithRunsQuery(t *testing.T) {
+	ga, rec := dryRunAdapter(t)
+	var ads []shared.Advertisement
+	if err := ga.FindWith(context.Background(), &ads, WhereGT("updatedAt", 1000), Limit(1)); err != nil {
+		t.Fatalf("FindWith: %v", err)
+	}
+	want := `SELECT * FROM "advertisement" WHERE "updatedAt" > 1000 LIMIT 1`
+	if len(rec.statements) != 1 || rec.statements[0] != want {
+		t.Fatalf("statements = %q, want %q", rec.statements, want)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"reflect"
 	"strings"
 	"time"
 	"unicode"
@@ -15,6 +16,7 @@ import (
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 	"gorm.io/gorm/schema"
 )
@@ -101,6 +103,8 @@ func (ga *GORMAdapter) Connect(ctx context.Context) error {
 			if err = sqlDB.PingContext(ctx); err == nil {
 				return nil
 			}
+			// Replacing the pool below; close the old one so its connections are not leaked.
+			_ = sqlDB.Close()
 		}
 	}
 	// 	NOTE: The following commented code is an example of how to create a database if it doesn't exist.
@@ -131,14 +135,6 @@ func (ga *GORMAdapter) Connect(ctx context.Context) error {
 		return cstmerr.NewDBConnectionError("gorm.Open failed", err)
 	}
 
-	// TODO: Uncomment if you want to auto-migrate models
-	ga.db.AutoMigrate(&shared.Updater{})
-	// ga.db.AutoMigrate(shared.AutoMigrateList...)
-	// err = ga.db.SetupJoinTable(&shared.Page{}, "Tabs", &shared.PageTabsTab{})
-	// if err != nil {
-	// 	return cstmerr.NewDBConnectionError("failed to setup join table for Page and Tabs", err)
-	// }
-
 	sqlDB, err := ga.db.DB()
 	if err != nil {
 		return cstmerr.NewDBConnectionError("failed to get underlying sql.DB from GORM", err)
@@ -171,6 +167,35 @@ func (ga *GORMAdapter) Ping(ctx context.Context) error {
 	return sqlDB.PingContext(ctx)
 }
 
+// Migrate auto-migrates models, e.g. shared.AutoMigrateList.
+func (ga *GORMAdapter) Migrate(ctx context.Context, models ...interface{}) error {
+	if ga.db == nil {
+		return cstmerr.NewDBError("database not connected (GORM)", nil)
+	}
+	return migrate(ga.db.WithContext(ctx), models)
+}
+
+// migrate sets up the shared.JoinTables of the given models, then auto-migrates them.
+// Join tables are created along with their many2many fields.
+func migrate(db *gorm.DB, models []interface{}) error {
+	migrating := make(map[reflect.Type]bool, len(models))
+	for _, model := range models {
+		migrating[reflect.TypeOf(model)] = true
+	}
+	for _, jt := range shared.JoinTables {
+		if !migrating[reflect.TypeOf(jt.Model)] {
+			continue
+		}
+		if err := db.SetupJoinTable(jt.Model, jt.Field, jt.JoinModel); err != nil {
+			return cstmerr.NewDBError(fmt.Sprintf("failed to setup join table for %T.%s", jt.Model, jt.Field), err)
+		}
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		return cstmerr.NewDBError("GORM AutoMigrate failed", err)
+	}
+	return nil
+}
+
 // --- ORM-like methods ---
 
 func (ga *GORMAdapter) Create(ctx context.Context, model interface{}) error {
@@ -195,6 +220,57 @@ func (ga *GORMAdapter) Save(ctx context.Context, model interface{}) error {
 	return nil
 }
 
+func (ga *GORMAdapter) SaveReturning(ctx context.Context, model interface{}) (bool, error) {
+	if ga.db == nil {
+		return false, cstmerr.NewDBError("database not connected (GORM)", nil)
+	}
+	return saveReturning(ga.db.WithContext(ctx), model)
+}
+
+// saveReturning saves model, reporting whether its primary key had no row before.
+// The lookup and the save share a transaction so the outcome matches the write.
+func saveReturning(db *gorm.DB, model interface{}) (bool, error) {
+	created := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		exists, err := recordExists(tx, model)
+		if err != nil {
+			return err
+		}
+		if err := tx.Save(model).Error; err != nil {
+			return cstmerr.NewDBQueryError("GORM Save failed", err)
+		}
+		created = !exists
+		return nil
+	})
+	return created, err
+}
+
+// recordExists reports whether a row with the primary key of model exists. A model
+// with a zero primary key is always new.
+func recordExists(db *gorm.DB, model interface{}) (bool, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return false, cstmerr.NewDBError(fmt.Sprintf("failed to resolve table of %T", model), err)
+	}
+	value := reflect.Indirect(reflect.ValueOf(model))
+	conditions := make(map[string]interface{}, len(stmt.Schema.PrimaryFields))
+	for _, field := range stmt.Schema.PrimaryFields {
+		fieldValue, zero := field.ValueOf(db.Statement.Context, value)
+		if zero {
+			return false, nil
+		}
+		conditions[field.DBName] = fieldValue
+	}
+	if len(conditions) == 0 {
+		return false, nil
+	}
+	var count int64
+	if err := db.Table(stmt.Schema.Table).Where(conditions).Count(&count).Error; err != nil {
+		return false, cstmerr.NewDBQueryError("GORM existence check failed", err)
+	}
+	return count > 0, nil
+}
+
 // Updates updates attributes for a record.
 // 'modelWithPK' identifies the record (e.g. User{ID: 1})
 // 'data' is a struct or map for the fields to update (e.g. User{Name: "new name"}, or map[string]interface{}{"name": "new name"})
@@ -252,6 +328,47 @@ func (ga *GORMAdapter) Delete(ctx context.Context, model interface{}, conditions
 	return nil
 }
 
+func (ga *GORMAdapter) DeleteByContentId(ctx context.Context, model interface{}, contentId int64) error {
+	if ga.db == nil {
+		return cstmerr.NewDBError("database not connected (GORM)", nil)
+	}
+	result := ga.db.WithContext(ctx).Where(`"contentId" = ?`, contentId).Delete(model)
+	if result.Error != nil {
+		return cstmerr.NewDBQueryError("GORM DeleteByContentId failed", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return cstmerr.NewDBNotFoundError(fmt.Sprintf("GORM DeleteByContentId found no record with contentId %d", contentId), nil)
+	}
+	return nil
+}
+
+func (ga *GORMAdapter) Truncate(ctx context.Context, models ...interface{}) error {
+	if ga.db == nil {
+		return cstmerr.NewDBError("database not connected (GORM)", nil)
+	}
+	return truncateTables(ga.db.WithContext(ctx), models...)
+}
+
+// truncateTables truncates the tables of models in one statement, cascading to the
+// tables that reference them.
+func truncateTables(db *gorm.DB, models ...interface{}) error {
+	if len(models) == 0 {
+		return nil
+	}
+	tables := make([]string, 0, len(models))
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return cstmerr.NewDBError(fmt.Sprintf("failed to resolve table of %T", model), err)
+		}
+		tables = append(tables, stmt.Quote(stmt.Schema.Table))
+	}
+	if err := db.Exec("TRUNCATE TABLE " + strings.Join(tables, ", ") + " CASCADE").Error; err != nil {
+		return cstmerr.NewDBQueryError("GORM Truncate failed", err)
+	}
+	return nil
+}
+
 func (ga *GORMAdapter) First(ctx context.Context, model interface{}, conditions ...interface{}) error {
 	if ga.db == nil {
 		return cstmerr.NewDBError("database not connected (GORM)", nil)
@@ -275,6 +392,24 @@ func (ga *GORMAdapter) First(ctx context.Context, model interface{}, conditions
 	return nil
 }
 
+func (ga *GORMAdapter) Exists(ctx context.Context, model interface{}, conditions ...interface{}) (bool, error) {
+	if ga.db == nil {
+		return false, cstmerr.NewDBError("database not connected (GORM)", nil)
+	}
+	db := ga.db.WithContext(ctx).Model(model)
+	if len(conditions) > 0 {
+		db = db.Where(conditions[0], conditions[1:]...)
+	} else {
+		// Count does not scope by the model's primary key on its own, so use the model as struct conditions.
+		db = db.Where(model)
+	}
+	var count int64
+	if err := db.Count(&count).Error; err != nil {
+		return false, cstmerr.NewDBQueryError("GORM Exists failed", err)
+	}
+	return count > 0, nil
+}
+
 func (ga *GORMAdapter) Find(ctx context.Context, collection interface{}, conditions ...interface{}) error {
 	if ga.db == nil {
 		return cstmerr.NewDBError("database not connected (GORM)", nil)
@@ -298,6 +433,56 @@ func (ga *GORMAdapter) Find(ctx context.Context, collection interface{}, conditi
 	return nil
 }
 
+func (ga *GORMAdapter) FindWith(ctx context.Context, collection interface{}, opts ...FindOption) error {
+	if ga.db == nil {
+		return cstmerr.NewDBError("database not connected (GORM)", nil)
+	}
+	result := applyFindQuery(ga.db.WithContext(ctx), BuildFindQuery(opts...)).Find(collection)
+	if result.Error != nil {
+		return cstmerr.NewDBQueryError("GORM FindWith failed", result.Error)
+	}
+	return nil
+}
+
+// applyFindQuery translates a FindQuery into GORM clauses. Column names are
+// quoted by the clause builders, so no raw SQL is assembled here.
+func applyFindQuery(db *gorm.DB, q FindQuery) *gorm.DB {
+	for _, c := range q.Conditions {
+		column := clause.Column{Name: c.Column}
+		switch c.Operator {
+		case FIND_OP_GT:
+			db = db.Where(clause.Gt{Column: column, Value: c.Value})
+		case FIND_OP_LT:
+			db = db.Where(clause.Lt{Column: column, Value: c.Value})
+		case FIND_OP_IN:
+			db = db.Where(clause.IN{Column: column, Values: toInterfaceSlice(c.Value)})
+		}
+	}
+	for _, o := range q.OrderBy {
+		db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: o.Column}, Desc: o.Desc})
+	}
+	if q.Limit > 0 {
+		db = db.Limit(q.Limit)
+	}
+	return db
+}
+
+// toInterfaceSlice turns a slice of any element type into []interface{} for clause.IN.
+func toInterfaceSlice(vals interface{}) []interface{} {
+	if vals, ok := vals.([]interface{}); ok {
+		return vals
+	}
+	rv := reflect.ValueOf(vals)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return []interface{}{vals}
+	}
+	out := make([]interface{}, rv.Len())
+	for i := range out {
+		out[i] = rv.Index(i).Interface()
+	}
+	return out
+}
+
 // --- Raw SQL methods ---
 type gormQueryResult struct { // Re-define if not already in this file from previous version
 	rowsAffected int64
@@ -318,25 +503,43 @@ func (ga *GORMAdapter) ExecRaw(ctx context.Context, query string, args ...interf
 	return &gormQueryResult{rowsAffected: result.RowsAffected}, nil
 }
 
-func (ga *GORMAdapter) SelectRaw(ctx context.Context, collectionOrModel interface{}, query string, args ...interface{}) error {
+func (ga *GORMAdapter) SelectRaw(ctx context.Context, model interface{}, query string, args ...interface{}) error {
+	if ga.db == nil {
+		return cstmerr.NewDBError("database not connected (GORM)", nil)
+	}
+	return selectRawRow(ga.db.WithContext(ctx), model, query, args...)
+}
+
+func (ga *GORMAdapter) SelectRawRows(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
 	if ga.db == nil {
 		return cstmerr.NewDBError("database not connected (GORM)", nil)
 	}
-	result := ga.db.WithContext(ctx).Raw(query, args...).Scan(collectionOrModel)
+	return selectRawRows(ga.db.WithContext(ctx), dest, query, args...)
+}
+
+// selectRawRow scans the first row of a raw query into model. Raw().Scan does not
+// report a missing row itself, so that is detected from RowsAffected.
+func selectRawRow(db *gorm.DB, model interface{}, query string, args ...interface{}) error {
+	if kind := reflect.TypeOf(model); kind == nil || kind.Kind() != reflect.Ptr || kind.Elem().Kind() == reflect.Slice {
+		return cstmerr.NewDBError(fmt.Sprintf("SelectRaw needs a pointer to a struct, got %T; use SelectRawRows for slices", model), nil)
+	}
+	result := db.Raw(query, args...).Scan(model)
 	if result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound { // Raw can also return this if Scan expects one row
-			return cstmerr.NewDBNotFoundError(fmt.Sprintf("GORM SelectRaw query (Scan) found no records: %s", query), result.Error)
-		}
 		return cstmerr.NewDBQueryError(fmt.Sprintf("GORM SelectRaw query failed: %s", query), result.Error)
 	}
-	// For Raw().Scan(&slice), if no rows, slice is empty, no error.
-	// If Raw().Scan(&struct), and no rows, result.Error will be gorm.ErrRecordNotFound if RowsAffected is 0
-	if result.RowsAffected == 0 && result.Error == nil {
-		// If scanning into a single struct and no rows are found, GORM might set result.Error to gorm.ErrRecordNotFound.
-		// If scanning into a slice, it will be empty.
-		// We check if the error is already set; if not, and scanning into a single struct (not a slice),
-		// and 0 rows affected, it implies not found. This logic is tricky to make generic here.
-		// The current check `result.Error == gorm.ErrRecordNotFound` should cover most cases for single struct scan.
+	if result.RowsAffected == 0 {
+		return cstmerr.NewDBNotFoundError(fmt.Sprintf("GORM SelectRaw query found no records: %s", query), gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// selectRawRows scans every row of a raw query into dest.
+func selectRawRows(db *gorm.DB, dest interface{}, query string, args ...interface{}) error {
+	if kind := reflect.TypeOf(dest); kind == nil || kind.Kind() != reflect.Ptr || kind.Elem().Kind() != reflect.Slice {
+		return cstmerr.NewDBError(fmt.Sprintf("SelectRawRows needs a pointer to a slice, got %T", dest), nil)
+	}
+	if err := db.Raw(query, args...).Scan(dest).Error; err != nil {
+		return cstmerr.NewDBQueryError(fmt.Sprintf("GORM SelectRawRows query failed: %s", query), err)
 	}
 	return nil
 }
@@ -356,6 +559,9 @@ func (gta *gormTxAdapter) Close() error { /* ... */
 	return cstmerr.NewDBError("cannot close in tx", nil)
 }
 func (gta *gormTxAdapter) Ping(ctx context.Context) error { /* ... */ return nil }
+func (gta *gormTxAdapter) Migrate(ctx context.Context, models ...interface{}) error {
+	return migrate(gta.tx.WithContext(ctx), models)
+}
 
 func (gta *gormTxAdapter) Create(ctx context.Context, model interface{}) error {
 	return gta.tx.WithContext(ctx).Create(model).Error
@@ -363,6 +569,9 @@ func (gta *gormTxAdapter) Create(ctx context.Context, model interface{}) error {
 func (gta *gormTxAdapter) Save(ctx context.Context, model interface{}) error {
 	return gta.tx.WithContext(ctx).Save(model).Error
 }
+func (gta *gormTxAdapter) SaveReturning(ctx context.Context, model interface{}) (bool, error) {
+	return saveReturning(gta.tx.WithContext(ctx), model)
+}
 func (gta *gormTxAdapter) CreateAssosiate(ctx context.Context, model interface{}, assosiation string, assosiate interface{}) error {
 	return gta.tx.WithContext(ctx).Model(model).Association(assosiation).Append(assosiate)
 }
@@ -378,6 +587,19 @@ func (gta *gormTxAdapter) Delete(ctx context.Context, model interface{}, conditi
 	}
 	return gta.tx.WithContext(ctx).Delete(model).Error
 }
+func (gta *gormTxAdapter) DeleteByContentId(ctx context.Context, model interface{}, contentId int64) error {
+	result := gta.tx.WithContext(ctx).Where(`"contentId" = ?`, contentId).Delete(model)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return cstmerr.NewDBNotFoundError(fmt.Sprintf("GORM DeleteByContentId (TX) found no record with contentId %d", contentId), nil)
+	}
+	return nil
+}
+func (gta *gormTxAdapter) Truncate(ctx context.Context, models ...interface{}) error {
+	return truncateTables(gta.tx.WithContext(ctx), models...)
+}
 func (gta *gormTxAdapter) First(ctx context.Context, model interface{}, conditions ...interface{}) error {
 	var result *gorm.DB
 	if len(conditions) > 0 {
@@ -390,6 +612,17 @@ func (gta *gormTxAdapter) First(ctx context.Context, model interface{}, conditio
 	}
 	return result.Error
 }
+func (gta *gormTxAdapter) Exists(ctx context.Context, model interface{}, conditions ...interface{}) (bool, error) {
+	db := gta.tx.WithContext(ctx).Model(model)
+	if len(conditions) > 0 {
+		db = db.Where(conditions[0], conditions[1:]...)
+	} else {
+		db = db.Where(model)
+	}
+	var count int64
+	err := db.Count(&count).Error
+	return count > 0, err
+}
 func (gta *gormTxAdapter) Find(ctx context.Context, collection interface{}, conditions ...interface{}) error {
 	var result *gorm.DB
 	if len(conditions) > 0 {
@@ -399,6 +632,9 @@ func (gta *gormTxAdapter) Find(ctx context.Context, collection interface{}, cond
 	}
 	return result.Error
 }
+func (gta *gormTxAdapter) FindWith(ctx context.Context, collection interface{}, opts ...FindOption) error {
+	return applyFindQuery(gta.tx.WithContext(ctx), BuildFindQuery(opts...)).Find(collection).Error
+}
 func (gta *gormTxAdapter) ExecRaw(ctx context.Context, query string, args ...interface{}) (QueryResult, error) {
 	res := gta.tx.WithContext(ctx).Exec(query, args...)
 	if res.Error != nil {
@@ -406,12 +642,11 @@ func (gta *gormTxAdapter) ExecRaw(ctx context.Context, query string, args ...int
 	}
 	return &gormQueryResult{rowsAffected: res.RowsAffected}, nil
 }
-func (gta *gormTxAdapter) SelectRaw(ctx context.Context, collectionOrModel interface{}, query string, args ...interface{}) error {
-	res := gta.tx.WithContext(ctx).Raw(query, args...).Scan(collectionOrModel)
-	if res.Error != nil && res.Error == gorm.ErrRecordNotFound {
-		return cstmerr.NewDBNotFoundError("GORM SelectRaw (TX) not found", res.Error)
-	}
-	return res.Error
+func (gta *gormTxAdapter) SelectRaw(ctx context.Context, model interface{}, query string, args ...interface{}) error {
+	return selectRawRow(gta.tx.WithContext(ctx), model, query, args...)
+}
+func (gta *gormTxAdapter) SelectRawRows(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return selectRawRows(gta.tx.WithContext(ctx), dest, query, args...)
 }
 func (gta *gormTxAdapter) RunInTransaction(ctx context.Context, fn func(ctx context.Context, txClient DBClient) error) error {
 	return cstmerr.NewDBError("nested transactions not directly supported by this basic GORM tx adapter", nil)
@@ -0,0 +1,116 @@
+package dbclient
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"embedup-go/internal/shared"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// sqlRecorder is a gorm logger that keeps every traced statement.
+type sqlRecorder struct {
+	logger.Interface
+	statements []string
+}
+
+func (r *sqlRecorder) LogMode(logger.LogLevel) logger.Interface { return r }
+
+func (r *sqlRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	r.statements = append(r.statements, sql)
+}
+
+// dryRunAdapter returns an adapter whose statements are recorded but never sent to a database.
+func dryRunAdapter(t *testing.T) (*GORMAdapter, *sqlRecorder) {
+	t.Helper()
+	rec := &sqlRecorder{Interface: logger.Discard}
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1 sslmode=disable"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+		Logger:               rec,
+		NamingStrategy:       CustomNamingStrategy{schema.NamingStrategy{SingularTable: true}},
+	})
+	if err != nil {
+		t.Fatalf("gorm.Open: %v", err)
+	}
+	return &GORMAdapter{db: db}, rec
+}
+
+// createdTable returns the CREATE TABLE statement of table, if any.
+func (r *sqlRecorder) createdTable(table string) string {
+	for _, sql := range r.statements {
+		if strings.HasPrefix(sql, `CREATE TABLE "`+table+`"`) {
+			return sql
+		}
+	}
+	return ""
+}
+
+func TestMigrateCreatesJoinTables(t *testing.T) {
+	ga, rec := dryRunAdapter(t)
+	if err := ga.Migrate(context.Background(), shared.AutoMigrateList...); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	joinTables := map[string][]string{
+		"page_tabs_tab":        {`"pageContentId" bigint`, `"tabContentId" bigint`, `PRIMARY KEY ("pageContentId","tabContentId")`},
+		"slider_tabs_tab":      {`"sliderContentId" bigint`, `"tabContentId" bigint`, `PRIMARY KEY ("sliderContentId","tabContentId")`},
+		"tab_sections_section": {`"tabContentId" bigint`, `"sectionContentId" bigint`, `PRIMARY KEY ("tabContentId","sectionContentId")`},
+	}
+	for table, columns := range joinTables {
+		sql := rec.createdTable(table)
+		if sql == "" {
+			t.Errorf("join table %s was not created", table)
+			continue
+		}
+		for _, column := range columns {
+			if !strings.Contains(sql, column) {
+				t.Errorf("%s: missing %s in %s", table, column, sql)
+			}
+		}
+	}
+	for _, table := range []string{"page", "tab", "slider", "section"} {
+		if rec.createdTable(table) == "" {
+			t.Errorf("table %s was not created", table)
+		}
+	}
+}
+
+func TestMigrateUsesJoinModels(t *testing.T) {
+	ga, _ := dryRunAdapter(t)
+	if err := ga.Migrate(context.Background(), shared.AutoMigrateList...); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	for _, jt := range shared.JoinTables {
+		stmt := &gorm.Statement{DB: ga.db}
+		if err := stmt.Parse(jt.Model); err != nil {
+			t.Fatalf("parse %T: %v", jt.Model, err)
+		}
+		rel := stmt.Schema.Relationships.Relations[jt.Field]
+		if rel == nil || rel.JoinTable == nil {
+			t.Fatalf("%T.%s is not a many2many relation", jt.Model, jt.Field)
+		}
+		joinStmt := &gorm.Statement{DB: ga.db}
+		if err := joinStmt.Parse(jt.JoinModel); err != nil {
+			t.Fatalf("parse %T: %v", jt.JoinModel, err)
+		}
+		joinSchema := joinStmt.Schema
+		if rel.JoinTable.Table != joinSchema.Table || rel.JoinTable.ModelType != joinSchema.ModelType {
+			t.Errorf("%T.%s joins through %s (%v), want %s (%v)", jt.Model, jt.Field,
+				rel.JoinTable.Table, rel.JoinTable.ModelType, joinSchema.Table, joinSchema.ModelType)
+		}
+	}
+}
+
+func TestMigrateWithoutConnection(t *testing.T) {
+	if err := (&GORMAdapter{}).Migrate(context.Background(), &shared.Updater{}); err == nil {
+		t.Fatal("Migrate on an unconnected adapter succeeded")
+	}
+}
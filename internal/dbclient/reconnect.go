@@ -0,0 +1,182 @@
+package dbclient
+
+import (
+	"context"
+	"database/sql/driver"
+	"embedup-go/internal/cstmerr"
+	"embedup-go/internal/shared"
+	"errors"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// reconnectingClient wraps a DBClient so that an operation failing because the
+// database connection was lost (e.g. Postgres restarted mid-cycle) is retried instead
+// of failing right away. The underlying sql.DB pool drops broken connections and dials
+// new ones by itself, so a retry is all it takes to reconnect.
+type reconnectingClient struct {
+	DBClient
+	clock      shared.Clock
+	maxRetries int
+	backoff    time.Duration
+}
+
+// NewReconnectingClient retries operations on db up to maxRetries times on connection
+// errors, doubling backoff after each attempt. A maxRetries
+// of 0 or less returns db unchanged. Once the retries are used up the operation fails
+// with a DBConnectionError.
+func NewReconnectingClient(db DBClient, clock shared.Clock, maxRetries int, backoff time.Duration) DBClient {
+	if maxRetries <= 0 {
+		return db
+	}
+	return &reconnectingClient{DBClient: db, clock: clock, maxRetries: maxRetries, backoff: backoff}
+}
+
+// isConnectionError reports whether err means the database connection was lost or
+// could not be made, as opposed to a problem with the query itself. A context that
+// expired or was canceled is not a connection error, even though the driver may
+// report it as a net.Error timeout.
+func isConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case strings.HasPrefix(pgErr.Code, "08"): // connection_exception
+			return true
+		case pgErr.Code == "57P01", pgErr.Code == "57P02", pgErr.Code == "57P03": // shutdowns, cannot_connect_now
+			return true
+		}
+		return false
+	}
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// do runs op, retrying while it fails with a connection error.
+func (rc *reconnectingClient) do(ctx context.Context, op func() error) error {
+	err := shared.RetryWithBackoff(ctx, rc.clock, rc.maxRetries, rc.backoff, isConnectionError,
+		func(attempt int) error {
+			if attempt > 0 {
+				log.Printf("Database connection lost, retrying (attempt %d/%d)", attempt, rc.maxRetries)
+			}
+			return op()
+		})
+	if isConnectionError(err) {
+		return cstmerr.NewDBConnectionError("database unavailable", err)
+	}
+	return err
+}
+
+func (rc *reconnectingClient) Ping(ctx context.Context) error {
+	return rc.do(ctx, func() error { return rc.DBClient.Ping(ctx) })
+}
+
+func (rc *reconnectingClient) Migrate(ctx context.Context, models ...interface{}) error {
+	return rc.do(ctx, func() error { return rc.DBClient.Migrate(ctx, models...) })
+}
+
+func (rc *reconnectingClient) Create(ctx context.Context, model interface{}) error {
+	return rc.do(ctx, func() error { return rc.DBClient.Create(ctx, model) })
+}
+
+func (rc *reconnectingClient) Save(ctx context.Context, model interface{}) error {
+	return rc.do(ctx, func() error { return rc.DBClient.Save(ctx, model) })
+}
+
+func (rc *reconnectingClient) SaveReturning(ctx context.Context, model interface{}) (bool, error) {
+	var created bool
+	err := rc.do(ctx, func() error {
+		var err error
+		created, err = rc.DBClient.SaveReturning(ctx, model)
+		return err
+	})
+	return created, err
+}
+
+func (rc *reconnectingClient) Updates(ctx context.Context, modelWithPK interface{}, data interface{}) error {
+	return rc.do(ctx, func() error { return rc.DBClient.Updates(ctx, modelWithPK, data) })
+}
+
+func (rc *reconnectingClient) Delete(ctx context.Context, model interface{}, conditions ...interface{}) error {
+	return rc.do(ctx, func() error { return rc.DBClient.Delete(ctx, model, conditions...) })
+}
+
+func (rc *reconnectingClient) DeleteByContentId(ctx context.Context, model interface{}, contentId int64) error {
+	return rc.do(ctx, func() error { return rc.DBClient.DeleteByContentId(ctx, model, contentId) })
+}
+
+func (rc *reconnectingClient) Truncate(ctx context.Context, models ...interface{}) error {
+	return rc.do(ctx, func() error { return rc.DBClient.Truncate(ctx, models...) })
+}
+
+func (rc *reconnectingClient) First(ctx context.Context, model interface{}, conditions ...interface{}) error {
+	return rc.do(ctx, func() error { return rc.DBClient.First(ctx, model, conditions...) })
+}
+
+func (rc *reconnectingClient) Exists(ctx context.Context, model interface{}, conditions ...interface{}) (bool, error) {
+	var exists bool
+	err := rc.do(ctx, func() error {
+		var err error
+		exists, err = rc.DBClient.Exists(ctx, model, conditions...)
+		return err
+	})
+	return exists, err
+}
+
+func (rc *reconnectingClient) Find(ctx context.Context, collection interface{}, conditions ...interface{}) error {
+	return rc.do(ctx, func() error { return rc.DBClient.Find(ctx, collection, conditions...) })
+}
+
+func (rc *reconnectingClient) FindWith(ctx context.Context, collection interface{}, opts ...FindOption) error {
+	return rc.do(ctx, func() error { return rc.DBClient.FindWith(ctx, collection, opts...) })
+}
+
+func (rc *reconnectingClient) ExecRaw(ctx context.Context, query string, args ...interface{}) (QueryResult, error) {
+	var result QueryResult
+	err := rc.do(ctx, func() error {
+		var err error
+		result, err = rc.DBClient.ExecRaw(ctx, query, args...)
+		return err
+	})
+	return result, err
+}
+
+func (rc *reconnectingClient) SelectRaw(ctx context.Context, model interface{}, query string, args ...interface{}) error {
+	return rc.do(ctx, func() error { return rc.DBClient.SelectRaw(ctx, model, query, args...) })
+}
+
+func (rc *reconnectingClient) SelectRawRows(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return rc.do(ctx, func() error { return rc.DBClient.SelectRawRows(ctx, dest, query, args...) })
+}
+
+// RunInTransaction retries the whole transaction; a transaction interrupted by a lost
+// connection has been rolled back by the server.
+func (rc *reconnectingClient) RunInTransaction(ctx context.Context, fn func(ctx context.Context, txClient DBClient) error) error {
+	return rc.do(ctx, func() error { return rc.DBClient.RunInTransaction(ctx, fn) })
+}
+
+func (rc *reconnectingClient) CreateAssosiate(ctx context.Context, model interface{},
+	assosiation string, assosiate interface{}) error {
+	return rc.do(ctx, func() error { return rc.DBClient.CreateAssosiate(ctx, model, assosiation, assosiate) })
+}
+
+func (rc *reconnectingClient) DeleteAssosiate(ctx context.Context, model interface{},
+	assosiation string, assosiate interface{}) error {
+	return rc.do(ctx, func() error { return rc.DBClient.DeleteAssosiate(ctx, model, assosiation, assosiate) })
+}
@@ -0,0 +1,113 @@
+package dbclient
+
+import (
+	"context"
+	"database/sql/driver"
+	"embedup-go/internal/clocktest"
+	"embedup-go/internal/cstmerr"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+// flakyClient fails Save with the queued errors, then succeeds, and counts Connect calls.
+type flakyClient struct {
+	DBClient
+	errs     []error
+	saves    int
+	connects int
+}
+
+func (f *flakyClient) Save(ctx context.Context, model interface{}) error {
+	f.saves++
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return err
+	}
+	return nil
+}
+
+func (f *flakyClient) Connect(ctx context.Context) error {
+	f.connects++
+	return nil
+}
+
+// timeoutError is a net.Error timeout, like the one pgx returns when a deadline passes.
+type timeoutError struct{ error }
+
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+func (e timeoutError) Unwrap() error { return e.error }
+
+var _ net.Error = timeoutError{}
+
+func TestIsConnectionError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"bad conn", fmt.Errorf("query: %w", driver.ErrBadConn), true},
+		{"net error", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"deadline exceeded", context.DeadlineExceeded, false},
+		{"canceled", fmt.Errorf("query: %w", context.Canceled), false},
+		{"deadline as net error", timeoutError{fmt.Errorf("read: %w", context.DeadlineExceeded)}, false},
+		{"query error", errors.New("syntax error"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isConnectionError(tt.err); got != tt.want {
+				t.Fatalf("isConnectionError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconnectingClientRetriesWithoutReconnecting(t *testing.T) {
+	inner := &flakyClient{errs: []error{driver.ErrBadConn, driver.ErrBadConn}}
+	clock := clocktest.NewFakeClock(time.Unix(0, 0))
+	db := NewReconnectingClient(inner, clock, 3, time.Second)
+
+	if err := db.Save(context.Background(), struct{}{}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if inner.saves != 3 {
+		t.Fatalf("saves = %d, want 3", inner.saves)
+	}
+	if inner.connects != 0 {
+		t.Fatalf("connects = %d, want 0; retries must reuse the pool", inner.connects)
+	}
+	if want := []time.Duration{time.Second, 2 * time.Second}; fmt.Sprint(clock.Sleeps) != fmt.Sprint(want) {
+		t.Fatalf("sleeps = %v, want %v", clock.Sleeps, want)
+	}
+}
+
+func TestReconnectingClientGivesUp(t *testing.T) {
+	inner := &flakyClient{errs: []error{driver.ErrBadConn, driver.ErrBadConn, driver.ErrBadConn}}
+	db := NewReconnectingClient(inner, clocktest.NewFakeClock(time.Unix(0, 0)), 2, time.Second)
+
+	err := db.Save(context.Background(), struct{}{})
+	var connErr *cstmerr.DBConnectionError
+	if !errors.As(err, &connErr) {
+		t.Fatalf("Save error = %v, want a DBConnectionError", err)
+	}
+	if inner.saves != 3 {
+		t.Fatalf("saves = %d, want 3", inner.saves)
+	}
+}
+
+func TestReconnectingClientDoesNotRetryDeadline(t *testing.T) {
+	inner := &flakyClient{errs: []error{context.DeadlineExceeded}}
+	db := NewReconnectingClient(inner, clocktest.NewFakeClock(time.Unix(0, 0)), 3, time.Second)
+
+	if err := db.Save(context.Background(), struct{}{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Save error = %v, want context.DeadlineExceeded", err)
+	}
+	if inner.saves != 1 {
+		t.Fatalf("saves = %d, want 1", inner.saves)
+	}
+}
@@ -0,0 +1,87 @@
+package dbclient
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+
+	"embedup-go/internal/cstmerr"
+)
+
+type rawRow struct {
+	ContentId int64  `gorm:"column:contentId"`
+	Title     string `gorm:"column:title"`
+}
+
+// rowsAnswer answers every query with rows of contentId and title.
+func rowsAnswer(rows ...rawRow) func(string) ([]string, [][]driver.Value) {
+	return func(string) ([]string, [][]driver.Value) {
+		values := make([][]driver.Value, len(rows))
+		for i, row := range rows {
+			values[i] = []driver.Value{row.ContentId, row.Title}
+		}
+		return []string{"contentId", "title"}, values
+	}
+}
+
+const rawQuery = `SELECT "contentId", "title" FROM "movie" WHERE "title" LIKE ?`
+
+func TestSelectRawRows(t *testing.T) {
+	tests := []struct {
+		name string
+		rows []rawRow
+	}{
+		{"empty", nil},
+		{"single row", []rawRow{{1, "Arrival"}}},
+		{"several rows", []rawRow{{1, "Arrival"}, {2, "Heat"}, {3, "Ran"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ga, fake := fakeAdapter(t)
+			fake.answer = rowsAnswer(tt.rows...)
+			var got []rawRow
+			if err := ga.SelectRawRows(context.Background(), &got, rawQuery, "%a%"); err != nil {
+				t.Fatalf("SelectRawRows: %v", err)
+			}
+			if len(got) != len(tt.rows) || (len(got) > 0 && !reflect.DeepEqual(got, tt.rows)) {
+				t.Fatalf("rows = %+v, want %+v", got, tt.rows)
+			}
+			sent := fake.sent()
+			if len(sent) != 1 || sent[0].sql != `SELECT "contentId", "title" FROM "movie" WHERE "title" LIKE $1` ||
+				!reflect.DeepEqual(sent[0].args, []interface{}{"%a%"}) {
+				t.Fatalf("queries = %+v", sent)
+			}
+		})
+	}
+
+	ga, _ := fakeAdapter(t)
+	var dbErr *cstmerr.DBError
+	if err := ga.SelectRawRows(context.Background(), &rawRow{}, rawQuery, "%a%"); !errors.As(err, &dbErr) {
+		t.Fatalf("SelectRawRows into a struct = %v, want a DBError", err)
+	}
+}
+
+func TestSelectRaw(t *testing.T) {
+	ga, fake := fakeAdapter(t)
+	fake.answer = rowsAnswer(rawRow{1, "Arrival"}, rawRow{2, "Heat"})
+	var got rawRow
+	if err := ga.SelectRaw(context.Background(), &got, rawQuery, "%a%"); err != nil {
+		t.Fatalf("SelectRaw: %v", err)
+	}
+	if got != (rawRow{1, "Arrival"}) {
+		t.Fatalf("row = %+v, want the first one", got)
+	}
+
+	fake.answer = rowsAnswer()
+	var notFound *cstmerr.DBNotFoundError
+	if err := ga.SelectRaw(context.Background(), &got, rawQuery, "%z%"); !errors.As(err, &notFound) {
+		t.Fatalf("SelectRaw without rows = %v, want a DBNotFoundError", err)
+	}
+
+	var dbErr *cstmerr.DBError
+	if err := ga.SelectRaw(context.Background(), &[]rawRow{}, rawQuery, "%a%"); !errors.As(err, &dbErr) {
+		t.Fatalf("SelectRaw into a slice = %v, want a DBError", err)
+	}
+}
@@ -0,0 +1,177 @@
+package dbclient
+
+import (
+	"context"
+	"embedup-go/internal/shared"
+	"log"
+	"sync"
+	"time"
+)
+
+// watchdogClient wraps a DBClient with a background health check that pings the
+// database every interval and, when the ping fails, calls Connect to refresh the pool,
+// so a connection gone stale between cycles is restored before the next operation
+// needs it. A reconnect waits for running operations and transactions to finish, and
+// operations started meanwhile wait for the reconnect.
+type watchdogClient struct {
+	DBClient
+	target   DBClient // Pinged and reconnected directly, bypassing any retry wrapper
+	clock    shared.Clock
+	interval time.Duration
+
+	opMu sync.RWMutex // Held shared by operations, exclusively by a reconnect
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewHealthWatchdog starts a watchdog checking target every interval and returns db
+// with its operations guarded against the watchdog's reconnects. Close stops the
+// watchdog. An interval of 0 or less returns db unchanged.
+func NewHealthWatchdog(db DBClient, target DBClient, clock shared.Clock, interval time.Duration) DBClient {
+	if interval <= 0 {
+		return db
+	}
+	w := &watchdogClient{DBClient: db, target: target, clock: clock, interval: interval, stop: make(chan struct{})}
+	go w.run()
+	return w
+}
+
+func (w *watchdogClient) run() {
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-w.clock.After(w.interval):
+			w.check()
+		}
+	}
+}
+
+// check pings the database and reconnects if the ping fails.
+func (w *watchdogClient) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Connection timeout
+	defer cancel()
+	err := w.target.Ping(ctx)
+	if err == nil {
+		return
+	}
+	log.Printf("Database health check failed, reconnecting: %v", err)
+	w.opMu.Lock()
+	defer w.opMu.Unlock()
+	if err := w.target.Connect(ctx); err != nil {
+		log.Printf("Database reconnect failed, retrying in %v: %v", w.interval, err)
+		return
+	}
+	log.Printf("Database connection restored")
+}
+
+// guard runs op while no reconnect is in progress.
+func (w *watchdogClient) guard(op func() error) error {
+	w.opMu.RLock()
+	defer w.opMu.RUnlock()
+	return op()
+}
+
+func (w *watchdogClient) Close() error {
+	w.once.Do(func() { close(w.stop) })
+	return w.DBClient.Close()
+}
+
+// Ping waits for a reconnect in progress, which replaces the pool being pinged.
+func (w *watchdogClient) Ping(ctx context.Context) error {
+	return w.guard(func() error { return w.DBClient.Ping(ctx) })
+}
+
+func (w *watchdogClient) Migrate(ctx context.Context, models ...interface{}) error {
+	return w.guard(func() error { return w.DBClient.Migrate(ctx, models...) })
+}
+
+func (w *watchdogClient) Create(ctx context.Context, model interface{}) error {
+	return w.guard(func() error { return w.DBClient.Create(ctx, model) })
+}
+
+func (w *watchdogClient) Save(ctx context.Context, model interface{}) error {
+	return w.guard(func() error { return w.DBClient.Save(ctx, model) })
+}
+
+func (w *watchdogClient) SaveReturning(ctx context.Context, model interface{}) (bool, error) {
+	var created bool
+	err := w.guard(func() error {
+		var err error
+		created, err = w.DBClient.SaveReturning(ctx, model)
+		return err
+	})
+	return created, err
+}
+
+func (w *watchdogClient) Updates(ctx context.Context, modelWithPK interface{}, data interface{}) error {
+	return w.guard(func() error { return w.DBClient.Updates(ctx, modelWithPK, data) })
+}
+
+func (w *watchdogClient) Delete(ctx context.Context, model interface{}, conditions ...interface{}) error {
+	return w.guard(func() error { return w.DBClient.Delete(ctx, model, conditions...) })
+}
+
+func (w *watchdogClient) DeleteByContentId(ctx context.Context, model interface{}, contentId int64) error {
+	return w.guard(func() error { return w.DBClient.DeleteByContentId(ctx, model, contentId) })
+}
+
+func (w *watchdogClient) Truncate(ctx context.Context, models ...interface{}) error {
+	return w.guard(func() error { return w.DBClient.Truncate(ctx, models...) })
+}
+
+func (w *watchdogClient) First(ctx context.Context, model interface{}, conditions ...interface{}) error {
+	return w.guard(func() error { return w.DBClient.First(ctx, model, conditions...) })
+}
+
+func (w *watchdogClient) Exists(ctx context.Context, model interface{}, conditions ...interface{}) (bool, error) {
+	var exists bool
+	err := w.guard(func() error {
+		var err error
+		exists, err = w.DBClient.Exists(ctx, model, conditions...)
+		return err
+	})
+	return exists, err
+}
+
+func (w *watchdogClient) Find(ctx context.Context, collection interface{}, conditions ...interface{}) error {
+	return w.guard(func() error { return w.DBClient.Find(ctx, collection, conditions...) })
+}
+
+func (w *watchdogClient) FindWith(ctx context.Context, collection interface{}, opts ...FindOption) error {
+	return w.guard(func() error { return w.DBClient.FindWith(ctx, collection, opts...) })
+}
+
+func (w *watchdogClient) ExecRaw(ctx context.Context, query string, args ...interface{}) (QueryResult, error) {
+	var result QueryResult
+	err := w.guard(func() error {
+		var err error
+		result, err = w.DBClient.ExecRaw(ctx, query, args...)
+		return err
+	})
+	return result, err
+}
+
+func (w *watchdogClient) SelectRaw(ctx context.Context, model interface{}, query string, args ...interface{}) error {
+	return w.guard(func() error { return w.DBClient.SelectRaw(ctx, model, query, args...) })
+}
+
+func (w *watchdogClient) SelectRawRows(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return w.guard(func() error { return w.DBClient.SelectRawRows(ctx, dest, query, args...) })
+}
+
+// RunInTransaction holds off reconnects for the whole transaction. fn must use txClient
+// rather than w, which would wait on a pending reconnect that waits on fn.
+func (w *watchdogClient) RunInTransaction(ctx context.Context, fn func(ctx context.Context, txClient DBClient) error) error {
+	return w.guard(func() error { return w.DBClient.RunInTransaction(ctx, fn) })
+}
+
+func (w *watchdogClient) CreateAssosiate(ctx context.Context, model interface{},
+	assosiation string, assosiate interface{}) error {
+	return w.guard(func() error { return w.DBClient.CreateAssosiate(ctx, model, assosiation, assosiate) })
+}
+
+func (w *watchdogClient) DeleteAssosiate(ctx context.Context, model interface{},
+	assosiation string, assosiate interface{}) error {
+	return w.guard(func() error { return w.DBClient.DeleteAssosiate(ctx, model, assosiation, assosiate) })
+}
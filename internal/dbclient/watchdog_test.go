@@ -0,0 +1,190 @@
+package dbclient
+
+import (
+	"context"
+	"embedup-go/internal/clocktest"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// pingClient records Ping and Connect calls. Ping fails while down is set, and Connect
+// clears it, as a reconnect restores a dropped connection. Save blocks until release
+// is closed.
+type pingClient struct {
+	DBClient
+	down     atomic.Bool
+	pings    chan error
+	connects atomic.Int32
+	saving   chan struct{}
+	release  chan struct{}
+}
+
+func (p *pingClient) Ping(ctx context.Context) error {
+	var err error
+	if p.down.Load() {
+		err = errors.New("connection refused")
+	}
+	p.pings <- err
+	return err
+}
+
+func (p *pingClient) Connect(ctx context.Context) error {
+	p.connects.Add(1)
+	p.down.Store(false)
+	return nil
+}
+
+func (p *pingClient) Save(ctx context.Context, model interface{}) error {
+	p.saving <- struct{}{}
+	<-p.release
+	return nil
+}
+
+func (p *pingClient) Close() error { return nil }
+
+// nextPing advances clock until the watchdog pings, and returns that ping's result.
+func nextPing(t *testing.T, clock *clocktest.FakeClock, target *pingClient, interval time.Duration) error {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		clock.Advance(interval)
+		select {
+		case err := <-target.pings:
+			return err
+		case <-time.After(10 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("watchdog did not ping")
+		}
+	}
+}
+
+// waitFor polls cond until it holds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHealthWatchdogRestoresDroppedConnection(t *testing.T) {
+	target := &pingClient{pings: make(chan error)}
+	clock := clocktest.NewFakeClock(time.Unix(0, 0))
+	db := NewHealthWatchdog(target, target, clock, time.Minute)
+	defer db.Close()
+
+	if err := nextPing(t, clock, target, time.Minute); err != nil {
+		t.Fatalf("healthy ping: %v", err)
+	}
+	if n := target.connects.Load(); n != 0 {
+		t.Fatalf("connects = %d after a healthy ping, want 0", n)
+	}
+
+	target.down.Store(true)
+	if err := nextPing(t, clock, target, time.Minute); err == nil {
+		t.Fatal("expected the ping of a dropped connection to fail")
+	}
+	waitFor(t, "the reconnect", func() bool { return target.connects.Load() == 1 })
+	if err := nextPing(t, clock, target, time.Minute); err != nil {
+		t.Fatalf("ping after the reconnect: %v", err)
+	}
+	if n := target.connects.Load(); n != 1 {
+		t.Fatalf("connects = %d, want a single reconnect", n)
+	}
+}
+
+func TestHealthWatchdogReconnectWaitsForOperations(t *testing.T) {
+	target := &pingClient{pings: make(chan error), saving: make(chan struct{}), release: make(chan struct{})}
+	clock := clocktest.NewFakeClock(time.Unix(0, 0))
+	db := NewHealthWatchdog(target, target, clock, time.Minute)
+	defer db.Close()
+
+	saved := make(chan error)
+	go func() { saved <- db.Save(context.Background(), &struct{}{}) }()
+	<-target.saving
+
+	target.down.Store(true)
+	if err := nextPing(t, clock, target, time.Minute); err == nil {
+		t.Fatal("expected the ping of a dropped connection to fail")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := target.connects.Load(); n != 0 {
+		t.Fatal("reconnected while an operation was running")
+	}
+
+	close(target.release)
+	if err := <-saved; err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	waitFor(t, "the reconnect", func() bool { return target.connects.Load() == 1 })
+}
+
+func TestHealthWatchdogDisabled(t *testing.T) {
+	target := &pingClient{pings: make(chan error)}
+	if db := NewHealthWatchdog(target, target, clocktest.NewFakeClock(time.Unix(0, 0)), 0); db != DBClient(target) {
+		t.Fatal("expected db to be returned unchanged when the interval is 0")
+	}
+}
+
+// poolSwapClient has no pool until Connect, which blocks until release is closed
+// and then sets it, as GORMAdapter replaces its pool.
+type poolSwapClient struct {
+	DBClient
+	pool       *struct{}
+	connecting chan struct{}
+	release    chan struct{}
+}
+
+func (r *poolSwapClient) Ping(ctx context.Context) error {
+	if r.pool == nil {
+		return errors.New("database not connected")
+	}
+	return nil
+}
+
+func (r *poolSwapClient) Connect(ctx context.Context) error {
+	close(r.connecting)
+	<-r.release
+	r.pool = &struct{}{}
+	return nil
+}
+
+func (r *poolSwapClient) Close() error { return nil }
+
+func TestHealthWatchdogPingWaitsForReconnect(t *testing.T) {
+	target := &poolSwapClient{connecting: make(chan struct{}), release: make(chan struct{})}
+	clock := clocktest.NewFakeClock(time.Unix(0, 0))
+	db := NewHealthWatchdog(target, target, clock, time.Minute)
+	defer db.Close()
+
+	deadline := time.After(5 * time.Second)
+reconnecting:
+	for {
+		clock.Advance(time.Minute)
+		select {
+		case <-target.connecting:
+			break reconnecting
+		case <-time.After(10 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("watchdog did not reconnect")
+		}
+	}
+
+	pinged := make(chan error)
+	go func() { pinged <- db.Ping(context.Background()) }()
+	select {
+	case err := <-pinged:
+		t.Fatalf("Ping returned %v during the reconnect", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(target.release)
+	if err := <-pinged; err != nil {
+		t.Fatalf("Ping after the reconnect: %v", err)
+	}
+}
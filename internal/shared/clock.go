@@ -0,0 +1,69 @@
+package shared
+
+import (
+	"context"
+	"embedup-go/internal/backoff"
+	"log"
+	"time"
+)
+
+// Clock abstracts time so that backoff and polling can be driven deterministically.
+type Clock interface {
+	Now() time.Time
+	// Sleep blocks for d or until ctx is done, in which case it returns ctx.Err().
+	Sleep(ctx context.Context, d time.Duration) error
+	After(d time.Duration) <-chan time.Time
+}
+
+// RealClock implements Clock using the time package.
+type RealClock struct{}
+
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+func (RealClock) Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (RealClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// RetryWithBackoff calls fn until it succeeds, retryable reports false for its error,
+// or maxRetries retries have been made. The delay between attempts starts at backoff
+// and doubles after each failed attempt. Each retry is also drawn from the
+// backoff.Budget of ctx, if any, and none are made once it is spent. The last error
+// is returned.
+func RetryWithBackoff(ctx context.Context, clock Clock, maxRetries int, initial time.Duration,
+	retryable func(error) bool, fn func(attempt int) error) error {
+	delays := backoff.Policy{Base: initial, Multiplier: 2, MaxAttempts: maxRetries}
+	for attempt := 0; ; attempt++ {
+		err := fn(attempt)
+		if err == nil {
+			return nil
+		}
+		if retryable != nil && !retryable(err) {
+			return err
+		}
+		// A Policy with MaxAttempts 0 never stops, so zero retries is checked here.
+		delay := delays.Next()
+		if maxRetries <= 0 || delay == backoff.Stop {
+			return err
+		}
+		if budget := backoff.BudgetFrom(ctx); budget != nil && !budget.Take() {
+			log.Printf("Retry budget of this cycle is spent, not retrying: %v", err)
+			return err
+		}
+		if sleepErr := clock.Sleep(ctx, delay); sleepErr != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package shared
+
+import (
+	"archive/zip"
+	"embedup-go/internal/cstmerr"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// FreeSpace returns the bytes available to unprivileged users on the filesystem
+// holding path. A path that doesn't exist yet is measured at its nearest existing
+// ancestor, where it will be created.
+func FreeSpace(path string) (int64, error) {
+	dir := filepath.Clean(path)
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, cstmerr.NewFileIOError(fmt.Sprintf("failed to stat filesystem of %s", path), err)
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
+
+// CheckFreeSpace returns a DiskSpaceError unless at least required bytes are free on
+// the filesystem holding path.
+func CheckFreeSpace(path string, required int64) error {
+	available, err := FreeSpace(path)
+	if err != nil {
+		return err
+	}
+	if available < required {
+		return cstmerr.NewDiskSpaceError(path, required, available)
+	}
+	return nil
+}
+
+// ZipUncompressedSize sums the uncompressed sizes recorded in the archive's central
+// directory, without reading any entry.
+func ZipUncompressedSize(zipFilePath string) (int64, error) {
+	r, err := zip.OpenReader(zipFilePath)
+	if err != nil {
+		return 0, cstmerr.NewArchiveError(fmt.Sprintf("Failed to open zip file %s", zipFilePath), err)
+	}
+	defer r.Close()
+	var total uint64
+	for _, f := range r.File {
+		total += f.UncompressedSize64
+	}
+	return int64(total), nil
+}
+
+// CheckExtractionSpace fails with a DiskSpaceError if extracting the archive into
+// outputDir would leave less than margin bytes free, so extraction fails up front
+// instead of running out of space with a half-written tree.
+func CheckExtractionSpace(zipFilePath string, outputDir string, margin int64) error {
+	size, err := ZipUncompressedSize(zipFilePath)
+	if err != nil {
+		return err
+	}
+	return CheckFreeSpace(outputDir, size+margin)
+}
+
+// CheckReplacingExtractionSpace is CheckExtractionSpace for an outputDir holding a
+// previous extraction that is removed before extracting, so its size counts as free.
+func CheckReplacingExtractionSpace(zipFilePath string, outputDir string, margin int64) error {
+	size, err := ZipUncompressedSize(zipFilePath)
+	if err != nil {
+		return err
+	}
+	existing, err := DirSize(outputDir)
+	if err != nil {
+		return err
+	}
+	return CheckFreeSpace(outputDir, size+margin-existing)
+}
+
+// DirSize sums the sizes of the regular files under dir. A missing dir is empty.
+func DirSize(dir string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return 0, cstmerr.NewFileIOError(fmt.Sprintf("failed to measure %s", dir), err)
+	}
+	return total, nil
+}
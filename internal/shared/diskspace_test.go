@@ -0,0 +1,48 @@
+package shared
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"embedup-go/internal/cstmerr"
+)
+
+func TestCheckReplacingExtractionSpaceCountsPreviousExtraction(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "update.zip")
+	writeZip(t, archive, map[string]string{"update.sh": "#!/bin/sh\n"})
+	outDir := filepath.Join(t.TempDir(), "update")
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// A sparse previous extraction of 1 GiB, larger than the margin below.
+	previous, err := os.Create(filepath.Join(outDir, "payload.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := previous.Truncate(1 << 30); err != nil {
+		t.Fatal(err)
+	}
+	previous.Close()
+
+	free, err := FreeSpace(outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	margin := free + 512<<20
+	var spaceErr *cstmerr.DiskSpaceError
+	if err := CheckExtractionSpace(archive, outDir, margin); !errors.As(err, &spaceErr) {
+		t.Fatalf("CheckExtractionSpace = %v, want a DiskSpaceError", err)
+	}
+	if err := CheckReplacingExtractionSpace(archive, outDir, margin); err != nil {
+		t.Fatalf("CheckReplacingExtractionSpace = %v, want the previous extraction counted as free", err)
+	}
+}
+
+func TestDirSizeOfMissingDir(t *testing.T) {
+	size, err := DirSize(filepath.Join(t.TempDir(), "missing"))
+	if err != nil || size != 0 {
+		t.Fatalf("DirSize = %d, %v; want 0", size, err)
+	}
+}
@@ -289,10 +289,23 @@ type Page struct {
 	Tabs      []*Tab  `gorm:"many2many:page_tabs_tab"`
 }
 
-// type PageTabsTab struct {
-// 	PageContentId int `gorm:"primaryKey;type:bigint;"`
-// 	TabContentId  int `gorm:"primaryKey;type:bigint;"`
-// }
+// PageTabsTab is the join table of Page.Tabs and Tab.Pages.
+type PageTabsTab struct {
+	PageContentId int64 `gorm:"primaryKey;type:bigint"`
+	TabContentId  int64 `gorm:"primaryKey;type:bigint"`
+}
+
+// SliderTabsTab is the join table of Slider.Tabs.
+type SliderTabsTab struct {
+	SliderContentId int64 `gorm:"primaryKey;type:bigint"`
+	TabContentId    int64 `gorm:"primaryKey;type:bigint"`
+}
+
+// TabSectionsSection is the join table of Tab.Sections.
+type TabSectionsSection struct {
+	TabContentId     int64 `gorm:"primaryKey;type:bigint"`
+	SectionContentId int64 `gorm:"primaryKey;type:bigint"`
+}
 
 type Podcast struct {
 	ContentId   int64        `gorm:"primaryKey;type:bigint"`
@@ -454,6 +467,17 @@ type Updater struct {
 	ContentId         int64 `gorm:"primaryKey;type:bigint;column:contentId"`
 	LastFromTimeStamp int64 `gorm:"not null;default:0;type:bigint;column:lastFromTimeStamp"`
 	UniqueFlag        bool  `gorm:"not null;default:false;column:uniqueFlag;index:,unique"`
+	// ResyncFrom is the last resync_from override applied, so it is applied only once.
+	ResyncFrom int64 `gorm:"not null;default:0;type:bigint;column:resyncFrom"`
+}
+
+// AssetMeta records the size and full-file hash of a downloaded asset, so the on-device
+// footprint can be computed without statting the filesystem.
+type AssetMeta struct {
+	Path      string    `gorm:"primaryKey;type:varchar;column:path"` // Relative to the content base path, e.g. "images/x.jpg"
+	Size      int64     `gorm:"not null;type:bigint;column:size"`
+	Hash      string    `gorm:"not null;type:varchar;column:hash"` // MD5 of the whole file
+	UpdatedAt time.Time `gorm:"not null;type:timestamptz;column:updatedAt"`
 }
 
 var AutoMigrateList = []any{
@@ -480,3 +504,18 @@ var AutoMigrateList = []any{
 	&Tab{},
 	&Video{},
 }
+
+// JoinTable names the explicit join model of a many2many field.
+type JoinTable struct {
+	Model     any
+	Field     string
+	JoinModel any
+}
+
+// JoinTables lists the join models set up before migrating AutoMigrateList.
+var JoinTables = []JoinTable{
+	{Model: &Page{}, Field: "Tabs", JoinModel: &PageTabsTab{}},
+	{Model: &Tab{}, Field: "Pages", JoinModel: &PageTabsTab{}},
+	{Model: &Slider{}, Field: "Tabs", JoinModel: &SliderTabsTab{}},
+	{Model: &Tab{}, Field: "Sections", JoinModel: &TabSectionsSection{}},
+}
@@ -6,6 +6,10 @@ import "encoding/json"
 type UpdateInfo struct {
 	VersionCode int    `json:"versionCode"`
 	FileURL     string `json:"fileUrl"`
+	FileSHA256  string `json:"fileSha256,omitempty"` // Checksum of the full archive, used to verify a patched one
+	// Optional delta patch (BSDIFF40) that turns the archive of DeltaFromVersion into this one.
+	DeltaFromVersion int    `json:"deltaFromVersion,omitempty"`
+	DeltaURL         string `json:"deltaUrl,omitempty"`
 }
 
 // UpdateErr matches the JSON structure for API error messages.
@@ -19,6 +23,24 @@ type StatusReportPayload struct {
 	StatusMessage string `json:"statusMessage"`
 }
 
+// StatusEvent is a status report as recorded by the server.
+type StatusEvent struct {
+	VersionCode   int    `json:"versionCode"`
+	StatusMessage string `json:"statusMessage"`
+	CreatedAt     int64  `json:"createdAt"` // Timestamp the server received the report
+}
+
+// StatusHistoryResponse is one page of the device's status history, newest first.
+type StatusHistoryResponse struct {
+	Events []StatusEvent `json:"events"`
+	Count  int           `json:"count"` // Total events on the server
+}
+
+// StatusReportBatchPayload matches the JSON structure for reporting several statuses at once.
+type StatusReportBatchPayload struct {
+	Reports []StatusReportPayload `json:"reports"`
+}
+
 // ContentUpdateRequestParams defines parameters for fetching content updates.
 type ContentUpdateRequestParams struct {
 	From   int64 `url:"from"`   // Timestamp
@@ -26,10 +48,19 @@ type ContentUpdateRequestParams struct {
 	Offset int   `url:"offset"` // Page offset
 }
 
+// ContentUpdateFilter is the JSON body sent when content updates are fetched with POST.
+type ContentUpdateFilter struct {
+	From   int64    `json:"from"`
+	Size   int      `json:"size"`
+	Offset int      `json:"offset"`
+	Types  []string `json:"types,omitempty"` // Content types to fetch; empty means all
+}
+
 // ContentUpdateResponse is the structure for the /contents/update API response.
 type ContentUpdateResponse struct {
-	Contents []GenericContentItem `json:"contents"`
-	Count    int                  `json:"count"` // Remaining contents count
+	Contents      []GenericContentItem `json:"contents"`
+	Count         int                  `json:"count"`                   // Remaining contents count
+	SchemaVersion int                  `json:"schemaVersion,omitempty"` // 0 if the server does not report one
 }
 
 // GenericContentItem is the base structure for items in the "contents" array.
@@ -40,6 +71,8 @@ type GenericContentItem struct {
 	UpdatedAt int64           `json:"updatedAt"`
 	Enable    bool            `json:"enable"`
 	Content   json.RawMessage `json:"content"` // Holds the type-specific content data
+	// Deleted marks a tombstone: the item was removed on the server and Content may be empty.
+	Deleted bool `json:"deleted,omitempty"`
 }
 
 // --- Specific Content Type Structs ---
@@ -194,6 +227,10 @@ type LocalAlbumSchema struct {
 type LocalDeviceUpdateSchema struct {
 	VersionCode int    `json:"versionCode"`
 	FileURL     string `json:"fileUrl"`
+	FileSHA256  string `json:"fileSha256,omitempty"` // Checksum of the full archive, used to verify a patched one
+	// Optional delta patch (BSDIFF40) that turns the archive of DeltaFromVersion into this one.
+	DeltaFromVersion int    `json:"deltaFromVersion,omitempty"`
+	DeltaURL         string `json:"deltaUrl,omitempty"`
 }
 type LocalTermsConditionsSchema struct {
 	Name    string `json:"name"`
@@ -208,4 +245,11 @@ type ProcessedContentSchema struct {
 	UpdatedAt int64
 	Enable    bool
 	Details   interface{} // This will hold the specific content struct (e.g., LocalAdvertisementContent)
+	// ForceRedownload makes processing re-fetch assets even if a local copy looks up to date.
+	ForceRedownload bool
+	// Deleted is set for tombstones, which carry no Details and are always processed as disabled.
+	Deleted bool
+	// UnknownType is set for items of a type this client can't parse that are to be
+	// quarantined; they carry no Details.
+	UnknownType bool
 }
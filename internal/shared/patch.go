@@ -0,0 +1,124 @@
+package shared
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"crypto/sha256"
+	"embedup-go/internal/cstmerr"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const bsdiffMagic = "BSDIFF40"
+const bsdiffHeaderSize = 32
+
+// ApplyBsdiffPatch applies a BSDIFF40 patch at patchPath to the file at oldPath and
+// writes the result to newPath. If expectedSHA256 is set, the result must match it,
+// otherwise newPath is removed and an error is returned. A patch producing a file
+// larger than the free space where newPath goes is rejected before it is applied.
+func ApplyBsdiffPatch(oldPath string, patchPath string, newPath string, expectedSHA256 string) error {
+	oldData, err := os.ReadFile(oldPath)
+	if err != nil {
+		return cstmerr.NewFileIOError(fmt.Sprintf("Failed to read patch source %s", oldPath), err)
+	}
+	patch, err := os.ReadFile(patchPath)
+	if err != nil {
+		return cstmerr.NewFileIOError(fmt.Sprintf("Failed to read patch %s", patchPath), err)
+	}
+
+	maxNewSize, err := FreeSpace(filepath.Dir(newPath))
+	if err != nil {
+		return err
+	}
+	newData, err := bspatch(oldData, patch, maxNewSize)
+	if err != nil {
+		return err
+	}
+
+	if expectedSHA256 != "" {
+		sum := sha256.Sum256(newData)
+		if actual := hex.EncodeToString(sum[:]); !strings.EqualFold(actual, expectedSHA256) {
+			return cstmerr.NewPatchError(fmt.Sprintf("patched file checksum %s does not match expected %s", actual, expectedSHA256), nil)
+		}
+	}
+
+	if err := os.WriteFile(newPath, newData, 0644); err != nil {
+		os.Remove(newPath)
+		return cstmerr.NewFileIOError(fmt.Sprintf("Failed to write patched file %s", newPath), err)
+	}
+	return nil
+}
+
+// bspatch reconstructs the new file from oldData and a BSDIFF40 patch. The header
+// comes from the patch file, so every length in it is checked before it is used, and
+// the new file may not be larger than maxNewSize.
+func bspatch(oldData []byte, patch []byte, maxNewSize int64) ([]byte, error) {
+	if len(patch) < bsdiffHeaderSize || string(patch[:8]) != bsdiffMagic {
+		return nil, cstmerr.NewPatchError("not a BSDIFF40 patch", nil)
+	}
+	ctrlLen := offtin(patch[8:16])
+	diffLen := offtin(patch[16:24])
+	newSize := offtin(patch[24:32])
+	bodyLen := int64(len(patch) - bsdiffHeaderSize)
+	if ctrlLen < 0 || diffLen < 0 || newSize < 0 || ctrlLen > bodyLen || diffLen > bodyLen-ctrlLen {
+		return nil, cstmerr.NewPatchError("corrupt patch header", nil)
+	}
+	if newSize > maxNewSize {
+		return nil, cstmerr.NewPatchError(fmt.Sprintf("patched file of %d bytes exceeds the %d bytes available", newSize, maxNewSize), nil)
+	}
+
+	body := patch[bsdiffHeaderSize:]
+	ctrl := bzip2.NewReader(bytes.NewReader(body[:ctrlLen]))
+	diff := bzip2.NewReader(bytes.NewReader(body[ctrlLen : ctrlLen+diffLen]))
+	extra := bzip2.NewReader(bytes.NewReader(body[ctrlLen+diffLen:]))
+
+	newData := make([]byte, newSize)
+	var oldPos, newPos int64
+	var ctrlBuf [24]byte
+	for newPos < newSize {
+		if _, err := io.ReadFull(ctrl, ctrlBuf[:]); err != nil {
+			return nil, cstmerr.NewPatchError("failed to read control block", err)
+		}
+		addLen := offtin(ctrlBuf[0:8])
+		copyLen := offtin(ctrlBuf[8:16])
+		seekLen := offtin(ctrlBuf[16:24])
+
+		if addLen < 0 || addLen > newSize-newPos {
+			return nil, cstmerr.NewPatchError("corrupt control block", nil)
+		}
+		if _, err := io.ReadFull(diff, newData[newPos:newPos+addLen]); err != nil {
+			return nil, cstmerr.NewPatchError("failed to read diff block", err)
+		}
+		for i := int64(0); i < addLen; i++ {
+			if oldPos+i >= 0 && oldPos+i < int64(len(oldData)) {
+				newData[newPos+i] += oldData[oldPos+i]
+			}
+		}
+		newPos += addLen
+		oldPos += addLen
+
+		if copyLen < 0 || copyLen > newSize-newPos {
+			return nil, cstmerr.NewPatchError("corrupt control block", nil)
+		}
+		if _, err := io.ReadFull(extra, newData[newPos:newPos+copyLen]); err != nil {
+			return nil, cstmerr.NewPatchError("failed to read extra block", err)
+		}
+		newPos += copyLen
+		oldPos += seekLen
+	}
+	return newData, nil
+}
+
+// offtin decodes bsdiff's sign-magnitude little-endian 64-bit integer.
+func offtin(buf []byte) int64 {
+	y := int64(binary.LittleEndian.Uint64(buf) &^ (1 << 63))
+	if buf[7]&0x80 != 0 {
+		return -y
+	}
+	return y
+}
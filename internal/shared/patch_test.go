@@ -0,0 +1,119 @@
+package shared
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embedup-go/internal/cstmerr"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// patchHeader builds a BSDIFF40 header with the given lengths followed by body.
+func patchHeader(ctrlLen, diffLen, newSize uint64, body []byte) []byte {
+	patch := make([]byte, bsdiffHeaderSize, bsdiffHeaderSize+len(body))
+	copy(patch, bsdiffMagic)
+	binary.LittleEndian.PutUint64(patch[8:16], ctrlLen)
+	binary.LittleEndian.PutUint64(patch[16:24], diffLen)
+	binary.LittleEndian.PutUint64(patch[24:32], newSize)
+	return append(patch, body...)
+}
+
+func TestBspatchRejectsCorruptHeaders(t *testing.T) {
+	body := make([]byte, 16)
+	tests := []struct {
+		name    string
+		patch   []byte
+		maxSize int64
+	}{
+		{"not bsdiff", []byte("BSDIFF41"), 1 << 20},
+		{"lengths overflowing int64", patchHeader(8, math.MaxInt64, 0, body), 1 << 20},
+		{"control longer than body", patchHeader(17, 0, 0, body), 1 << 20},
+		{"diff longer than body", patchHeader(8, 9, 0, body), 1 << 20},
+		{"negative new size", patchHeader(0, 0, 1<<63|1, body), 1 << 20},
+		{"new size above budget", patchHeader(0, 0, math.MaxInt64, body), 1 << 20},
+		{"new size just above budget", patchHeader(0, 0, 1025, body), 1024},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := bspatch(nil, tt.patch, tt.maxSize)
+			var patchErr *cstmerr.PatchError
+			if !errors.As(err, &patchErr) {
+				t.Fatalf("bspatch error = %v, want a PatchError", err)
+			}
+		})
+	}
+}
+
+func TestBspatchEmptyResult(t *testing.T) {
+	got, err := bspatch([]byte("old"), patchHeader(0, 0, 0, nil), 0)
+	if err != nil {
+		t.Fatalf("bspatch: %v", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("bspatch = %q, want empty", got)
+	}
+}
+
+func TestOfftinSignMagnitude(t *testing.T) {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, 1<<63|42)
+	if got := offtin(buf); got != -42 {
+		t.Fatalf("offtin = %d, want -42", got)
+	}
+	binary.LittleEndian.PutUint64(buf, 42)
+	if got := offtin(buf); got != 42 {
+		t.Fatalf("offtin = %d, want 42", got)
+	}
+}
+
+func TestApplyBsdiffPatchToExtractedTree(t *testing.T) {
+	dir := t.TempDir()
+	newPath := filepath.Join(dir, "update_v2.zip")
+	want, err := os.ReadFile(filepath.Join("testdata", "update_v2.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(want)
+
+	err = ApplyBsdiffPatch(filepath.Join("testdata", "update_v1.zip"), filepath.Join("testdata", "update_v1_v2.patch"),
+		newPath, hex.EncodeToString(sum[:]))
+	if err != nil {
+		t.Fatalf("ApplyBsdiffPatch: %v", err)
+	}
+	if got, _ := os.ReadFile(newPath); !bytes.Equal(got, want) {
+		t.Fatal("patched archive differs from version 2")
+	}
+
+	tree := filepath.Join(dir, "update_v2")
+	if err := UnzipFile(newPath, tree); err != nil {
+		t.Fatalf("UnzipFile: %v", err)
+	}
+	for name, content := range map[string]string{
+		"update.sh":      "#!/bin/sh\necho v2\n",
+		"app/config.txt": "version=2\nmode=normal\nfeature=on\n",
+		"app/notes.txt":  "added in v2\n",
+	} {
+		if got, err := os.ReadFile(filepath.Join(tree, filepath.FromSlash(name))); err != nil || string(got) != content {
+			t.Errorf("%s = %q, %v; want %q", name, got, err, content)
+		}
+	}
+}
+
+func TestApplyBsdiffPatchChecksumMismatch(t *testing.T) {
+	newPath := filepath.Join(t.TempDir(), "update_v2.zip")
+	err := ApplyBsdiffPatch(filepath.Join("testdata", "update_v1.zip"), filepath.Join("testdata", "update_v1_v2.patch"),
+		newPath, strings.Repeat("0", 64))
+	var patchErr *cstmerr.PatchError
+	if !errors.As(err, &patchErr) {
+		t.Fatalf("ApplyBsdiffPatch error = %v, want a PatchError", err)
+	}
+	if _, err := os.Stat(newPath); !os.IsNotExist(err) {
+		t.Fatalf("patched file was written despite the mismatch: %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package shared
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// REDACTED replaces secret values in anything that gets logged.
+const REDACTED = "****"
+
+// secretHeaders are request/response headers whose values are never logged.
+var secretHeaders = []string{"Authorization", "Proxy-Authorization", "Device-Token", "Cookie", "Set-Cookie"}
+
+// RedactSecrets replaces every occurrence of the given secret values in s.
+// Empty secrets are ignored.
+func RedactSecrets(s string, secrets ...string) string {
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		s = strings.ReplaceAll(s, secret, REDACTED)
+	}
+	return s
+}
+
+// RedactHeaders returns a copy of h with the values of known secret headers masked.
+func RedactHeaders(h http.Header) http.Header {
+	redacted := h.Clone()
+	for _, name := range secretHeaders {
+		if _, ok := redacted[http.CanonicalHeaderKey(name)]; ok {
+			redacted.Set(name, REDACTED)
+		}
+	}
+	return redacted
+}
+
+// RedactURL masks the password of any userinfo embedded in rawURL.
+func RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL
+	}
+	return u.Redacted()
+}
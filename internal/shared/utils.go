@@ -2,17 +2,23 @@ package shared
 
 import (
 	"archive/zip"
+	"context"
 	"crypto/md5"
+	"crypto/sha256"
 	"embedup-go/internal/cstmerr"
 	"encoding/hex"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"log"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 func ResetNTPService() error {
@@ -27,14 +33,14 @@ func ResetNTPService() error {
 	return nil
 }
 
-func UpdateNTPService() {
+func UpdateNTPService(clock Clock) {
 	for {
 		if err := ResetNTPService(); err != nil {
 			log.Printf("NTP reset error (continuing): %v", err)
 		} else {
 			break
 		}
-		time.Sleep(time.Duration(300) * time.Second)
+		clock.Sleep(context.Background(), time.Duration(300)*time.Second)
 	}
 }
 
@@ -52,6 +58,22 @@ func CheckAndCreateDir(dir string) error {
 	return nil
 }
 
+// Modes of a downloaded asset while it is protected against modification, and while
+// it may be written, e.g. to resume or replace it.
+const (
+	READ_ONLY_FILE_MODE os.FileMode = 0444
+	WRITABLE_FILE_MODE  os.FileMode = 0644
+)
+
+// RemoveAsset deletes a downloaded file, first restoring write permission in case it
+// was made read-only, since some filesystems refuse to delete read-only files.
+func RemoveAsset(path string) error {
+	if err := os.Chmod(path, WRITABLE_FILE_MODE); err != nil && !os.IsNotExist(err) {
+		log.Printf("failed to restore write permission on %s: %v", path, err)
+	}
+	return os.Remove(path)
+}
+
 func CalculateStringMD5(data string) string {
 	hash := md5.Sum([]byte(data))
 	return hex.EncodeToString(hash[:])
@@ -74,8 +96,128 @@ func CalculateMD5(filePath string, n int) ([]byte, error) {
 	return hash.Sum(nil), nil
 }
 
+// CalculateFileMD5 returns the hex-encoded MD5 of the whole file.
+func CalculateFileMD5(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// CalculateFileSHA256 returns the hex-encoded SHA-256 of the whole file.
+func CalculateFileSHA256(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// OSVersion returns the running OS's pretty name from /etc/os-release, falling back
+// to the kernel release. It returns "" if neither is available.
+func OSVersion() string {
+	if data, err := os.ReadFile("/etc/os-release"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if value, ok := strings.CutPrefix(line, "PRETTY_NAME="); ok {
+				return strings.Trim(value, `"`)
+			}
+		}
+	}
+	if data, err := os.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		return strings.TrimSpace(string(data))
+	}
+	return ""
+}
+
+// ValidateZipFile reads every entry of the archive without extracting it, so a
+// truncated file or an entry with a bad CRC is detected before anything is written.
+func ValidateZipFile(zipFilePath string) error {
+	r, err := zip.OpenReader(zipFilePath)
+	if err != nil {
+		return cstmerr.NewArchiveError(fmt.Sprintf("Failed to open zip file %s", zipFilePath), err)
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return cstmerr.NewArchiveError(fmt.Sprintf("Failed to open file in archive %s", f.Name), err)
+		}
+		// The zip reader checks the entry's CRC32 once it is read to EOF.
+		_, err = io.Copy(io.Discard, rc)
+		rc.Close()
+		if err != nil {
+			return cstmerr.NewArchiveError(fmt.Sprintf("Corrupt file in archive %s", f.Name), err)
+		}
+	}
+	return nil
+}
+
+// ArchiveEntryPath returns where the archive entry name is extracted to under
+// outputDir. The name is normalized first: backslashes from archives made on Windows
+// become separators, dot segments are resolved and Unicode is NFC-normalized, so
+// equivalent names land on the same path. Names escaping outputDir, or resolving to
+// nothing or to outputDir itself, are rejected.
+func ArchiveEntryPath(outputDir string, name string) (string, error) {
+	normalized := path.Clean(norm.NFC.String(strings.ReplaceAll(name, "\\", "/")))
+	if normalized == "." || normalized == ".." || strings.HasPrefix(normalized, "../") || path.IsAbs(normalized) {
+		return "", cstmerr.NewArchiveError(fmt.Sprintf("Illegal file path in archive: %s", name), nil)
+	}
+	outPath := filepath.Join(outputDir, filepath.FromSlash(normalized))
+	if !strings.HasPrefix(outPath, filepath.Clean(outputDir)+string(os.PathSeparator)) {
+		return "", cstmerr.NewArchiveError(fmt.Sprintf("Illegal file path in archive: %s", name), nil)
+	}
+	return outPath, nil
+}
+
 func UnzipFile(zipFilePath string, outputDir string) error {
-	log.Printf("Unzipping update from %s to %s", zipFilePath, outputDir)
+	return unzipFile(zipFilePath, outputDir, false)
+}
+
+// UnzipFileResume is UnzipFile that picks up an interrupted extraction: entries already
+// extracted in full, with the archived size and CRC-32, are kept and only missing or
+// incomplete ones are written. Entries written are checked against their CRC-32 as
+// they are read from the archive.
+func UnzipFileResume(zipFilePath string, outputDir string) error {
+	return unzipFile(zipFilePath, outputDir, true)
+}
+
+// ExtractedEntryComplete reports whether path holds the archive entry f in full.
+func ExtractedEntryComplete(path string, f *zip.File) bool {
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() || uint64(info.Size()) != f.UncompressedSize64 {
+		return false
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	hash := crc32.NewIEEE()
+	if _, err := io.Copy(hash, file); err != nil {
+		return false
+	}
+	return hash.Sum32() == f.CRC32
+}
+
+func unzipFile(zipFilePath string, outputDir string, resume bool) error {
+	log.Printf("Unzipping %s to %s", zipFilePath, outputDir)
 
 	r, err := zip.OpenReader(zipFilePath)
 	if err != nil {
@@ -85,11 +227,11 @@ func UnzipFile(zipFilePath string, outputDir string) error {
 
 	log.Printf("Archive contains %d files", len(r.File))
 
+	kept := 0
 	for _, f := range r.File {
-		outPath := filepath.Join(outputDir, f.Name)
-
-		if !strings.HasPrefix(outPath, filepath.Clean(outputDir)+string(os.PathSeparator)) {
-			return cstmerr.NewArchiveError(fmt.Sprintf("Illegal file path in archive: %s", f.Name), nil)
+		outPath, err := ArchiveEntryPath(outputDir, f.Name)
+		if err != nil {
+			return err
 		}
 
 		if f.FileInfo().IsDir() {
@@ -98,6 +240,10 @@ func UnzipFile(zipFilePath string, outputDir string) error {
 			}
 			continue
 		}
+		if resume && ExtractedEntryComplete(outPath, f) {
+			kept++
+			continue
+		}
 
 		if err := os.MkdirAll(filepath.Dir(outPath), os.ModePerm); err != nil { //
 			return cstmerr.NewFileSystemError(fmt.Sprintf("Failed to create parent directory for %s: %v", outPath, err))
@@ -135,6 +281,9 @@ func UnzipFile(zipFilePath string, outputDir string) error {
 			}
 		}
 	}
+	if kept > 0 {
+		log.Printf("Resumed extraction, kept %d already extracted file(s)", kept)
+	}
 	log.Println("Unzipping done.")
 	return nil
 }
@@ -0,0 +1,149 @@
+package shared
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"embedup-go/internal/cstmerr"
+)
+
+// writeZip writes an archive of files, by entry name, to path.
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	w := zip.NewWriter(out)
+	for name, content := range files {
+		entry, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := entry.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnzipFileResumeWritesOnlyMissingEntries(t *testing.T) {
+	files := map[string]string{
+		"hls/master.m3u8":  "#EXTM3U\n",
+		"hls/segment0.ts":  "segment zero",
+		"hls/segment1.ts":  "segment one",
+		"hls/segment2.ts":  "segment two",
+		"hls/variant.m3u8": "#EXTM3U\n#EXTINF:1,\nsegment0.ts\n",
+	}
+	archive := filepath.Join(t.TempDir(), "movie.zip")
+	writeZip(t, archive, files)
+	outDir := filepath.Join(t.TempDir(), "movie")
+
+	// An interrupted run: one entry in full, one cut short, one with the right size but wrong bytes.
+	if err := os.MkdirAll(filepath.Join(outDir, "hls"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	complete := filepath.Join(outDir, "hls", "segment0.ts")
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for path, content := range map[string]string{
+		complete:                                 files["hls/segment0.ts"],
+		filepath.Join(outDir, "hls/segment1.ts"): "segm",
+		filepath.Join(outDir, "hls/segment2.ts"): "segment twX",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := UnzipFileResume(archive, outDir); err != nil {
+		t.Fatalf("UnzipFileResume: %v", err)
+	}
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join(outDir, filepath.FromSlash(name)))
+		if err != nil || string(got) != want {
+			t.Fatalf("%s = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	info, err := os.Stat(complete)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(old) {
+		t.Fatalf("%s was written again, want it kept", complete)
+	}
+	for _, name := range []string{"hls/segment1.ts", "hls/segment2.ts"} {
+		info, err := os.Stat(filepath.Join(outDir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.ModTime().Equal(old) {
+			t.Fatalf("%s was kept, want it extracted again", name)
+		}
+	}
+}
+
+func TestUnzipFileNormalizesEntryNames(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "movie.zip")
+	writeZip(t, archive, map[string]string{
+		`hls\master.m3u8`:           "#EXTM3U\n",
+		`hls\low\segment0.ts`:       "segment zero",
+		"hls/./extra/../poster.jpg": "poster",
+		"subs/cafe\u0301.vtt":       "WEBVTT\n",
+	})
+	outDir := filepath.Join(t.TempDir(), "movie")
+
+	if err := UnzipFile(archive, outDir); err != nil {
+		t.Fatalf("UnzipFile: %v", err)
+	}
+	for name, want := range map[string]string{
+		"hls/master.m3u8":     "#EXTM3U\n",
+		"hls/low/segment0.ts": "segment zero",
+		"hls/poster.jpg":      "poster",
+		"subs/caf\u00e9.vtt":  "WEBVTT\n",
+	} {
+		got, err := os.ReadFile(filepath.Join(outDir, filepath.FromSlash(name)))
+		if err != nil || string(got) != want {
+			t.Fatalf("%s = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "hls", "extra")); !os.IsNotExist(err) {
+		t.Fatalf("dot-segment directory was created: %v", err)
+	}
+}
+
+func TestUnzipFileRejectsIllegalEntries(t *testing.T) {
+	for _, name := range []string{
+		"../escape.ts",
+		`..\escape.ts`,
+		`hls\..\..\escape.ts`,
+		"hls/../..",
+		"hls/..",
+		"/etc/escape.ts",
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			archive := filepath.Join(dir, "movie.zip")
+			writeZip(t, archive, map[string]string{name: "payload"})
+			outDir := filepath.Join(dir, "out", "movie")
+
+			err := UnzipFile(archive, outDir)
+			var archiveErr *cstmerr.ArchiveError
+			if !errors.As(err, &archiveErr) {
+				t.Fatalf("UnzipFile = %v, want an ArchiveError", err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "out", "escape.ts")); !os.IsNotExist(err) {
+				t.Fatalf("entry was written outside the output directory: %v", err)
+			}
+		})
+	}
+}